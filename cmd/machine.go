@@ -152,6 +152,16 @@ func main() {
 			Name:   "native-ssh",
 			Usage:  "Use the native (Go-based) SSH implementation.",
 		},
+		cli.BoolFlag{
+			EnvVar: "MACHINE_SSH_MULTIPLEX",
+			Name:   "ssh-multiplex",
+			Usage:  "Share connections between the commands run with the external SSH client (ControlMaster).",
+		},
+		cli.BoolFlag{
+			EnvVar: "MACHINE_MANAGE_CONTEXTS",
+			Name:   "manage-contexts",
+			Usage:  "Write the Docker CLI context of the created machines and remove the one of the removed machines.",
+		},
 		cli.StringFlag{
 			EnvVar: "MACHINE_BUGSNAG_API_TOKEN",
 			Name:   "bugsnag-api-token",
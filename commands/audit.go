@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/audit"
+)
+
+// maxAuditCommandLength is where the commands are cut in the audit table.
+const maxAuditCommandLength = 60
+
+func cmdAudit(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		c.ShowHelp()
+		return ErrExpectedOneMachine
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	exists, err := api.Exists(target)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("Host does not exist: %q", target)
+	}
+
+	filter, err := auditFilterFromFlags(c)
+	if err != nil {
+		return err
+	}
+
+	records, err := audit.Read(target)
+	if err != nil {
+		return err
+	}
+
+	selected := []audit.Record{}
+	for _, record := range records {
+		if filter.Match(record) {
+			selected = append(selected, record)
+		}
+	}
+
+	if c.Bool("json") {
+		return printAuditJSON(os.Stdout, selected)
+	}
+
+	return printAuditTable(os.Stdout, selected)
+}
+
+func auditFilterFromFlags(c CommandLine) (audit.Filter, error) {
+	filter := audit.Filter{
+		Type:    c.String("type"),
+		Command: c.String("command"),
+		Failed:  c.Bool("failed"),
+	}
+
+	switch filter.Type {
+	case "", audit.TypeCommand, audit.TypeFile, audit.TypeSession:
+	default:
+		return filter, fmt.Errorf("Unknown record type %q, expected one of %s, %s or %s", filter.Type, audit.TypeCommand, audit.TypeFile, audit.TypeSession)
+	}
+
+	if since := c.String("since"); since != "" {
+		if duration, err := time.ParseDuration(since); err == nil {
+			filter.Since = time.Now().Add(-duration)
+		} else if timestamp, err := time.Parse(time.RFC3339, since); err == nil {
+			filter.Since = timestamp
+		} else {
+			return filter, fmt.Errorf("Invalid --since value %q, expected a duration such as 24h or a RFC 3339 date", since)
+		}
+	}
+
+	return filter, nil
+}
+
+func printAuditJSON(w io.Writer, records []audit.Record) error {
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func printAuditTable(w io.Writer, records []audit.Record) error {
+	tabWriter := tabwriter.NewWriter(w, 5, 1, 3, ' ', 0)
+
+	fmt.Fprintln(tabWriter, "TIME\tUSER\tTYPE\tSTATUS\tDURATION\tCOMMAND")
+	for _, record := range records {
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			record.Time.Local().Format("2006-01-02 15:04:05"),
+			record.User,
+			record.Type,
+			record.ExitStatus,
+			record.Duration(),
+			shortenCommand(record.Command))
+	}
+
+	return tabWriter.Flush()
+}
+
+// shortenCommand keeps the first line of command, cut to fit in a table.
+func shortenCommand(command string) string {
+	command = strings.TrimSpace(command)
+
+	short := command
+	if i := strings.IndexByte(short, '\n'); i >= 0 {
+		short = short[:i]
+	}
+	if len(short) > maxAuditCommandLength {
+		short = short[:maxAuditCommandLength]
+	}
+	if short != command {
+		short += "..."
+	}
+
+	return short
+}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShortenCommand(t *testing.T) {
+	assert.Equal(t, "uptime", shortenCommand("uptime"))
+	assert.Equal(t, "if ! grep -xq '.*\\s dev' /etc/hosts; then...", shortenCommand("\n\t\tif ! grep -xq '.*\\s dev' /etc/hosts; then\n\t\t\tfi"))
+
+	long := "sudo apt-get install -y docker-engine=1.12.0-0~xenial curl git wget"
+	assert.Equal(t, long[:maxAuditCommandLength]+"...", shortenCommand(long))
+}
+
+func TestAuditFilterFromFlags(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"since":   "2016-10-01T12:00:00Z",
+				"type":    "session",
+				"command": "docker",
+				"failed":  true,
+			},
+		},
+	}
+
+	filter, err := auditFilterFromFlags(commandLine)
+	assert.NoError(t, err)
+	assert.Equal(t, audit.Filter{
+		Since:   time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC),
+		Type:    "session",
+		Command: "docker",
+		Failed:  true,
+	}, filter)
+
+	commandLine.LocalFlags.Data["since"] = "2h"
+	filter, err = auditFilterFromFlags(commandLine)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(-2*time.Hour), filter.Since, time.Minute)
+
+	commandLine.LocalFlags.Data["since"] = "yesterday"
+	_, err = auditFilterFromFlags(commandLine)
+	assert.EqualError(t, err, `Invalid --since value "yesterday", expected a duration such as 24h or a RFC 3339 date`)
+
+	commandLine.LocalFlags.Data["since"] = ""
+	commandLine.LocalFlags.Data["type"] = "unknown"
+	_, err = auditFilterFromFlags(commandLine)
+	assert.EqualError(t, err, `Unknown record type "unknown", expected one of command, file or session`)
+}
+
+func TestPrintAuditTable(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	err := printAuditTable(buf, []audit.Record{
+		{
+			Time:       time.Date(2016, 10, 1, 12, 0, 0, 0, time.Local),
+			User:       "jane",
+			Type:       audit.TypeCommand,
+			Command:    "sudo systemctl restart docker",
+			ExitStatus: 1,
+			DurationMs: 1500,
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `TIME                  USER   TYPE      STATUS   DURATION   COMMAND
+2016-10-01 12:00:00   jane   command   1        1.5s       sudo systemctl restart docker
+`, buf.String())
+}
@@ -4,11 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/codegangsta/cli"
 	"github.com/docker/machine/commands/mcndirs"
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/audit"
 	"github.com/docker/machine/libmachine/crashreport"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
@@ -78,7 +80,11 @@ func (c *contextCommandLine) Application() *cli.App {
 // targetHost returns a specific host name if one is indicated by the first CLI
 // arg, or the default host name if no host is specified.
 func targetHost(c CommandLine, api libmachine.API) (string, error) {
-	if len(c.Args()) == 0 {
+	return targetHostFromArgs(c.Args(), api)
+}
+
+func targetHostFromArgs(args []string, api libmachine.API) (string, error) {
+	if len(args) == 0 {
 		defaultExists, err := api.Exists(defaultMachineName)
 		if err != nil {
 			return "", fmt.Errorf("Error checking if host %q exists: %s", defaultMachineName, err)
@@ -91,10 +97,41 @@ func targetHost(c CommandLine, api libmachine.API) (string, error) {
 		return "", ErrNoDefault
 	}
 
-	return c.Args()[0], nil
+	return args[0], nil
 }
 
 func runAction(actionName string, c CommandLine, api libmachine.API) error {
+	return runActionWith(actionName, c, api, func(*host.Host) {})
+}
+
+// runActionWith runs the action after calling prepare on each of the hosts.
+// The changes made to the hosts are saved.
+func runActionWith(actionName string, c CommandLine, api libmachine.API, prepare func(*host.Host)) error {
+	hosts, err := loadActionHosts(c, api)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hosts {
+		prepare(h)
+	}
+
+	if errs := runActionForeachMachine(actionName, hosts); len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	for _, h := range hosts {
+		if err := api.Save(h); err != nil {
+			return fmt.Errorf("Error saving host to store: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// loadActionHosts loads the machines named in the arguments, or the default
+// machine when there are none.
+func loadActionHosts(c CommandLine, api libmachine.API) ([]*host.Host, error) {
 	var (
 		hostsToLoad []string
 	)
@@ -105,7 +142,7 @@ func runAction(actionName string, c CommandLine, api libmachine.API) error {
 	if len(c.Args()) == 0 {
 		target, err := targetHost(c, api)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		hostsToLoad = []string{target}
@@ -120,24 +157,14 @@ func runAction(actionName string, c CommandLine, api libmachine.API) error {
 		for _, err := range hostsInError {
 			errs = append(errs, err)
 		}
-		return consolidateErrs(errs)
+		return nil, consolidateErrs(errs)
 	}
 
 	if len(hosts) == 0 {
-		return ErrHostLoad
-	}
-
-	if errs := runActionForeachMachine(actionName, hosts); len(errs) > 0 {
-		return consolidateErrs(errs)
-	}
-
-	for _, h := range hosts {
-		if err := api.Save(h); err != nil {
-			return fmt.Errorf("Error saving host to store: %s", err)
-		}
+		return nil, ErrHostLoad
 	}
 
-	return nil
+	return hosts, nil
 }
 
 func runCommand(command func(commandLine CommandLine, api libmachine.API) error) func(context *cli.Context) {
@@ -159,6 +186,11 @@ func runCommand(command func(commandLine CommandLine, api libmachine.API) error)
 		mcndirs.BaseDir = api.Filestore.Path
 		mcnutils.GithubAPIToken = api.GithubAPIToken
 		ssh.SetDefaultClient(api.SSHClientType)
+		if context.GlobalBool("ssh-multiplex") {
+			ssh.SetControlPathDir(filepath.Join(mcndirs.GetBaseDir(), "ssh"))
+		}
+		audit.SetMachinesDir(mcndirs.GetMachineDir())
+		manageContexts = context.GlobalBool("manage-contexts")
 
 		if err := command(&contextCommandLine{context}, api); err != nil {
 			log.Error(err)
@@ -205,6 +237,34 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:        "audit",
+		Usage:       "Show the commands run on a machine",
+		Description: "Argument is a machine name.",
+		Action:      runCommand(cmdAudit),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "since",
+				Usage: "Only show the records newer than a duration (e.g. 24h) or a RFC 3339 date",
+			},
+			cli.StringFlag{
+				Name:  "type",
+				Usage: "Only show the records of a type: command, file or session",
+			},
+			cli.StringFlag{
+				Name:  "command",
+				Usage: "Only show the commands containing a string",
+			},
+			cli.BoolFlag{
+				Name:  "failed",
+				Usage: "Only show the commands which failed",
+			},
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the full records, output included, as JSON lines",
+			},
+		},
+	},
 	{
 		Name:        "config",
 		Usage:       "Print the connection config for machine",
@@ -215,6 +275,26 @@ var Commands = []cli.Command{
 				Name:  "swarm",
 				Usage: "Display the Swarm config instead of the Docker daemon",
 			},
+			cli.StringFlag{
+				Name:  "format",
+				Usage: "Print the environment in a format of env, or with a Go template, instead of the flags",
+			},
+			cli.BoolFlag{
+				Name:  "no-proxy",
+				Usage: "Add machine IP to NO_PROXY environment variable, with --format",
+			},
+		},
+	},
+	{
+		Name:  "context",
+		Usage: "Manage the Docker CLI contexts of the machines",
+		Subcommands: []cli.Command{
+			{
+				Name:        "sync",
+				Usage:       "Write the Docker CLI contexts of the machines and remove the ones of the removed machines",
+				Description: "Argument(s) are one or more machine names, all the machines when none is given.",
+				Action:      runCommand(cmdContextSync),
+			},
 		},
 	},
 	{
@@ -225,6 +305,70 @@ var Commands = []cli.Command{
 		Action:          runCommand(cmdCreateOuter),
 		SkipFlagParsing: true,
 	},
+	{
+		Name:  "engine",
+		Usage: "Manage the engine configuration of a machine",
+		Subcommands: []cli.Command{
+			{
+				Name:        "set",
+				Usage:       "Change the engine options of a machine and apply them without provisioning it again",
+				Description: "Argument is a machine name.",
+				Action:      runCommand(cmdEngineSet),
+				Flags: []cli.Flag{
+					cli.StringSliceFlag{
+						Name:  "label",
+						Usage: "Set a label of the engine, as key=value",
+						Value: &cli.StringSlice{},
+					},
+					cli.StringSliceFlag{
+						Name:  "unset-label",
+						Usage: "Remove the label of the engine with this key",
+						Value: &cli.StringSlice{},
+					},
+					cli.StringSliceFlag{
+						Name:  "registry-mirror",
+						Usage: "Add a registry mirror",
+						Value: &cli.StringSlice{},
+					},
+					cli.StringSliceFlag{
+						Name:  "unset-registry-mirror",
+						Usage: "Remove a registry mirror",
+						Value: &cli.StringSlice{},
+					},
+					cli.StringSliceFlag{
+						Name:  "insecure-registry",
+						Usage: "Add an insecure registry",
+						Value: &cli.StringSlice{},
+					},
+					cli.StringSliceFlag{
+						Name:  "unset-insecure-registry",
+						Usage: "Remove an insecure registry",
+						Value: &cli.StringSlice{},
+					},
+					cli.StringFlag{
+						Name:  "log-driver",
+						Usage: "Set the default logging driver of the containers",
+					},
+					cli.StringSliceFlag{
+						Name:  "log-opt",
+						Usage: "Set an option of the logging driver, as key=value",
+						Value: &cli.StringSlice{},
+					},
+					cli.StringSliceFlag{
+						Name:  "unset-log-opt",
+						Usage: "Remove the option of the logging driver with this key",
+						Value: &cli.StringSlice{},
+					},
+				},
+			},
+			{
+				Name:        "show",
+				Usage:       "Show the engine options stored for a machine next to the configuration its engine runs with",
+				Description: "Argument is a machine name.",
+				Action:      runCommand(cmdEngineShow),
+			},
+		},
+	},
 	{
 		Name:        "env",
 		Usage:       "Display the commands to set up the environment for the Docker client",
@@ -239,6 +383,10 @@ var Commands = []cli.Command{
 				Name:  "shell",
 				Usage: "Force environment to be configured for a specified shell: [fish, cmd, powershell, tcsh], default is auto-detect",
 			},
+			cli.StringFlag{
+				Name:  "format",
+				Usage: "Print the environment in a format instead of for a shell: [json, dotenv, direnv, nushell, make], or with a Go template",
+			},
 			cli.BoolFlag{
 				Name:  "unset, u",
 				Usage: "Unset variables instead of setting them",
@@ -247,6 +395,22 @@ var Commands = []cli.Command{
 				Name:  "no-proxy",
 				Usage: "Add machine IP to NO_PROXY environment variable",
 			},
+			cli.BoolFlag{
+				Name:  "repair",
+				Usage: "Recreate the Swarm containers which aren't running as configured, with --swarm",
+			},
+		},
+	},
+	{
+		Name:  "image",
+		Usage: "Move images to the machines",
+		Subcommands: []cli.Command{
+			{
+				Name:        "push",
+				Usage:       "Push images from the local Docker daemon to machines, without a registry",
+				Description: "Arguments are the machine names, comma-separated, and one or more images.",
+				Action:      runCommand(cmdImagePush),
+			},
 		},
 	},
 	{
@@ -303,6 +467,25 @@ var Commands = []cli.Command{
 		Name:   "provision",
 		Usage:  "Re-provision existing machines",
 		Action: runCommand(cmdProvision),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "provisioner",
+				Usage: "Provisioner to use from now on instead of the one chosen when the machine was created",
+			},
+			cli.StringSliceFlag{
+				Name:  "provision-hook",
+				Usage: "Replace the provisioning hooks of the machine with pre=<file> or post=<file> scripts",
+				Value: &cli.StringSlice{},
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show what the provisioning would do without changing anything",
+			},
+			cli.BoolFlag{
+				Name:  "resume",
+				Usage: "Resume a failed provisioning after the last step it completed",
+			},
+		},
 	},
 	{
 		Name:        "regenerate-certs",
@@ -314,6 +497,22 @@ var Commands = []cli.Command{
 				Name:  "force, f",
 				Usage: "Force rebuild and do not prompt",
 			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show the certs which would be issued and the configuration which would be written without changing anything",
+			},
+		},
+	},
+	{
+		Name:  "registry",
+		Usage: "Manage the registry credentials and CA certs of the machines",
+		Subcommands: []cli.Command{
+			{
+				Name:        "sync",
+				Usage:       "Install the registry CA certs and credentials of the machines again",
+				Description: "Argument(s) are one or more machine names, all the machines with registry options when none is given.",
+				Action:      runCommand(cmdRegistrySync),
+			},
 		},
 	},
 	{
@@ -341,7 +540,7 @@ var Commands = []cli.Command{
 	{
 		Name:            "ssh",
 		Usage:           "Log into or run a command on a machine with SSH.",
-		Description:     "Arguments are [machine-name] [command]",
+		Description:     "Arguments are [-A] [machine-name] [command]. Use -A to forward the local ssh-agent.",
 		Action:          runCommand(cmdSSH),
 		SkipFlagParsing: true,
 	},
@@ -355,6 +554,14 @@ var Commands = []cli.Command{
 				Name:  "recursive, r",
 				Usage: "Copy files recursively (required to copy directories)",
 			},
+			cli.BoolFlag{
+				Name:  "delta, d",
+				Usage: "Only copy the files whose size or modification time changed",
+			},
+			cli.BoolFlag{
+				Name:  "quiet, q",
+				Usage: "Don't display the progress of the copy",
+			},
 		},
 	},
 	{
@@ -375,11 +582,95 @@ var Commands = []cli.Command{
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdStop),
 	},
+	{
+		Name:  "swarm",
+		Usage: "Manage the Swarm and swarm mode clusters of the machines",
+		Subcommands: []cli.Command{
+			{
+				Name:   "ls",
+				Usage:  "List the clusters of the machines",
+				Action: runCommand(cmdSwarmLs),
+			},
+			{
+				Name:        "add",
+				Usage:       "Add a machine to a cluster",
+				Description: "Argument is a machine name.",
+				Action:      runCommand(cmdSwarmAdd),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "cluster",
+						Usage: "Machine of the cluster to add the machine to",
+					},
+				},
+			},
+			{
+				Name:        "remove",
+				Usage:       "Remove a machine from its cluster",
+				Description: "Argument is a machine name.",
+				Action:      runCommand(cmdSwarmRemove),
+			},
+			{
+				Name:        "promote",
+				Usage:       "Make a machine a master of its Swarm cluster or a manager of its swarm mode cluster",
+				Description: "Argument is a machine name.",
+				Action:      runCommand(cmdSwarmPromote),
+			},
+			{
+				Name:        "demote",
+				Usage:       "Make a master of a Swarm cluster an agent or a swarm mode manager a worker",
+				Description: "Argument is a machine name.",
+				Action:      runCommand(cmdSwarmDemote),
+			},
+			{
+				Name:        "doctor",
+				Usage:       "Recreate the Swarm containers which are missing, stopped or misconfigured",
+				Description: "Argument(s) are one or more machine names, all the Swarm machines when none is given.",
+				Action:      runCommand(cmdSwarmDoctor),
+			},
+			{
+				Name:        "rotate-master",
+				Usage:       "Move the master of a Swarm cluster to a machine",
+				Description: "Argument is a machine name.",
+				Action:      runCommand(cmdSwarmRotateMaster),
+			},
+		},
+	},
+	{
+		Name:        "sync",
+		Usage:       "Sync a local directory to a machine",
+		Description: "Arguments are [local-dir] [machine:][remote-dir].",
+		Action:      runCommand(cmdSync),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "watch, w",
+				Usage: "Keep sending the local changes until interrupted",
+			},
+			cli.StringSliceFlag{
+				Name:  "exclude, e",
+				Usage: "Exclude the paths matching the pattern, in addition to the .dockerignore file",
+				Value: &cli.StringSlice{},
+			},
+			cli.BoolFlag{
+				Name:  "force, f",
+				Usage: "Overwrite the files modified on the machine since they were last synced",
+			},
+		},
+	},
 	{
 		Name:        "upgrade",
 		Usage:       "Upgrade a machine to the latest version of Docker",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdUpgrade),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "engine-version",
+				Usage: "Version of Docker to install and pin the machine to, \"latest\" to unpin it",
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show the current and target versions of Docker without upgrading",
+			},
+		},
 	},
 	{
 		Name:        "url",
@@ -412,14 +703,15 @@ func printIP(h *host.Host) func() error {
 func machineCommand(actionName string, host *host.Host, errorChan chan<- error) {
 	// TODO: These actions should have their own type.
 	commands := map[string](func() error){
-		"configureAuth": host.ConfigureAuth,
-		"start":         host.Start,
-		"stop":          host.Stop,
-		"restart":       host.Restart,
-		"kill":          host.Kill,
-		"upgrade":       host.Upgrade,
-		"ip":            printIP(host),
-		"provision":     host.Provision,
+		"configureAuth":   host.ConfigureAuth,
+		"start":           host.Start,
+		"stop":            host.Stop,
+		"restart":         host.Restart,
+		"kill":            host.Kill,
+		"upgrade":         host.Upgrade,
+		"ip":              printIP(host),
+		"provision":       host.Provision,
+		"resumeProvision": host.ResumeProvision,
 	}
 
 	log.Debugf("command=%s machine=%s", actionName, host.Name)
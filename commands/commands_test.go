@@ -21,7 +21,7 @@ import (
 func TestRunActionForeachMachine(t *testing.T) {
 	defer provision.SetDetector(&provision.StandardDetector{})
 	provision.SetDetector(&provision.FakeDetector{
-		Provisioner: provision.NewNetstatProvisioner(),
+		Provisioner: provision.NewFakeProvisioner(nil),
 	})
 
 	// Assume a bunch of machines in randomly started or
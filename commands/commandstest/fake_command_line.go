@@ -48,10 +48,16 @@ func (fcli *FakeCommandLine) IsSet(key string) bool {
 }
 
 func (fcli *FakeCommandLine) String(key string) string {
+	if fcli.LocalFlags == nil {
+		return ""
+	}
 	return fcli.LocalFlags.String(key)
 }
 
 func (fcli *FakeCommandLine) StringSlice(key string) []string {
+	if fcli.LocalFlags == nil {
+		return []string{}
+	}
 	return fcli.LocalFlags.StringSlice(key)
 }
 
@@ -14,6 +14,20 @@ func cmdConfig(c CommandLine, api libmachine.API) error {
 	// being run (it is intended to be run in a subshell)
 	log.SetOutWriter(os.Stderr)
 
+	if c.String("format") != "" {
+		shellCfg, err := shellCfgSet(c, api)
+		if err != nil {
+			return err
+		}
+
+		_, format, err := lookupEnvFormat(c)
+		if err != nil {
+			return err
+		}
+
+		return format.execute(os.Stdout, shellCfg, false)
+	}
+
 	target, err := targetHost(c, api)
 	if err != nil {
 		return err
@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/dockercontext"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+// manageContexts is set by --manage-contexts: create and rm keep the Docker
+// CLI contexts of the machines in sync.
+var manageContexts = false
+
+// contextStore returns the store the contexts of the machines of api are
+// recorded with, so that the stores given with --storage-path don't touch
+// the contexts of each other.
+func contextStore(api libmachine.API) string {
+	store := api.GetMachinesDir()
+	if abs, err := filepath.Abs(store); err == nil {
+		return abs
+	}
+	return store
+}
+
+// cmdContextSync writes the Docker CLI context of the machines, all the
+// machines when none is given. The contexts of the removed machines of the
+// store are removed when all the machines are synced.
+func cmdContextSync(c CommandLine, api libmachine.API) error {
+	dir := dockercontext.Dir()
+	store := contextStore(api)
+
+	var (
+		hosts []*host.Host
+		errs  []error
+	)
+	if len(c.Args()) > 0 {
+		loaded, hostsInError := persist.LoadHosts(api, c.Args())
+		for _, err := range hostsInError {
+			errs = append(errs, err)
+		}
+		hosts = loaded
+	} else {
+		all, hostsInError, err := persist.LoadAllHosts(api)
+		if err != nil {
+			return err
+		}
+		for _, err := range hostsInError {
+			errs = append(errs, err)
+		}
+		hosts = all
+
+		removed, err := removeStaleContexts(dir, api)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, name := range removed {
+			log.Infof("Removed the context of %s", name)
+		}
+	}
+
+	for _, h := range hosts {
+		if err := writeContext(dir, store, h); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", h.Name, err))
+			continue
+		}
+		log.Infof("Synced the context of %s", h.Name)
+	}
+
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	return nil
+}
+
+// writeContext writes the Docker CLI context of the machine of the store in
+// the context store of dir.
+func writeContext(dir, store string, h *host.Host) error {
+	url, err := h.URL()
+	if err != nil {
+		return fmt.Errorf("Error getting the URL of the machine: %s", err)
+	}
+
+	authOptions := h.AuthOptions()
+	if authOptions == nil {
+		return fmt.Errorf("The machine has no TLS configuration")
+	}
+
+	return dockercontext.Write(dir, dockercontext.Context{
+		Name:   h.Name,
+		Host:   url,
+		Store:  store,
+		CACert: authOptions.CaCertPath,
+		Cert:   authOptions.ClientCertPath,
+		Key:    authOptions.ClientKeyPath,
+	})
+}
+
+// removeStaleContexts removes the contexts written by Machine for machines of
+// the store of api which don't exist anymore, and returns their names.
+func removeStaleContexts(dir string, api libmachine.API) ([]string, error) {
+	store := contextStore(api)
+	names, err := dockercontext.List(dir, store)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	for _, name := range names {
+		exists, err := api.Exists(name)
+		if err != nil {
+			return removed, err
+		}
+		if exists {
+			continue
+		}
+
+		if err := dockercontext.Remove(dir, name, store); err != nil {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+
+	return removed, nil
+}
+
+// syncCreatedContext writes the context of a created machine with
+// --manage-contexts. A failure doesn't fail the creation.
+func syncCreatedContext(api libmachine.API, h *host.Host) {
+	if !manageContexts {
+		return
+	}
+
+	if err := writeContext(dockercontext.Dir(), contextStore(api), h); err != nil {
+		log.Warnf("Unable to write the Docker context of %s: %s", h.Name, err)
+	}
+}
+
+// syncRemovedContext removes the context of a removed machine with
+// --manage-contexts.
+func syncRemovedContext(api libmachine.API, name string) {
+	if !manageContexts {
+		return
+	}
+
+	if err := dockercontext.Remove(dockercontext.Dir(), name, contextStore(api)); err != nil {
+		log.Warnf("Unable to remove the Docker context of %s: %s", name, err)
+	}
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/dockercontext"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// withContextsDir points the Docker CLI config dir to a temporary directory
+// holding the TLS material of the machines.
+func withContextsDir(t *testing.T) (string, *auth.Options, func()) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+
+	for _, name := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0600))
+	}
+
+	previous := os.Getenv("DOCKER_CONFIG")
+	os.Setenv("DOCKER_CONFIG", dir)
+
+	return dir, &auth.Options{
+		CaCertPath:     filepath.Join(dir, "ca.pem"),
+		ClientCertPath: filepath.Join(dir, "cert.pem"),
+		ClientKeyPath:  filepath.Join(dir, "key.pem"),
+	}, func() {
+		os.Setenv("DOCKER_CONFIG", previous)
+		os.RemoveAll(dir)
+	}
+}
+
+func newContextTestHost(name, ip string, authOptions *auth.Options) *host.Host {
+	return &host.Host{
+		Name:   name,
+		Driver: &fakedriver.Driver{MockState: state.Running, MockIP: ip},
+		HostOptions: &host.Options{
+			AuthOptions: authOptions,
+		},
+	}
+}
+
+func TestCmdContextSync(t *testing.T) {
+	dir, authOptions, cleanup := withContextsDir(t)
+	defer cleanup()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			newContextTestHost("dev", "10.0.0.1", authOptions),
+			newContextTestHost("prod", "10.0.0.2", authOptions),
+		},
+	}
+
+	assert.NoError(t, dockercontext.Write(dir, dockercontext.Context{
+		Name:   "gone",
+		Host:   "tcp://10.0.0.9:2376",
+		Store:  contextStore(api),
+		CACert: authOptions.CaCertPath,
+		Cert:   authOptions.ClientCertPath,
+		Key:    authOptions.ClientKeyPath,
+	}))
+
+	err := cmdContextSync(&commandstest.FakeCommandLine{}, api)
+
+	assert.NoError(t, err)
+	names, err := dockercontext.List(dir, contextStore(api))
+	assert.NoError(t, err)
+	assert.Len(t, names, 2)
+	assert.Contains(t, names, "dev")
+	assert.Contains(t, names, "prod")
+}
+
+func TestCmdContextSyncSelectedMachines(t *testing.T) {
+	dir, authOptions, cleanup := withContextsDir(t)
+	defer cleanup()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			newContextTestHost("dev", "10.0.0.1", authOptions),
+			newContextTestHost("prod", "10.0.0.2", authOptions),
+		},
+	}
+
+	err := cmdContextSync(&commandstest.FakeCommandLine{CliArgs: []string{"prod"}}, api)
+
+	assert.NoError(t, err)
+	names, err := dockercontext.List(dir, contextStore(api))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod"}, names)
+}
+
+func TestCmdRmRemovesManagedContext(t *testing.T) {
+	dir, authOptions, cleanup := withContextsDir(t)
+	defer cleanup()
+	defer func(previous bool) { manageContexts = previous }(manageContexts)
+	manageContexts = true
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			newContextTestHost("dev", "10.0.0.1", authOptions),
+		},
+	}
+	assert.NoError(t, writeContext(dir, contextStore(api), api.Hosts[0]))
+
+	err := cmdRm(&commandstest.FakeCommandLine{
+		CliArgs: []string{"dev"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"y": true},
+		},
+	}, api)
+
+	assert.NoError(t, err)
+	names, err := dockercontext.List(dir, contextStore(api))
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+func TestCmdContextSyncKeepsContextsOfOtherStores(t *testing.T) {
+	dir, authOptions, cleanup := withContextsDir(t)
+	defer cleanup()
+
+	first := &libmachinetest.FakeAPI{
+		Hosts:       []*host.Host{newContextTestHost("dev", "10.0.0.1", authOptions)},
+		MachinesDir: "/store/first/machines",
+	}
+	second := &libmachinetest.FakeAPI{
+		Hosts:       []*host.Host{newContextTestHost("prod", "10.0.0.2", authOptions)},
+		MachinesDir: "/store/second/machines",
+	}
+
+	assert.NoError(t, cmdContextSync(&commandstest.FakeCommandLine{}, first))
+	assert.NoError(t, cmdContextSync(&commandstest.FakeCommandLine{}, second))
+
+	names, err := dockercontext.List(dir, contextStore(first))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dev"}, names)
+	names, err = dockercontext.List(dir, contextStore(second))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod"}, names)
+
+	// The machine of the first store is removed: only its context goes.
+	first.Hosts = []*host.Host{}
+	assert.NoError(t, cmdContextSync(&commandstest.FakeCommandLine{}, first))
+
+	names, err = dockercontext.List(dir, contextStore(first))
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+	names, err = dockercontext.List(dir, contextStore(second))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod"}, names)
+}
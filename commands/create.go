@@ -2,8 +2,10 @@ package commands
 
 import (
 	"encoding/json"
+	"encoding/pem"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -26,7 +28,12 @@ import (
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/registry"
 	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/libmachine/swarmmode"
 )
 
 var (
@@ -49,6 +56,18 @@ var (
 			Value:  drivers.DefaultEngineInstallURL,
 			EnvVar: "MACHINE_DOCKER_INSTALL_URL",
 		},
+		cli.StringFlag{
+			Name:  "engine-install-bundle",
+			Usage: "Install the engine from a local tarball of binaries or packages, or from its URL on a mirror, without network access",
+		},
+		cli.StringFlag{
+			Name:  "engine-version",
+			Usage: "Specify the version of the engine to install and pin the machine to",
+		},
+		cli.BoolFlag{
+			Name:  "engine-rootless",
+			Usage: "Run the engine as the SSH user with rootless Docker, without sudo",
+		},
 		cli.StringSliceFlag{
 			Name:  "engine-opt",
 			Usage: "Specify arbitrary flags to include with the created engine in the form flag=value",
@@ -79,6 +98,54 @@ var (
 			Usage: "Specify environment variables to set in the engine",
 			Value: &cli.StringSlice{},
 		},
+		cli.StringFlag{
+			Name:  "engine-log-driver",
+			Usage: "Specify the default logging driver of the containers",
+		},
+		cli.StringSliceFlag{
+			Name:  "engine-log-opt",
+			Usage: "Specify options of the logging driver, as key=value",
+			Value: &cli.StringSlice{},
+		},
+		cli.StringFlag{
+			Name:  "engine-cgroup-driver",
+			Usage: fmt.Sprintf("Specify the cgroup driver of the engine: %s", strings.Join(engine.CgroupDrivers, ", ")),
+		},
+		cli.BoolFlag{
+			Name:  "engine-live-restore",
+			Usage: "Keep the containers running while the engine is down",
+		},
+		cli.StringSliceFlag{
+			Name:  "engine-default-ulimit",
+			Usage: "Specify default ulimits of the containers, as name=soft[:hard]",
+			Value: &cli.StringSlice{},
+		},
+		cli.StringSliceFlag{
+			Name:  "engine-default-address-pool",
+			Usage: "Specify pools the subnets of the networks are taken from, as base=CIDR,size=N",
+			Value: &cli.StringSlice{},
+		},
+		cli.StringFlag{
+			Name:  "engine-bip",
+			Usage: "Specify the IP of the docker0 bridge, with its netmask in CIDR notation",
+		},
+		cli.IntFlag{
+			Name:  "engine-mtu",
+			Usage: "Specify the MTU of the container networks",
+		},
+		cli.StringFlag{
+			Name:  "engine-data-root",
+			Usage: "Specify the directory of the state of the engine, instead of /var/lib/docker",
+		},
+		cli.StringFlag{
+			Name:  "provisioner",
+			Usage: fmt.Sprintf("Provisioner to use instead of detecting it from the OS: %s", strings.Join(provision.ProvisionerNames(), ", ")),
+		},
+		cli.StringSliceFlag{
+			Name:  "provision-hook",
+			Usage: "Run a script on the machine before (pre=<file>) or after (post=<file>) provisioning it",
+			Value: &cli.StringSlice{},
+		},
 		cli.BoolFlag{
 			Name:  "swarm",
 			Usage: "Configure Machine to join a Swarm cluster",
@@ -95,9 +162,13 @@ var (
 		},
 		cli.StringFlag{
 			Name:  "swarm-discovery",
-			Usage: "Discovery service to use with Swarm",
+			Usage: "Discovery service to use with Swarm, auto to run one on the master and use it on the agents",
 			Value: "",
 		},
+		cli.BoolFlag{
+			Name:  "swarm-discovery-public",
+			Usage: "Publish the discovery service run with --swarm-discovery auto on all the interfaces of the master",
+		},
 		cli.StringFlag{
 			Name:  "swarm-strategy",
 			Usage: "Define a default scheduling strategy for Swarm",
@@ -127,11 +198,33 @@ var (
 			Name:  "swarm-experimental",
 			Usage: "Enable Swarm experimental features",
 		},
+		cli.StringFlag{
+			Name:  "swarm-mode",
+			Usage: "Make the machine a node of a swarm mode cluster with this role: manager or worker",
+		},
+		cli.StringFlag{
+			Name:  "swarm-mode-join",
+			Usage: "Manager machine whose swarm mode cluster the machine joins, the cluster is initialized on the machine when none is given",
+		},
+		cli.StringSliceFlag{
+			Name:  "registry-auth",
+			Usage: "Log the engine in to a registry, as registry=source where the source is env:NAME, file:PATH or helper:NAME",
+			Value: &cli.StringSlice{},
+		},
+		cli.StringSliceFlag{
+			Name:  "registry-ca",
+			Usage: "Trust a CA cert for a registry, as registry=path of the PEM file",
+			Value: &cli.StringSlice{},
+		},
 		cli.StringSliceFlag{
 			Name:  "tls-san",
 			Usage: "Support extra SANs for TLS certs",
 			Value: &cli.StringSlice{},
 		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Show what would be done without creating the machine",
+		},
 	}
 )
 
@@ -155,6 +248,41 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 		return fmt.Errorf("Error parsing swarm discovery: %s", err)
 	}
 
+	if provisionerName := c.String("provisioner"); provisionerName != "" {
+		if err := provision.ValidateProvisionerName(provisionerName); err != nil {
+			return err
+		}
+	}
+
+	provisionHooks, err := readProvisionHooks(c.StringSlice("provision-hook"))
+	if err != nil {
+		return err
+	}
+
+	installBundle, err := engineInstallBundle(c)
+	if err != nil {
+		return err
+	}
+
+	if err := validateEngineRootless(c); err != nil {
+		return err
+	}
+
+	registryAuth, registryCA, err := registryOptions(c)
+	if err != nil {
+		return err
+	}
+
+	swarmMode, err := swarmModeOptions(c, api)
+	if err != nil {
+		return err
+	}
+
+	discovery, localDiscovery, err := swarmDiscovery(c, api)
+	if err != nil {
+		return err
+	}
+
 	// TODO: Fix hacky JSON solution
 	rawDriver, err := json.Marshal(&drivers.BaseDriver{
 		MachineName: name,
@@ -171,6 +299,8 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 	}
 
 	h.HostOptions = &host.Options{
+		Provisioner:    c.String("provisioner"),
+		ProvisionHooks: provisionHooks,
 		AuthOptions: &auth.Options{
 			CertDir:          mcndirs.GetMachineCertDir(),
 			CaCertPath:       tlsPath(c, "tls-ca-cert", "ca.pem"),
@@ -191,20 +321,42 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 			StorageDriver:    c.String("engine-storage-driver"),
 			TLSVerify:        true,
 			InstallURL:       c.String("engine-install-url"),
+			InstallBundle:    installBundle,
+			Version:          c.String("engine-version"),
+			Rootless:         c.Bool("engine-rootless"),
+			RegistryAuth:     registryAuth,
+			RegistryCA:       registryCA,
+
+			LogDriver:           c.String("engine-log-driver"),
+			LogOpts:             c.StringSlice("engine-log-opt"),
+			CgroupDriver:        c.String("engine-cgroup-driver"),
+			LiveRestore:         c.Bool("engine-live-restore"),
+			DefaultUlimits:      c.StringSlice("engine-default-ulimit"),
+			DefaultAddressPools: c.StringSlice("engine-default-address-pool"),
+			Bip:                 c.String("engine-bip"),
+			MTU:                 c.Int("engine-mtu"),
+			DataRoot:            c.String("engine-data-root"),
 		},
 		SwarmOptions: &swarm.Options{
 			IsSwarm:            c.Bool("swarm") || c.Bool("swarm-master"),
 			Image:              c.String("swarm-image"),
 			Agent:              c.Bool("swarm"),
 			Master:             c.Bool("swarm-master"),
-			Discovery:          c.String("swarm-discovery"),
+			Discovery:          discovery,
 			Address:            c.String("swarm-addr"),
 			Host:               c.String("swarm-host"),
 			Strategy:           c.String("swarm-strategy"),
 			ArbitraryFlags:     c.StringSlice("swarm-opt"),
 			ArbitraryJoinFlags: c.StringSlice("swarm-join-opt"),
 			IsExperimental:     c.Bool("swarm-experimental"),
+			LocalDiscovery:     localDiscovery,
+			PublicDiscovery:    c.Bool("swarm-discovery-public"),
 		},
+		SwarmModeOptions: swarmMode,
+	}
+
+	if err := h.HostOptions.EngineOptions.Validate(); err != nil {
+		return err
 	}
 
 	exists, err := api.Exists(h.Name)
@@ -222,11 +374,20 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 	// concrete type rpcdriver.RpcFlags).
 	mcnFlags := h.Driver.GetCreateFlags()
 	driverOpts := getDriverOpts(c, mcnFlags)
+	if version := c.String("engine-version"); version != "" {
+		if err := pinBoot2DockerURL(driverOpts, mcnFlags, version); err != nil {
+			return err
+		}
+	}
 
 	if err := h.Driver.SetConfigFromFlags(driverOpts); err != nil {
 		return fmt.Errorf("Error setting machine configuration from flags provided: %s", err)
 	}
 
+	if c.Bool("dry-run") {
+		return printPlan(os.Stdout, h.Name, provision.PlanCreation(h.DriverName, h.HostOptions.Provisioner, h.HostOptions.ProvisionHooks, *h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions))
+	}
+
 	if err := api.Create(h); err != nil {
 		// Wait for all the logs to reach the client
 		time.Sleep(2 * time.Second)
@@ -249,6 +410,8 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 		return fmt.Errorf("Error attempting to save store: %s", err)
 	}
 
+	syncCreatedContext(api, h)
+
 	log.Infof("To see how to connect your Docker Client to the Docker Engine running on this virtual machine, run: %s env %s", os.Args[0], name)
 
 	return nil
@@ -343,6 +506,195 @@ func cmdCreateOuter(c CommandLine, api libmachine.API) error {
 	return c.Application().Run(os.Args)
 }
 
+// validateEngineRootless checks that the options given with
+// --engine-rootless can be applied by the SSH user.
+func validateEngineRootless(c CommandLine) error {
+	if !c.Bool("engine-rootless") {
+		return nil
+	}
+
+	if c.Bool("swarm") || c.Bool("swarm-master") || c.String("swarm-mode") != "" {
+		return errors.New("--engine-rootless can't be used with --swarm, --swarm-master or --swarm-mode")
+	}
+
+	if c.String("engine-version") != "" || c.String("engine-install-bundle") != "" {
+		return errors.New("--engine-rootless can't be used with --engine-version or --engine-install-bundle, the engine is installed with the rootless install script")
+	}
+
+	return nil
+}
+
+// registryOptions returns the registry credential sources and CA certs
+// given with --registry-auth and --registry-ca, with the absolute paths of
+// the certs.
+func registryOptions(c CommandLine) ([]string, []string, error) {
+	auths := c.StringSlice("registry-auth")
+	for _, option := range auths {
+		_, source, err := registry.ParseOption(option)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := registry.ValidateSource(source); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	cas := []string{}
+	for _, option := range c.StringSlice("registry-ca") {
+		name, pemPath, err := registry.ParseOption(option)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if pemPath, err = filepath.Abs(pemPath); err != nil {
+			return nil, nil, err
+		}
+		content, err := ioutil.ReadFile(pemPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Unable to read the CA cert of %s: %s", name, err)
+		}
+		if block, _ := pem.Decode(content); block == nil {
+			return nil, nil, fmt.Errorf("The CA cert of %s isn't PEM encoded: %s", name, pemPath)
+		}
+
+		cas = append(cas, name+"="+pemPath)
+	}
+
+	return auths, cas, nil
+}
+
+// swarmModeOptions returns the swarm mode options of the machine, or nil
+// when it isn't part of a swarm mode cluster. The machine to join must be a
+// manager.
+func swarmModeOptions(c CommandLine, api libmachine.API) (*swarmmode.Options, error) {
+	role := c.String("swarm-mode")
+	join := c.String("swarm-mode-join")
+
+	if role == "" {
+		if join != "" {
+			return nil, errors.New("--swarm-mode-join can't be used without --swarm-mode")
+		}
+		return nil, nil
+	}
+
+	if !swarmmode.IsValidRole(role) {
+		return nil, fmt.Errorf("Invalid swarm mode role %q, expected %s or %s", role, swarmmode.RoleManager, swarmmode.RoleWorker)
+	}
+
+	if join == "" {
+		if role == swarmmode.RoleWorker {
+			return nil, errors.New("--swarm-mode worker needs --swarm-mode-join, the manager machine to join")
+		}
+		return &swarmmode.Options{Role: role}, nil
+	}
+
+	manager, err := api.Load(join)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading the machine to join: %s", err)
+	}
+
+	if manager.SwarmModeRole() != swarmmode.RoleManager {
+		return nil, fmt.Errorf("%s isn't a swarm mode manager, it can't be joined", join)
+	}
+
+	return &swarmmode.Options{Role: role, Join: join}, nil
+}
+
+// swarmDiscovery returns the discovery of the Swarm options, and whether the
+// machine runs it. With auto, a master runs the discovery service of its
+// cluster, its URL is set once the machine is created. An agent uses the one
+// run by a master of the store.
+func swarmDiscovery(c CommandLine, api libmachine.API) (string, bool, error) {
+	discovery := c.String("swarm-discovery")
+	if discovery != swarm.DiscoveryAuto {
+		return discovery, false, nil
+	}
+
+	if c.Bool("swarm-master") {
+		return "", true, nil
+	}
+
+	hosts, _, err := persist.LoadAllHosts(api)
+	if err != nil {
+		return "", false, err
+	}
+
+	found := []*host.Host{}
+	for _, h := range hosts {
+		if options := swarmOptions(h); options.LocalDiscovery && options.Discovery != "" {
+			found = append(found, h)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", false, errors.New("No machine runs a Swarm discovery service, create a master with --swarm-master --swarm-discovery auto first")
+	case 1:
+		return swarmOptions(found[0]).Discovery, false, nil
+	}
+
+	names := []string{}
+	for _, h := range found {
+		names = append(names, h.Name)
+	}
+	return "", false, fmt.Errorf("Several machines run a Swarm discovery service (%s), give the discovery of the cluster to join with --swarm-discovery", strings.Join(names, ", "))
+}
+
+// engineInstallBundle returns the absolute path of the bundle given with
+// --engine-install-bundle, or its URL on a mirror.
+func engineInstallBundle(c CommandLine) (string, error) {
+	bundle := c.String("engine-install-bundle")
+	if bundle == "" {
+		return "", nil
+	}
+
+	if c.String("engine-version") != "" {
+		return "", errors.New("--engine-version can't be used with --engine-install-bundle, the bundle decides the version of the engine")
+	}
+
+	if provision.IsRemoteBundle(bundle) {
+		return bundle, nil
+	}
+
+	path, err := filepath.Abs(bundle)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("Error reading the engine install bundle: %s", err)
+	}
+
+	return path, nil
+}
+
+// pinBoot2DockerURL makes the drivers running Boot2Docker download the
+// release shipping the given version of the engine, unless their ISO was
+// chosen explicitly.
+func pinBoot2DockerURL(driverOpts drivers.DriverOptions, mcnflags []mcnflag.Flag, version string) error {
+	flags, ok := driverOpts.(rpcdriver.RPCFlags)
+	if !ok {
+		return nil
+	}
+
+	for _, f := range mcnflags {
+		name := f.String()
+		if !strings.HasSuffix(name, "-boot2docker-url") {
+			continue
+		}
+
+		if url, _ := flags.Values[name].(string); url == "" {
+			releaseURL, err := mcnutils.Boot2DockerReleaseURL(version)
+			if err != nil {
+				return err
+			}
+			flags.Values[name] = releaseURL
+		}
+	}
+
+	return nil
+}
+
 func getDriverOpts(c CommandLine, mcnflags []mcnflag.Flag) drivers.DriverOptions {
 	// TODO: This function is pretty damn YOLO and would benefit from some
 	// sanity checking around types and assertions.
@@ -439,7 +791,7 @@ func addDriverFlagsToCommand(cliFlags []cli.Flag, cmd *cli.Command) *cli.Command
 }
 
 func validateSwarmDiscovery(discovery string) error {
-	if discovery == "" {
+	if discovery == "" || discovery == swarm.DiscoveryAuto {
 		return nil
 	}
 
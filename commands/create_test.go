@@ -1,11 +1,18 @@
 package commands
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"flag"
 	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
 	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/libmachine/swarmmode"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -117,3 +124,188 @@ func TestGetDriverOpts(t *testing.T) {
 		assert.Equal(t, tt.expected["stringslice_defaulted"], driverOpts.StringSlice("stringslice_defaulted"))
 	}
 }
+
+func TestPinBoot2DockerURL(t *testing.T) {
+	flags := []mcnflag.Flag{
+		mcnflag.StringFlag{Name: "virtualbox-boot2docker-url"},
+		mcnflag.StringFlag{Name: "vmwarefusion-boot2docker-url"},
+		mcnflag.StringFlag{Name: "virtualbox-hostonly-cidr"},
+	}
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"vmwarefusion-boot2docker-url": fakeFlagGetter{value: "https://example.com/custom.iso"},
+			},
+		},
+	}
+
+	driverOpts := getDriverOpts(commandLine, flags)
+	err := pinBoot2DockerURL(driverOpts, flags, "17.03.1-ce")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/boot2docker/boot2docker/releases/download/v17.03.1-ce/boot2docker.iso", driverOpts.String("virtualbox-boot2docker-url"))
+	assert.Equal(t, "https://example.com/custom.iso", driverOpts.String("vmwarefusion-boot2docker-url"))
+	assert.Equal(t, "", driverOpts.String("virtualbox-hostonly-cidr"))
+
+	err = pinBoot2DockerURL(getDriverOpts(commandLine, flags), flags, "20.10.7")
+
+	assert.EqualError(t, err, "Boot2Docker has no release shipping Docker 20.10.7, the last one ships 19.03.12")
+}
+
+func TestEngineInstallBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bundlePath := filepath.Join(dir, "bundle.tgz")
+	assert.NoError(t, ioutil.WriteFile(bundlePath, []byte("bundle"), 0600))
+
+	testCases := []struct {
+		flags       map[string]interface{}
+		expected    string
+		expectedErr string
+	}{
+		{map[string]interface{}{}, "", ""},
+		{map[string]interface{}{"engine-install-bundle": bundlePath}, bundlePath, ""},
+		{map[string]interface{}{"engine-install-bundle": "http://mirror.lab/docker.tgz"}, "http://mirror.lab/docker.tgz", ""},
+		{map[string]interface{}{"engine-install-bundle": filepath.Join(dir, "missing.tgz")}, "", "Error reading the engine install bundle"},
+		{map[string]interface{}{"engine-install-bundle": bundlePath, "engine-version": "17.03.1-ce"}, "", "--engine-version can't be used with --engine-install-bundle"},
+	}
+
+	for _, tc := range testCases {
+		commandLine := &commandstest.FakeCommandLine{
+			LocalFlags: &commandstest.FakeFlagger{Data: tc.flags},
+		}
+
+		bundle, err := engineInstallBundle(commandLine)
+
+		assert.Equal(t, tc.expected, bundle)
+		if tc.expectedErr == "" {
+			assert.NoError(t, err)
+		} else if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), tc.expectedErr)
+		}
+	}
+}
+
+func TestValidateEngineRootless(t *testing.T) {
+	testCases := []struct {
+		flags       map[string]interface{}
+		expectedErr string
+	}{
+		{map[string]interface{}{}, ""},
+		{map[string]interface{}{"engine-rootless": true}, ""},
+		{map[string]interface{}{"engine-rootless": true, "swarm-master": true}, "--engine-rootless can't be used with --swarm"},
+		{map[string]interface{}{"engine-rootless": true, "engine-version": "17.03.1-ce"}, "--engine-rootless can't be used with --engine-version"},
+		{map[string]interface{}{"swarm": true, "engine-version": "17.03.1-ce"}, ""},
+	}
+
+	for _, tc := range testCases {
+		commandLine := &commandstest.FakeCommandLine{
+			LocalFlags: &commandstest.FakeFlagger{Data: tc.flags},
+		}
+
+		err := validateEngineRootless(commandLine)
+
+		if tc.expectedErr == "" {
+			assert.NoError(t, err)
+		} else if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), tc.expectedErr)
+		}
+	}
+}
+
+func TestSwarmModeOptions(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name: "manager1",
+				HostOptions: &host.Options{
+					SwarmModeOptions: &swarmmode.Options{Role: swarmmode.RoleManager},
+				},
+			},
+			{
+				Name: "worker1",
+				HostOptions: &host.Options{
+					SwarmModeOptions: &swarmmode.Options{Role: swarmmode.RoleWorker, Join: "manager1"},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		flags       map[string]interface{}
+		expected    *swarmmode.Options
+		expectedErr string
+	}{
+		{map[string]interface{}{}, nil, ""},
+		{map[string]interface{}{"swarm-mode": "manager"}, &swarmmode.Options{Role: "manager"}, ""},
+		{map[string]interface{}{"swarm-mode": "worker", "swarm-mode-join": "manager1"}, &swarmmode.Options{Role: "worker", Join: "manager1"}, ""},
+		{map[string]interface{}{"swarm-mode": "manager", "swarm-mode-join": "manager1"}, &swarmmode.Options{Role: "manager", Join: "manager1"}, ""},
+		{map[string]interface{}{"swarm-mode": "leader"}, nil, "Invalid swarm mode role \"leader\""},
+		{map[string]interface{}{"swarm-mode": "worker"}, nil, "--swarm-mode worker needs --swarm-mode-join"},
+		{map[string]interface{}{"swarm-mode-join": "manager1"}, nil, "--swarm-mode-join can't be used without --swarm-mode"},
+		{map[string]interface{}{"swarm-mode": "worker", "swarm-mode-join": "worker1"}, nil, "worker1 isn't a swarm mode manager"},
+		{map[string]interface{}{"swarm-mode": "worker", "swarm-mode-join": "missing"}, nil, "Error loading the machine to join"},
+	}
+
+	for _, tc := range testCases {
+		commandLine := &commandstest.FakeCommandLine{
+			LocalFlags: &commandstest.FakeFlagger{Data: tc.flags},
+		}
+
+		options, err := swarmModeOptions(commandLine, api)
+
+		assert.Equal(t, tc.expected, options)
+		if tc.expectedErr == "" {
+			assert.NoError(t, err)
+		} else if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), tc.expectedErr)
+		}
+	}
+}
+
+func TestValidateSwarmDiscoveryAcceptsAuto(t *testing.T) {
+	assert.NoError(t, validateSwarmDiscovery(swarm.DiscoveryAuto))
+}
+
+func TestSwarmDiscovery(t *testing.T) {
+	master := &host.Host{
+		Name: "master",
+		HostOptions: &host.Options{
+			SwarmOptions: &swarm.Options{IsSwarm: true, Master: true, Discovery: "consul://10.0.0.1:8500/swarm", LocalDiscovery: true},
+		},
+	}
+	agent := &host.Host{
+		Name: "agent",
+		HostOptions: &host.Options{
+			SwarmOptions: &swarm.Options{IsSwarm: true, Agent: true, Discovery: "consul://10.0.0.1:8500/swarm"},
+		},
+	}
+	discovery := func(api *libmachinetest.FakeAPI, flags map[string]interface{}) (string, bool, error) {
+		return swarmDiscovery(&commandstest.FakeCommandLine{
+			LocalFlags: &commandstest.FakeFlagger{Data: flags},
+		}, api)
+	}
+
+	api := &libmachinetest.FakeAPI{Hosts: []*host.Host{agent}}
+
+	url, local, err := discovery(api, map[string]interface{}{"swarm-discovery": "token://abc", "swarm": true})
+	assert.NoError(t, err)
+	assert.Equal(t, "token://abc", url)
+	assert.False(t, local)
+
+	url, local, err = discovery(api, map[string]interface{}{"swarm-discovery": "auto", "swarm-master": true})
+	assert.NoError(t, err)
+	assert.Equal(t, "", url)
+	assert.True(t, local)
+
+	_, _, err = discovery(api, map[string]interface{}{"swarm-discovery": "auto", "swarm": true})
+	assert.EqualError(t, err, "No machine runs a Swarm discovery service, create a master with --swarm-master --swarm-discovery auto first")
+
+	api.Hosts = append(api.Hosts, master)
+	url, local, err = discovery(api, map[string]interface{}{"swarm-discovery": "auto", "swarm": true})
+	assert.NoError(t, err)
+	assert.Equal(t, "consul://10.0.0.1:8500/swarm", url)
+	assert.False(t, local)
+}
@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcndockerclient"
+)
+
+var errEngineSetNoChange = errors.New("Error: Expected at least one option to change, e.g. --label or --unset-label")
+
+// engineSetSliceFlags are the flags of engine set which can be repeated,
+// along with --log-driver they all change the options.
+var engineSetSliceFlags = []string{
+	"label", "unset-label",
+	"registry-mirror", "unset-registry-mirror",
+	"insecure-registry", "unset-insecure-registry",
+	"log-opt", "unset-log-opt",
+}
+
+func storedEngineOptions(h *host.Host) engine.Options {
+	if h.HostOptions == nil || h.HostOptions.EngineOptions == nil {
+		return engine.Options{}
+	}
+	return *h.HostOptions.EngineOptions
+}
+
+// cmdEngineSet changes the options of the engine of the machine, and applies
+// them by writing its configuration again and reloading or restarting it.
+func cmdEngineSet(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		return ErrExpectedOneMachine
+	}
+
+	changed := c.String("log-driver") != ""
+	for _, flag := range engineSetSliceFlags {
+		changed = changed || len(c.StringSlice(flag)) > 0
+	}
+	if !changed {
+		return errEngineSetNoChange
+	}
+
+	h, err := api.Load(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	options := setEngineOptions(c, storedEngineOptions(h))
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	change, err := h.ConfigureEngine(options)
+	if err == nil || change.Changed() {
+		if saveErr := api.Save(h); saveErr != nil {
+			return saveErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !change.Changed():
+		log.Infof("The engine of %s already runs with these options", h.Name)
+	case change.Reloaded:
+		log.Infof("Reloaded the engine of %s with the new options", h.Name)
+	default:
+		log.Infof("Restarted the engine of %s with the new options", h.Name)
+	}
+
+	return nil
+}
+
+// setEngineOptions returns options with the changes given as flags. The
+// slices are copied, options is left as is.
+func setEngineOptions(c CommandLine, options engine.Options) engine.Options {
+	options.Labels = setKeyValues(options.Labels, c.StringSlice("label"), c.StringSlice("unset-label"))
+	options.RegistryMirror = setValues(options.RegistryMirror, c.StringSlice("registry-mirror"), c.StringSlice("unset-registry-mirror"))
+	options.InsecureRegistry = setValues(options.InsecureRegistry, c.StringSlice("insecure-registry"), c.StringSlice("unset-insecure-registry"))
+	if logDriver := c.String("log-driver"); logDriver != "" {
+		options.LogDriver = logDriver
+	}
+	options.LogOpts = setKeyValues(options.LogOpts, c.StringSlice("log-opt"), c.StringSlice("unset-log-opt"))
+
+	return options
+}
+
+func optionKey(option string) string {
+	return strings.SplitN(option, "=", 2)[0]
+}
+
+// setKeyValues replaces the key=value entries of current whose key is set,
+// in place, adds the other ones, and removes the keys unset.
+func setKeyValues(current, set, unset []string) []string {
+	values := map[string]string{}
+	keys := []string{}
+	for _, option := range set {
+		if _, ok := values[optionKey(option)]; !ok {
+			keys = append(keys, optionKey(option))
+		}
+		values[optionKey(option)] = option
+	}
+
+	removed := map[string]bool{}
+	for _, option := range unset {
+		removed[optionKey(option)] = true
+	}
+
+	result := []string{}
+	placed := map[string]bool{}
+	for _, option := range current {
+		key := optionKey(option)
+		switch {
+		case removed[key] || placed[key]:
+		case values[key] != "":
+			result = append(result, values[key])
+			placed[key] = true
+		default:
+			result = append(result, option)
+		}
+	}
+	for _, key := range keys {
+		if !placed[key] && !removed[key] {
+			result = append(result, values[key])
+		}
+	}
+
+	return result
+}
+
+// setValues adds the values set which current doesn't have, and removes the
+// values unset.
+func setValues(current, set, unset []string) []string {
+	removed := map[string]bool{}
+	for _, value := range unset {
+		removed[value] = true
+	}
+
+	result := []string{}
+	present := map[string]bool{}
+	for _, value := range append(append([]string{}, current...), set...) {
+		if !removed[value] && !present[value] {
+			result = append(result, value)
+			present[value] = true
+		}
+	}
+
+	return result
+}
+
+// cmdEngineShow prints the options stored for the engine of the machine next
+// to the configuration the engine reports.
+func cmdEngineShow(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	info, err := mcndockerclient.GetEngineInfo(h)
+	if err != nil {
+		return err
+	}
+
+	return printEngineConfig(os.Stdout, storedEngineOptions(h), info)
+}
+
+func printEngineConfig(w io.Writer, options engine.Options, info mcndockerclient.EngineInfo) error {
+	tabWriter := tabwriter.NewWriter(w, 5, 1, 3, ' ', 0)
+
+	fmt.Fprintln(tabWriter, "SETTING\tSTORED\tENGINE")
+	for _, setting := range []struct {
+		name   string
+		stored string
+		engine string
+	}{
+		{"labels", strings.Join(options.Labels, ","), strings.Join(info.Labels, ",")},
+		{"registry-mirrors", strings.Join(options.RegistryMirror, ","), strings.Join(info.RegistryMirrors, ",")},
+		{"insecure-registries", strings.Join(options.InsecureRegistry, ","), strings.Join(info.InsecureRegistries, ",")},
+		{"storage-driver", options.StorageDriver, info.StorageDriver},
+		{"log-driver", options.LogDriver, info.LoggingDriver},
+		{"cgroup-driver", options.CgroupDriver, info.CgroupDriver},
+		{"live-restore", strconv.FormatBool(options.LiveRestore), strconv.FormatBool(info.LiveRestore)},
+		{"data-root", options.DataRoot, info.DataRoot},
+	} {
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\n", setting.name, orDash(setting.stored), orDash(setting.engine))
+	}
+
+	return tabWriter.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
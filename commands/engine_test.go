@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/mcndockerclient"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetEngineOptions(t *testing.T) {
+	current := engine.Options{
+		Labels:           []string{"env=dev", "team=web", "tier=front"},
+		RegistryMirror:   []string{"https://mirror1.local"},
+		InsecureRegistry: []string{"registry.local:5000"},
+		LogDriver:        "json-file",
+		LogOpts:          []string{"max-size=10m"},
+	}
+
+	options := setEngineOptions(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"label":                   []string{"env=prod", "zone=a"},
+				"unset-label":             []string{"tier"},
+				"registry-mirror":         []string{"https://mirror2.local", "https://mirror1.local"},
+				"unset-insecure-registry": []string{"registry.local:5000"},
+				"log-opt":                 []string{"max-file=3"},
+				"unset-log-opt":           []string{"max-size"},
+			},
+		},
+	}, current)
+
+	assert.Equal(t, engine.Options{
+		Labels:           []string{"env=prod", "team=web", "zone=a"},
+		RegistryMirror:   []string{"https://mirror1.local", "https://mirror2.local"},
+		InsecureRegistry: []string{},
+		LogDriver:        "json-file",
+		LogOpts:          []string{"max-file=3"},
+	}, options)
+	// The stored options are left as they are until they're applied.
+	assert.Equal(t, []string{"env=dev", "team=web", "tier=front"}, current.Labels)
+}
+
+func TestCmdEngineSetErrors(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{{Name: "dev", Driver: &fakedriver.Driver{MockState: state.Running}}},
+	}
+
+	assert.Equal(t, ErrExpectedOneMachine, cmdEngineSet(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{"label": []string{"a=b"}}},
+	}, api))
+	assert.Equal(t, errEngineSetNoChange, cmdEngineSet(&commandstest.FakeCommandLine{
+		CliArgs:    []string{"dev"},
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+	}, api))
+	assert.EqualError(t, cmdEngineSet(&commandstest.FakeCommandLine{
+		CliArgs:    []string{"dev"},
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{"log-opt": []string{"max-size"}}},
+	}, api), `Invalid log option "max-size", expected key=value`)
+}
+
+func TestPrintEngineConfig(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	err := printEngineConfig(buf, engine.Options{
+		Labels:        []string{"env=prod"},
+		StorageDriver: "overlay2",
+	}, mcndockerclient.EngineInfo{
+		Labels:        []string{"env=dev", "provider=virtualbox"},
+		StorageDriver: "overlay2",
+		LoggingDriver: "json-file",
+		CgroupDriver:  "cgroupfs",
+		DataRoot:      "/var/lib/docker",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `SETTING               STORED     ENGINE
+labels                env=prod   env=dev,provider=virtualbox
+registry-mirrors      -          -
+insecure-registries   -          -
+storage-driver        overlay2   overlay2
+log-driver            -          json-file
+cgroup-driver         -          cgroupfs
+live-restore          false      false
+data-root             -          /var/lib/docker
+`, buf.String())
+}
+
+func TestCmdEngineShowError(t *testing.T) {
+	defer func(previous mcndockerclient.EngineInfoer) { mcndockerclient.CurrentEngineInfoer = previous }(mcndockerclient.CurrentEngineInfoer)
+	mcndockerclient.CurrentEngineInfoer = &mcndockerclient.FakeEngineInfoer{Err: errors.New("Unable to query the engine info: connection refused")}
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{{Name: "dev", Driver: &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.1"}}},
+	}
+
+	err := cmdEngineShow(&commandstest.FakeCommandLine{CliArgs: []string{"dev"}}, api)
+
+	assert.EqualError(t, err, "Unable to query the engine info: connection refused")
+}
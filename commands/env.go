@@ -6,12 +6,13 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"text/template"
+	"time"
 
 	"github.com/docker/machine/commands/mcndirs"
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/check"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/shell"
 )
 
@@ -21,6 +22,8 @@ const (
 
 var (
 	errImproperUnsetEnvArgs = errors.New("Error: Expected no machine name when the -u flag is present")
+	errRepairWithoutSwarm   = errors.New("Error: The --repair flag can only be used with --swarm")
+	errFormatWithShell      = errors.New("Error: The --format and --shell flags can't be used together")
 	defaultUsageHinter      UsageHintGenerator
 )
 
@@ -51,7 +54,8 @@ func cmdEnv(c CommandLine, api libmachine.API) error {
 	// being run (it is intended to be run in a subshell)
 	log.SetOutWriter(os.Stderr)
 
-	if c.Bool("unset") {
+	unset := c.Bool("unset")
+	if unset {
 		shellCfg, err = shellCfgUnset(c, api)
 		if err != nil {
 			return err
@@ -63,7 +67,12 @@ func cmdEnv(c CommandLine, api libmachine.API) error {
 		}
 	}
 
-	return executeTemplateStdout(shellCfg)
+	_, format, err := lookupEnvFormat(c)
+	if err != nil {
+		return err
+	}
+
+	return format.execute(os.Stdout, shellCfg, unset)
 }
 
 func shellCfgSet(c CommandLine, api libmachine.API) (*ShellConfig, error) {
@@ -81,17 +90,38 @@ func shellCfgSet(c CommandLine, api libmachine.API) (*ShellConfig, error) {
 		return nil, err
 	}
 
+	repaired := false
+	if c.Bool("repair") {
+		if !c.Bool("swarm") {
+			return nil, errRepairWithoutSwarm
+		}
+
+		if repaired, err = swarmDoctor(host); err != nil {
+			return nil, fmt.Errorf("Error repairing the Swarm containers: %s", err)
+		}
+	}
+
 	dockerHost, _, err := check.DefaultConnChecker.Check(host, c.Bool("swarm"))
+	if err == check.ErrSwarmNotStarted && repaired {
+		// The recreated master takes a moment to listen.
+		mcnutils.WaitForSpecific(func() bool {
+			dockerHost, _, err = check.DefaultConnChecker.Check(host, true)
+			return err != check.ErrSwarmNotStarted
+		}, 10, time.Second)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Error checking TLS connection: %s", err)
 	}
 
-	userShell, err := getShell(c.String("shell"))
+	userShell, format, err := lookupEnvFormat(c)
 	if err != nil {
 		return nil, err
 	}
 
 	shellCfg := &ShellConfig{
+		Prefix:          format.Set.Prefix,
+		Delimiter:       format.Set.Delimiter,
+		Suffix:          format.Set.Suffix,
 		DockerCertPath:  filepath.Join(mcndirs.GetMachineDir(), host.Name),
 		DockerHost:      dockerHost,
 		DockerTLSVerify: "1",
@@ -121,33 +151,6 @@ func shellCfgSet(c CommandLine, api libmachine.API) (*ShellConfig, error) {
 		shellCfg.NoProxyValue = noProxyValue
 	}
 
-	switch userShell {
-	case "fish":
-		shellCfg.Prefix = "set -gx "
-		shellCfg.Suffix = "\";\n"
-		shellCfg.Delimiter = " \""
-	case "powershell":
-		shellCfg.Prefix = "$Env:"
-		shellCfg.Suffix = "\"\n"
-		shellCfg.Delimiter = " = \""
-	case "cmd":
-		shellCfg.Prefix = "SET "
-		shellCfg.Suffix = "\n"
-		shellCfg.Delimiter = "="
-	case "tcsh":
-		shellCfg.Prefix = "setenv "
-		shellCfg.Suffix = "\";\n"
-		shellCfg.Delimiter = " \""
-	case "emacs":
-		shellCfg.Prefix = "(setenv \""
-		shellCfg.Suffix = "\")\n"
-		shellCfg.Delimiter = "\" \""
-	default:
-		shellCfg.Prefix = "export "
-		shellCfg.Suffix = "\"\n"
-		shellCfg.Delimiter = "=\""
-	}
-
 	return shellCfg, nil
 }
 
@@ -156,12 +159,15 @@ func shellCfgUnset(c CommandLine, api libmachine.API) (*ShellConfig, error) {
 		return nil, errImproperUnsetEnvArgs
 	}
 
-	userShell, err := getShell(c.String("shell"))
+	userShell, format, err := lookupEnvFormat(c)
 	if err != nil {
 		return nil, err
 	}
 
 	shellCfg := &ShellConfig{
+		Prefix:    format.Unset.Prefix,
+		Delimiter: format.Unset.Delimiter,
+		Suffix:    format.Unset.Suffix,
 		UsageHint: defaultUsageHinter.GenerateUsageHint(userShell, os.Args),
 	}
 
@@ -169,46 +175,9 @@ func shellCfgUnset(c CommandLine, api libmachine.API) (*ShellConfig, error) {
 		shellCfg.NoProxyVar, shellCfg.NoProxyValue = findNoProxyFromEnv()
 	}
 
-	switch userShell {
-	case "fish":
-		shellCfg.Prefix = "set -e "
-		shellCfg.Suffix = ";\n"
-		shellCfg.Delimiter = ""
-	case "powershell":
-		shellCfg.Prefix = `Remove-Item Env:\\`
-		shellCfg.Suffix = "\n"
-		shellCfg.Delimiter = ""
-	case "cmd":
-		shellCfg.Prefix = "SET "
-		shellCfg.Suffix = "\n"
-		shellCfg.Delimiter = "="
-	case "emacs":
-		shellCfg.Prefix = "(setenv \""
-		shellCfg.Suffix = ")\n"
-		shellCfg.Delimiter = "\" nil"
-	case "tcsh":
-		shellCfg.Prefix = "unsetenv "
-		shellCfg.Suffix = ";\n"
-		shellCfg.Delimiter = ""
-	default:
-		shellCfg.Prefix = "unset "
-		shellCfg.Suffix = "\n"
-		shellCfg.Delimiter = ""
-	}
-
 	return shellCfg, nil
 }
 
-func executeTemplateStdout(shellCfg *ShellConfig) error {
-	t := template.New("envConfig")
-	tmpl, err := t.Parse(envTmpl)
-	if err != nil {
-		return err
-	}
-
-	return tmpl.Execute(os.Stdout, shellCfg)
-}
-
 func getShell(userShell string) (string, error) {
 	if userShell != "" {
 		return userShell, nil
@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// envFraming frames each variable in envTmpl.
+type envFraming struct {
+	Prefix    string
+	Delimiter string
+	Suffix    string
+}
+
+// envFormat is an output format of env, and of config with --format.
+type envFormat struct {
+	// Set and Unset frame the variables in envTmpl, for the shells.
+	Set   envFraming
+	Unset envFraming
+	// SetTemplate and UnsetTemplate replace envTmpl for the formats which
+	// aren't shells. They render an envTemplateData.
+	SetTemplate   string
+	UnsetTemplate string
+}
+
+// envFormats are the output formats of env and config, by name. A format
+// which isn't here is read as a Go template.
+var envFormats = map[string]*envFormat{
+	"bash": {
+		Set:   envFraming{Prefix: "export ", Delimiter: "=\"", Suffix: "\"\n"},
+		Unset: envFraming{Prefix: "unset ", Suffix: "\n"},
+	},
+	"fish": {
+		Set:   envFraming{Prefix: "set -gx ", Delimiter: " \"", Suffix: "\";\n"},
+		Unset: envFraming{Prefix: "set -e ", Suffix: ";\n"},
+	},
+	"powershell": {
+		Set:   envFraming{Prefix: "$Env:", Delimiter: " = \"", Suffix: "\"\n"},
+		Unset: envFraming{Prefix: `Remove-Item Env:\\`, Suffix: "\n"},
+	},
+	"cmd": {
+		Set:   envFraming{Prefix: "SET ", Delimiter: "=", Suffix: "\n"},
+		Unset: envFraming{Prefix: "SET ", Delimiter: "=", Suffix: "\n"},
+	},
+	"tcsh": {
+		Set:   envFraming{Prefix: "setenv ", Delimiter: " \"", Suffix: "\";\n"},
+		Unset: envFraming{Prefix: "unsetenv ", Suffix: ";\n"},
+	},
+	"emacs": {
+		Set:   envFraming{Prefix: "(setenv \"", Delimiter: "\" \"", Suffix: "\")\n"},
+		Unset: envFraming{Prefix: "(setenv \"", Delimiter: "\" nil", Suffix: ")\n"},
+	},
+	"json": {
+		SetTemplate:   "{{ json .Env }}\n",
+		UnsetTemplate: "{{ json .Env }}\n",
+	},
+	"dotenv": {
+		SetTemplate:   "{{ range .Vars }}{{ .Name }}={{ .Value }}\n{{ end }}",
+		UnsetTemplate: "{{ range .Vars }}{{ .Name }}=\n{{ end }}",
+	},
+	"direnv": {
+		SetTemplate:   "watch_file {{ shellquote .ConfigPath }}\n{{ range .Vars }}export {{ .Name }}={{ shellquote .Value }}\n{{ end }}",
+		UnsetTemplate: "{{ range .Vars }}unset {{ .Name }}\n{{ end }}",
+	},
+	"nushell": {
+		SetTemplate:   "{{ range .Vars }}$env.{{ .Name }} = {{ quote .Value }}\n{{ end }}",
+		UnsetTemplate: "{{ range .Vars }}hide-env {{ .Name }}\n{{ end }}",
+	},
+	"make": {
+		SetTemplate:   "{{ range .Vars }}export {{ .Name }} := {{ .Value }}\n{{ end }}",
+		UnsetTemplate: "{{ range .Vars }}unexport {{ .Name }}\n{{ end }}",
+	},
+}
+
+var envTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		content, err := json.Marshal(v)
+		return string(content), err
+	},
+	"quote":      strconv.Quote,
+	"shellquote": shellQuote,
+}
+
+// envVar is a variable of the environment of a machine.
+type envVar struct {
+	Name  string
+	Value string
+}
+
+// envTemplateData is what the templates of the formats render: the shell
+// config, along with its variables in order and as a map.
+type envTemplateData struct {
+	*ShellConfig
+	Unset bool
+	// ConfigPath is the config file of the machine, which direnv watches.
+	ConfigPath string
+	Vars       []envVar
+	// Env maps the variables to their value, or to nil when they're unset.
+	Env map[string]interface{}
+}
+
+func newEnvTemplateData(shellCfg *ShellConfig, unset bool) *envTemplateData {
+	data := &envTemplateData{
+		ShellConfig: shellCfg,
+		Unset:       unset,
+		Vars: []envVar{
+			{"DOCKER_TLS_VERIFY", shellCfg.DockerTLSVerify},
+			{"DOCKER_HOST", shellCfg.DockerHost},
+			{"DOCKER_CERT_PATH", shellCfg.DockerCertPath},
+			{"DOCKER_MACHINE_NAME", shellCfg.MachineName},
+		},
+		Env: map[string]interface{}{},
+	}
+
+	if shellCfg.DockerCertPath != "" {
+		data.ConfigPath = filepath.Join(shellCfg.DockerCertPath, "config.json")
+	}
+
+	if shellCfg.NoProxyVar != "" {
+		data.Vars = append(data.Vars, envVar{shellCfg.NoProxyVar, shellCfg.NoProxyValue})
+	}
+
+	for _, v := range data.Vars {
+		if unset {
+			data.Env[v.Name] = nil
+		} else {
+			data.Env[v.Name] = v.Value
+		}
+	}
+
+	return data
+}
+
+// lookupEnvFormat returns the format given with --format, or the one of the
+// shell given with --shell or detected. Unknown shells get the bash format.
+func lookupEnvFormat(c CommandLine) (string, *envFormat, error) {
+	name := c.String("format")
+	if name != "" {
+		if c.String("shell") != "" {
+			return "", nil, errFormatWithShell
+		}
+
+		if strings.Contains(name, "{{") {
+			return name, &envFormat{SetTemplate: name, UnsetTemplate: name}, nil
+		}
+
+		format, ok := envFormats[name]
+		if !ok {
+			return "", nil, fmt.Errorf("Error: Unknown format %q, expected a Go template or one of %s", name, strings.Join(envFormatNames(), ", "))
+		}
+
+		return name, format, nil
+	}
+
+	userShell, err := getShell(c.String("shell"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	format, ok := envFormats[userShell]
+	if !ok {
+		format = envFormats["bash"]
+	}
+
+	return userShell, format, nil
+}
+
+func envFormatNames() []string {
+	names := []string{}
+	for name := range envFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// execute renders the shell config in the format to w.
+func (f *envFormat) execute(w io.Writer, shellCfg *ShellConfig, unset bool) error {
+	text := f.SetTemplate
+	if unset {
+		text = f.UnsetTemplate
+	}
+	if text == "" {
+		text = envTmpl
+	}
+
+	tmpl, err := template.New("envConfig").Funcs(envTemplateFuncs).Parse(text)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, newEnvTemplateData(shellCfg, unset))
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/check"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// renderEnvFormat renders the env of a swarm machine with --no-proxy in the
+// format.
+func renderEnvFormat(t *testing.T, format string, unset bool) string {
+	defer func(previous string) { mcndirs.BaseDir = previous }(mcndirs.BaseDir)
+	mcndirs.BaseDir = "/machine"
+	defer func(previous check.ConnChecker) { check.DefaultConnChecker = previous }(check.DefaultConnChecker)
+	check.DefaultConnChecker = &FakeConnChecker{DockerHost: "tcp://1.2.3.4:3376"}
+	defer os.Unsetenv("NO_PROXY")
+	os.Setenv("NO_PROXY", "example.com")
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"quux"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"format":   format,
+				"swarm":    true,
+				"no-proxy": true,
+			},
+		},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name: "quux",
+				Driver: &fakedriver.Driver{
+					MockState: state.Running,
+					MockIP:    "1.2.3.4",
+				},
+			},
+		},
+	}
+
+	var (
+		shellCfg *ShellConfig
+		err      error
+	)
+	if unset {
+		commandLine.CliArgs = nil
+		shellCfg, err = shellCfgUnset(commandLine, api)
+	} else {
+		shellCfg, err = shellCfgSet(commandLine, api)
+	}
+	assert.NoError(t, err)
+
+	_, envFormat, err := lookupEnvFormat(commandLine)
+	assert.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, envFormat.execute(out, shellCfg, unset))
+
+	return out.String()
+}
+
+func TestEnvFormatsGolden(t *testing.T) {
+	for _, format := range []string{"json", "dotenv", "direnv", "nushell", "make"} {
+		for _, unset := range []bool{false, true} {
+			golden := filepath.Join("testdata", "env", format+".golden")
+			if unset {
+				golden = filepath.Join("testdata", "env", format+"-unset.golden")
+			}
+
+			expected, err := ioutil.ReadFile(golden)
+			assert.NoError(t, err)
+
+			assert.Equal(t, string(expected), renderEnvFormat(t, format, unset), golden)
+		}
+	}
+}
+
+func TestEnvFormatTemplate(t *testing.T) {
+	format := `{{ .MachineName }} {{ .DockerHost }}{{ range .Vars }} {{ .Name }}{{ end }}`
+
+	out := renderEnvFormat(t, format, false)
+
+	assert.Equal(t, "quux tcp://1.2.3.4:3376 DOCKER_TLS_VERIFY DOCKER_HOST DOCKER_CERT_PATH DOCKER_MACHINE_NAME NO_PROXY", out)
+}
+
+func TestLookupEnvFormatErrors(t *testing.T) {
+	for _, test := range []struct {
+		format   string
+		shell    string
+		expected string
+	}{
+		{"yaml", "", fmt.Sprintf("Error: Unknown format %q, expected a Go template or one of bash, cmd, direnv, dotenv, emacs, fish, json, make, nushell, powershell, tcsh", "yaml")},
+		{"json", "bash", errFormatWithShell.Error()},
+	} {
+		commandLine := &commandstest.FakeCommandLine{
+			LocalFlags: &commandstest.FakeFlagger{
+				Data: map[string]interface{}{
+					"format": test.format,
+					"shell":  test.shell,
+				},
+			},
+		}
+
+		_, _, err := lookupEnvFormat(commandLine)
+
+		assert.EqualError(t, err, test.expected)
+	}
+}
+
+func TestLookupEnvFormatUnknownShell(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"shell": "zsh"},
+		},
+	}
+
+	name, format, err := lookupEnvFormat(commandLine)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "zsh", name)
+	assert.Equal(t, envFormats["bash"], format)
+}
@@ -430,6 +430,28 @@ func TestShellCfgSet(t *testing.T) {
 			noProxyValue: "192.168.59.1",
 			expectedErr:  nil,
 		},
+		{
+			description: "repair without swarm",
+			commandLine: &commandstest.FakeCommandLine{
+				CliArgs: []string{"quux"},
+				LocalFlags: &commandstest.FakeFlagger{
+					Data: map[string]interface{}{
+						"shell":  "bash",
+						"swarm":  false,
+						"repair": true,
+					},
+				},
+			},
+			api: &libmachinetest.FakeAPI{
+				Hosts: []*host.Host{
+					{
+						Name: "quux",
+					},
+				},
+			},
+			expectedShellCfg: nil,
+			expectedErr:      errRepairWithoutSwarm,
+		},
 	}
 
 	for _, test := range tests {
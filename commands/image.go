@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/docker/go-units"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcndockerclient"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+var errImagePushArgs = errors.New("Error: Expected the machine names, comma-separated, and one or more images as arguments")
+
+// cmdImagePush saves the images from the local engine once, and loads them
+// in the engines of the machines in parallel.
+func cmdImagePush(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) < 2 {
+		return errImagePushArgs
+	}
+
+	names := strings.Split(c.Args()[0], ",")
+	images := c.Args()[1:]
+
+	hosts, hostsInError := persist.LoadHosts(api, names)
+	if len(hostsInError) > 0 {
+		errs := []error{}
+		for _, err := range hostsInError {
+			errs = append(errs, err)
+		}
+		return consolidateErrs(errs)
+	}
+
+	file, err := ioutil.TempFile("", "docker-machine-images-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	log.Infof("Saving %s from the local engine...", strings.Join(images, ", "))
+	err = mcndockerclient.SaveImages(mcndockerclient.LocalDockerHost(), images, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	archive, err := mcndockerclient.ReadImageArchive(file.Name())
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		errs []error
+	)
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(h *host.Host) {
+			defer wg.Done()
+
+			if err := pushImageArchive(h, archive); err != nil {
+				lock.Lock()
+				errs = append(errs, fmt.Errorf("%s: %s", h.Name, err))
+				lock.Unlock()
+			}
+		}(h)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	return nil
+}
+
+// pushImageArchive loads the images in the engine of the machine, logging
+// the progress every tenth of the way.
+func pushImageArchive(h *host.Host, archive *mcndockerclient.ImageArchive) error {
+	logged := 0
+	skipped, err := archive.Push(h, func(sent, total int64) {
+		if total <= 0 {
+			return
+		}
+
+		percent := int(sent * 100 / total)
+		if percent > 100 {
+			percent = 100
+		}
+		if percent/10 > logged/10 {
+			logged = percent
+			log.Infof("%s: sent %d%% of %s", h.Name, percent, units.HumanSize(float64(total)))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if skipped > 0 {
+		log.Infof("%s: images loaded, %d layer(s) were already there", h.Name, skipped)
+	} else {
+		log.Infof("%s: images loaded", h.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"sort"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/mcndockerclient"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// newImageArchive returns the tarball docker save writes for a base image of
+// one layer and an app image adding another one.
+func newImageArchive(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	writer := tar.NewWriter(buf)
+
+	for _, file := range []struct {
+		name    string
+		content string
+	}{
+		{"l1/layer.tar", "base layer"},
+		{"l2/layer.tar", "app layer"},
+		{"base.json", `{"rootfs":{"type":"layers","diff_ids":["sha256:d1"]}}`},
+		{"app.json", `{"rootfs":{"type":"layers","diff_ids":["sha256:d1","sha256:d2"]}}`},
+		{"manifest.json", `[{"Config":"base.json","Layers":["l1/layer.tar"]},{"Config":"app.json","Layers":["l1/layer.tar","l2/layer.tar"]}]`},
+	} {
+		assert.NoError(t, writer.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content))}))
+		_, err := writer.Write([]byte(file.content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, writer.Close())
+
+	return buf.Bytes()
+}
+
+func archiveFiles(t *testing.T, content []byte) []string {
+	names := []string{}
+
+	reader := tar.NewReader(bytes.NewReader(content))
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestCmdImagePush(t *testing.T) {
+	imager := &mcndockerclient.FakeImager{
+		Saved: newImageArchive(t),
+		Layers: map[string][][]string{
+			"tcp://10.0.0.1:2376": {{"sha256:d1"}},
+			"tcp://10.0.0.2:2376": {{"sha256:d0"}},
+		},
+	}
+	defer func(previous mcndockerclient.Imager) { mcndockerclient.CurrentImager = previous }(mcndockerclient.CurrentImager)
+	mcndockerclient.CurrentImager = imager
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "dev", Driver: &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.1"}},
+			{Name: "prod", Driver: &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.2"}},
+		},
+	}
+
+	err := cmdImagePush(&commandstest.FakeCommandLine{CliArgs: []string{"dev,prod", "app"}}, api)
+
+	assert.NoError(t, err)
+	// dev has the base layer already.
+	assert.Equal(t, []string{"app.json", "base.json", "l2/layer.tar", "manifest.json"}, archiveFiles(t, imager.Loaded["tcp://10.0.0.1:2376"]))
+	assert.Equal(t, []string{"app.json", "base.json", "l1/layer.tar", "l2/layer.tar", "manifest.json"}, archiveFiles(t, imager.Loaded["tcp://10.0.0.2:2376"]))
+}
+
+func TestCmdImagePushErrors(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "dev", Driver: &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.1"}},
+		},
+	}
+
+	assert.Equal(t, errImagePushArgs, cmdImagePush(&commandstest.FakeCommandLine{CliArgs: []string{"dev"}}, api))
+	assert.EqualError(t, cmdImagePush(&commandstest.FakeCommandLine{CliArgs: []string{"dev,gone", "app"}}, api), `Host does not exist: "gone"`)
+}
@@ -28,7 +28,7 @@ import (
 const (
 	lsDefaultTimeout = 10
 	tableFormatKey   = "table"
-	lsDefaultFormat  = "table {{ .Name }}\t{{ .Active }}\t{{ .DriverName}}\t{{ .State }}\t{{ .URL }}\t{{ .Swarm }}\t{{ .DockerVersion }}\t{{ .Error}}"
+	lsDefaultFormat  = "table {{ .Name }}\t{{ .Active }}\t{{ .DriverName}}\t{{ .State }}\t{{ .URL }}\t{{ .Swarm }}\t{{ .SwarmMode }}\t{{ .DockerVersion }}\t{{ .Error}}"
 )
 
 var (
@@ -42,6 +42,7 @@ var (
 		"URL":           "URL",
 		"SwarmOptions":  "SWARM_OPTIONS",
 		"Swarm":         "SWARM",
+		"SwarmMode":     "SWARM_MODE",
 		"EngineOptions": "ENGINE_OPTIONS",
 		"Error":         "ERRORS",
 		"DockerVersion": "DOCKER",
@@ -59,6 +60,7 @@ type HostListItem struct {
 	URL           string
 	SwarmOptions  *swarm.Options
 	Swarm         string
+	SwarmMode     string
 	EngineOptions *engine.Options
 	Error         string
 	DockerVersion string
@@ -408,6 +410,7 @@ func attemptGetHostState(h *host.Host, stateQueryChan chan<- HostListItem) {
 		State:         currentState,
 		URL:           url,
 		SwarmOptions:  swarmOptions,
+		SwarmMode:     h.SwarmModeRole(),
 		EngineOptions: engineOptions,
 		DockerVersion: dockerVersion,
 		Error:         hostError,
@@ -14,6 +14,7 @@ import (
 	"github.com/docker/machine/libmachine/mcndockerclient"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/libmachine/swarmmode"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -494,6 +495,29 @@ func TestGetHostStateError(t *testing.T) {
 	assert.Nil(t, hostItem.SwarmOptions)
 }
 
+func TestGetHostStateSwarmMode(t *testing.T) {
+	defer func(versioner mcndockerclient.DockerVersioner) { mcndockerclient.CurrentDockerVersioner = versioner }(mcndockerclient.CurrentDockerVersioner)
+	mcndockerclient.CurrentDockerVersioner = &mcndockerclient.FakeDockerVersioner{Version: "17.03.1-ce"}
+
+	hosts := []*host.Host{
+		{
+			Name: "worker1",
+			Driver: &fakedriver.Driver{
+				MockState: state.Running,
+				MockIP:    "10.0.0.2",
+			},
+			HostOptions: &host.Options{
+				SwarmModeOptions: &swarmmode.Options{Role: swarmmode.RoleWorker, Join: "manager1"},
+			},
+		},
+	}
+
+	hostItem := getHostListItems(hosts, nil, 10*time.Second)[0]
+
+	assert.Equal(t, "worker1", hostItem.Name)
+	assert.Equal(t, "worker", hostItem.SwarmMode)
+}
+
 func TestGetSomeHostInError(t *testing.T) {
 	defer func(versioner mcndockerclient.DockerVersioner) { mcndockerclient.CurrentDockerVersioner = versioner }(mcndockerclient.CurrentDockerVersioner)
 	mcndockerclient.CurrentDockerVersioner = &mcndockerclient.FakeDockerVersioner{Version: "1.9"}
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/provision"
+)
+
+// printHostPlans prints the plan of each host, with the errors of the hosts
+// which couldn't be planned.
+func printHostPlans(c CommandLine, api libmachine.API, prepare func(*host.Host), plan func(*host.Host) (*provision.Plan, error)) error {
+	hosts, err := loadActionHosts(c, api)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, h := range hosts {
+		prepare(h)
+
+		p, err := plan(h)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Error planning %s: %s", h.Name, err))
+			continue
+		}
+
+		if err := printPlan(os.Stdout, h.Name, p); err != nil {
+			return err
+		}
+	}
+
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	return nil
+}
+
+// printPlan prints what would be done to the machine, with the diff of the
+// configuration files.
+func printPlan(w io.Writer, name string, plan *provision.Plan) error {
+	fmt.Fprintf(w, "Machine: %s\n", name)
+	fmt.Fprintf(w, "Driver: %s\n", plan.Driver)
+	fmt.Fprintf(w, "Provisioner: %s\n", plan.Provisioner)
+
+	if plan.Packages != nil {
+		fmt.Fprintf(w, "Packages to install: %s\n", listOrNone(plan.Packages))
+	}
+	fmt.Fprintf(w, "Engine: %s\n", plan.Engine)
+
+	hooks := []string{}
+	for _, hook := range plan.Hooks {
+		hooks = append(hooks, hook.Phase+" "+hook.Name)
+	}
+	fmt.Fprintf(w, "Provisioning hooks: %s\n", listOrNone(hooks))
+
+	fmt.Fprintf(w, "Server cert: %s", plan.ServerCert.Path)
+	if plan.ServerCert.RemotePath != "" {
+		fmt.Fprintf(w, " -> %s", plan.ServerCert.RemotePath)
+	}
+	fmt.Fprintf(w, "\n    SANs: %s\n", strings.Join(plan.ServerCert.SANs, ", "))
+
+	if plan.Files == nil {
+		fmt.Fprintln(w, "Engine configuration: rendered once the OS of the machine is known")
+	} else {
+		fmt.Fprintln(w, "Engine configuration:")
+	}
+	for _, file := range plan.Files {
+		if !file.Changed() {
+			fmt.Fprintf(w, "    %s: unchanged\n", file.Path)
+			continue
+		}
+
+		diff, err := file.Diff()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(w, diff)
+		if !strings.HasSuffix(diff, "\n") {
+			fmt.Fprintln(w)
+		}
+	}
+
+	if len(plan.SwarmContainers) == 0 {
+		fmt.Fprintln(w, "Swarm containers to create: none")
+	} else {
+		fmt.Fprintln(w, "Swarm containers to create:")
+	}
+	for _, container := range plan.SwarmContainers {
+		fmt.Fprintf(w, "    %s (%s)", container.Name, container.Image)
+		if len(container.Cmd) > 0 {
+			fmt.Fprintf(w, ": %s", strings.Join(container.Cmd, " "))
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w)
+	return nil
+}
+
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintPlan(t *testing.T) {
+	plan := &provision.Plan{
+		Driver:      "virtualbox",
+		Provisioner: "ubuntu(systemd)",
+		Packages:    []string{"curl"},
+		Engine:      "17.03.1-ce, already installed",
+		Hooks:       []provision.Hook{{Phase: provision.HookPost, Name: "users.sh"}},
+		ServerCert: provision.PlannedCert{
+			Path:       "/machines/dev/server.pem",
+			RemotePath: "/etc/docker/server.pem",
+			SANs:       []string{"10.0.0.12", "localhost"},
+		},
+		Files: []provision.PlannedFile{
+			{Path: "/etc/docker/daemon.json", Current: "{}\n", Content: "{\"debug\": true}\n"},
+			{Path: "/etc/default/docker", Current: "DOCKER_OPTS=''\n", Content: "DOCKER_OPTS=''\n"},
+		},
+		SwarmContainers: []provision.PlannedContainer{
+			{Name: "swarm-agent", Image: "swarm:latest", Cmd: []string{"join", "token://abc"}},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	err := printPlan(buf, "dev", plan)
+
+	assert.NoError(t, err)
+	out := buf.String()
+	assert.Contains(t, out, "Machine: dev\n")
+	assert.Contains(t, out, "Driver: virtualbox\n")
+	assert.Contains(t, out, "Provisioner: ubuntu(systemd)\n")
+	assert.Contains(t, out, "Packages to install: curl\n")
+	assert.Contains(t, out, "Provisioning hooks: post users.sh\n")
+	assert.Contains(t, out, "Server cert: /machines/dev/server.pem -> /etc/docker/server.pem\n    SANs: 10.0.0.12, localhost\n")
+	assert.Contains(t, out, "--- /etc/docker/daemon.json (current)\n")
+	assert.Contains(t, out, "+{\"debug\": true}\n")
+	assert.Contains(t, out, "    /etc/default/docker: unchanged\n")
+	assert.Contains(t, out, "    swarm-agent (swarm:latest): join token://abc\n")
+}
+
+func TestPrintPlanBeforeCreation(t *testing.T) {
+	plan := provision.PlanCreation("virtualbox", "", nil, swarm.Options{}, auth.Options{
+		ServerCertPath: "/machines/dev/server.pem",
+	}, engine.Options{
+		InstallURL: "https://get.docker.com",
+	})
+
+	buf := &bytes.Buffer{}
+	err := printPlan(buf, "dev", plan)
+
+	assert.NoError(t, err)
+	out := buf.String()
+	assert.Contains(t, out, "Provisioner: detected once the machine is created\n")
+	assert.NotContains(t, out, "Packages to install")
+	assert.Contains(t, out, "Engine: latest version, installed with https://get.docker.com unless the OS ships the engine\n")
+	assert.Contains(t, out, "SANs: <machine IP>, localhost\n")
+	assert.Contains(t, out, "Engine configuration: rendered once the OS of the machine is known\n")
+	assert.Contains(t, out, "Swarm containers to create: none\n")
+}
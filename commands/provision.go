@@ -1,7 +1,100 @@
 package commands
 
-import "github.com/docker/machine/libmachine"
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/provision"
+)
 
 func cmdProvision(c CommandLine, api libmachine.API) error {
-	return runAction("provision", c, api)
+	provisionerName := c.String("provisioner")
+	if provisionerName != "" {
+		if err := provision.ValidateProvisionerName(provisionerName); err != nil {
+			return err
+		}
+	}
+
+	provisionHooks, err := readProvisionHooks(c.StringSlice("provision-hook"))
+	if err != nil {
+		return err
+	}
+
+	prepare := func(h *host.Host) {
+		if provisionerName != "" {
+			h.HostOptions.Provisioner = provisionerName
+		}
+		if len(provisionHooks) > 0 {
+			h.HostOptions.ProvisionHooks = provisionHooks
+		}
+	}
+
+	if c.Bool("dry-run") {
+		return printHostPlans(c, api, prepare, (*host.Host).PlanProvision)
+	}
+
+	hosts, err := loadActionHosts(c, api)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hosts {
+		prepare(h)
+	}
+
+	action := "provision"
+	if c.Bool("resume") {
+		action = "resumeProvision"
+	}
+
+	errs := runActionForeachMachine(action, hosts)
+
+	// The hosts are saved even when their provisioning failed, with the last
+	// step it completed, so that it can be resumed.
+	for _, h := range hosts {
+		if err := api.Save(h); err != nil {
+			return fmt.Errorf("Error saving host to store: %s", err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	return nil
+}
+
+// readProvisionHooks reads the scripts of the --provision-hook flags, given
+// as phase=file.
+func readProvisionHooks(values []string) ([]provision.Hook, error) {
+	hooks := []provision.Hook{}
+
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid provisioning hook %q, expected phase=file", value)
+		}
+
+		phase, path := parts[0], parts[1]
+		if err := provision.ValidateHookPhase(phase); err != nil {
+			return nil, err
+		}
+
+		script, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the %s provisioning hook: %s", phase, err)
+		}
+
+		hooks = append(hooks, provision.Hook{
+			Phase:  phase,
+			Name:   filepath.Base(path),
+			Script: string(script),
+		})
+	}
+
+	return hooks, nil
 }
@@ -1,6 +1,9 @@
 package commands
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/docker/machine/commands/commandstest"
@@ -65,3 +68,55 @@ func TestCmdProvision(t *testing.T) {
 		assert.Equal(t, tc.expectedErr, cmdProvision(tc.commandLine, tc.api))
 	}
 }
+
+func TestCmdProvisionUnknownProvisioner(t *testing.T) {
+	h := &host.Host{
+		Name:        "foo",
+		Driver:      &fakedriver.Driver{},
+		HostOptions: &host.Options{},
+	}
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"foo"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"provisioner": "Nope",
+			},
+		},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{h},
+	}
+
+	err := cmdProvision(commandLine, api)
+
+	assert.Contains(t, err.Error(), `Unknown provisioner "Nope"`)
+	assert.Empty(t, h.HostOptions.Provisioner)
+}
+
+func TestReadProvisionHooks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "proxy.sh")
+	assert.NoError(t, ioutil.WriteFile(script, []byte("echo proxy"), 0644))
+
+	hooks, err := readProvisionHooks([]string{"pre=" + script, "post=" + script})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []provision.Hook{
+		{Phase: "pre", Name: "proxy.sh", Script: "echo proxy"},
+		{Phase: "post", Name: "proxy.sh", Script: "echo proxy"},
+	}, hooks)
+}
+
+func TestReadProvisionHooksErrors(t *testing.T) {
+	_, err := readProvisionHooks([]string{"pre"})
+	assert.EqualError(t, err, `Invalid provisioning hook "pre", expected phase=file`)
+
+	_, err = readProvisionHooks([]string{"during=hook.sh"})
+	assert.EqualError(t, err, `Unknown provisioning hook phase "during", expected one of: pre, post`)
+
+	_, err = readProvisionHooks([]string{"post=/does/not/exist.sh"})
+	assert.Contains(t, err.Error(), "Error reading the post provisioning hook")
+}
@@ -2,10 +2,15 @@ package commands
 
 import (
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
 )
 
 func cmdRegenerateCerts(c CommandLine, api libmachine.API) error {
+	if c.Bool("dry-run") {
+		return printHostPlans(c, api, func(*host.Host) {}, (*host.Host).PlanConfigureAuth)
+	}
+
 	if !c.Bool("force") {
 		ok, err := confirmInput("Regenerate TLS machine certs?  Warning: this is irreversible.")
 		if err != nil {
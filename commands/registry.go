@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+// cmdRegistrySync installs the registry CA certs and credentials of the
+// machines again, reading the credentials from their sources, all the
+// machines with registry options when none is given.
+func cmdRegistrySync(c CommandLine, api libmachine.API) error {
+	var hosts []*host.Host
+	if len(c.Args()) > 0 {
+		loaded, hostsInError := persist.LoadHosts(api, c.Args())
+		if len(hostsInError) > 0 {
+			errs := []error{}
+			for _, err := range hostsInError {
+				errs = append(errs, err)
+			}
+			return consolidateErrs(errs)
+		}
+		hosts = loaded
+	} else {
+		all, _, err := persist.LoadAllHosts(api)
+		if err != nil {
+			return err
+		}
+		for _, h := range all {
+			if hasRegistryOptions(h) {
+				hosts = append(hosts, h)
+			}
+		}
+	}
+
+	errs := []error{}
+	for _, h := range hosts {
+		if !hasRegistryOptions(h) {
+			errs = append(errs, fmt.Errorf("%s: The machine has no registry credentials or CA certs", h.Name))
+			continue
+		}
+
+		if err := h.ConfigureRegistries(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", h.Name, err))
+			continue
+		}
+		log.Infof("Synced the registries of %s", h.Name)
+	}
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	return nil
+}
+
+func hasRegistryOptions(h *host.Host) bool {
+	if h.HostOptions == nil || h.HostOptions.EngineOptions == nil {
+		return false
+	}
+
+	engineOptions := h.HostOptions.EngineOptions
+	return len(engineOptions.RegistryAuth) > 0 || len(engineOptions.RegistryCA) > 0
+}
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	caPath := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(caPath, []byte("-----BEGIN CERTIFICATE-----\nQ0E=\n-----END CERTIFICATE-----\n"), 0644))
+	notPEM := filepath.Join(dir, "ca.der")
+	assert.NoError(t, ioutil.WriteFile(notPEM, []byte("CA"), 0644))
+
+	auths, cas, err := registryOptions(&commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"registry-auth": []string{"registry.corp=helper:pass"},
+				"registry-ca":   []string{"registry.corp=" + caPath},
+			},
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"registry.corp=helper:pass"}, auths)
+	assert.Equal(t, []string{"registry.corp=" + caPath}, cas)
+
+	for _, test := range []struct {
+		flag     string
+		value    string
+		expected string
+	}{
+		{"registry-auth", "registry.corp=deploy:s3cret", `Invalid credential source "deploy:s3cret", expected env:NAME, file:PATH or helper:NAME`},
+		{"registry-auth", "registry.corp", `Invalid registry option "registry.corp": expected registry=value`},
+		{"registry-ca", "registry.corp=" + notPEM, "The CA cert of registry.corp isn't PEM encoded: " + notPEM},
+	} {
+		_, _, err := registryOptions(&commandstest.FakeCommandLine{
+			LocalFlags: &commandstest.FakeFlagger{
+				Data: map[string]interface{}{test.flag: []string{test.value}},
+			},
+		})
+
+		assert.EqualError(t, err, test.expected)
+	}
+}
+
+func TestCmdRegistrySyncWithoutOptions(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{Name: "dev", HostOptions: &host.Options{EngineOptions: &engine.Options{}}},
+		},
+	}
+
+	assert.NoError(t, cmdRegistrySync(&commandstest.FakeCommandLine{}, api))
+	assert.EqualError(t, cmdRegistrySync(&commandstest.FakeCommandLine{CliArgs: []string{"dev"}}, api), "dev: The machine has no registry credentials or CA certs")
+}
@@ -8,7 +8,10 @@ import (
 	"errors"
 
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarmmode"
 )
 
 func cmdRm(c CommandLine, api libmachine.API) error {
@@ -29,7 +32,7 @@ func cmdRm(c CommandLine, api libmachine.API) error {
 	}
 
 	for _, hostName := range c.Args() {
-		err := removeRemoteMachine(hostName, api)
+		err := removeRemoteMachine(hostName, api, force)
 		if err != nil {
 			errorOccured = collectError(fmt.Sprintf("Error removing host %q: %s", hostName, err), force, errorOccured)
 		}
@@ -39,6 +42,7 @@ func cmdRm(c CommandLine, api libmachine.API) error {
 			if removeErr != nil {
 				errorOccured = collectError(fmt.Sprintf("Can't remove \"%s\"", hostName), force, errorOccured)
 			} else {
+				syncRemovedContext(api, hostName)
 				log.Infof("Successfully removed %s", hostName)
 			}
 		}
@@ -64,15 +68,80 @@ func userConfirm(confirm bool, force bool) bool {
 	return sure
 }
 
-func removeRemoteMachine(hostName string, api libmachine.API) error {
+func removeRemoteMachine(hostName string, api libmachine.API, force bool) error {
 	currentHost, loaderr := api.Load(hostName)
 	if loaderr != nil {
 		return loaderr
 	}
 
+	if err := leaveSwarmMode(api, currentHost); err != nil {
+		if !force {
+			return fmt.Errorf("Error removing the machine from its swarm mode cluster: %s", err)
+		}
+		log.Errorf("Error removing %s from its swarm mode cluster: %s", hostName, err)
+	}
+
 	return currentHost.Driver.Remove()
 }
 
+// leaveSwarmMode drains the machine and removes it from its swarm mode
+// cluster, through another manager of the cluster when there's one.
+func leaveSwarmMode(api libmachine.API, h *host.Host) error {
+	if h.SwarmModeRole() == "" {
+		return nil
+	}
+
+	manager, err := swarmModeManager(api, h)
+	if err != nil {
+		return err
+	}
+
+	return h.LeaveSwarmMode(manager)
+}
+
+// swarmModeManager returns a running manager of the swarm mode cluster of h
+// other than h: the machine h joined, a manager which joined h, or one which
+// joined the same machine. It returns nil when there's none.
+func swarmModeManager(api libmachine.API, h *host.Host) (*host.Host, error) {
+	join := h.HostOptions.SwarmModeOptions.Join
+
+	names, err := api.List()
+	if err != nil {
+		return nil, err
+	}
+
+	// The machine h joined comes first.
+	candidates := []string{}
+	if join != "" {
+		candidates = append(candidates, join)
+	}
+	for _, name := range names {
+		if name != h.Name && name != join {
+			candidates = append(candidates, name)
+		}
+	}
+
+	for _, name := range candidates {
+		candidate, err := api.Load(name)
+		if err != nil || candidate.SwarmModeRole() != swarmmode.RoleManager {
+			continue
+		}
+
+		candidateJoin := candidate.HostOptions.SwarmModeOptions.Join
+		if name != join && candidateJoin != h.Name && (join == "" || candidateJoin != join) {
+			continue
+		}
+
+		if machineState, err := candidate.Driver.GetState(); err != nil || machineState != state.Running {
+			continue
+		}
+
+		return candidate, nil
+	}
+
+	return nil, nil
+}
+
 func removeLocalMachine(hostName string, api libmachine.API) error {
 	exist, _ := api.Exists(hostName)
 	if !exist {
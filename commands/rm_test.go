@@ -9,6 +9,9 @@ import (
 	"github.com/docker/machine/drivers/fakedriver"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/mcndockerclient"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarmmode"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -231,3 +234,93 @@ func TestDontRemoveMachineIsRemovalFailsAndNotForced(t *testing.T) {
 
 	assert.True(t, libmachinetest.Exists(api, "machineToRemove1"))
 }
+
+func TestCmdRmLeavesSwarmMode(t *testing.T) {
+	moder := &mcndockerclient.FakeSwarmModer{
+		Infos: map[string]mcndockerclient.SwarmInfo{
+			"tcp://10.0.0.2:2376": {NodeID: "node2", LocalNodeState: "active"},
+		},
+	}
+	defer func(moder mcndockerclient.SwarmModer) { mcndockerclient.CurrentSwarmModer = moder }(mcndockerclient.CurrentSwarmModer)
+	mcndockerclient.CurrentSwarmModer = moder
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"worker1"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"y": true,
+			},
+		},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "manager1",
+				Driver: &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.1"},
+				HostOptions: &host.Options{
+					SwarmModeOptions: &swarmmode.Options{Role: swarmmode.RoleManager, NodeID: "node1"},
+				},
+			},
+			{
+				Name:   "worker1",
+				Driver: &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.2"},
+				HostOptions: &host.Options{
+					SwarmModeOptions: &swarmmode.Options{Role: swarmmode.RoleWorker, Join: "manager1", NodeID: "node2"},
+				},
+			},
+		},
+	}
+
+	err := cmdRm(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.False(t, libmachinetest.Exists(api, "worker1"))
+	assert.Equal(t, []string{
+		"tcp://10.0.0.1:2376 drain node2",
+		"tcp://10.0.0.2:2376 leave",
+		"tcp://10.0.0.1:2376 rm node2",
+	}, moder.Calls)
+}
+
+func TestSwarmModeManager(t *testing.T) {
+	newHost := func(name string, machineState state.State, role, join string) *host.Host {
+		return &host.Host{
+			Name:   name,
+			Driver: &fakedriver.Driver{MockState: machineState},
+			HostOptions: &host.Options{
+				SwarmModeOptions: &swarmmode.Options{Role: role, Join: join},
+			},
+		}
+	}
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			newHost("manager1", state.Running, swarmmode.RoleManager, ""),
+			newHost("manager2", state.Running, swarmmode.RoleManager, "manager1"),
+			newHost("manager3", state.Stopped, swarmmode.RoleManager, "manager1"),
+			newHost("worker1", state.Running, swarmmode.RoleWorker, "manager1"),
+			newHost("other", state.Running, swarmmode.RoleManager, ""),
+		},
+	}
+
+	for name, expected := range map[string]string{
+		"worker1":  "manager1",
+		"manager1": "manager2",
+		"manager2": "manager1",
+	} {
+		h, _ := api.Load(name)
+		manager, err := swarmModeManager(api, h)
+
+		assert.NoError(t, err)
+		if assert.NotNil(t, manager, name) {
+			assert.Equal(t, expected, manager.Name, name)
+		}
+	}
+
+	api.Remove("manager2")
+	h, _ := api.Load("manager1")
+	manager, err := swarmModeManager(api, h)
+
+	assert.NoError(t, err)
+	assert.Nil(t, manager)
+}
@@ -3,57 +3,22 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
-	"os/exec"
+	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/docker/machine/libmachine"
-	"github.com/docker/machine/libmachine/log"
-	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/ssh"
 )
 
 var (
 	errWrongNumberArguments = errors.New("Improper number of arguments")
-
-	// TODO: possibly move this to ssh package
-	baseSSHArgs = []string{
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "LogLevel=quiet", // suppress "Warning: Permanently added '[localhost]:2022' (ECDSA) to the list of known hosts."
-	}
+	errNoMachinePath        = errors.New("Error: At least one of the paths must be on a machine, e.g. machinename:/path/to/files")
 )
 
-// HostInfo gives the mandatory information to connect to a host.
-type HostInfo interface {
-	GetMachineName() string
-
-	GetIP() (string, error)
-
-	GetSSHPort() (int, error)
-
-	GetSSHUsername() string
-
-	GetSSHKeyPath() string
-}
-
-// HostInfoLoader loads host information.
-type HostInfoLoader interface {
-	load(name string) (HostInfo, error)
-}
-
-type storeHostInfoLoader struct {
-	store persist.Store
-}
-
-func (s *storeHostInfoLoader) load(name string) (HostInfo, error) {
-	host, err := s.store.Load(name)
-	if err != nil {
-		return nil, fmt.Errorf("Error loading host: %s", err)
-	}
-
-	return host.Driver, nil
-}
-
 func cmdScp(c CommandLine, api libmachine.API) error {
 	args := c.Args()
 	if len(args) != 2 {
@@ -61,78 +26,80 @@ func cmdScp(c CommandLine, api libmachine.API) error {
 		return errWrongNumberArguments
 	}
 
-	src := args[0]
-	dest := args[1]
-
-	hostInfoLoader := &storeHostInfoLoader{api}
-
-	cmd, err := getScpCmd(src, dest, c.Bool("recursive"), hostInfoLoader)
-	if err != nil {
-		return err
+	opts := ssh.TransferOptions{
+		Recursive: c.Bool("recursive"),
+		Delta:     c.Bool("delta"),
+	}
+	if !c.Bool("quiet") {
+		opts.Progress = printProgress
 	}
 
-	return runCmdWithStdIo(*cmd)
+	return runScp(args[0], args[1], opts, api)
 }
 
-func getScpCmd(src, dest string, recursive bool, hostInfoLoader HostInfoLoader) (*exec.Cmd, error) {
-	cmdPath, err := exec.LookPath("scp")
+func runScp(src, dest string, opts ssh.TransferOptions, api libmachine.API) error {
+	srcHost, srcPath, err := getInfoForScpArg(src, api)
 	if err != nil {
-		return nil, errors.New("Error: You must have a copy of the scp binary locally to use the scp feature.")
-	}
-
-	srcHost, srcPath, srcOpts, err := getInfoForScpArg(src, hostInfoLoader)
-	if err != nil {
-		return nil, err
+		return err
 	}
 
-	destHost, destPath, destOpts, err := getInfoForScpArg(dest, hostInfoLoader)
+	destHost, destPath, err := getInfoForScpArg(dest, api)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// TODO: Check that "-3" flag is available in user's version of scp.
-	// It is on every system I've checked, but the manual mentioned it's "newer"
-	sshArgs := baseSSHArgs
-	sshArgs = append(sshArgs, "-3")
-	if recursive {
-		sshArgs = append(sshArgs, "-r")
+	switch {
+	case srcHost == nil && destHost == nil:
+		return errNoMachinePath
+	case srcHost == nil:
+		client, err := destHost.CreateSSHClient()
+		if err != nil {
+			return err
+		}
+		return client.Upload(srcPath, destPath, opts)
+	case destHost == nil:
+		client, err := srcHost.CreateSSHClient()
+		if err != nil {
+			return err
+		}
+		return client.Download(srcPath, destPath, opts)
+	default:
+		return scpBetweenMachines(srcHost, srcPath, destHost, destPath, opts)
 	}
+}
 
-	// Don't use ssh-agent if both hosts have explicit ssh keys
-	if !missesExplicitSSHKey(srcHost) && !missesExplicitSSHKey(destHost) {
-		sshArgs = append(sshArgs, "-o", "IdentitiesOnly=yes")
+// scpBetweenMachines copies files from one machine to another through the
+// local host's filesystem.
+func scpBetweenMachines(srcHost *host.Host, srcPath string, destHost *host.Host, destPath string, opts ssh.TransferOptions) error {
+	srcClient, err := srcHost.CreateSSHClient()
+	if err != nil {
+		return err
 	}
 
-	// Append needed -i / private key flags to command.
-	sshArgs = append(sshArgs, srcOpts...)
-	sshArgs = append(sshArgs, destOpts...)
-
-	// Append actual arguments for the scp command (i.e. docker@<ip>:/path)
-	locationArg, err := generateLocationArg(srcHost, srcPath)
+	destClient, err := destHost.CreateSSHClient()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	sshArgs = append(sshArgs, locationArg)
-	locationArg, err = generateLocationArg(destHost, destPath)
+	tmpDir, err := ioutil.TempDir("", "docker-machine-scp-")
 	if err != nil {
-		return nil, err
+		return err
 	}
-	sshArgs = append(sshArgs, locationArg)
+	defer os.RemoveAll(tmpDir)
 
-	cmd := exec.Command(cmdPath, sshArgs...)
-	log.Debug(*cmd)
-	return cmd, nil
-}
+	downloadOpts := opts
+	downloadOpts.Delta = false
+	if err := srcClient.Download(srcPath, tmpDir, downloadOpts); err != nil {
+		return err
+	}
 
-func missesExplicitSSHKey(hostInfo HostInfo) bool {
-	return hostInfo != nil && hostInfo.GetSSHKeyPath() == ""
+	return destClient.Upload(filepath.Join(tmpDir, path.Base(srcPath)), destPath, opts)
 }
 
-func getInfoForScpArg(hostAndPath string, hostInfoLoader HostInfoLoader) (HostInfo, string, []string, error) {
+func getInfoForScpArg(hostAndPath string, api libmachine.API) (*host.Host, string, error) {
 	// Local path.  e.g. "/tmp/foo"
 	if !strings.Contains(hostAndPath, ":") {
-		return nil, hostAndPath, nil, nil
+		return nil, hostAndPath, nil
 	}
 
 	// Path with hostname.  e.g. "hostname:/usr/bin/cmatrix"
@@ -140,46 +107,26 @@ func getInfoForScpArg(hostAndPath string, hostInfoLoader HostInfoLoader) (HostIn
 	hostName := parts[0]
 	path := parts[1]
 	if hostName == "localhost" {
-		return nil, path, nil, nil
+		return nil, path, nil
 	}
 
 	// Remote path
-	hostInfo, err := hostInfoLoader.load(hostName)
+	h, err := api.Load(hostName)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("Error loading host: %s", err)
-	}
-
-	args := []string{}
-	port, err := hostInfo.GetSSHPort()
-	if err == nil && port > 0 {
-		args = append(args, "-P", fmt.Sprintf("%v", port))
-	}
-
-	if hostInfo.GetSSHKeyPath() != "" {
-		args = append(args, "-i", hostInfo.GetSSHKeyPath())
+		return nil, "", fmt.Errorf("Error loading host: %s", err)
 	}
 
-	return hostInfo, path, args, nil
+	return h, path, nil
 }
 
-func generateLocationArg(hostInfo HostInfo, path string) (string, error) {
-	if hostInfo == nil {
-		return path, nil
+func printProgress(path string, copied, total int64) {
+	percent := int64(100)
+	if total > 0 {
+		percent = copied * 100 / total
 	}
 
-	ip, err := hostInfo.GetIP()
-	if err != nil {
-		return "", err
+	fmt.Fprintf(os.Stderr, "\r%s %3d%% %d/%d", path, percent, copied, total)
+	if copied == total {
+		fmt.Fprintln(os.Stderr)
 	}
-
-	location := fmt.Sprintf("%s@%s:%s", hostInfo.GetSSHUsername(), ip, path)
-	return location, nil
-}
-
-func runCmdWithStdIo(cmd exec.Cmd) error {
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	return cmd.Run()
 }
@@ -1,146 +1,106 @@
 package commands
 
 import (
-	"os/exec"
 	"testing"
 
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/ssh/sshtest"
+	"github.com/docker/machine/libmachine/state"
 	"github.com/stretchr/testify/assert"
 )
 
-type MockHostInfo struct {
-	name        string
-	ip          string
-	sshPort     int
-	sshUsername string
-	sshKeyPath  string
-}
-
-func (h *MockHostInfo) GetMachineName() string {
-	return h.name
-}
-
-func (h *MockHostInfo) GetIP() (string, error) {
-	return h.ip, nil
-}
-
-func (h *MockHostInfo) GetSSHPort() (int, error) {
-	return h.sshPort, nil
-}
-
-func (h *MockHostInfo) GetSSHUsername() string {
-	return h.sshUsername
-}
-
-func (h *MockHostInfo) GetSSHKeyPath() string {
-	return h.sshKeyPath
-}
-
-type MockHostInfoLoader struct {
-	hostInfo MockHostInfo
-}
-
-func (l *MockHostInfoLoader) load(name string) (HostInfo, error) {
-	info := l.hostInfo
-	info.name = name
-	return &info, nil
+func newScpTestAPI(names ...string) *libmachinetest.FakeAPI {
+	api := &libmachinetest.FakeAPI{}
+	for _, name := range names {
+		api.Hosts = append(api.Hosts, &host.Host{
+			Name: name,
+			Driver: &fakedriver.Driver{
+				MockState: state.Running,
+			},
+		})
+	}
+	return api
 }
 
 func TestGetInfoForLocalScpArg(t *testing.T) {
-	host, path, opts, err := getInfoForScpArg("/tmp/foo", nil)
+	host, path, err := getInfoForScpArg("/tmp/foo", nil)
 	assert.Nil(t, host)
 	assert.Equal(t, "/tmp/foo", path)
-	assert.Nil(t, opts)
 	assert.NoError(t, err)
 
-	host, path, opts, err = getInfoForScpArg("localhost:C:\\path", nil)
+	host, path, err = getInfoForScpArg("localhost:C:\\path", nil)
 	assert.Nil(t, host)
 	assert.Equal(t, "C:\\path", path)
-	assert.Nil(t, opts)
 	assert.NoError(t, err)
 }
 
 func TestGetInfoForRemoteScpArg(t *testing.T) {
-	hostInfoLoader := MockHostInfoLoader{MockHostInfo{
-		sshKeyPath: "/fake/keypath/id_rsa",
-	}}
+	api := newScpTestAPI("myfunhost")
 
-	host, path, opts, err := getInfoForScpArg("myfunhost:/home/docker/foo", &hostInfoLoader)
-	assert.Equal(t, "myfunhost", host.GetMachineName())
+	host, path, err := getInfoForScpArg("myfunhost:/home/docker/foo", api)
+	assert.Equal(t, "myfunhost", host.Name)
 	assert.Equal(t, "/home/docker/foo", path)
-	assert.Equal(t, []string{"-i", "/fake/keypath/id_rsa"}, opts)
 	assert.NoError(t, err)
 
-	host, path, opts, err = getInfoForScpArg("myfunhost:C:\\path", &hostInfoLoader)
-	assert.Equal(t, "myfunhost", host.GetMachineName())
+	host, path, err = getInfoForScpArg("myfunhost:C:\\path", api)
+	assert.Equal(t, "myfunhost", host.Name)
 	assert.Equal(t, "C:\\path", path)
 	assert.NoError(t, err)
+
+	_, _, err = getInfoForScpArg("unknown:/home/docker/foo", api)
+	assert.EqualError(t, err, `Error loading host: Host does not exist: "unknown"`)
 }
 
-func TestHostLocation(t *testing.T) {
-	arg, err := generateLocationArg(nil, "/home/docker/foo")
+func TestScpUpload(t *testing.T) {
+	clientCreator := &FakeSSHClientCreator{}
+	host.SetSSHClientCreator(clientCreator)
+	defer host.SetSSHClientCreator(&host.StandardSSHClientCreator{})
 
-	assert.Equal(t, "/home/docker/foo", arg)
+	opts := ssh.TransferOptions{Recursive: true, Delta: true}
+	err := runScp("/tmp/foo", "myfunhost:/home/docker/foo", opts, newScpTestAPI("myfunhost"))
 	assert.NoError(t, err)
-}
 
-func TestRemoteLocation(t *testing.T) {
-	hostInfo := MockHostInfo{
-		ip:          "12.34.56.78",
-		sshUsername: "root",
-	}
+	client := clientCreator.client.(*sshtest.FakeClient)
+	assert.Equal(t, []sshtest.Transfer{{Src: "/tmp/foo", Dst: "/home/docker/foo", Opts: opts}}, client.Uploads)
+	assert.Empty(t, client.Downloads)
+}
 
-	arg, err := generateLocationArg(&hostInfo, "/home/docker/foo")
+func TestScpDownload(t *testing.T) {
+	clientCreator := &FakeSSHClientCreator{}
+	host.SetSSHClientCreator(clientCreator)
+	defer host.SetSSHClientCreator(&host.StandardSSHClientCreator{})
 
-	assert.Equal(t, "root@12.34.56.78:/home/docker/foo", arg)
+	opts := ssh.TransferOptions{}
+	err := runScp("myfunhost:/home/docker/foo", ".", opts, newScpTestAPI("myfunhost"))
 	assert.NoError(t, err)
-}
 
-func TestGetScpCmd(t *testing.T) {
-	hostInfoLoader := MockHostInfoLoader{MockHostInfo{
-		ip:          "12.34.56.78",
-		sshPort:     234,
-		sshUsername: "root",
-		sshKeyPath:  "/fake/keypath/id_rsa",
-	}}
-
-	cmd, err := getScpCmd("/tmp/foo", "myfunhost:/home/docker/foo", true, &hostInfoLoader)
-
-	expectedArgs := append(
-		baseSSHArgs,
-		"-3",
-		"-r",
-		"-o",
-		"IdentitiesOnly=yes",
-		"-P",
-		"234",
-		"-i",
-		"/fake/keypath/id_rsa",
-		"/tmp/foo",
-		"root@12.34.56.78:/home/docker/foo",
-	)
-	expectedCmd := exec.Command("/usr/bin/scp", expectedArgs...)
-
-	assert.Equal(t, expectedCmd, cmd)
-	assert.NoError(t, err)
+	client := clientCreator.client.(*sshtest.FakeClient)
+	assert.Equal(t, []sshtest.Transfer{{Src: "/home/docker/foo", Dst: ".", Opts: opts}}, client.Downloads)
+	assert.Empty(t, client.Uploads)
 }
 
-func TestGetScpCmdWithoutSshKey(t *testing.T) {
-	hostInfoLoader := MockHostInfoLoader{MockHostInfo{
-		ip:          "1.2.3.4",
-		sshUsername: "user",
-	}}
+func TestScpBetweenMachines(t *testing.T) {
+	clientCreator := &FakeSSHClientCreator{}
+	host.SetSSHClientCreator(clientCreator)
+	defer host.SetSSHClientCreator(&host.StandardSSHClientCreator{})
 
-	cmd, err := getScpCmd("/tmp/foo", "myfunhost:/home/docker/foo", true, &hostInfoLoader)
+	opts := ssh.TransferOptions{Recursive: true, Delta: true}
+	err := runScp("src:/home/docker/foo", "dest:/home/docker/", opts, newScpTestAPI("src", "dest"))
+	assert.NoError(t, err)
 
-	expectedArgs := append(
-		baseSSHArgs,
-		"-3",
-		"-r",
-		"/tmp/foo",
-		"user@1.2.3.4:/home/docker/foo",
-	)
-	expectedCmd := exec.Command("/usr/bin/scp", expectedArgs...)
+	client := clientCreator.client.(*sshtest.FakeClient)
+	if assert.Len(t, client.Downloads, 1) && assert.Len(t, client.Uploads, 1) {
+		assert.Equal(t, "/home/docker/foo", client.Downloads[0].Src)
+		assert.False(t, client.Downloads[0].Opts.Delta)
+		assert.Equal(t, "/home/docker/", client.Uploads[0].Dst)
+		assert.True(t, client.Uploads[0].Opts.Delta)
+	}
+}
 
-	assert.Equal(t, expectedCmd, cmd)
-	assert.NoError(t, err)
+func TestScpLocalOnly(t *testing.T) {
+	err := runScp("/tmp/foo", "/tmp/bar", ssh.TransferOptions{}, newScpTestAPI())
+	assert.Equal(t, errNoMachinePath, err)
 }
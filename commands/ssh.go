@@ -1,9 +1,13 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/audit"
+	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 )
 
@@ -23,7 +27,17 @@ func cmdSSH(c CommandLine, api libmachine.API) error {
 		return nil
 	}
 
-	target, err := targetHost(c, api)
+	args := c.Args()
+
+	// Flags have to be parsed by hand since the remaining arguments are
+	// passed as is to the remote command.
+	forwardAgent := false
+	if len(args) > 0 && (args[0] == "-A" || args[0] == "--forward-agent") {
+		forwardAgent = true
+		args = args[1:]
+	}
+
+	target, err := targetHostFromArgs(args, api)
 	if err != nil {
 		return err
 	}
@@ -47,5 +61,22 @@ func cmdSSH(c CommandLine, api libmachine.API) error {
 		return err
 	}
 
-	return client.Shell(c.Args().Tail()...)
+	if forwardAgent {
+		forwarder, ok := client.(ssh.AgentForwarder)
+		if !ok {
+			return errors.New("Error: The SSH client does not support agent forwarding")
+		}
+		forwarder.SetForwardAgent(true)
+	}
+
+	transcript := &audit.Transcript{}
+	if transcriber, ok := client.(ssh.Transcriber); ok {
+		transcriber.SetTranscript(transcript)
+	}
+
+	done := audit.Start(host.Name, audit.TypeSession, strings.Join(args.Tail(), " "))
+	err = client.Shell(args.Tail()...)
+	done(transcript.String(), err)
+
+	return err
 }
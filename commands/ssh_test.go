@@ -1,11 +1,15 @@
 package commands
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/docker/machine/commands/commandstest"
 	"github.com/docker/machine/drivers/fakedriver"
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/audit"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/libmachinetest"
@@ -34,6 +38,7 @@ func TestCmdSSH(t *testing.T) {
 		helpShown     bool
 		clientCreator host.SSHClientCreator
 		expectedShell []string
+		forwardAgent  bool
 	}{
 		{
 			commandLine: &commandstest.FakeCommandLine{
@@ -76,6 +81,25 @@ func TestCmdSSH(t *testing.T) {
 			clientCreator: &FakeSSHClientCreator{},
 			expectedShell: []string{"df", "-h"},
 		},
+		{
+			commandLine: &commandstest.FakeCommandLine{
+				CliArgs: []string{"-A", "default", "ssh-add", "-l"},
+			},
+			api: &libmachinetest.FakeAPI{
+				Hosts: []*host.Host{
+					{
+						Name: "default",
+						Driver: &fakedriver.Driver{
+							MockState: state.Running,
+						},
+					},
+				},
+			},
+			expectedErr:   nil,
+			clientCreator: &FakeSSHClientCreator{},
+			expectedShell: []string{"ssh-add", "-l"},
+			forwardAgent:  true,
+		},
 		{
 			commandLine: &commandstest.FakeCommandLine{
 				CliArgs: []string{"default"},
@@ -106,6 +130,47 @@ func TestCmdSSH(t *testing.T) {
 
 		if fcc, ok := tc.clientCreator.(*FakeSSHClientCreator); ok {
 			assert.Equal(t, tc.expectedShell, fcc.client.(*sshtest.FakeClient).ActivatedShell)
+			assert.Equal(t, tc.forwardAgent, fcc.client.(*sshtest.FakeClient).ForwardAgent)
 		}
 	}
 }
+
+func TestCmdSSHRecordsSession(t *testing.T) {
+	machinesDir, err := ioutil.TempDir("", "machine-test-ssh-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(machinesDir)
+	os.Mkdir(filepath.Join(machinesDir, "default"), 0700)
+
+	audit.SetMachinesDir(machinesDir)
+	defer audit.SetMachinesDir("")
+
+	clientCreator := &FakeSSHClientCreator{}
+	host.SetSSHClientCreator(clientCreator)
+	defer host.SetSSHClientCreator(&host.StandardSSHClientCreator{})
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"default", "df", "-h"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name: "default",
+				Driver: &fakedriver.Driver{
+					MockState: state.Running,
+				},
+			},
+		},
+	}
+
+	assert.NoError(t, cmdSSH(commandLine, api))
+	assert.NotNil(t, clientCreator.client.(*sshtest.FakeClient).Transcript)
+
+	records, err := audit.Read("default")
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, audit.TypeSession, records[0].Type)
+		assert.Equal(t, "df -h", records[0].Command)
+	}
+}
@@ -0,0 +1,478 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/libmachine/swarmmode"
+)
+
+const (
+	swarmClusterType     = "swarm"
+	swarmModeClusterType = "swarm mode"
+)
+
+var (
+	errSwarmNoCluster       = errors.New("--cluster is required, it's a machine of the cluster to add the machine to")
+	errSwarmModeNoMaster    = errors.New("A swarm mode cluster has no master, use promote and demote to change its managers")
+	errSwarmModeLastManager = errors.New("The machine is the last running manager of its swarm mode cluster")
+)
+
+// swarmCluster is a cluster of machines found in the store: a Swarm cluster,
+// named after its discovery, or a swarm mode cluster, named after the machine
+// it was initialized on.
+type swarmCluster struct {
+	Name    string
+	Type    string
+	Masters []string
+	Nodes   []string
+}
+
+// swarmClusters returns the clusters the hosts are part of, sorted by name.
+func swarmClusters(hosts []*host.Host) []*swarmCluster {
+	byName := map[string]*host.Host{}
+	for _, h := range hosts {
+		byName[h.Name] = h
+	}
+
+	clusters := map[string]*swarmCluster{}
+	cluster := func(name, clusterType string) *swarmCluster {
+		key := name + "\n" + clusterType
+		if clusters[key] == nil {
+			clusters[key] = &swarmCluster{
+				Name:    name,
+				Type:    clusterType,
+				Masters: []string{},
+				Nodes:   []string{},
+			}
+		}
+		return clusters[key]
+	}
+
+	for _, h := range hosts {
+		if role := h.SwarmModeRole(); role != "" {
+			c := cluster(swarmModeRoot(h, byName), swarmModeClusterType)
+			c.Nodes = append(c.Nodes, h.Name)
+			if role == swarmmode.RoleManager {
+				c.Masters = append(c.Masters, h.Name)
+			}
+		}
+
+		if options := swarmOptions(h); options.IsSwarm {
+			c := cluster(options.Discovery, swarmClusterType)
+			c.Nodes = append(c.Nodes, h.Name)
+			if options.Master {
+				c.Masters = append(c.Masters, h.Name)
+			}
+		}
+	}
+
+	keys := []string{}
+	for key := range clusters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	list := []*swarmCluster{}
+	for _, key := range keys {
+		c := clusters[key]
+		sort.Strings(c.Masters)
+		sort.Strings(c.Nodes)
+		list = append(list, c)
+	}
+
+	return list
+}
+
+// swarmModeRoot returns the machine the swarm mode cluster of h was
+// initialized on, following the machines each one joined. A machine whose
+// joined machine is gone stands for the cluster.
+func swarmModeRoot(h *host.Host, byName map[string]*host.Host) string {
+	seen := map[string]bool{}
+	for !seen[h.Name] {
+		seen[h.Name] = true
+
+		joined, ok := byName[h.HostOptions.SwarmModeOptions.Join]
+		if !ok || joined.SwarmModeRole() == "" {
+			break
+		}
+		h = joined
+	}
+
+	return h.Name
+}
+
+// swarmOptions returns the Swarm options of h, the zero options when it has
+// none.
+func swarmOptions(h *host.Host) swarm.Options {
+	if h.HostOptions == nil || h.HostOptions.SwarmOptions == nil {
+		return swarm.Options{}
+	}
+	return *h.HostOptions.SwarmOptions
+}
+
+func cmdSwarmLs(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 0 {
+		return ErrTooManyArguments
+	}
+
+	hosts, _, err := persist.LoadAllHosts(api)
+	if err != nil {
+		return err
+	}
+
+	return printSwarmClusters(os.Stdout, swarmClusters(hosts))
+}
+
+func printSwarmClusters(out io.Writer, clusters []*swarmCluster) error {
+	w := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tTYPE\tMASTERS\tNODES")
+	for _, c := range clusters {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Name, c.Type, strings.Join(c.Masters, ","), strings.Join(c.Nodes, ","))
+	}
+	return w.Flush()
+}
+
+// loadSwarmHost loads the machine named by the single argument of the
+// command.
+func loadSwarmHost(c CommandLine, api libmachine.API) (*host.Host, error) {
+	if len(c.Args()) != 1 {
+		return nil, ErrExpectedOneMachine
+	}
+
+	return api.Load(c.Args().First())
+}
+
+func cmdSwarmAdd(c CommandLine, api libmachine.API) error {
+	h, err := loadSwarmHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	if c.String("cluster") == "" {
+		return errSwarmNoCluster
+	}
+
+	if h.SwarmModeRole() != "" || swarmOptions(h).IsSwarm {
+		return fmt.Errorf("%s is already part of a cluster", h.Name)
+	}
+
+	member, err := api.Load(c.String("cluster"))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case member.SwarmModeRole() != "":
+		manager := member
+		if member.SwarmModeRole() != swarmmode.RoleManager {
+			if manager, err = swarmModeManager(api, member); err != nil {
+				return err
+			}
+			if manager == nil {
+				return fmt.Errorf("No running manager found in the swarm mode cluster of %s", member.Name)
+			}
+		}
+
+		h.HostOptions.SwarmModeOptions = &swarmmode.Options{Role: swarmmode.RoleWorker, Join: manager.Name}
+		err = h.ConfigureSwarmMode(manager)
+	case swarmOptions(member).IsSwarm:
+		// The discovery of the cluster is reused, its service stays where it
+		// runs.
+		options := swarmOptions(member)
+		options.Agent = true
+		options.Master = false
+		options.LocalDiscovery = false
+		err = h.ConfigureSwarm(options)
+	default:
+		return fmt.Errorf("%s isn't part of a cluster", member.Name)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return api.Save(h)
+}
+
+func cmdSwarmRemove(c CommandLine, api libmachine.API) error {
+	h, err := loadSwarmHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case h.SwarmModeRole() != "":
+		if err := leaveSwarmMode(api, h); err != nil {
+			return err
+		}
+		h.HostOptions.SwarmModeOptions = nil
+	case swarmOptions(h).IsSwarm:
+		options := swarmOptions(h)
+		if options.Master || options.LocalDiscovery {
+			others, err := swarmMembers(api, h)
+			if err != nil {
+				return err
+			}
+			if len(others) > 0 && options.Master {
+				return fmt.Errorf("%s is the master of its Swarm cluster, rotate the master to another machine first", h.Name)
+			}
+			if len(others) > 0 {
+				return fmt.Errorf("%s runs the discovery service of its Swarm cluster, remove the other machines of the cluster first", h.Name)
+			}
+		}
+
+		options.IsSwarm = false
+		options.Agent = false
+		options.Master = false
+		options.Discovery = ""
+		options.LocalDiscovery = false
+		if err := h.ConfigureSwarm(options); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s isn't part of a cluster", h.Name)
+	}
+
+	return api.Save(h)
+}
+
+func cmdSwarmPromote(c CommandLine, api libmachine.API) error {
+	h, err := loadSwarmHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case h.SwarmModeRole() == swarmmode.RoleManager:
+		return fmt.Errorf("%s is already a manager", h.Name)
+	case h.SwarmModeRole() != "":
+		manager, err := swarmModeManager(api, h)
+		if err != nil {
+			return err
+		}
+		if manager == nil {
+			return fmt.Errorf("No running manager found in the swarm mode cluster of %s", h.Name)
+		}
+
+		if err := h.SetSwarmModeRole(manager, swarmmode.RoleManager); err != nil {
+			return err
+		}
+	case swarmOptions(h).IsSwarm:
+		options := swarmOptions(h)
+		if options.Master {
+			return fmt.Errorf("%s is already the master", h.Name)
+		}
+
+		others, err := swarmMembers(api, h)
+		if err != nil {
+			return err
+		}
+		for _, other := range others {
+			if swarmOptions(other).Master {
+				return fmt.Errorf("%s is the master of the Swarm cluster, use rotate-master to replace it", other.Name)
+			}
+		}
+
+		options.Master = true
+		if err := h.ConfigureSwarm(options); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s isn't part of a cluster", h.Name)
+	}
+
+	return api.Save(h)
+}
+
+func cmdSwarmDemote(c CommandLine, api libmachine.API) error {
+	h, err := loadSwarmHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case h.SwarmModeRole() == swarmmode.RoleWorker:
+		return fmt.Errorf("%s is already a worker", h.Name)
+	case h.SwarmModeRole() != "":
+		manager, err := swarmModeManager(api, h)
+		if err != nil {
+			return err
+		}
+		if manager == nil {
+			return errSwarmModeLastManager
+		}
+
+		if err := h.SetSwarmModeRole(manager, swarmmode.RoleWorker); err != nil {
+			return err
+		}
+	case swarmOptions(h).IsSwarm:
+		if !swarmOptions(h).Master {
+			return fmt.Errorf("%s isn't the master", h.Name)
+		}
+
+		if err := h.ConfigureSwarm(demotedSwarmOptions(swarmOptions(h))); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s isn't part of a cluster", h.Name)
+	}
+
+	return api.Save(h)
+}
+
+// cmdSwarmRotateMaster makes the machine the master of its Swarm cluster in
+// place of the current one, which stays in the cluster as an agent.
+func cmdSwarmRotateMaster(c CommandLine, api libmachine.API) error {
+	h, err := loadSwarmHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	if h.SwarmModeRole() != "" {
+		return errSwarmModeNoMaster
+	}
+
+	options := swarmOptions(h)
+	if !options.IsSwarm {
+		return fmt.Errorf("%s isn't part of a Swarm cluster", h.Name)
+	}
+
+	others, err := swarmMembers(api, h)
+	if err != nil {
+		return err
+	}
+
+	// The new master is up before the old one goes.
+	if !options.Master {
+		options.Master = true
+		if err := h.ConfigureSwarm(options); err != nil {
+			return err
+		}
+		if err := api.Save(h); err != nil {
+			return err
+		}
+	}
+
+	for _, other := range others {
+		if !swarmOptions(other).Master {
+			continue
+		}
+
+		if err := other.ConfigureSwarm(demotedSwarmOptions(swarmOptions(other))); err != nil {
+			return fmt.Errorf("Error demoting %s: %s", other.Name, err)
+		}
+		if err := api.Save(other); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cmdSwarmDoctor recreates the Swarm containers of the machines which aren't
+// running as configured, all the Swarm machines when none is given.
+func cmdSwarmDoctor(c CommandLine, api libmachine.API) error {
+	var hosts []*host.Host
+	if len(c.Args()) > 0 {
+		loaded, hostsInError := persist.LoadHosts(api, c.Args())
+		if len(hostsInError) > 0 {
+			errs := []error{}
+			for _, err := range hostsInError {
+				errs = append(errs, err)
+			}
+			return consolidateErrs(errs)
+		}
+		hosts = loaded
+	} else {
+		all, _, err := persist.LoadAllHosts(api)
+		if err != nil {
+			return err
+		}
+		for _, h := range all {
+			if swarmOptions(h).IsSwarm {
+				hosts = append(hosts, h)
+			}
+		}
+	}
+
+	errs := []error{}
+	for _, h := range hosts {
+		if _, err := swarmDoctor(h); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", h.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return consolidateErrs(errs)
+	}
+
+	return nil
+}
+
+// swarmDoctor recreates the Swarm containers of the machine which aren't
+// running as configured, reports what it fixed and tells whether it fixed
+// anything.
+func swarmDoctor(h *host.Host) (bool, error) {
+	if !swarmOptions(h).IsSwarm {
+		return false, errors.New("The machine isn't part of a Swarm cluster")
+	}
+
+	machineState, err := h.Driver.GetState()
+	if err != nil {
+		return false, err
+	}
+	if machineState != state.Running {
+		return false, fmt.Errorf("The machine is %s, it must be running to repair its Swarm containers", machineState)
+	}
+
+	repairs, err := h.RepairSwarm()
+	for _, repair := range repairs {
+		log.Infof("%s: recreated %s, it was %s", h.Name, repair.Container, repair.Problem)
+	}
+	if err != nil {
+		return len(repairs) > 0, err
+	}
+
+	if len(repairs) == 0 {
+		log.Infof("%s: the Swarm containers are running as configured", h.Name)
+	}
+
+	return len(repairs) > 0, nil
+}
+
+// demotedSwarmOptions returns the options of a master which stays in the
+// cluster as an agent.
+func demotedSwarmOptions(options swarm.Options) swarm.Options {
+	options.Master = false
+	options.Agent = true
+	return options
+}
+
+// swarmMembers returns the machines of the Swarm cluster of h, other than h.
+func swarmMembers(api libmachine.API, h *host.Host) ([]*host.Host, error) {
+	hosts, _, err := persist.LoadAllHosts(api)
+	if err != nil {
+		return nil, err
+	}
+
+	discovery := swarmOptions(h).Discovery
+	members := []*host.Host{}
+	for _, other := range hosts {
+		if other.Name != h.Name && swarmOptions(other).IsSwarm && swarmOptions(other).Discovery == discovery {
+			members = append(members, other)
+		}
+	}
+
+	return members, nil
+}
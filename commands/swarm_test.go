@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/mcndockerclient"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/libmachine/swarmmode"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSwarmModeTestHost(name, ip string, options *swarmmode.Options) *host.Host {
+	return &host.Host{
+		Name:   name,
+		Driver: &fakedriver.Driver{MockState: state.Running, MockIP: ip},
+		HostOptions: &host.Options{
+			SwarmOptions:     &swarm.Options{},
+			SwarmModeOptions: options,
+		},
+	}
+}
+
+func newSwarmTestHost(name string, options *swarm.Options) *host.Host {
+	return &host.Host{
+		Name:   name,
+		Driver: &fakedriver.Driver{MockState: state.Running},
+		HostOptions: &host.Options{
+			SwarmOptions: options,
+		},
+	}
+}
+
+func withFakeSwarmModer(moder *mcndockerclient.FakeSwarmModer) func() {
+	previous := mcndockerclient.CurrentSwarmModer
+	mcndockerclient.CurrentSwarmModer = moder
+	return func() {
+		mcndockerclient.CurrentSwarmModer = previous
+	}
+}
+
+func TestSwarmClusters(t *testing.T) {
+	hosts := []*host.Host{
+		newSwarmTestHost("master", &swarm.Options{IsSwarm: true, Master: true, Agent: true, Discovery: "token://abc"}),
+		newSwarmTestHost("agent", &swarm.Options{IsSwarm: true, Agent: true, Discovery: "token://abc"}),
+		newSwarmTestHost("alone", &swarm.Options{}),
+		newSwarmModeTestHost("manager1", "10.0.0.1", &swarmmode.Options{Role: swarmmode.RoleManager}),
+		newSwarmModeTestHost("worker1", "10.0.0.2", &swarmmode.Options{Role: swarmmode.RoleWorker, Join: "manager2"}),
+		newSwarmModeTestHost("manager2", "10.0.0.3", &swarmmode.Options{Role: swarmmode.RoleManager, Join: "manager1"}),
+		newSwarmModeTestHost("orphan", "10.0.0.4", &swarmmode.Options{Role: swarmmode.RoleWorker, Join: "gone"}),
+	}
+
+	clusters := swarmClusters(hosts)
+
+	assert.Equal(t, []*swarmCluster{
+		{Name: "manager1", Type: swarmModeClusterType, Masters: []string{"manager1", "manager2"}, Nodes: []string{"manager1", "manager2", "worker1"}},
+		{Name: "orphan", Type: swarmModeClusterType, Masters: []string{}, Nodes: []string{"orphan"}},
+		{Name: "token://abc", Type: swarmClusterType, Masters: []string{"master"}, Nodes: []string{"agent", "master"}},
+	}, clusters)
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, printSwarmClusters(out, clusters))
+	assert.Equal(t, `CLUSTER       TYPE         MASTERS             NODES
+manager1      swarm mode   manager1,manager2   manager1,manager2,worker1
+orphan        swarm mode                       orphan
+token://abc   swarm        master              agent,master
+`, out.String())
+}
+
+func TestCmdSwarmAddSwarmMode(t *testing.T) {
+	moder := &mcndockerclient.FakeSwarmModer{
+		JoinTokens: mcndockerclient.SwarmJoinTokens{Worker: "SWMTKN-worker"},
+	}
+	defer withFakeSwarmModer(moder)()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			newSwarmModeTestHost("manager1", "10.0.0.1", &swarmmode.Options{Role: swarmmode.RoleManager}),
+			newSwarmModeTestHost("worker1", "10.0.0.2", &swarmmode.Options{Role: swarmmode.RoleWorker, Join: "manager1"}),
+			newSwarmModeTestHost("new", "10.0.0.3", nil),
+		},
+	}
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"new"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"cluster": "worker1"},
+		},
+	}
+
+	err := cmdSwarmAdd(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tcp://10.0.0.3:2376 join 10.0.0.3:2377 10.0.0.1:2377 SWMTKN-worker"}, moder.Calls)
+	h, _ := api.Load("new")
+	assert.Equal(t, &swarmmode.Options{Role: swarmmode.RoleWorker, Join: "manager1"}, h.HostOptions.SwarmModeOptions)
+}
+
+func TestCmdSwarmAddErrors(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			newSwarmModeTestHost("manager1", "10.0.0.1", &swarmmode.Options{Role: swarmmode.RoleManager}),
+			newSwarmModeTestHost("alone", "10.0.0.2", nil),
+			newSwarmModeTestHost("new", "10.0.0.3", nil),
+		},
+	}
+
+	for _, test := range []struct {
+		machine  string
+		cluster  string
+		expected string
+	}{
+		{"new", "", errSwarmNoCluster.Error()},
+		{"new", "alone", "alone isn't part of a cluster"},
+		{"manager1", "new", "manager1 is already part of a cluster"},
+	} {
+		commandLine := &commandstest.FakeCommandLine{
+			CliArgs: []string{test.machine},
+			LocalFlags: &commandstest.FakeFlagger{
+				Data: map[string]interface{}{"cluster": test.cluster},
+			},
+		}
+
+		assert.EqualError(t, cmdSwarmAdd(commandLine, api), test.expected)
+	}
+}
+
+func TestCmdSwarmRemoveSwarmMode(t *testing.T) {
+	moder := &mcndockerclient.FakeSwarmModer{}
+	defer withFakeSwarmModer(moder)()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			newSwarmModeTestHost("manager1", "10.0.0.1", &swarmmode.Options{Role: swarmmode.RoleManager, NodeID: "node1"}),
+			newSwarmModeTestHost("worker1", "10.0.0.2", &swarmmode.Options{Role: swarmmode.RoleWorker, Join: "manager1", NodeID: "node2"}),
+		},
+	}
+
+	err := cmdSwarmRemove(&commandstest.FakeCommandLine{CliArgs: []string{"worker1"}}, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"tcp://10.0.0.1:2376 drain node2",
+		"tcp://10.0.0.1:2376 rm node2",
+	}, moder.Calls)
+	h, _ := api.Load("worker1")
+	assert.Nil(t, h.HostOptions.SwarmModeOptions)
+	assert.True(t, libmachinetest.Exists(api, "worker1"))
+}
+
+func TestCmdSwarmPromoteDemoteSwarmMode(t *testing.T) {
+	moder := &mcndockerclient.FakeSwarmModer{}
+	defer withFakeSwarmModer(moder)()
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			newSwarmModeTestHost("manager1", "10.0.0.1", &swarmmode.Options{Role: swarmmode.RoleManager, NodeID: "node1"}),
+			newSwarmModeTestHost("worker1", "10.0.0.2", &swarmmode.Options{Role: swarmmode.RoleWorker, Join: "manager1", NodeID: "node2"}),
+		},
+	}
+	worker := &commandstest.FakeCommandLine{CliArgs: []string{"worker1"}}
+	manager := &commandstest.FakeCommandLine{CliArgs: []string{"manager1"}}
+
+	assert.EqualError(t, cmdSwarmDemote(worker, api), "worker1 is already a worker")
+	assert.Equal(t, errSwarmModeLastManager, cmdSwarmDemote(manager, api))
+
+	assert.NoError(t, cmdSwarmPromote(worker, api))
+	h, _ := api.Load("worker1")
+	assert.Equal(t, swarmmode.RoleManager, h.SwarmModeRole())
+
+	assert.NoError(t, cmdSwarmDemote(manager, api))
+	h, _ = api.Load("manager1")
+	assert.Equal(t, swarmmode.RoleWorker, h.SwarmModeRole())
+
+	assert.Equal(t, []string{
+		"tcp://10.0.0.1:2376 manager node2",
+		"tcp://10.0.0.2:2376 worker node1",
+	}, moder.Calls)
+
+	assert.Equal(t, errSwarmModeNoMaster, cmdSwarmRotateMaster(worker, api))
+}
+
+func TestCmdSwarmStandaloneErrors(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			newSwarmTestHost("master", &swarm.Options{IsSwarm: true, Master: true, Discovery: "token://abc"}),
+			newSwarmTestHost("agent", &swarm.Options{IsSwarm: true, Agent: true, Discovery: "token://abc"}),
+			newSwarmTestHost("alone", &swarm.Options{}),
+		},
+	}
+
+	for _, test := range []struct {
+		command  func(CommandLine, libmachine.API) error
+		machine  string
+		expected string
+	}{
+		{cmdSwarmPromote, "agent", "master is the master of the Swarm cluster, use rotate-master to replace it"},
+		{cmdSwarmPromote, "master", "master is already the master"},
+		{cmdSwarmDemote, "agent", "agent isn't the master"},
+		{cmdSwarmRemove, "master", "master is the master of its Swarm cluster, rotate the master to another machine first"},
+		{cmdSwarmRemove, "alone", "alone isn't part of a cluster"},
+		{cmdSwarmRotateMaster, "alone", "alone isn't part of a Swarm cluster"},
+	} {
+		err := test.command(&commandstest.FakeCommandLine{CliArgs: []string{test.machine}}, api)
+
+		assert.EqualError(t, err, test.expected)
+	}
+}
+
+func TestSwarmDoctorErrors(t *testing.T) {
+	notSwarm := newSwarmTestHost("alone", &swarm.Options{})
+	stopped := newSwarmTestHost("agent", &swarm.Options{IsSwarm: true, Agent: true, Discovery: "token://abc"})
+	stopped.Driver = &fakedriver.Driver{MockState: state.Stopped}
+
+	_, err := swarmDoctor(notSwarm)
+	assert.EqualError(t, err, "The machine isn't part of a Swarm cluster")
+
+	_, err = swarmDoctor(stopped)
+	assert.EqualError(t, err, "The machine is Stopped, it must be running to repair its Swarm containers")
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/filesync"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var errSyncNoMachinePath = errors.New("Error: The destination must be on a machine, e.g. machinename:/path/to/dir")
+
+func cmdSync(c CommandLine, api libmachine.API) error {
+	args := c.Args()
+	if len(args) != 2 {
+		c.ShowHelp()
+		return errWrongNumberArguments
+	}
+
+	syncer, err := newSyncer(args[0], args[1], c.StringSlice("exclude"), api)
+	if err != nil {
+		return err
+	}
+	syncer.Force = c.Bool("force")
+
+	conflicts, err := syncer.Sync()
+	if err != nil {
+		return err
+	}
+	filesync.WarnConflicts(conflicts)
+	log.Infof("%s synced to %s", args[0], args[1])
+
+	if !c.Bool("watch") {
+		return nil
+	}
+
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	go func() {
+		<-interrupt
+		close(stop)
+	}()
+
+	log.Info("Watching for changes, press Ctrl-C to stop...")
+	return syncer.Watch(stop)
+}
+
+func newSyncer(localDir, dest string, excludes []string, api libmachine.API) (*filesync.Syncer, error) {
+	h, remoteDir, err := getInfoForScpArg(dest, api)
+	if err != nil {
+		return nil, err
+	}
+
+	if h == nil {
+		return nil, errSyncNoMachinePath
+	}
+
+	if remoteDir == "" {
+		remoteDir = "."
+	}
+
+	client, err := h.CreateSSHClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return filesync.NewSyncer(client, localDir, remoteDir, excludes)
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/ssh/sshtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdSyncWrongArguments(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"./app"},
+	}
+
+	err := cmdSync(commandLine, newScpTestAPI("myfunhost"))
+	assert.Equal(t, errWrongNumberArguments, err)
+	assert.True(t, commandLine.HelpShown)
+}
+
+func TestCmdSyncNoMachinePath(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs:    []string{"./app", "/tmp/app"},
+		LocalFlags: &commandstest.FakeFlagger{},
+	}
+
+	err := cmdSync(commandLine, newScpTestAPI("myfunhost"))
+	assert.Equal(t, errSyncNoMachinePath, err)
+}
+
+func TestCmdSync(t *testing.T) {
+	clientCreator := &FakeSSHClientCreator{}
+	host.SetSSHClientCreator(clientCreator)
+	defer host.SetSSHClientCreator(&host.StandardSSHClientCreator{})
+
+	dir, err := ioutil.TempDir("", "machine-test-sync-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{dir, "myfunhost:/home/docker/app"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"exclude": []string{"*.log"},
+			},
+		},
+	}
+
+	err = cmdSync(commandLine, newScpTestAPI("myfunhost"))
+	assert.NoError(t, err)
+
+	client := clientCreator.client.(*sshtest.FakeClient)
+	if assert.Len(t, client.Uploads, 1) {
+		assert.Equal(t, dir+string(filepath.Separator)+".", client.Uploads[0].Src)
+		assert.Equal(t, "/home/docker/app", client.Uploads[0].Dst)
+	}
+}
@@ -1,7 +1,100 @@
 package commands
 
-import "github.com/docker/machine/libmachine"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// latestEngineVersion is given to --engine-version to unpin the version of
+// the engine and follow the latest one again.
+const latestEngineVersion = "latest"
+
+var errUpgradeMachineNotRunning = errors.New("machine is not running")
 
 func cmdUpgrade(c CommandLine, api libmachine.API) error {
-	return runAction("upgrade", c, api)
+	version := c.String("engine-version")
+
+	if c.Bool("dry-run") {
+		hosts, err := loadActionHosts(c, api)
+		if err != nil {
+			return err
+		}
+
+		return printUpgradePlan(os.Stdout, hosts, version, currentEngineVersion)
+	}
+
+	return runActionWith("upgrade", c, api, func(h *host.Host) {
+		if version != "" && h.HostOptions != nil && h.HostOptions.EngineOptions != nil {
+			h.HostOptions.EngineOptions.Version = pinnedEngineVersion(version)
+		}
+	})
+}
+
+// pinnedEngineVersion returns the version to store in the engine options
+// for the value of --engine-version.
+func pinnedEngineVersion(version string) string {
+	if version == latestEngineVersion {
+		return ""
+	}
+	return version
+}
+
+// upgradeTarget returns the version of the engine an upgrade installs on the
+// host.
+func upgradeTarget(h *host.Host, version string) string {
+	if version == "" && h.HostOptions != nil && h.HostOptions.EngineOptions != nil && h.HostOptions.EngineOptions.InstallBundle != "" {
+		return "bundle " + h.HostOptions.EngineOptions.InstallBundle
+	}
+	if version == "" {
+		version = h.EngineVersion()
+	}
+	if pinnedEngineVersion(version) == "" {
+		return latestEngineVersion
+	}
+	return version
+}
+
+func currentEngineVersion(h *host.Host) (string, error) {
+	currentState, err := h.Driver.GetState()
+	if err != nil {
+		return "", err
+	}
+	if currentState != state.Running {
+		return "", errUpgradeMachineNotRunning
+	}
+
+	provisioner, err := h.Provisioner()
+	if err != nil {
+		return "", err
+	}
+
+	return provision.EngineVersion(provisioner)
+}
+
+// printUpgradePlan shows the current and target versions of the engine of
+// each host, without changing anything.
+func printUpgradePlan(w io.Writer, hosts []*host.Host, version string, current func(*host.Host) (string, error)) error {
+	tabWriter := tabwriter.NewWriter(w, 5, 1, 3, ' ', 0)
+
+	fmt.Fprintln(tabWriter, "NAME\tCURRENT\tTARGET\tERRORS")
+	for _, h := range hosts {
+		currentVersion, err := current(h)
+		errorMessage := ""
+		if err != nil {
+			currentVersion = "Unknown"
+			errorMessage = err.Error()
+		}
+
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\n", h.Name, currentVersion, upgradeTarget(h, version), errorMessage)
+	}
+
+	return tabWriter.Flush()
 }
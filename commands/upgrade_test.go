@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func newUpgradeTestHost(name, version string) *host.Host {
+	return &host.Host{
+		Name:   name,
+		Driver: &fakedriver.Driver{MockState: state.Running},
+		HostOptions: &host.Options{
+			EngineOptions: &engine.Options{Version: version},
+		},
+	}
+}
+
+func TestUpgradeTarget(t *testing.T) {
+	assert.Equal(t, "latest", upgradeTarget(newUpgradeTestHost("a", ""), ""))
+	assert.Equal(t, "17.03.1-ce", upgradeTarget(newUpgradeTestHost("a", "17.03.1-ce"), ""))
+	assert.Equal(t, "17.06.0-ce", upgradeTarget(newUpgradeTestHost("a", "17.03.1-ce"), "17.06.0-ce"))
+	assert.Equal(t, "latest", upgradeTarget(newUpgradeTestHost("a", "17.03.1-ce"), "latest"))
+
+	bundled := newUpgradeTestHost("a", "")
+	bundled.HostOptions.EngineOptions.InstallBundle = "/tmp/docker.tgz"
+	assert.Equal(t, "bundle /tmp/docker.tgz", upgradeTarget(bundled, ""))
+}
+
+func TestPrintUpgradePlan(t *testing.T) {
+	hosts := []*host.Host{
+		newUpgradeTestHost("pinned", "17.03.1-ce"),
+		newUpgradeTestHost("stopped", ""),
+	}
+	current := func(h *host.Host) (string, error) {
+		if h.Name == "stopped" {
+			return "", errUpgradeMachineNotRunning
+		}
+		return "17.03.0-ce", nil
+	}
+
+	buf := &bytes.Buffer{}
+	err := printUpgradePlan(buf, hosts, "", current)
+
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, []string{"NAME", "CURRENT", "TARGET", "ERRORS"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"pinned", "17.03.0-ce", "17.03.1-ce"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"stopped", "Unknown", "latest", "machine", "is", "not", "running"}, strings.Fields(lines[2]))
+}
+
+func TestCmdUpgradeDryRunKeepsVersion(t *testing.T) {
+	h := newUpgradeTestHost("default", "17.03.1-ce")
+	h.Driver = &fakedriver.Driver{MockState: state.Stopped}
+	api := &libmachinetest.FakeAPI{Hosts: []*host.Host{h}}
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"default"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"engine-version": "latest",
+				"dry-run":        true,
+			},
+		},
+	}
+
+	err := cmdUpgrade(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "17.03.1-ce", h.EngineVersion())
+}
+
+func TestCmdUpgradeNotRunning(t *testing.T) {
+	h := newUpgradeTestHost("default", "")
+	h.Driver = &fakedriver.Driver{MockState: state.Stopped}
+	api := &libmachinetest.FakeAPI{Hosts: []*host.Host{h}}
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"default"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"engine-version": "17.03.1-ce",
+			},
+		},
+	}
+
+	err := cmdUpgrade(commandLine, api)
+
+	assert.Equal(t, errors.New("Error: machine must be running to upgrade."), err)
+}
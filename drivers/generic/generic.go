@@ -3,10 +3,12 @@ package generic
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/docker/machine/libmachine/drivers"
@@ -21,6 +23,11 @@ type Driver struct {
 	*drivers.BaseDriver
 	EnginePort int
 	SSHKey     string
+	// SSHPassword is only kept while the machine is created, it isn't
+	// saved in the config of the machine. SSHPasswordSource is read
+	// instead every time the machine is reached.
+	SSHPassword       string `json:"-"`
+	SSHPasswordSource string
 }
 
 const (
@@ -54,6 +61,18 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  "",
 			EnvVar: "GENERIC_SSH_KEY",
 		},
+		mcnflag.StringFlag{
+			Name:   "generic-ssh-password",
+			Usage:  "SSH password, used if key authentication fails (password and keyboard-interactive)",
+			Value:  "",
+			EnvVar: "GENERIC_SSH_PASSWORD",
+		},
+		mcnflag.StringFlag{
+			Name:   "generic-ssh-password-source",
+			Usage:  "Source of the SSH password, as env:NAME or file:PATH, read every time the machine is reached",
+			Value:  "",
+			EnvVar: "GENERIC_SSH_PASSWORD_SOURCE",
+		},
 		mcnflag.IntFlag{
 			Name:   "generic-ssh-port",
 			Usage:  "SSH port",
@@ -91,17 +110,39 @@ func (d *Driver) GetSSHKeyPath() string {
 	return d.SSHKeyPath
 }
 
+// GetSSHPassword returns the password given when the machine was created,
+// or reads it from its source.
+func (d *Driver) GetSSHPassword() string {
+	if d.SSHPassword != "" || d.SSHPasswordSource == "" {
+		return d.SSHPassword
+	}
+
+	password, err := readPassword(d.SSHPasswordSource)
+	if err != nil {
+		log.Warnf("Unable to read the SSH password: %s", err)
+		return ""
+	}
+
+	return password
+}
+
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.EnginePort = flags.Int("generic-engine-port")
 	d.IPAddress = flags.String("generic-ip-address")
 	d.SSHUser = flags.String("generic-ssh-user")
 	d.SSHKey = flags.String("generic-ssh-key")
+	d.SSHPassword = flags.String("generic-ssh-password")
+	d.SSHPasswordSource = flags.String("generic-ssh-password-source")
 	d.SSHPort = flags.Int("generic-ssh-port")
 
 	if d.IPAddress == "" {
 		return errors.New("generic driver requires the --generic-ip-address option")
 	}
 
+	if d.SSHPassword != "" && d.SSHPasswordSource != "" {
+		return errors.New("--generic-ssh-password can't be used with --generic-ssh-password-source")
+	}
+
 	return nil
 }
 
@@ -114,12 +155,20 @@ func (d *Driver) PreCreateCheck() error {
 		// TODO: validate the key is a valid key
 	}
 
+	if d.SSHPasswordSource != "" {
+		if _, err := readPassword(d.SSHPasswordSource); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (d *Driver) Create() error {
 	if d.SSHKey == "" {
-		log.Info("No SSH key specified. Assuming an existing key at the default location.")
+		if d.SSHPassword == "" && d.SSHPasswordSource == "" {
+			log.Info("No SSH key specified. Assuming an existing key at the default location or in ssh-agent.")
+		}
 	} else {
 		log.Info("Importing SSH key...")
 
@@ -183,6 +232,32 @@ func (d *Driver) Remove() error {
 	return nil
 }
 
+// readPassword reads the password held by the variable or the file given
+// as env:NAME or file:PATH.
+func readPassword(source string) (string, error) {
+	parts := strings.SplitN(source, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", fmt.Errorf("Invalid SSH password source %q, expected env:NAME or file:PATH", source)
+	}
+
+	switch parts[0] {
+	case "env":
+		password := os.Getenv(parts[1])
+		if password == "" {
+			return "", fmt.Errorf("The SSH password variable %s is not set", parts[1])
+		}
+		return password, nil
+	case "file":
+		content, err := ioutil.ReadFile(parts[1])
+		if err != nil {
+			return "", fmt.Errorf("Error reading the SSH password: %s", err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+
+	return "", fmt.Errorf("Invalid SSH password source %q, expected env:NAME or file:PATH", source)
+}
+
 func copySSHKey(src, dst string) error {
 	if err := mcnutils.CopyFile(src, dst); err != nil {
 		return fmt.Errorf("unable to copy ssh key: %s", err)
@@ -1,6 +1,10 @@
 package generic
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/docker/machine/libmachine/drivers"
@@ -24,3 +28,42 @@ func TestSetConfigFromFlags(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Empty(t, checkFlags.InvalidFlags)
 }
+
+func TestSSHPasswordNotSaved(t *testing.T) {
+	driver := NewDriver("default", "path").(*Driver)
+	driver.SSHPassword = "secret"
+	driver.SSHPasswordSource = "env:GENERIC_TEST_PASSWORD"
+
+	data, err := json.Marshal(driver)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+	assert.Contains(t, string(data), `"SSHPasswordSource":"env:GENERIC_TEST_PASSWORD"`)
+}
+
+func TestGetSSHPasswordFromSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generic")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	passwordFile := filepath.Join(dir, "password")
+	assert.NoError(t, ioutil.WriteFile(passwordFile, []byte("from-file\n"), 0600))
+
+	os.Setenv("GENERIC_TEST_PASSWORD", "from-env")
+	defer os.Unsetenv("GENERIC_TEST_PASSWORD")
+
+	driver := NewDriver("default", "path").(*Driver)
+
+	driver.SSHPasswordSource = "env:GENERIC_TEST_PASSWORD"
+	assert.Equal(t, "from-env", driver.GetSSHPassword())
+
+	driver.SSHPasswordSource = "file:" + passwordFile
+	assert.Equal(t, "from-file", driver.GetSSHPassword())
+
+	driver.SSHPasswordSource = "file:" + filepath.Join(dir, "missing")
+	assert.Equal(t, "", driver.GetSSHPassword())
+	assert.Error(t, driver.PreCreateCheck())
+
+	driver.SSHPasswordSource = "vault:secret"
+	assert.EqualError(t, driver.PreCreateCheck(), `Invalid SSH password source "vault:secret", expected env:NAME or file:PATH`)
+}
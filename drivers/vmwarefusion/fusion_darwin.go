@@ -746,6 +746,7 @@ func executeSSHCommand(command string, d *Driver) error {
 		Auth: []cryptossh.AuthMethod{
 			cryptossh.Password(d.SSHPassword),
 		},
+		HostKeyCallback: cryptossh.InsecureIgnoreHostKey(),
 	}
 
 	client, err := cryptossh.Dial("tcp", fmt.Sprintf("%s:%d", d.IPAddress, d.SSHPort), config)
@@ -0,0 +1,253 @@
+// Package audit keeps an append-only log of the commands run on each machine
+// over SSH.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// FileName is the name of the audit log in the machine directories.
+	FileName = "audit.log"
+
+	// MaxOutput is the number of bytes of output kept in a record.
+	MaxOutput = 4096
+)
+
+// The types of records.
+const (
+	TypeCommand = "command"
+	TypeFile    = "file"
+	TypeSession = "session"
+)
+
+var (
+	mu          sync.Mutex
+	machinesDir string
+)
+
+// Record describes a command run on a machine.
+type Record struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	Machine    string    `json:"machine"`
+	Type       string    `json:"type"`
+	Command    string    `json:"command"`
+	ExitStatus int       `json:"exit_status"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	Output     string    `json:"output,omitempty"`
+	Truncated  bool      `json:"truncated,omitempty"`
+}
+
+// Duration returns how long the command took.
+func (r Record) Duration() time.Duration {
+	return time.Duration(r.DurationMs) * time.Millisecond
+}
+
+// SetMachinesDir enables the audit log. The records are written to the
+// directories of dir named after the machines. Nothing is recorded while it
+// is empty.
+func SetMachinesDir(dir string) {
+	mu.Lock()
+	defer mu.Unlock()
+	machinesDir = dir
+}
+
+// Path returns the path of the audit log of the given machine.
+func Path(machineName string) string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if machinesDir == "" {
+		return ""
+	}
+	return filepath.Join(machinesDir, machineName, FileName)
+}
+
+// Start returns a function to call once the command is done, which records
+// it along with its output and error.
+func Start(machineName, recordType, command string) func(output string, err error) {
+	start := time.Now()
+
+	return func(output string, err error) {
+		record := Record{
+			Time:       start,
+			Machine:    machineName,
+			Type:       recordType,
+			Command:    command,
+			ExitStatus: exitStatus(err),
+			DurationMs: int64(time.Since(start) / time.Millisecond),
+			Output:     output,
+		}
+		if err != nil {
+			record.Error = err.Error()
+		}
+
+		if err := Append(record); err != nil {
+			log.Warnf("Error writing the audit log of %s: %s", machineName, err)
+		}
+	}
+}
+
+// Append adds record to the audit log of its machine. The secrets are
+// redacted and the output is truncated first.
+func Append(record Record) error {
+	path := Path(record.Machine)
+	if path == "" {
+		return nil
+	}
+
+	// The machine may not exist yet, or not anymore.
+	if _, err := os.Stat(filepath.Dir(path)); os.IsNotExist(err) {
+		log.Debugf("Not recording %q: %s doesn't exist", record.Command, filepath.Dir(path))
+		return nil
+	}
+
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	if record.User == "" {
+		record.User = mcnutils.GetUsername()
+	}
+	record.Command = log.RedactSecrets(record.Command)
+	record.Output = log.RedactSecrets(record.Output)
+	if len(record.Output) > MaxOutput {
+		record.Output = record.Output[:MaxOutput]
+		record.Truncated = true
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Read returns the records of the audit log of the given machine, oldest
+// first.
+func Read(machineName string) ([]Record, error) {
+	path := Path(machineName)
+	if path == "" {
+		return nil, fmt.Errorf("The audit log is disabled")
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []Record{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records := []Record{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("Error reading %s, line %d: %s", path, n, err)
+		}
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// Filter selects records. The zero value selects everything.
+type Filter struct {
+	Since   time.Time
+	Type    string
+	Command string
+	Failed  bool
+}
+
+// Match tells whether record is selected by the filter.
+func (f Filter) Match(record Record) bool {
+	switch {
+	case !f.Since.IsZero() && record.Time.Before(f.Since):
+		return false
+	case f.Type != "" && record.Type != f.Type:
+		return false
+	case f.Command != "" && !strings.Contains(record.Command, f.Command):
+		return false
+	case f.Failed && record.ExitStatus == 0 && record.Error == "":
+		return false
+	}
+
+	return true
+}
+
+// exitStatus returns the exit status of the remote command, or -1 if it
+// didn't complete.
+func exitStatus(err error) int {
+	switch err := err.(type) {
+	case nil:
+		return 0
+	case *ssh.ExitError:
+		return err.ExitStatus()
+	case *exec.ExitError:
+		if status, ok := err.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus()
+		}
+	}
+
+	return -1
+}
+
+// Transcript keeps the beginning of the output of a session, to be recorded
+// along with it.
+type Transcript struct {
+	buf bytes.Buffer
+}
+
+func (t *Transcript) Write(p []byte) (int, error) {
+	// One more byte than what's recorded tells that the output was
+	// truncated.
+	if room := MaxOutput + 1 - t.buf.Len(); room > 0 {
+		if len(p) > room {
+			t.buf.Write(p[:room])
+		} else {
+			t.buf.Write(p)
+		}
+	}
+
+	return len(p), nil
+}
+
+func (t *Transcript) String() string {
+	return t.buf.String()
+}
@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupMachinesDir(t *testing.T, machines ...string) func() {
+	dir, err := ioutil.TempDir("", "machine-test-audit-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, machine := range machines {
+		if err := os.Mkdir(filepath.Join(dir, machine), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	SetMachinesDir(dir)
+
+	return func() {
+		SetMachinesDir("")
+		os.RemoveAll(dir)
+	}
+}
+
+func TestAppendDisabled(t *testing.T) {
+	assert.NoError(t, Append(Record{Machine: "dev", Command: "uptime"}))
+	assert.Equal(t, "", Path("dev"))
+
+	_, err := Read("dev")
+	assert.EqualError(t, err, "The audit log is disabled")
+}
+
+func TestAppendAndRead(t *testing.T) {
+	defer setupMachinesDir(t, "dev")()
+
+	records, err := Read("dev")
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	Start("dev", TypeCommand, "uptime")(" 10:00:00 up 1 day", nil)
+	Start("dev", TypeCommand, "docker login -p s3cr3t")("", errors.New("connection lost"))
+	Start("dev", TypeSession, "")(strings.Repeat("x", MaxOutput+10), nil)
+
+	// The machines which don't exist are ignored.
+	Start("unknown", TypeCommand, "uptime")("", nil)
+
+	records, err = Read("dev")
+	assert.NoError(t, err)
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, "dev", records[0].Machine)
+		assert.Equal(t, TypeCommand, records[0].Type)
+		assert.Equal(t, "uptime", records[0].Command)
+		assert.Equal(t, 0, records[0].ExitStatus)
+		assert.Equal(t, " 10:00:00 up 1 day", records[0].Output)
+		assert.NotEmpty(t, records[0].User)
+		assert.False(t, records[0].Time.IsZero())
+
+		assert.Equal(t, "docker login -p <REDACTED>", records[1].Command)
+		assert.Equal(t, -1, records[1].ExitStatus)
+		assert.Equal(t, "connection lost", records[1].Error)
+
+		assert.Len(t, records[2].Output, MaxOutput)
+		assert.True(t, records[2].Truncated)
+	}
+
+	info, err := os.Stat(Path("dev"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, err = os.Stat(Path("unknown"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestExitStatus(t *testing.T) {
+	assert.Equal(t, 0, exitStatus(nil))
+	assert.Equal(t, -1, exitStatus(errors.New("dial error")))
+	assert.Equal(t, 3, exitStatus(exec.Command("sh", "-c", "exit 3").Run()))
+}
+
+func TestFilter(t *testing.T) {
+	now := time.Now()
+	record := Record{
+		Time:       now.Add(-time.Hour),
+		Type:       TypeCommand,
+		Command:    "sudo systemctl restart docker",
+		ExitStatus: 1,
+	}
+
+	assert.True(t, Filter{}.Match(record))
+	assert.True(t, Filter{Since: now.Add(-2 * time.Hour)}.Match(record))
+	assert.False(t, Filter{Since: now.Add(-time.Minute)}.Match(record))
+	assert.True(t, Filter{Type: TypeCommand}.Match(record))
+	assert.False(t, Filter{Type: TypeSession}.Match(record))
+	assert.True(t, Filter{Command: "systemctl"}.Match(record))
+	assert.False(t, Filter{Command: "apt-get"}.Match(record))
+	assert.True(t, Filter{Failed: true}.Match(record))
+
+	record.ExitStatus = 0
+	assert.False(t, Filter{Failed: true}.Match(record))
+}
+
+func TestTranscript(t *testing.T) {
+	transcript := &Transcript{}
+
+	n, err := transcript.Write([]byte("hello "))
+	assert.Equal(t, 6, n)
+	assert.NoError(t, err)
+
+	n, err = transcript.Write([]byte(strings.Repeat("x", 2*MaxOutput)))
+	assert.Equal(t, 2*MaxOutput, n)
+	assert.NoError(t, err)
+
+	assert.Len(t, transcript.String(), MaxOutput+1)
+	assert.True(t, strings.HasPrefix(transcript.String(), "hello x"))
+}
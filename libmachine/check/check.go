@@ -13,7 +13,7 @@ import (
 
 var (
 	DefaultConnChecker ConnChecker
-	ErrSwarmNotStarted = errors.New("Connection to Swarm cannot be checked but the certs are valid. Maybe swarm is not started")
+	ErrSwarmNotStarted = errors.New("Connection to Swarm cannot be checked but the certs are valid. Maybe swarm is not started, run 'docker-machine swarm doctor' to restart it")
 )
 
 func init() {
@@ -62,8 +62,8 @@ func (mcc *MachineConnChecker) Check(h *host.Host, swarm bool) (string, *auth.Op
 
 	if err := checkCert(u.Host, authOptions); err != nil {
 		if swarm {
-			// Connection to the swarm port cannot be checked. Maybe it's just the swarm containers that are down
-			// TODO: check the containers and restart them
+			// Connection to the swarm port cannot be checked. Maybe it's just the swarm containers that are down,
+			// which 'docker-machine swarm doctor' repairs.
 			// Let's check the non-swarm connection to give a better error message to the user.
 			if _, _, err := mcc.Check(h, false); err == nil {
 				return "", &auth.Options{}, ErrSwarmNotStarted
@@ -0,0 +1,217 @@
+// Package dockercontext writes the contexts of the Docker CLI reaching the
+// engines of the machines, in the context store of the CLI config dir.
+package dockercontext
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+const (
+	metaFile = "meta.json"
+	// managedKey marks the metadata of the contexts written by Machine, the
+	// other ones are left alone.
+	managedKey = "DockerMachine"
+	// storeKey holds the store of the machine of the context, each store
+	// only touches its own contexts.
+	storeKey = "DockerMachineStore"
+)
+
+var (
+	// ErrNotManaged is returned when a context of the name of a machine
+	// exists but wasn't written by Machine.
+	ErrNotManaged = errors.New("A context which wasn't created by Docker Machine already has this name")
+	// ErrOtherStore is returned when a context of the name of a machine was
+	// written for a machine of another store.
+	ErrOtherStore = errors.New("The context of a machine of another store already has this name")
+)
+
+// Context is a context of the Docker CLI reaching the engine of a machine
+// over TLS.
+type Context struct {
+	Name string
+	Host string
+	// Store is the machines dir of the store of the machine.
+	Store string
+	// CACert, Cert and Key are the paths of the TLS material the context
+	// is written with.
+	CACert string
+	Cert   string
+	Key    string
+}
+
+type meta struct {
+	Name      string
+	Metadata  map[string]interface{}
+	Endpoints map[string]endpoint
+}
+
+type endpoint struct {
+	Host          string
+	SkipTLSVerify bool
+}
+
+// Dir returns the config dir of the Docker CLI, where the context store is.
+func Dir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	return filepath.Join(mcnutils.GetHomeDir(), ".docker")
+}
+
+// id returns the directory name of a context in the store, the CLI names
+// them after the digest of the context name.
+func id(name string) string {
+	digest := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(digest[:])
+}
+
+func metaDir(dir, name string) string {
+	return filepath.Join(dir, "contexts", "meta", id(name))
+}
+
+func tlsDir(dir, name string) string {
+	return filepath.Join(dir, "contexts", "tls", id(name))
+}
+
+func readMeta(path string) (*meta, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &meta{}
+	if err := json.Unmarshal(content, m); err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", path, err)
+	}
+
+	return m, nil
+}
+
+func (m *meta) managed() bool {
+	managed, _ := m.Metadata[managedKey].(bool)
+	return managed
+}
+
+// store returns the store of the machine of the context, it's empty for the
+// contexts written before it was recorded.
+func (m *meta) store() string {
+	store, _ := m.Metadata[storeKey].(string)
+	return store
+}
+
+// owned checks that the context of the name, when it exists, was written by
+// Machine for a machine of the store, or before the store was recorded.
+func owned(dir, name, store string) (bool, error) {
+	m, err := readMeta(filepath.Join(metaDir(dir, name), metaFile))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+
+	if !m.managed() {
+		return true, ErrNotManaged
+	}
+	if m.store() != "" && m.store() != store {
+		return true, ErrOtherStore
+	}
+
+	return true, nil
+}
+
+// Write writes the context in the store of dir, in place of the one of the
+// same name when Machine wrote it for a machine of the same store.
+func Write(dir string, context Context) error {
+	if _, err := owned(dir, context.Name, context.Store); err != nil {
+		return err
+	}
+
+	certs := filepath.Join(tlsDir(dir, context.Name), "docker")
+	if err := os.MkdirAll(certs, 0700); err != nil {
+		return err
+	}
+
+	for name, src := range map[string]string{
+		"ca.pem":   context.CACert,
+		"cert.pem": context.Cert,
+		"key.pem":  context.Key,
+	} {
+		content, err := ioutil.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(certs, name), content, 0600); err != nil {
+			return err
+		}
+	}
+
+	content, err := json.Marshal(meta{
+		Name: context.Name,
+		Metadata: map[string]interface{}{
+			"Description": fmt.Sprintf("Docker Machine %s", context.Name),
+			managedKey:    true,
+			storeKey:      context.Store,
+		},
+		Endpoints: map[string]endpoint{
+			"docker": {Host: context.Host},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(metaDir(dir, context.Name), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(metaDir(dir, context.Name), metaFile), content, 0644)
+}
+
+// Remove removes the context of the name from the store of dir when Machine
+// wrote it for a machine of the store. Nothing is done when there's no such
+// context.
+func Remove(dir, name, store string) error {
+	exists, err := owned(dir, name, store)
+	if err != nil || !exists {
+		return err
+	}
+
+	if err := os.RemoveAll(tlsDir(dir, name)); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(metaDir(dir, name))
+}
+
+// List returns the names of the contexts of the store of dir written by
+// Machine for the machines of the store.
+func List(dir, store string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "contexts", "meta"))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		m, err := readMeta(filepath.Join(dir, "contexts", "meta", entry.Name(), metaFile))
+		if err != nil || !m.managed() || m.store() != store {
+			continue
+		}
+		names = append(names, m.Name)
+	}
+
+	return names, nil
+}
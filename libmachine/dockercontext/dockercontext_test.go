@@ -0,0 +1,133 @@
+package dockercontext
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCerts(t *testing.T, dir string) Context {
+	for _, name := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0600))
+	}
+
+	return Context{
+		CACert: filepath.Join(dir, "ca.pem"),
+		Cert:   filepath.Join(dir, "cert.pem"),
+		Key:    filepath.Join(dir, "key.pem"),
+	}
+}
+
+func TestWriteListRemove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	context := writeCerts(t, dir)
+	context.Name = "dev"
+	context.Host = "tcp://10.0.0.1:2376"
+	context.Store = "/home/user/.docker/machine/machines"
+
+	assert.NoError(t, Write(dir, context))
+
+	// The store layout is the one of the CLI.
+	id := "ef260e9aa3c673af240d17a2660480361a8e081d1ffeca2a5ed0e3219fc18567"
+	content, err := ioutil.ReadFile(filepath.Join(dir, "contexts", "meta", id, "meta.json"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"Name": "dev",
+		"Metadata": {"Description": "Docker Machine dev", "DockerMachine": true, "DockerMachineStore": "/home/user/.docker/machine/machines"},
+		"Endpoints": {"docker": {"Host": "tcp://10.0.0.1:2376", "SkipTLSVerify": false}}
+	}`, string(content))
+	key, err := ioutil.ReadFile(filepath.Join(dir, "contexts", "tls", id, "docker", "key.pem"))
+	assert.NoError(t, err)
+	assert.Equal(t, "key.pem", string(key))
+
+	context.Host = "tcp://10.0.0.2:2376"
+	assert.NoError(t, Write(dir, context))
+
+	names, err := List(dir, context.Store)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dev"}, names)
+
+	assert.NoError(t, Remove(dir, "dev", context.Store))
+	assert.NoError(t, Remove(dir, "dev", context.Store))
+	_, err = os.Stat(filepath.Join(dir, "contexts", "tls", id))
+	assert.True(t, os.IsNotExist(err))
+
+	names, err = List(dir, context.Store)
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+func TestUnmanagedContextsAreLeftAlone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	other := metaDir(dir, "prod")
+	assert.NoError(t, os.MkdirAll(other, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(other, "meta.json"), []byte(`{"Name":"prod","Metadata":{},"Endpoints":{"docker":{"Host":"ssh://prod"}}}`), 0644))
+
+	context := writeCerts(t, dir)
+	context.Name = "prod"
+
+	assert.Equal(t, ErrNotManaged, Write(dir, context))
+	assert.Equal(t, ErrNotManaged, Remove(dir, "prod", ""))
+
+	names, err := List(dir, "")
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+func TestContextsOfOtherStoresAreLeftAlone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	context := writeCerts(t, dir)
+	context.Name = "dev"
+	context.Store = "/store/a/machines"
+
+	assert.NoError(t, Write(dir, context))
+
+	names, err := List(dir, "/store/b/machines")
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+
+	other := context
+	other.Store = "/store/b/machines"
+	assert.Equal(t, ErrOtherStore, Write(dir, other))
+	assert.Equal(t, ErrOtherStore, Remove(dir, "dev", other.Store))
+
+	names, err = List(dir, context.Store)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dev"}, names)
+}
+
+func TestContextsWithoutStoreAreAdopted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	old := metaDir(dir, "dev")
+	assert.NoError(t, os.MkdirAll(old, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(old, "meta.json"), []byte(`{"Name":"dev","Metadata":{"DockerMachine":true},"Endpoints":{"docker":{"Host":"tcp://10.0.0.1:2376"}}}`), 0644))
+
+	// It isn't known which store it belongs to, so it isn't pruned.
+	names, err := List(dir, "/store/a/machines")
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+
+	context := writeCerts(t, dir)
+	context.Name = "dev"
+	context.Store = "/store/a/machines"
+	assert.NoError(t, Write(dir, context))
+
+	names, err = List(dir, context.Store)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dev"}, names)
+}
@@ -72,6 +72,13 @@ type Driver interface {
 	Stop() error
 }
 
+// SSHPasswordDriver is implemented by the drivers able to authenticate over
+// SSH with a password, in addition to or instead of a private key.
+type SSHPasswordDriver interface {
+	// GetSSHPassword returns the password for use with ssh
+	GetSSHPassword() string
+}
+
 var ErrHostIsNotRunning = errors.New("Host is not running")
 
 type DriverOptions interface {
@@ -72,6 +72,7 @@ const (
 	GetIPMethod              = `.GetIP`
 	GetSSHHostnameMethod     = `.GetSSHHostname`
 	GetSSHKeyPathMethod      = `.GetSSHKeyPath`
+	GetSSHPasswordMethod     = `.GetSSHPassword`
 	GetSSHPortMethod         = `.GetSSHPort`
 	GetSSHUsernameMethod     = `.GetSSHUsername`
 	GetStateMethod           = `.GetState`
@@ -304,6 +305,17 @@ func (c *RPCClientDriver) GetSSHKeyPath() string {
 	return path
 }
 
+// GetSSHPassword returns the SSH password. Plugins built before this method
+// existed don't have any password.
+func (c *RPCClientDriver) GetSSHPassword() string {
+	password, err := c.rpcStringCall(GetSSHPasswordMethod)
+	if err != nil {
+		log.Debugf("Error attempting call to get SSH password: %s", err)
+	}
+
+	return password
+}
+
 func (c *RPCClientDriver) GetSSHPort() (int, error) {
 	var port int
 
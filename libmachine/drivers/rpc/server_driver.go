@@ -169,6 +169,13 @@ func (r *RPCServerDriver) GetSSHKeyPath(_ *struct{}, reply *string) error {
 	return nil
 }
 
+func (r *RPCServerDriver) GetSSHPassword(_ *struct{}, reply *string) error {
+	if pd, ok := r.ActualDriver.(drivers.SSHPasswordDriver); ok {
+		*reply = pd.GetSSHPassword()
+	}
+	return nil
+}
+
 // GetSSHPort returns port for use with ssh
 func (r *RPCServerDriver) GetSSHPort(_ *struct{}, reply *int) error {
 	port, err := r.ActualDriver.GetSSHPort()
@@ -87,6 +87,17 @@ func (d *SerialDriver) GetSSHKeyPath() string {
 	return d.Driver.GetSSHKeyPath()
 }
 
+// GetSSHPassword returns the password for use with ssh, if the underlying
+// driver has one
+func (d *SerialDriver) GetSSHPassword() string {
+	d.Lock()
+	defer d.Unlock()
+	if pd, ok := d.Driver.(SSHPasswordDriver); ok {
+		return pd.GetSSHPassword()
+	}
+	return ""
+}
+
 // GetSSHPort returns port for use with ssh
 func (d *SerialDriver) GetSSHPort() (int, error) {
 	d.Lock()
@@ -2,7 +2,9 @@ package drivers
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/docker/machine/libmachine/audit"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/ssh"
@@ -19,21 +21,48 @@ func GetSSHClientFromDriver(d Driver) (ssh.Client, error) {
 		return nil, err
 	}
 
-	var auth *ssh.Auth
-	if d.GetSSHKeyPath() == "" {
-		auth = &ssh.Auth{}
-	} else {
-		auth = &ssh.Auth{
-			Keys: []string{d.GetSSHKeyPath()},
-		}
+	return NewSSHClient(d, address, port)
+}
+
+// NewSSHClient returns a client to SSH into the machine of the driver,
+// reached at address and port.
+func NewSSHClient(d Driver, address string, port int) (ssh.Client, error) {
+	client, err := ssh.NewClient(d.GetSSHUsername(), address, port, GetSSHAuthFromDriver(d))
+	if err != nil {
+		return nil, err
+	}
+
+	if nativeClient, ok := client.(*ssh.NativeClient); ok {
+		nativeClient.DriverName = d.DriverName()
+	}
+
+	return client, nil
+}
+
+// GetSSHAuthFromDriver returns the credentials to use to SSH into the
+// machine: its private key and, if the driver supports it, its password.
+func GetSSHAuthFromDriver(d Driver) *ssh.Auth {
+	auth := &ssh.Auth{}
+	if d.GetSSHKeyPath() != "" {
+		auth.Keys = []string{d.GetSSHKeyPath()}
 	}
 
-	client, err := ssh.NewClient(d.GetSSHUsername(), address, port, auth)
-	return client, err
+	if pd, ok := d.(SSHPasswordDriver); ok {
+		if password := pd.GetSSHPassword(); password != "" {
+			auth.Passwords = []string{password}
+		}
+	}
 
+	return auth
 }
 
+// RunSSHCommandFromDriver runs command on the machine and records it in the
+// machine's audit log.
 func RunSSHCommandFromDriver(d Driver, command string) (string, error) {
+	return runSSHCommandFromDriver(d, command, true)
+}
+
+func runSSHCommandFromDriver(d Driver, command string, audited bool) (string, error) {
 	client, err := GetSSHClientFromDriver(d)
 	if err != nil {
 		return "", err
@@ -41,7 +70,13 @@ func RunSSHCommandFromDriver(d Driver, command string) (string, error) {
 
 	log.Debugf("About to run SSH command:\n%s", command)
 
+	done := func(string, error) {}
+	if audited {
+		done = audit.Start(d.GetMachineName(), audit.TypeCommand, command)
+	}
+
 	output, err := client.Output(command)
+	done(output, err)
 	log.Debugf("SSH cmd err, output: %v: %s", err, output)
 	if err != nil {
 		return "", fmt.Errorf(`Something went wrong running an SSH command!
@@ -54,10 +89,51 @@ output  : %s
 	return output, nil
 }
 
+// WriteSSHFileFromDriver writes content to the file at path on the machine,
+// as the SSH user.
+func WriteSSHFileFromDriver(d Driver, path string, content []byte, mode os.FileMode) error {
+	client, err := GetSSHClientFromDriver(d)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("About to write file over SSH: %s", path)
+
+	done := audit.Start(d.GetMachineName(), audit.TypeFile, fmt.Sprintf("write %s (mode %o)", path, mode))
+	err = client.WriteFile(path, content, mode)
+	done("", err)
+	if err != nil {
+		return fmt.Errorf("Error writing %s over SSH: %s", path, err)
+	}
+
+	return nil
+}
+
+// UploadSSHFileFromDriver copies the local file src to dst on the machine,
+// as the SSH user. The file is streamed, it isn't read in memory first.
+func UploadSSHFileFromDriver(d Driver, src, dst string) error {
+	client, err := GetSSHClientFromDriver(d)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("About to upload file over SSH: %s", dst)
+
+	done := audit.Start(d.GetMachineName(), audit.TypeFile, fmt.Sprintf("upload %s to %s", src, dst))
+	err = client.Upload(src, dst, ssh.TransferOptions{})
+	done("", err)
+	if err != nil {
+		return fmt.Errorf("Error uploading %s over SSH: %s", src, err)
+	}
+
+	return nil
+}
+
 func sshAvailableFunc(d Driver) func() bool {
 	return func() bool {
 		log.Debug("Getting to WaitForSSH function...")
-		if _, err := RunSSHCommandFromDriver(d, "exit 0"); err != nil {
+		// The attempts made while the machine boots aren't worth recording.
+		if _, err := runSSHCommandFromDriver(d, "exit 0", false); err != nil {
 			log.Debugf("Error getting ssh command 'exit 0' : %s", err)
 			return false
 		}
@@ -18,4 +18,44 @@ type Options struct {
 	TLSVerify        bool `json:"TlsVerify"`
 	RegistryMirror   []string
 	InstallURL       string
+	// InstallBundle is the path of a local tarball of engine binaries or
+	// distribution packages, or its URL on a mirror the machine can reach,
+	// to install the engine from instead of InstallURL.
+	InstallBundle string
+	// Version pins the version of the engine, e.g. 17.03.1-ce. The latest
+	// one is installed when it's empty.
+	Version string
+	// Rootless runs the engine as the SSH user with rootless Docker, when
+	// the user can't use sudo.
+	Rootless bool
+	// RegistryAuth are the credentials the daemon user logs in to the
+	// registries with, as registry=source. Only the sources are stored, the
+	// credentials are read from them when provisioning.
+	RegistryAuth []string
+	// RegistryCA are the CA certs the engine trusts for the registries, as
+	// registry=path of the PEM file.
+	RegistryCA []string
+	// LogDriver is the default logging driver of the containers, and
+	// LogOpts its options, as key=value.
+	LogDriver string
+	LogOpts   []string
+	// CgroupDriver is the cgroup driver of the engine, cgroupfs or systemd.
+	CgroupDriver string
+	// LiveRestore keeps the containers running while the engine is down.
+	LiveRestore bool
+	// DefaultUlimits are the default ulimits of the containers, as
+	// name=soft[:hard].
+	DefaultUlimits []string
+	// DefaultAddressPools are the pools the subnets of the networks are
+	// taken from, as base=CIDR,size=N.
+	DefaultAddressPools []string
+	// Bip is the IP of the docker0 bridge, with its netmask in CIDR
+	// notation.
+	Bip string
+	// MTU is the MTU of the container networks, the engine's default when
+	// it's 0.
+	MTU int
+	// DataRoot is the directory of the state of the engine, instead of
+	// /var/lib/docker.
+	DataRoot string
 }
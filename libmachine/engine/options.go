@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/docker/go-units"
+)
+
+// CgroupDrivers are the cgroup drivers the engine can use.
+var CgroupDrivers = []string{"cgroupfs", "systemd"}
+
+// AddressPool is a pool the engine takes the subnets of the networks it
+// creates from.
+type AddressPool struct {
+	Base string `json:"base"`
+	Size int    `json:"size"`
+}
+
+// ParseAddressPool parses a pool given as base=CIDR,size=N.
+func ParseAddressPool(value string) (AddressPool, error) {
+	pool := AddressPool{}
+	for _, field := range strings.Split(value, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return AddressPool{}, fmt.Errorf("Invalid address pool %q, expected base=CIDR,size=N", value)
+		}
+
+		switch parts[0] {
+		case "base":
+			pool.Base = parts[1]
+		case "size":
+			size, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return AddressPool{}, fmt.Errorf("Invalid address pool %q: the size must be a number", value)
+			}
+			pool.Size = size
+		default:
+			return AddressPool{}, fmt.Errorf("Invalid address pool %q: unknown field %q", value, parts[0])
+		}
+	}
+
+	_, network, err := net.ParseCIDR(pool.Base)
+	if err != nil {
+		return AddressPool{}, fmt.Errorf("Invalid address pool %q: %s", value, err)
+	}
+
+	ones, bits := network.Mask.Size()
+	if pool.Size < ones || pool.Size > bits {
+		return AddressPool{}, fmt.Errorf("Invalid address pool %q: the size must be between %d and %d", value, ones, bits)
+	}
+
+	return pool, nil
+}
+
+// ParseUlimit parses a ulimit given as name=soft[:hard].
+func ParseUlimit(value string) (*units.Ulimit, error) {
+	ulimit, err := units.ParseUlimit(value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid ulimit %q: %s", value, err)
+	}
+
+	return ulimit, nil
+}
+
+// Validate checks the typed options of the engine, so that a typo is caught
+// before the engine refuses to start.
+func (o *Options) Validate() error {
+	for _, option := range o.LogOpts {
+		if parts := strings.SplitN(option, "=", 2); len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("Invalid log option %q, expected key=value", option)
+		}
+	}
+
+	if o.CgroupDriver != "" && o.CgroupDriver != "cgroupfs" && o.CgroupDriver != "systemd" {
+		return fmt.Errorf("Invalid cgroup driver %q, expected one of %s", o.CgroupDriver, strings.Join(CgroupDrivers, ", "))
+	}
+
+	for _, ulimit := range o.DefaultUlimits {
+		if _, err := ParseUlimit(ulimit); err != nil {
+			return err
+		}
+	}
+
+	for _, pool := range o.DefaultAddressPools {
+		if _, err := ParseAddressPool(pool); err != nil {
+			return err
+		}
+	}
+
+	if o.Bip != "" {
+		if _, _, err := net.ParseCIDR(o.Bip); err != nil {
+			return fmt.Errorf("Invalid bridge IP %q, expected an IP and a netmask in CIDR notation", o.Bip)
+		}
+	}
+
+	// 68 is the smallest MTU of IPv4.
+	if o.MTU != 0 && (o.MTU < 68 || o.MTU > 65535) {
+		return fmt.Errorf("Invalid MTU %d, expected a value between 68 and 65535", o.MTU)
+	}
+
+	if o.DataRoot != "" && !path.IsAbs(o.DataRoot) {
+		return fmt.Errorf("Invalid data root %q, expected an absolute path", o.DataRoot)
+	}
+
+	return nil
+}
+
+// TypedFlags returns the typed options as flags of the engine, in the
+// flag=value form of ArbitraryFlags.
+func (o Options) TypedFlags() []string {
+	flags := []string{}
+
+	if o.LogDriver != "" {
+		flags = append(flags, "log-driver="+o.LogDriver)
+	}
+	for _, option := range o.LogOpts {
+		flags = append(flags, "log-opt="+option)
+	}
+	if o.CgroupDriver != "" {
+		flags = append(flags, "exec-opt=native.cgroupdriver="+o.CgroupDriver)
+	}
+	if o.LiveRestore {
+		flags = append(flags, "live-restore")
+	}
+	for _, ulimit := range o.DefaultUlimits {
+		flags = append(flags, "default-ulimit="+ulimit)
+	}
+	for _, pool := range o.DefaultAddressPools {
+		flags = append(flags, "default-address-pool="+pool)
+	}
+	if o.Bip != "" {
+		flags = append(flags, "bip="+o.Bip)
+	}
+	if o.MTU != 0 {
+		flags = append(flags, fmt.Sprintf("mtu=%d", o.MTU))
+	}
+	if o.DataRoot != "" {
+		flags = append(flags, "data-root="+o.DataRoot)
+	}
+
+	return flags
+}
+
+// AdoptArbitraryFlags moves the arbitrary flags which have a typed option
+// to it, keeping the other ones and the ones which aren't valid.
+func (o *Options) AdoptArbitraryFlags() {
+	kept := []string{}
+	for _, flag := range o.ArbitraryFlags {
+		adopted := *o
+		if !adopted.adoptFlag(strings.TrimLeft(flag, "-")) || adopted.Validate() != nil {
+			kept = append(kept, flag)
+			continue
+		}
+		*o = adopted
+	}
+
+	if o.ArbitraryFlags != nil {
+		o.ArbitraryFlags = kept
+	}
+}
+
+// adoptFlag sets the typed option of a flag given as flag=value, and tells
+// whether there is one.
+func (o *Options) adoptFlag(flag string) bool {
+	parts := strings.SplitN(flag, "=", 2)
+	name := parts[0]
+	if len(parts) == 1 {
+		if name != "live-restore" {
+			return false
+		}
+		o.LiveRestore = true
+		return true
+	}
+	value := parts[1]
+
+	switch name {
+	case "log-driver":
+		o.LogDriver = value
+	case "log-opt":
+		o.LogOpts = append(o.LogOpts, value)
+	case "exec-opt":
+		if !strings.HasPrefix(value, "native.cgroupdriver=") {
+			return false
+		}
+		o.CgroupDriver = strings.TrimPrefix(value, "native.cgroupdriver=")
+	case "live-restore":
+		liveRestore, err := strconv.ParseBool(value)
+		if err != nil {
+			return false
+		}
+		o.LiveRestore = liveRestore
+	case "default-ulimit":
+		o.DefaultUlimits = append(o.DefaultUlimits, value)
+	case "default-address-pool":
+		o.DefaultAddressPools = append(o.DefaultAddressPools, value)
+	case "bip":
+		o.Bip = value
+	case "mtu":
+		mtu, err := strconv.Atoi(value)
+		if err != nil {
+			return false
+		}
+		o.MTU = mtu
+	case "data-root", "graph", "g":
+		o.DataRoot = value
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Options{
+		LogDriver:           "json-file",
+		LogOpts:             []string{"max-size=10m"},
+		CgroupDriver:        "systemd",
+		LiveRestore:         true,
+		DefaultUlimits:      []string{"nofile=1024:2048"},
+		DefaultAddressPools: []string{"base=172.80.0.0/16,size=24"},
+		Bip:                 "10.200.0.1/24",
+		MTU:                 1450,
+		DataRoot:            "/mnt/docker",
+	}
+	assert.NoError(t, valid.Validate())
+
+	for _, options := range []Options{
+		{LogOpts: []string{"max-size"}},
+		{CgroupDriver: "upstart"},
+		{DefaultUlimits: []string{"files=1024"}},
+		{DefaultUlimits: []string{"nofile=2048:1024"}},
+		{DefaultAddressPools: []string{"base=172.80.0.0/16"}},
+		{DefaultAddressPools: []string{"base=172.80.0.0/16,size=8"}},
+		{DefaultAddressPools: []string{"172.80.0.0/16"}},
+		{Bip: "10.200.0.1"},
+		{MTU: 10},
+		{DataRoot: "docker"},
+	} {
+		assert.Error(t, options.Validate(), "%+v", options)
+	}
+}
+
+func TestTypedFlags(t *testing.T) {
+	options := Options{
+		LogDriver:           "json-file",
+		LogOpts:             []string{"max-size=10m"},
+		CgroupDriver:        "systemd",
+		LiveRestore:         true,
+		DefaultUlimits:      []string{"nofile=1024:2048"},
+		DefaultAddressPools: []string{"base=172.80.0.0/16,size=24"},
+		Bip:                 "10.200.0.1/24",
+		MTU:                 1450,
+		DataRoot:            "/mnt/docker",
+	}
+
+	assert.Equal(t, []string{
+		"log-driver=json-file",
+		"log-opt=max-size=10m",
+		"exec-opt=native.cgroupdriver=systemd",
+		"live-restore",
+		"default-ulimit=nofile=1024:2048",
+		"default-address-pool=base=172.80.0.0/16,size=24",
+		"bip=10.200.0.1/24",
+		"mtu=1450",
+		"data-root=/mnt/docker",
+	}, options.TypedFlags())
+	assert.Empty(t, Options{}.TypedFlags())
+}
+
+func TestAdoptArbitraryFlags(t *testing.T) {
+	options := Options{
+		GraphDir: "/mnt/docker",
+		ArbitraryFlags: []string{
+			"graph=/mnt/sda1/docker",
+			"log-driver=syslog",
+			"log-opt=tag=engine",
+			"exec-opt=native.cgroupdriver=cgroupfs",
+			"exec-opt=native.umask=normal",
+			"live-restore",
+			"mtu=1400",
+			"bip=not-an-ip",
+			"selinux-enabled",
+		},
+	}
+
+	options.AdoptArbitraryFlags()
+
+	assert.Equal(t, Options{
+		GraphDir:     "/mnt/docker",
+		DataRoot:     "/mnt/sda1/docker",
+		LogDriver:    "syslog",
+		LogOpts:      []string{"tag=engine"},
+		CgroupDriver: "cgroupfs",
+		LiveRestore:  true,
+		MTU:          1400,
+		ArbitraryFlags: []string{
+			"exec-opt=native.umask=normal",
+			"bip=not-an-ip",
+			"selinux-enabled",
+		},
+	}, options)
+}
@@ -0,0 +1,431 @@
+// Package filesync keeps a directory on a machine up to date with a local
+// directory, over the machine's SSH connection.
+package filesync
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/ssh"
+)
+
+// debounceDelay is how long Watch waits for the file system to settle
+// before sending a batch of changes.
+var debounceDelay = 200 * time.Millisecond
+
+// statBatchSize is the number of files whose state is read on the machine
+// with a single command, so that it stays under the limit of the length of
+// the command line.
+const statBatchSize = 200
+
+type fileState struct {
+	size    int64
+	modTime int64
+}
+
+func stateOf(info os.FileInfo) fileState {
+	return fileState{info.Size(), info.ModTime().Unix()}
+}
+
+// Syncer does a one-way sync from a local directory to a directory on a
+// machine. It remembers the state of the files it sent, so that the files
+// modified on the machine in the meantime are reported as conflicts rather
+// than silently overwritten.
+type Syncer struct {
+	// Force overwrites the conflicting files.
+	Force bool
+
+	client    ssh.Client
+	localDir  string
+	remoteDir string
+	matcher   *Matcher
+	synced    map[string]fileState
+}
+
+// NewSyncer creates a Syncer. The paths excluded from the sync are the ones
+// listed in the .dockerignore file of localDir, if any, and in excludes.
+func NewSyncer(client ssh.Client, localDir, remoteDir string, excludes []string) (*Syncer, error) {
+	localDir, err := filepath.Abs(localDir)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(localDir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", localDir)
+	}
+
+	patterns, err := ReadIgnoreFile(localDir)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", IgnoreFile, err)
+	}
+
+	matcher, err := NewMatcher(append(patterns, excludes...))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Syncer{
+		client:    client,
+		localDir:  localDir,
+		remoteDir: remoteDir,
+		matcher:   matcher,
+		synced:    map[string]fileState{},
+	}, nil
+}
+
+func (s *Syncer) remotePath(rel string) string {
+	return path.Join(s.remoteDir, rel)
+}
+
+// Sync does the initial bulk transfer and returns the paths skipped because
+// they were modified on the machine. Only the files which differ from the
+// ones on the machine are sent. Nothing is removed from the machine.
+func (s *Syncer) Sync() ([]string, error) {
+	return s.syncDir(".")
+}
+
+// syncDir sends the content of the local directory rel, except the files
+// modified on the machine which it returns, and records the state of the
+// files it sent.
+func (s *Syncer) syncDir(rel string) ([]string, error) {
+	localPath := filepath.Join(s.localDir, filepath.FromSlash(rel))
+
+	exclude := func(sub string, info os.FileInfo) bool {
+		return s.matcher.Excludes(path.Join(rel, sub), info)
+	}
+
+	files := []string{}
+	states := map[string]fileState{}
+	err := filepath.Walk(localPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		sub, err := filepath.Rel(localPath, p)
+		if err != nil {
+			return err
+		}
+		sub = filepath.ToSlash(sub)
+
+		if sub != "." && exclude(sub, info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.Mode().IsRegular() {
+			file := path.Join(rel, sub)
+			files = append(files, file)
+			states[file] = stateOf(info)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := s.remoteStates(files)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []string{}
+	skipped := map[string]bool{}
+	for _, file := range files {
+		if s.conflicting(file, states[file], remote) {
+			conflicts = append(conflicts, file)
+			skipped[file] = true
+		}
+	}
+
+	// Like with "scp -r dir/. dest", the content of the directory is
+	// copied, whether or not the destination already exists.
+	opts := ssh.TransferOptions{
+		Recursive: true,
+		Delta:     true,
+		Exclude: func(sub string, info os.FileInfo) bool {
+			return exclude(sub, info) || skipped[path.Join(rel, sub)]
+		},
+	}
+	src := localPath + string(filepath.Separator) + "."
+	if err := s.client.Upload(src, s.remotePath(rel), opts); err != nil {
+		return nil, err
+	}
+
+	for file, state := range states {
+		if !skipped[file] {
+			s.synced[file] = state
+		}
+	}
+
+	return conflicts, nil
+}
+
+// conflicting tells whether the file rel, whose local state is local, was
+// modified on the machine since it was last sent. A file which was never
+// sent is taken as modified when the copy on the machine is the newer one.
+func (s *Syncer) conflicting(rel string, local fileState, remote map[string]fileState) bool {
+	remoteState, exists := remote[s.remotePath(rel)]
+	if s.Force || !exists || remoteState == local {
+		return false
+	}
+
+	if synced, known := s.synced[rel]; known {
+		return remoteState != synced
+	}
+	return remoteState.modTime > local.modTime
+}
+
+// Update sends the changes made to the given local paths and returns the
+// paths skipped because they were modified on the machine since they were
+// last sent.
+func (s *Syncer) Update(paths []string) ([]string, error) {
+	var (
+		dirs    []string
+		files   []string
+		removed []string
+	)
+
+	for _, p := range dedupe(paths) {
+		rel, err := filepath.Rel(s.localDir, p)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			log.Debugf("Ignoring %s: not in %s", p, s.localDir)
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." && s.matcher.Matches(rel) {
+			continue
+		}
+
+		info, err := os.Lstat(p)
+		switch {
+		case os.IsNotExist(err):
+			if rel != "." {
+				removed = append(removed, rel)
+			}
+		case err != nil:
+			return nil, err
+		case info.IsDir():
+			dirs = append(dirs, rel)
+		case info.Mode().IsRegular():
+			if state, ok := s.synced[rel]; !ok || state != stateOf(info) {
+				files = append(files, rel)
+			}
+		default:
+			log.Debugf("Skipping %s: not a regular file", p)
+		}
+	}
+
+	if err := s.remove(removed); err != nil {
+		return nil, err
+	}
+
+	conflicts := []string{}
+	for _, rel := range dirs {
+		dirConflicts, err := s.syncDir(rel)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, dirConflicts...)
+	}
+
+	fileConflicts, err := s.sendFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(conflicts, fileConflicts...), nil
+}
+
+func (s *Syncer) remove(rels []string) error {
+	if len(rels) == 0 {
+		return nil
+	}
+
+	args := []string{}
+	for _, rel := range rels {
+		args = append(args, shellQuote(s.remotePath(rel)))
+
+		for synced := range s.synced {
+			if synced == rel || strings.HasPrefix(synced, rel+"/") {
+				delete(s.synced, synced)
+			}
+		}
+	}
+
+	if out, err := s.client.Output("rm -rf " + strings.Join(args, " ")); err != nil {
+		return fmt.Errorf("Error removing files on the machine: %s: %s", err, out)
+	}
+
+	return nil
+}
+
+func (s *Syncer) sendFiles(rels []string) ([]string, error) {
+	if len(rels) == 0 {
+		return nil, nil
+	}
+
+	remote, err := s.remoteStates(rels)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []string{}
+	for _, rel := range rels {
+		localPath := filepath.Join(s.localDir, filepath.FromSlash(rel))
+		info, err := os.Stat(localPath)
+		if os.IsNotExist(err) {
+			// Removed since Update looked at it, the next event will tell.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if s.conflicting(rel, stateOf(info), remote) {
+			conflicts = append(conflicts, rel)
+			continue
+		}
+
+		log.Debugf("Sending %s", rel)
+		if err := s.client.Upload(localPath, s.remotePath(rel), ssh.TransferOptions{}); err != nil {
+			return nil, err
+		}
+		s.synced[rel] = stateOf(info)
+	}
+
+	return conflicts, nil
+}
+
+// remoteStates returns the size and modification time of the files on the
+// machine, for the ones which exist.
+func (s *Syncer) remoteStates(rels []string) (map[string]fileState, error) {
+	states := map[string]fileState{}
+	for start := 0; start < len(rels); start += statBatchSize {
+		end := start + statBatchSize
+		if end > len(rels) {
+			end = len(rels)
+		}
+
+		if err := s.readRemoteStates(rels[start:end], states); err != nil {
+			return nil, err
+		}
+	}
+
+	return states, nil
+}
+
+// readRemoteStates adds the states of the files on the machine to states,
+// with a single command.
+func (s *Syncer) readRemoteStates(rels []string, states map[string]fileState) error {
+	args := []string{}
+	for _, rel := range rels {
+		args = append(args, shellQuote(s.remotePath(rel)))
+	}
+
+	out, err := s.client.Output(fmt.Sprintf("stat -c '%%s %%Y %%n' %s 2>/dev/null || true", strings.Join(args, " ")))
+	if err != nil {
+		return fmt.Errorf("Error reading the files on the machine: %s: %s", err, out)
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(strings.TrimRight(line, "\r"), " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		modTime, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		states[fields[2]] = fileState{size, modTime}
+	}
+
+	return nil
+}
+
+// Watch sends the local changes as they happen, until stop is closed.
+func (s *Syncer) Watch(stop <-chan struct{}) error {
+	watcher, err := NewWatcher(s.localDir, func(p string) bool {
+		rel, err := filepath.Rel(s.localDir, p)
+		if err != nil {
+			return false
+		}
+		return s.matcher.Matches(filepath.ToSlash(rel)) && !s.matcher.hasExceptions
+	})
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	var (
+		pending []string
+		timer   <-chan time.Time
+	)
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case err := <-watcher.Errors():
+			return err
+		case p, ok := <-watcher.Events():
+			if !ok {
+				return nil
+			}
+			pending = append(pending, p)
+			timer = time.After(debounceDelay)
+		case <-timer:
+			conflicts, err := s.Update(pending)
+			if err != nil {
+				return err
+			}
+			WarnConflicts(conflicts)
+			pending, timer = nil, nil
+		}
+	}
+}
+
+func dedupe(paths []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, p := range paths {
+		if !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// WarnConflicts reports the paths skipped because they were modified on the
+// machine.
+func WarnConflicts(conflicts []string) {
+	for _, conflict := range conflicts {
+		log.Warnf("Conflict: %s was modified on the machine since it was last synced, skipping it (use --force to overwrite it)", conflict)
+	}
+}
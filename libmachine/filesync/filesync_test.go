@@ -0,0 +1,268 @@
+package filesync
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/ssh/sshtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "machine-test-filesync-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		".dockerignore":             "*.log\n",
+		"main.go":                   "package main",
+		"debug.log":                 "ignored",
+		"vendor/lib/lib.go":         "package lib",
+		"node_modules/pkg/index.js": "ignored",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func newTestSyncer(t *testing.T, dir string) (*Syncer, *sshtest.FakeClient) {
+	client := &sshtest.FakeClient{Outputs: map[string]sshtest.CmdResult{}}
+
+	syncer, err := NewSyncer(client, dir, "/home/docker/app", []string{"node_modules"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := syncer.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	client.Uploads = nil
+
+	return syncer, client
+}
+
+func touch(t *testing.T, path, content string, modTime time.Time) os.FileInfo {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, modTime, modTime)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func statCommand(paths string) string {
+	return fmt.Sprintf("stat -c '%%s %%Y %%n' %s 2>/dev/null || true", paths)
+}
+
+func TestSyncerSync(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	client := &sshtest.FakeClient{}
+	syncer, err := NewSyncer(client, dir, "/home/docker/app", []string{"node_modules"})
+	assert.NoError(t, err)
+	conflicts, err := syncer.Sync()
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	if assert.Len(t, client.Uploads, 1) {
+		upload := client.Uploads[0]
+		assert.Equal(t, dir+string(filepath.Separator)+".", upload.Src)
+		assert.Equal(t, "/home/docker/app", upload.Dst)
+		assert.True(t, upload.Opts.Recursive)
+		assert.True(t, upload.Opts.Delta)
+
+		info, _ := os.Stat(dir)
+		assert.True(t, upload.Opts.Exclude("node_modules", info))
+		assert.False(t, upload.Opts.Exclude("vendor", info))
+	}
+
+	synced := []string{}
+	for rel := range syncer.synced {
+		synced = append(synced, rel)
+	}
+	assert.Len(t, synced, 3)
+	assert.Contains(t, synced, ".dockerignore")
+	assert.Contains(t, synced, "main.go")
+	assert.Contains(t, synced, "vendor/lib/lib.go")
+}
+
+func TestSyncerSyncConflict(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	main := filepath.Join(dir, "main.go")
+	local := touch(t, main, "package main", time.Unix(1000, 0))
+	lib := touch(t, filepath.Join(dir, "vendor", "lib", "lib.go"), "package lib", time.Unix(3000, 0))
+
+	// main.go is newer on the machine, lib.go is older.
+	client := &sshtest.FakeClient{Outputs: map[string]sshtest.CmdResult{
+		statCommand("'/home/docker/app/.dockerignore' '/home/docker/app/main.go' '/home/docker/app/vendor/lib/lib.go'"): {
+			Out: fmt.Sprintf("%d 2000 /home/docker/app/main.go\n%d 2000 /home/docker/app/vendor/lib/lib.go\n", local.Size(), lib.Size()),
+		},
+	}}
+	syncer, err := NewSyncer(client, dir, "/home/docker/app", []string{"node_modules"})
+	assert.NoError(t, err)
+
+	conflicts, err := syncer.Sync()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"main.go"}, conflicts)
+	if assert.Len(t, client.Uploads, 1) {
+		exclude := client.Uploads[0].Opts.Exclude
+		assert.True(t, exclude("main.go", local))
+		assert.False(t, exclude("vendor/lib/lib.go", lib))
+	}
+	assert.NotContains(t, syncer.synced, "main.go")
+	assert.Contains(t, syncer.synced, "vendor/lib/lib.go")
+
+	syncer.Force = true
+	conflicts, err = syncer.Sync()
+
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.Contains(t, syncer.synced, "main.go")
+}
+
+func TestSyncerUpdateDirectoryConflict(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	syncer, client := newTestSyncer(t, dir)
+
+	// lib.go was modified on the machine since it was sent.
+	client.Outputs[statCommand("'/home/docker/app/vendor/lib/lib.go'")] = sshtest.CmdResult{
+		Out: "42 1234 /home/docker/app/vendor/lib/lib.go\n",
+	}
+	touch(t, filepath.Join(dir, "vendor", "lib", "lib.go"), "package lib // changed", time.Now().Add(time.Hour))
+
+	conflicts, err := syncer.Update([]string{filepath.Join(dir, "vendor")})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vendor/lib/lib.go"}, conflicts)
+	if assert.Len(t, client.Uploads, 1) {
+		info, _ := os.Stat(filepath.Join(dir, "vendor", "lib", "lib.go"))
+		assert.True(t, client.Uploads[0].Opts.Exclude("lib/lib.go", info))
+	}
+}
+
+func TestNewSyncerNotADirectory(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	_, err := NewSyncer(&sshtest.FakeClient{}, filepath.Join(dir, "main.go"), "/home/docker/app", nil)
+	assert.EqualError(t, err, filepath.Join(dir, "main.go")+" is not a directory")
+}
+
+func TestSyncerUpdate(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	syncer, client := newTestSyncer(t, dir)
+
+	main := filepath.Join(dir, "main.go")
+	before := syncer.synced["main.go"]
+	client.Outputs[statCommand("'/home/docker/app/main.go'")] = sshtest.CmdResult{
+		Out: fmt.Sprintf("%d %d /home/docker/app/main.go\n", before.size, before.modTime),
+	}
+	info := touch(t, main, "package main // changed", time.Now().Add(time.Hour))
+
+	conflicts, err := syncer.Update([]string{main, filepath.Join(dir, "debug.log"), main})
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.Equal(t, []sshtest.Transfer{{Src: main, Dst: "/home/docker/app/main.go"}}, client.Uploads)
+	assert.Equal(t, stateOf(info), syncer.synced["main.go"])
+
+	// Nothing changed since the last update.
+	client.Uploads = nil
+	conflicts, err = syncer.Update([]string{main})
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.Empty(t, client.Uploads)
+}
+
+func TestSyncerUpdateConflict(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	syncer, client := newTestSyncer(t, dir)
+
+	main := filepath.Join(dir, "main.go")
+	client.Outputs[statCommand("'/home/docker/app/main.go'")] = sshtest.CmdResult{
+		Out: "42 1234 /home/docker/app/main.go\n",
+	}
+	touch(t, main, "package main // changed", time.Now().Add(time.Hour))
+
+	conflicts, err := syncer.Update([]string{main})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"main.go"}, conflicts)
+	assert.Empty(t, client.Uploads)
+
+	syncer.Force = true
+	conflicts, err = syncer.Update([]string{main})
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.Len(t, client.Uploads, 1)
+}
+
+func TestSyncerUpdateNewFileAndDirectory(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	syncer, client := newTestSyncer(t, dir)
+
+	newFile := filepath.Join(dir, "util.go")
+	touch(t, newFile, "package main", time.Now())
+	newDir := filepath.Join(dir, "pkg")
+	os.Mkdir(newDir, 0755)
+	touch(t, filepath.Join(newDir, "pkg.go"), "package pkg", time.Now())
+
+	client.Outputs[statCommand("'/home/docker/app/util.go'")] = sshtest.CmdResult{}
+
+	conflicts, err := syncer.Update([]string{newFile, newDir})
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	if assert.Len(t, client.Uploads, 2) {
+		assert.Equal(t, newDir+string(filepath.Separator)+".", client.Uploads[0].Src)
+		assert.Equal(t, "/home/docker/app/pkg", client.Uploads[0].Dst)
+		assert.True(t, client.Uploads[0].Opts.Recursive)
+		assert.Equal(t, sshtest.Transfer{Src: newFile, Dst: "/home/docker/app/util.go"}, client.Uploads[1])
+	}
+	assert.Contains(t, syncer.synced, "pkg/pkg.go")
+	assert.Contains(t, syncer.synced, "util.go")
+}
+
+func TestSyncerUpdateRemoved(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	syncer, client := newTestSyncer(t, dir)
+
+	os.RemoveAll(filepath.Join(dir, "vendor"))
+	client.Outputs["rm -rf '/home/docker/app/vendor'"] = sshtest.CmdResult{}
+
+	conflicts, err := syncer.Update([]string{filepath.Join(dir, "vendor")})
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.NotContains(t, syncer.synced, "vendor/lib/lib.go")
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, `'/home/docker/it'\''s here'`, shellQuote("/home/docker/it's here"))
+}
@@ -0,0 +1,161 @@
+package filesync
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFile is the name of the file listing the paths excluded from a sync,
+// using the same syntax as the Docker build context.
+const IgnoreFile = ".dockerignore"
+
+type pattern struct {
+	re        *regexp.Regexp
+	exception bool
+}
+
+// Matcher tells which paths are excluded by a list of .dockerignore-style
+// patterns. As with Docker, the last pattern matching a path wins and the
+// patterns starting with '!' re-include the paths they match.
+type Matcher struct {
+	patterns      []pattern
+	hasExceptions bool
+}
+
+// ReadIgnoreFile returns the patterns listed in the .dockerignore file of
+// dir, if any.
+func ReadIgnoreFile(dir string) ([]string, error) {
+	f, err := os.Open(filepath.Join(dir, IgnoreFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+
+	return patterns, scanner.Err()
+}
+
+// NewMatcher compiles the given patterns.
+func NewMatcher(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+
+	for _, p := range patterns {
+		exception := strings.HasPrefix(p, "!")
+		if exception {
+			p = p[1:]
+		}
+
+		p = strings.TrimPrefix(path.Clean(filepath.ToSlash(p)), "/")
+		if p == "" || p == "." {
+			continue
+		}
+
+		re, err := compilePattern(p)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid exclude pattern %q: %s", p, err)
+		}
+
+		m.patterns = append(m.patterns, pattern{re, exception})
+		m.hasExceptions = m.hasExceptions || exception
+	}
+
+	return m, nil
+}
+
+// Matches tells whether the slash separated path rel is excluded. A pattern
+// matching a directory also matches everything below it.
+func (m *Matcher) Matches(rel string) bool {
+	matched := false
+
+	for _, p := range m.patterns {
+		if p.exception != matched {
+			continue
+		}
+
+		for parent := rel; parent != "." && parent != "/"; parent = path.Dir(parent) {
+			if p.re.MatchString(parent) {
+				matched = !p.exception
+				break
+			}
+		}
+	}
+
+	return matched
+}
+
+// Excludes is meant to be used as ssh.TransferOptions.Exclude. The
+// directories aren't excluded when an exception could re-include some of
+// their content.
+func (m *Matcher) Excludes(rel string, info os.FileInfo) bool {
+	if info.IsDir() && m.hasExceptions {
+		return false
+	}
+
+	return m.Matches(rel)
+}
+
+// compilePattern turns a pattern into a regexp. '*' and '?' don't match
+// slashes, while '**' matches any number of directories.
+func compilePattern(p string) (*regexp.Regexp, error) {
+	var buf bytes.Buffer
+	buf.WriteString("^")
+
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			if i+1 < len(p) && p[i+1] == '*' {
+				i++
+				if i+1 < len(p) && p[i+1] == '/' {
+					// "**/" also matches no directory at all
+					i++
+					buf.WriteString("(.*/)?")
+				} else {
+					buf.WriteString(".*")
+				}
+			} else {
+				buf.WriteString("[^/]*")
+			}
+		case '?':
+			buf.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(p[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ]")
+			}
+			class := p[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + class + "]")
+			i += end
+		case '\\':
+			if i+1 < len(p) {
+				i++
+			}
+			buf.WriteString(regexp.QuoteMeta(string(p[i])))
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	buf.WriteString("$")
+	return regexp.Compile(buf.String())
+}
@@ -0,0 +1,70 @@
+package filesync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatcher(t *testing.T) {
+	var tests = []struct {
+		patterns []string
+		path     string
+		matches  bool
+	}{
+		{[]string{"node_modules"}, "node_modules", true},
+		{[]string{"node_modules"}, "node_modules/lodash/index.js", true},
+		{[]string{"node_modules"}, "src/node_modules", false},
+		{[]string{"/node_modules/"}, "node_modules/lodash", true},
+		{[]string{"*.log"}, "debug.log", true},
+		{[]string{"*.log"}, "logs/debug.log", false},
+		{[]string{"*/*.log"}, "logs/debug.log", true},
+		{[]string{"**/*.log"}, "debug.log", true},
+		{[]string{"**/*.log"}, "a/b/c/debug.log", true},
+		{[]string{"build/**"}, "build/out/app", true},
+		{[]string{"file?.txt"}, "file1.txt", true},
+		{[]string{"file?.txt"}, "file10.txt", false},
+		{[]string{"file[0-9].txt"}, "file1.txt", true},
+		{[]string{"file[!0-9].txt"}, "file1.txt", false},
+		{[]string{"*.md", "!README.md"}, "README.md", false},
+		{[]string{"*.md", "!README.md"}, "CHANGELOG.md", true},
+		{[]string{"*.md", "!README.md", "README*"}, "README.md", true},
+		{[]string{"docs", "!docs/index.md"}, "docs/index.md", false},
+		{[]string{}, "main.go", false},
+	}
+
+	for _, test := range tests {
+		m, err := NewMatcher(test.patterns)
+		assert.NoError(t, err)
+		assert.Equal(t, test.matches, m.Matches(test.path), "%v %s", test.patterns, test.path)
+	}
+}
+
+func TestMatcherInvalidPattern(t *testing.T) {
+	_, err := NewMatcher([]string{"file[0-9.txt"})
+	assert.EqualError(t, err, `Invalid exclude pattern "file[0-9.txt": missing ]`)
+}
+
+func TestReadIgnoreFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-filesync-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	patterns, err := ReadIgnoreFile(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, patterns)
+
+	content := "# dependencies\nnode_modules\n\n  *.log  \n!important.log\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, ".dockerignore"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	patterns, err = ReadIgnoreFile(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node_modules", "*.log", "!important.log"}, patterns)
+}
@@ -0,0 +1,18 @@
+package filesync
+
+// Watcher reports the paths created, modified or removed under a directory.
+type Watcher interface {
+	// Events delivers the changed paths. A changed directory means that
+	// its whole content must be looked at again.
+	Events() <-chan string
+
+	Errors() <-chan error
+
+	Close() error
+}
+
+// NewWatcher watches dir recursively. The directories for which skip
+// returns true aren't watched.
+func NewWatcher(dir string, skip func(path string) bool) (Watcher, error) {
+	return newWatcher(dir, skip)
+}
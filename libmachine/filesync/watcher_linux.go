@@ -0,0 +1,184 @@
+// +build linux,go1.12
+
+// The inotify watcher needs the runtime poller of Go 1.12 for reads of a non
+// blocking descriptor wrapped in an os.File to block, and to be interrupted by
+// Close. Older versions use the polling watcher.
+
+package filesync
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// inotifyWatcher relies on inotify, which only watches single directories:
+// a watch is added for each directory of the tree, including the ones
+// created after the watcher was started.
+type inotifyWatcher struct {
+	root string
+	fd   int
+	file *os.File
+	skip func(path string) bool
+
+	mu      sync.Mutex
+	watches map[int32]string
+
+	events chan string
+	errors chan error
+	done   chan struct{}
+}
+
+func newWatcher(dir string, skip func(path string) bool) (Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	w := &inotifyWatcher{
+		root: dir,
+		fd:   fd,
+		// The descriptor is non blocking, so the runtime poller handles it
+		// and closing the file interrupts a pending read.
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		skip:    skip,
+		watches: map[int32]string{},
+		events:  make(chan string),
+		errors:  make(chan error),
+		done:    make(chan struct{}),
+	}
+
+	if err := w.addRecursive(dir); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+
+	go w.readEvents()
+
+	return w, nil
+}
+
+func (w *inotifyWatcher) Events() <-chan string {
+	return w.events
+}
+
+func (w *inotifyWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *inotifyWatcher) Close() error {
+	close(w.done)
+	return w.file.Close()
+}
+
+func (w *inotifyWatcher) sendEvent(path string) {
+	select {
+	case w.events <- path:
+	case <-w.done:
+	}
+}
+
+func (w *inotifyWatcher) sendError(err error) {
+	select {
+	case w.errors <- err:
+	case <-w.done:
+	}
+}
+
+func (w *inotifyWatcher) addRecursive(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// The directory may have been removed in the meantime.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		if path != dir && w.skip != nil && w.skip(path) {
+			return filepath.SkipDir
+		}
+
+		wd, err := syscall.InotifyAddWatch(w.fd, path, inotifyMask)
+		if err != nil {
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+
+		w.mu.Lock()
+		w.watches[int32(wd)] = path
+		w.mu.Unlock()
+
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) readEvents() {
+	defer close(w.events)
+	defer close(w.errors)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.PathMax))
+
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if pathErr, ok := err.(*os.PathError); !ok || pathErr.Err != os.ErrClosed {
+				w.sendError(err)
+			}
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(event.Len)], "\x00"))
+			offset = nameStart + int(event.Len)
+
+			w.handle(event, name)
+		}
+	}
+}
+
+func (w *inotifyWatcher) handle(event *syscall.InotifyEvent, name string) {
+	if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+		log.Debug("Too many file system events, rescanning everything")
+		w.sendEvent(w.root)
+		return
+	}
+
+	w.mu.Lock()
+	dir, ok := w.watches[event.Wd]
+	if event.Mask&syscall.IN_IGNORED != 0 {
+		delete(w.watches, event.Wd)
+	}
+	w.mu.Unlock()
+
+	if !ok || name == "" {
+		return
+	}
+
+	path := filepath.Join(dir, name)
+
+	if event.Mask&syscall.IN_ISDIR != 0 && event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		if w.skip != nil && w.skip(path) {
+			return
+		}
+		if err := w.addRecursive(path); err != nil {
+			w.sendError(err)
+			return
+		}
+	}
+
+	w.sendEvent(path)
+}
@@ -0,0 +1,132 @@
+// +build !linux !go1.12
+
+package filesync
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pollInterval is the delay between two scans of the watched directory.
+var pollInterval = time.Second
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+	mode    os.FileMode
+}
+
+// pollWatcher scans the directory periodically. It's used where there's no
+// inotify.
+type pollWatcher struct {
+	root  string
+	skip  func(path string) bool
+	files map[string]fileStamp
+
+	events chan string
+	errors chan error
+	done   chan struct{}
+}
+
+func newWatcher(dir string, skip func(path string) bool) (Watcher, error) {
+	w := &pollWatcher{
+		root:   dir,
+		skip:   skip,
+		events: make(chan string),
+		errors: make(chan error),
+		done:   make(chan struct{}),
+	}
+
+	files, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	w.files = files
+
+	go w.poll()
+
+	return w, nil
+}
+
+func (w *pollWatcher) Events() <-chan string {
+	return w.events
+}
+
+func (w *pollWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *pollWatcher) Close() error {
+	close(w.done)
+	return nil
+}
+
+func (w *pollWatcher) scan() (map[string]fileStamp, error) {
+	files := map[string]fileStamp{}
+
+	err := filepath.Walk(w.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if info.IsDir() && path != w.root && w.skip != nil && w.skip(path) {
+			return filepath.SkipDir
+		}
+
+		files[path] = fileStamp{info.Size(), info.ModTime(), info.Mode()}
+		return nil
+	})
+
+	return files, err
+}
+
+func (w *pollWatcher) poll() {
+	defer close(w.events)
+	defer close(w.errors)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		files, err := w.scan()
+		if err != nil {
+			select {
+			case w.errors <- err:
+			case <-w.done:
+				return
+			}
+			continue
+		}
+
+		changed := []string{}
+		for path, stamp := range files {
+			if previous, ok := w.files[path]; !ok || previous != stamp {
+				changed = append(changed, path)
+			}
+		}
+		for path := range w.files {
+			if _, ok := files[path]; !ok {
+				changed = append(changed, path)
+			}
+		}
+		w.files = files
+
+		for _, path := range changed {
+			select {
+			case w.events <- path:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
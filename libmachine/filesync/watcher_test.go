@@ -0,0 +1,66 @@
+package filesync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func expectEvent(t *testing.T, w Watcher, path string) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-w.Events():
+			if strings.Contains(event, "ignored") {
+				t.Errorf("Unexpected event for %s", event)
+			}
+			if event == path {
+				return
+			}
+		case err := <-w.Errors():
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("No event received for %s", path)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-filesync-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.Mkdir(filepath.Join(dir, "ignored"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWatcher(dir, func(path string) bool {
+		return strings.HasSuffix(path, "ignored")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	file := filepath.Join(dir, "main.go")
+	ioutil.WriteFile(file, []byte("package main"), 0644)
+	expectEvent(t, w, file)
+
+	// The directories created after the watcher started are watched too.
+	subDir := filepath.Join(dir, "pkg")
+	os.Mkdir(subDir, 0755)
+	expectEvent(t, w, subDir)
+
+	subFile := filepath.Join(subDir, "pkg.go")
+	ioutil.WriteFile(filepath.Join(dir, "ignored", "file"), []byte("ignored"), 0644)
+	ioutil.WriteFile(subFile, []byte("package pkg"), 0644)
+	expectEvent(t, w, subFile)
+
+	os.Remove(file)
+	expectEvent(t, w, file)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/libmachine/swarmmode"
 )
 
 var (
@@ -46,12 +47,25 @@ type Host struct {
 }
 
 type Options struct {
-	Driver        string
-	Memory        int
-	Disk          int
-	EngineOptions *engine.Options
-	SwarmOptions  *swarm.Options
-	AuthOptions   *auth.Options
+	Driver string
+	Memory int
+	Disk   int
+	// Provisioner is the name of the provisioner used for the machine. It's
+	// detected from the OS of the machine when empty.
+	Provisioner string
+	// ProvisionHooks are the scripts run on the machine each time it's
+	// provisioned.
+	ProvisionHooks []provision.Hook
+	// ProvisionedStep is the last step completed by a provisioning which
+	// failed, which it's resumed after. It's empty once the provisioning
+	// succeeds.
+	ProvisionedStep string
+	EngineOptions   *engine.Options
+	SwarmOptions    *swarm.Options
+	// SwarmModeOptions holds the role of the machine in a swarm mode
+	// cluster. It's nil for the machines which aren't part of one.
+	SwarmModeOptions *swarmmode.Options
+	AuthOptions      *auth.Options
 }
 
 type Metadata struct {
@@ -83,12 +97,7 @@ func (creator *StandardSSHClientCreator) CreateSSHClient(d drivers.Driver) (ssh.
 		return &ssh.ExternalClient{}, err
 	}
 
-	auth := &ssh.Auth{}
-	if d.GetSSHKeyPath() != "" {
-		auth.Keys = []string{d.GetSSHKeyPath()}
-	}
-
-	return ssh.NewClient(d.GetSSHUsername(), addr, port, auth)
+	return drivers.NewSSHClient(d, addr, port)
 }
 
 func (h *Host) runActionForState(action func() error, desiredState state.State) error {
@@ -106,13 +115,52 @@ func (h *Host) runActionForState(action func() error, desiredState state.State)
 	return mcnutils.WaitFor(drivers.MachineInState(h.Driver, desiredState))
 }
 
+// Provisioner returns the provisioner saved in the machine's config. For the
+// machines which don't have one yet, it's detected and saved in the config.
+// The provisioner of the OS is wrapped in a rootless provisioner for the
+// machines running a rootless engine.
+func (h *Host) Provisioner() (provision.Provisioner, error) {
+	provisioner, err := h.osProvisioner()
+	if err != nil {
+		return nil, err
+	}
+
+	if engineOptions := h.engineOptions(); engineOptions.Rootless {
+		return provision.NewRootlessProvisioner(provisioner, engineOptions), nil
+	}
+
+	return provisioner, nil
+}
+
+func (h *Host) osProvisioner() (provision.Provisioner, error) {
+	if h.HostOptions != nil && h.HostOptions.Provisioner != "" {
+		return provision.NewProvisioner(h.HostOptions.Provisioner, h.Driver)
+	}
+
+	name, provisioner, err := provision.DetectNamedProvisioner(h.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.HostOptions != nil {
+		h.HostOptions.Provisioner = name
+	}
+
+	return provisioner, nil
+}
+
 func (h *Host) WaitForDocker() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := h.Provisioner()
 	if err != nil {
 		return err
 	}
 
-	return provision.WaitForDocker(provisioner, engine.DefaultPort)
+	authOptions := auth.Options{}
+	if h.AuthOptions() != nil {
+		authOptions = *h.AuthOptions()
+	}
+
+	return provision.WaitForDockerWithAuth(provisioner, authOptions, engine.DefaultPort)
 }
 
 func (h *Host) Start() error {
@@ -174,20 +222,50 @@ func (h *Host) Upgrade() error {
 		return errMachineMustBeRunningForUpgrade
 	}
 
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := h.Provisioner()
 	if err != nil {
 		return err
 	}
 
-	log.Info("Upgrading docker...")
-	if err := provisioner.Package("docker", pkgaction.Upgrade); err != nil {
-		return err
+	if bundle := h.engineOptions().InstallBundle; bundle != "" {
+		if err := provision.InstallEngineBundle(provisioner, bundle); err != nil {
+			return err
+		}
+	} else if version := h.EngineVersion(); version != "" {
+		if err := provision.InstallEngineVersion(provisioner, version); err != nil {
+			return err
+		}
+	} else {
+		log.Info("Upgrading docker...")
+		if err := provisioner.Package("docker", pkgaction.Upgrade); err != nil {
+			return err
+		}
 	}
 
 	log.Info("Restarting docker...")
 	return provisioner.Service("docker", serviceaction.Restart)
 }
 
+// EngineVersion returns the version of the engine the host is pinned to, or
+// an empty string when it follows the latest version.
+func (h *Host) EngineVersion() string {
+	return h.engineOptions().Version
+}
+
+func (h *Host) engineOptions() engine.Options {
+	if h.HostOptions == nil || h.HostOptions.EngineOptions == nil {
+		return engine.Options{}
+	}
+	return *h.HostOptions.EngineOptions
+}
+
+func (h *Host) swarmOptions() swarm.Options {
+	if h.HostOptions == nil || h.HostOptions.SwarmOptions == nil {
+		return swarm.Options{}
+	}
+	return *h.HostOptions.SwarmOptions
+}
+
 func (h *Host) URL() (string, error) {
 	return h.Driver.GetURL()
 }
@@ -200,7 +278,7 @@ func (h *Host) AuthOptions() *auth.Options {
 }
 
 func (h *Host) ConfigureAuth() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := h.Provisioner()
 	if err != nil {
 		return err
 	}
@@ -213,11 +291,114 @@ func (h *Host) ConfigureAuth() error {
 	return provisioner.Provision(swarm.Options{}, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
 }
 
+// PlanConfigureAuth renders what ConfigureAuth would do, without changing
+// anything.
+func (h *Host) PlanConfigureAuth() (*provision.Plan, error) {
+	provisioner, err := h.Provisioner()
+	if err != nil {
+		return nil, err
+	}
+
+	return provision.PlanProvisioning(provisioner, nil, swarm.Options{}, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+}
+
+// PlanProvision renders what Provision would do, without changing anything.
+func (h *Host) PlanProvision() (*provision.Plan, error) {
+	provisioner, err := h.Provisioner()
+	if err != nil {
+		return nil, err
+	}
+
+	return provision.PlanProvisioning(provisioner, h.HostOptions.ProvisionHooks, *h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+}
+
 func (h *Host) Provision() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := h.Provisioner()
+	if err != nil {
+		return err
+	}
+
+	return h.ProvisionWith(provisioner, "")
+}
+
+// ResumeProvision provisions the machine again, starting after the last
+// step completed by the provisioning which failed.
+func (h *Host) ResumeProvision() error {
+	provisioner, err := h.Provisioner()
+	if err != nil {
+		return err
+	}
+
+	return h.ProvisionWith(provisioner, h.HostOptions.ProvisionedStep)
+}
+
+// ProvisionWith provisions the machine with the provisioner, after the step
+// resumeAfter when it's set. The completed steps are recorded in the host
+// options so that a failed provisioning can be resumed.
+func (h *Host) ProvisionWith(provisioner provision.Provisioner, resumeAfter string) error {
+	h.HostOptions.ProvisionedStep = resumeAfter
+
+	err := provision.ProvisionSteps(provisioner, h.HostOptions.ProvisionHooks, *h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions, resumeAfter, func(step string) {
+		h.HostOptions.ProvisionedStep = step
+	})
 	if err != nil {
 		return err
 	}
 
-	return provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+	h.HostOptions.ProvisionedStep = ""
+	return nil
+}
+
+// ConfigureSwarm runs the Swarm containers of swarmOptions on the machine in
+// place of the ones of its current Swarm options, which swarmOptions become.
+func (h *Host) ConfigureSwarm(swarmOptions swarm.Options) error {
+	provisioner, err := h.Provisioner()
+	if err != nil {
+		return err
+	}
+
+	if err := provision.ReconfigureSwarm(provisioner, h.swarmOptions(), swarmOptions, *h.HostOptions.AuthOptions); err != nil {
+		return err
+	}
+
+	h.HostOptions.SwarmOptions = &swarmOptions
+	return nil
+}
+
+// ConfigureRegistries installs the CA certs and the credentials of the
+// registries of the engine options on the machine.
+func (h *Host) ConfigureRegistries() error {
+	provisioner, err := h.Provisioner()
+	if err != nil {
+		return err
+	}
+
+	return provision.ConfigureRegistries(provisioner, *h.HostOptions.EngineOptions)
+}
+
+// ConfigureEngine applies engineOptions to the engine of the machine without
+// provisioning it again, and sets them as its options once they're written.
+func (h *Host) ConfigureEngine(engineOptions engine.Options) (provision.EngineChange, error) {
+	provisioner, err := h.Provisioner()
+	if err != nil {
+		return provision.EngineChange{}, err
+	}
+
+	change, err := provision.ReconfigureEngine(provisioner, *h.HostOptions.AuthOptions, engineOptions)
+	if err == nil || change.Changed() {
+		h.HostOptions.EngineOptions = &engineOptions
+	}
+
+	return change, err
+}
+
+// RepairSwarm recreates the Swarm containers of the machine which aren't
+// running as its Swarm options say, and returns the ones it recreated.
+func (h *Host) RepairSwarm() ([]provision.SwarmRepair, error) {
+	provisioner, err := h.Provisioner()
+	if err != nil {
+		return nil, err
+	}
+
+	return provision.RepairSwarm(provisioner, h.swarmOptions(), *h.HostOptions.AuthOptions)
 }
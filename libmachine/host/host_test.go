@@ -1,12 +1,17 @@
 package host
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/docker/machine/drivers/fakedriver"
 	_ "github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestValidateHostnameValid(t *testing.T) {
@@ -42,7 +47,7 @@ func TestValidateHostnameInvalid(t *testing.T) {
 func TestStart(t *testing.T) {
 	defer provision.SetDetector(&provision.StandardDetector{})
 	provision.SetDetector(&provision.FakeDetector{
-		Provisioner: provision.NewNetstatProvisioner(),
+		Provisioner: provision.NewFakeProvisioner(nil),
 	})
 
 	host := &Host{
@@ -55,3 +60,69 @@ func TestStart(t *testing.T) {
 		t.Fatalf("Expected no error but got one: %s", err)
 	}
 }
+
+func TestProvisionerSavesDetectedName(t *testing.T) {
+	defer provision.SetDetector(&provision.StandardDetector{})
+	provision.SetDetector(&provision.FakeDetector{
+		Provisioner: provision.NewFakeProvisioner(nil),
+		Name:        "Debian",
+	})
+
+	host := &Host{
+		Driver:      &fakedriver.Driver{},
+		HostOptions: &Options{},
+	}
+
+	provisioner, err := host.Provisioner()
+
+	assert.NoError(t, err)
+	assert.NotNil(t, provisioner)
+	assert.Equal(t, "Debian", host.HostOptions.Provisioner)
+}
+
+type failingProvisioner struct {
+	provision.FakeProvisioner
+}
+
+func (p *failingProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	return errors.New("provisioning failed")
+}
+
+func newProvisionTestHost() *Host {
+	return &Host{
+		Driver: &fakedriver.Driver{},
+		HostOptions: &Options{
+			ProvisionedStep: "swarm",
+			EngineOptions:   &engine.Options{},
+			SwarmOptions:    &swarm.Options{},
+			AuthOptions:     &auth.Options{},
+		},
+	}
+}
+
+func TestProvisionWithClearsStepOnSuccess(t *testing.T) {
+	host := newProvisionTestHost()
+
+	err := host.ProvisionWith(&provision.FakeProvisioner{}, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "", host.HostOptions.ProvisionedStep)
+}
+
+func TestProvisionWithRecordsLastCompletedStep(t *testing.T) {
+	host := newProvisionTestHost()
+
+	err := host.ProvisionWith(&failingProvisioner{}, "")
+
+	assert.EqualError(t, err, "provisioning failed")
+	assert.Equal(t, provision.StepPreHooks, host.HostOptions.ProvisionedStep)
+}
+
+func TestProvisionWithResumesAfterStep(t *testing.T) {
+	host := newProvisionTestHost()
+
+	err := host.ProvisionWith(&failingProvisioner{}, provision.StepProvision)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "", host.HostOptions.ProvisionedStep)
+}
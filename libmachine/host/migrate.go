@@ -110,6 +110,13 @@ func MigrateHost(h *Host, data []byte) (*Host, bool, error) {
 				driver.Data = h.RawDriver
 				h.Driver = driver
 			case 3:
+				if migratedHostMetadata.ConfigVersion == 3 {
+					h.Driver = driver
+					if err := json.Unmarshal(data, &h); err != nil {
+						return nil, migrationPerformed, fmt.Errorf("Error unmarshalling host config version 3: %s", err)
+					}
+				}
+				h = MigrateHostV3ToHostV4(h)
 			}
 		}
 	}
@@ -24,7 +24,7 @@ func TestMigrateHost(t *testing.T) {
 			//
 			// Note that we don't check for the presence of RawDriver's literal "on
 			// disk" here.  It's intentional.
-			description: "Config version 3 load with existing RawDriver on disk",
+			description: "Config version 3 load and migrate with existing RawDriver on disk",
 			hostBefore: &Host{
 				Name: "default",
 			},
@@ -44,7 +44,7 @@ func TestMigrateHost(t *testing.T) {
     "RawDriver": "eyJWQm94TWFuYWdlciI6e30sIklQQWRkcmVzcyI6IjE5Mi4xNjguOTkuMTAwIiwiTWFjaGluZU5hbWUiOiJkZWZhdWx0IiwiU1NIVXNlciI6ImRvY2tlciIsIlNTSFBvcnQiOjU4MTQ1LCJTU0hLZXlQYXRoIjoiL1VzZXJzL25hdGhhbmxlY2xhaXJlLy5kb2NrZXIvbWFjaGluZS9tYWNoaW5lcy9kZWZhdWx0L2lkX3JzYSIsIlN0b3JlUGF0aCI6Ii9Vc2Vycy9uYXRoYW5sZWNsYWlyZS8uZG9ja2VyL21hY2hpbmUiLCJTd2FybU1hc3RlciI6ZmFsc2UsIlN3YXJtSG9zdCI6InRjcDovLzAuMC4wLjA6MzM3NiIsIlN3YXJtRGlzY292ZXJ5IjoiIiwiQ1BVIjoxLCJNZW1vcnkiOjEwMjQsIkRpc2tTaXplIjoyMDAwMCwiQm9vdDJEb2NrZXJVUkwiOiIiLCJCb290MkRvY2tlckltcG9ydFZNIjoiIiwiSG9zdE9ubHlDSURSIjoiMTkyLjE2OC45OS4xLzI0IiwiSG9zdE9ubHlOaWNUeXBlIjoiODI1NDBFTSIsIkhvc3RPbmx5UHJvbWlzY01vZGUiOiJkZW55IiwiTm9TaGFyZSI6ZmFsc2V9"
 }`),
 			expectedHostAfter: &Host{
-				ConfigVersion: 3,
+				ConfigVersion: 4,
 				HostOptions: &Options{
 					AuthOptions: &auth.Options{
 						StorePath: "/Users/nathanleclaire/.docker/machine/machines/default",
@@ -65,16 +65,16 @@ func TestMigrateHost(t *testing.T) {
 					Driver: none.NewDriver("default", "."),
 				},
 			},
-			expectedMigrationPerformed: false,
+			expectedMigrationPerformed: true,
 			expectedMigrationError:     nil,
 		},
 		{
-			description: "Config version 4 (from the FUTURE) on disk",
+			description: "Config version 5 (from the FUTURE) on disk",
 			hostBefore: &Host{
 				Name: "default",
 			},
 			rawData: []byte(`{
-    "ConfigVersion": 4,
+    "ConfigVersion": 5,
     "Driver": {"MachineName": "default"},
     "DriverName": "virtualbox",
     "HostOptions": {
@@ -92,7 +92,7 @@ func TestMigrateHost(t *testing.T) {
 			expectedMigrationError:     errConfigFromFuture,
 		},
 		{
-			description: "Config version 3 load WITHOUT any existing RawDriver field on disk",
+			description: "Config version 3 load and migrate WITHOUT any existing RawDriver field on disk",
 			hostBefore: &Host{
 				Name: "default",
 			},
@@ -111,7 +111,7 @@ func TestMigrateHost(t *testing.T) {
     "Name": "default"
 }`),
 			expectedHostAfter: &Host{
-				ConfigVersion: 3,
+				ConfigVersion: 4,
 				HostOptions: &Options{
 					AuthOptions: &auth.Options{
 						StorePath: "/Users/nathanleclaire/.docker/machine/machines/default",
@@ -127,7 +127,7 @@ func TestMigrateHost(t *testing.T) {
 					Driver: none.NewDriver("default", "."),
 				},
 			},
-			expectedMigrationPerformed: false,
+			expectedMigrationPerformed: true,
 			expectedMigrationError:     nil,
 		},
 		{
@@ -151,7 +151,7 @@ func TestMigrateHost(t *testing.T) {
     "Name": "default"
 }`),
 			expectedHostAfter: &Host{
-				ConfigVersion: 3,
+				ConfigVersion: 4,
 				HostOptions: &Options{
 					AuthOptions: &auth.Options{
 						StorePath: "/Users/nathanleclaire/.docker/machine/machines/default",
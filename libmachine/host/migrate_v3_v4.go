@@ -0,0 +1,12 @@
+package host
+
+// MigrateHostV3ToHostV4 moves the engine options given as arbitrary flags to
+// the typed engine options. The graph dir is left alone, it was never given
+// to the engine.
+func MigrateHostV3ToHostV4(h *Host) *Host {
+	if h.HostOptions != nil && h.HostOptions.EngineOptions != nil {
+		h.HostOptions.EngineOptions.AdoptArbitraryFlags()
+	}
+
+	return h
+}
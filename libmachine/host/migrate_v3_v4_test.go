@@ -0,0 +1,42 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+var v3conf = []byte(`{
+    "ConfigVersion": 3,
+    "Driver": {"MachineName": "default"},
+    "DriverName": "virtualbox",
+    "HostOptions": {
+        "EngineOptions": {
+            "ArbitraryFlags": ["log-driver=journald", "mtu=1400", "default-ulimit=nofile=1024:2048", "g=/mnt/sda1/docker", "selinux-enabled"],
+            "GraphDir": "/var/lib/docker",
+            "Labels": ["env=test"]
+        },
+        "AuthOptions": {
+            "StorePath": "/Users/catbug/.docker/machine/machines/default"
+        }
+    },
+    "Name": "default"
+}`)
+
+func TestMigrateHostV3ToHostV4(t *testing.T) {
+	h, migrationPerformed, err := MigrateHost(&Host{Name: "default"}, v3conf)
+
+	assert.NoError(t, err)
+	assert.True(t, migrationPerformed)
+	assert.Equal(t, 4, h.ConfigVersion)
+	assert.Equal(t, &engine.Options{
+		ArbitraryFlags: []string{"selinux-enabled"},
+		Labels:         []string{"env=test"},
+		LogDriver:      "journald",
+		MTU:            1400,
+		DefaultUlimits: []string{"nofile=1024:2048"},
+		DataRoot:       "/mnt/sda1/docker",
+		GraphDir:       "/var/lib/docker",
+	}, h.HostOptions.EngineOptions)
+}
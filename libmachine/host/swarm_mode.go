@@ -0,0 +1,160 @@
+package host
+
+import (
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcndockerclient"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarmmode"
+)
+
+var (
+	errSwarmModeWorkerWithoutManager = errors.New("A worker needs a manager to join the swarm mode cluster of")
+	errNotSwarmModeNode              = errors.New("The machine isn't a node of a swarm mode cluster")
+)
+
+// SwarmModeRole returns the role of the machine in its swarm mode cluster,
+// or an empty string when it isn't part of one.
+func (h *Host) SwarmModeRole() string {
+	if h.HostOptions == nil || h.HostOptions.SwarmModeOptions == nil {
+		return ""
+	}
+	return h.HostOptions.SwarmModeOptions.Role
+}
+
+// swarmModeAddr returns the address the node of the machine advertises to
+// the cluster.
+func (h *Host) swarmModeAddr() (string, error) {
+	ip, err := h.Driver.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(ip, strconv.Itoa(swarmmode.ListenPort)), nil
+}
+
+// ConfigureSwarmMode makes the machine a node of a swarm mode cluster with
+// its role: the cluster is initialized on the machine when manager is nil,
+// the machine joins the cluster of manager otherwise. Nothing is done when
+// the machine is already part of a cluster.
+func (h *Host) ConfigureSwarmMode(manager *Host) error {
+	role := h.SwarmModeRole()
+	if role == "" {
+		return nil
+	}
+
+	info, err := mcndockerclient.GetSwarmInfo(h)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsActive() {
+		addr, err := h.swarmModeAddr()
+		if err != nil {
+			return err
+		}
+
+		if manager == nil {
+			if role != swarmmode.RoleManager {
+				return errSwarmModeWorkerWithoutManager
+			}
+
+			log.Infof("Initializing the swarm mode cluster on %s...", h.Name)
+			if err := mcndockerclient.SwarmInit(h, addr); err != nil {
+				return err
+			}
+		} else {
+			tokens, err := mcndockerclient.GetSwarmJoinTokens(manager)
+			if err != nil {
+				return err
+			}
+
+			token := tokens.Worker
+			if role == swarmmode.RoleManager {
+				token = tokens.Manager
+			}
+
+			managerAddr, err := manager.swarmModeAddr()
+			if err != nil {
+				return err
+			}
+
+			log.Infof("Joining the swarm mode cluster of %s as a %s...", manager.Name, role)
+			if err := mcndockerclient.SwarmJoin(h, addr, managerAddr, token); err != nil {
+				return err
+			}
+		}
+
+		if info, err = mcndockerclient.GetSwarmInfo(h); err != nil {
+			return err
+		}
+	}
+
+	h.HostOptions.SwarmModeOptions.NodeID = info.NodeID
+	return nil
+}
+
+// LeaveSwarmMode removes the machine from its swarm mode cluster. The node
+// is drained and removed through manager, a manager of the cluster other
+// than the machine. When manager is nil, the machine is the last manager
+// and the cluster goes with it.
+func (h *Host) LeaveSwarmMode(manager *Host) error {
+	if h.SwarmModeRole() == "" {
+		return nil
+	}
+	nodeID := h.HostOptions.SwarmModeOptions.NodeID
+
+	if manager != nil && nodeID != "" {
+		log.Infof("Draining %s...", h.Name)
+		if err := mcndockerclient.DrainNode(manager, nodeID); err != nil {
+			return err
+		}
+	}
+
+	// A stopped machine can't leave, the manager removes its node anyway.
+	if machineState, err := h.Driver.GetState(); err == nil && machineState == state.Running {
+		info, err := mcndockerclient.GetSwarmInfo(h)
+		if err != nil {
+			return err
+		}
+
+		if info.IsActive() {
+			log.Infof("Leaving the swarm mode cluster...")
+			if err := mcndockerclient.SwarmLeave(h, manager == nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	if manager != nil && nodeID != "" {
+		if err := mcndockerclient.RemoveNode(manager, nodeID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetSwarmModeRole promotes or demotes the node of the machine to role,
+// through manager, a manager of the cluster other than the machine.
+func (h *Host) SetSwarmModeRole(manager *Host, role string) error {
+	if h.SwarmModeRole() == "" {
+		return errNotSwarmModeNode
+	}
+
+	nodeID := h.HostOptions.SwarmModeOptions.NodeID
+	if nodeID == "" {
+		return errNotSwarmModeNode
+	}
+
+	log.Infof("Making %s a %s...", h.Name, role)
+	if err := mcndockerclient.SetNodeRole(manager, nodeID, role); err != nil {
+		return err
+	}
+
+	h.HostOptions.SwarmModeOptions.Role = role
+	return nil
+}
@@ -0,0 +1,146 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/mcndockerclient"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarmmode"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSwarmModeHost(name, ip string, machineState state.State, options *swarmmode.Options) *Host {
+	return &Host{
+		Name: name,
+		Driver: &fakedriver.Driver{
+			MockName:  name,
+			MockIP:    ip,
+			MockState: machineState,
+		},
+		HostOptions: &Options{
+			SwarmModeOptions: options,
+		},
+	}
+}
+
+func withFakeSwarmModer(moder *mcndockerclient.FakeSwarmModer) func() {
+	previous := mcndockerclient.CurrentSwarmModer
+	mcndockerclient.CurrentSwarmModer = moder
+	return func() {
+		mcndockerclient.CurrentSwarmModer = previous
+	}
+}
+
+func TestConfigureSwarmModeInitializesFirstManager(t *testing.T) {
+	moder := &mcndockerclient.FakeSwarmModer{}
+	defer withFakeSwarmModer(moder)()
+
+	h := newSwarmModeHost("manager1", "10.0.0.1", state.Running, &swarmmode.Options{Role: swarmmode.RoleManager})
+
+	assert.NoError(t, h.ConfigureSwarmMode(nil))
+	assert.Equal(t, []string{"tcp://10.0.0.1:2376 init 10.0.0.1:2377"}, moder.Calls)
+}
+
+func TestConfigureSwarmModeJoinsManager(t *testing.T) {
+	moder := &mcndockerclient.FakeSwarmModer{
+		JoinTokens: mcndockerclient.SwarmJoinTokens{Worker: "SWMTKN-worker", Manager: "SWMTKN-manager"},
+		Infos:      map[string]mcndockerclient.SwarmInfo{},
+	}
+	defer withFakeSwarmModer(moder)()
+
+	manager := newSwarmModeHost("manager1", "10.0.0.1", state.Running, &swarmmode.Options{Role: swarmmode.RoleManager})
+	worker := newSwarmModeHost("worker1", "10.0.0.2", state.Running, &swarmmode.Options{Role: swarmmode.RoleWorker, Join: "manager1"})
+	manager2 := newSwarmModeHost("manager2", "10.0.0.3", state.Running, &swarmmode.Options{Role: swarmmode.RoleManager, Join: "manager1"})
+
+	assert.NoError(t, worker.ConfigureSwarmMode(manager))
+	assert.NoError(t, manager2.ConfigureSwarmMode(manager))
+	assert.Equal(t, []string{
+		"tcp://10.0.0.2:2376 join 10.0.0.2:2377 10.0.0.1:2377 SWMTKN-worker",
+		"tcp://10.0.0.3:2376 join 10.0.0.3:2377 10.0.0.1:2377 SWMTKN-manager",
+	}, moder.Calls)
+}
+
+func TestConfigureSwarmModeSkipsActiveNode(t *testing.T) {
+	moder := &mcndockerclient.FakeSwarmModer{
+		Infos: map[string]mcndockerclient.SwarmInfo{
+			"tcp://10.0.0.1:2376": {NodeID: "node1", LocalNodeState: "active"},
+		},
+	}
+	defer withFakeSwarmModer(moder)()
+
+	h := newSwarmModeHost("manager1", "10.0.0.1", state.Running, &swarmmode.Options{Role: swarmmode.RoleManager})
+
+	assert.NoError(t, h.ConfigureSwarmMode(nil))
+	assert.Empty(t, moder.Calls)
+	assert.Equal(t, "node1", h.HostOptions.SwarmModeOptions.NodeID)
+}
+
+func TestConfigureSwarmModeWorkerWithoutManager(t *testing.T) {
+	defer withFakeSwarmModer(&mcndockerclient.FakeSwarmModer{})()
+
+	h := newSwarmModeHost("worker1", "10.0.0.2", state.Running, &swarmmode.Options{Role: swarmmode.RoleWorker})
+
+	assert.Equal(t, errSwarmModeWorkerWithoutManager, h.ConfigureSwarmMode(nil))
+}
+
+func TestLeaveSwarmMode(t *testing.T) {
+	moder := &mcndockerclient.FakeSwarmModer{
+		Infos: map[string]mcndockerclient.SwarmInfo{
+			"tcp://10.0.0.2:2376": {NodeID: "node2", LocalNodeState: "active"},
+		},
+	}
+	defer withFakeSwarmModer(moder)()
+
+	manager := newSwarmModeHost("manager1", "10.0.0.1", state.Running, &swarmmode.Options{Role: swarmmode.RoleManager})
+	worker := newSwarmModeHost("worker1", "10.0.0.2", state.Running, &swarmmode.Options{Role: swarmmode.RoleWorker, Join: "manager1", NodeID: "node2"})
+
+	assert.NoError(t, worker.LeaveSwarmMode(manager))
+	assert.Equal(t, []string{
+		"tcp://10.0.0.1:2376 drain node2",
+		"tcp://10.0.0.2:2376 leave",
+		"tcp://10.0.0.1:2376 rm node2",
+	}, moder.Calls)
+}
+
+func TestLeaveSwarmModeStoppedMachine(t *testing.T) {
+	moder := &mcndockerclient.FakeSwarmModer{}
+	defer withFakeSwarmModer(moder)()
+
+	manager := newSwarmModeHost("manager1", "10.0.0.1", state.Running, &swarmmode.Options{Role: swarmmode.RoleManager})
+	worker := newSwarmModeHost("worker1", "10.0.0.2", state.Stopped, &swarmmode.Options{Role: swarmmode.RoleWorker, Join: "manager1", NodeID: "node2"})
+
+	assert.NoError(t, worker.LeaveSwarmMode(manager))
+	assert.Equal(t, []string{
+		"tcp://10.0.0.1:2376 drain node2",
+		"tcp://10.0.0.1:2376 rm node2",
+	}, moder.Calls)
+}
+
+func TestLeaveSwarmModeLastManager(t *testing.T) {
+	moder := &mcndockerclient.FakeSwarmModer{
+		Infos: map[string]mcndockerclient.SwarmInfo{
+			"tcp://10.0.0.1:2376": {NodeID: "node1", LocalNodeState: "active"},
+		},
+	}
+	defer withFakeSwarmModer(moder)()
+
+	manager := newSwarmModeHost("manager1", "10.0.0.1", state.Running, &swarmmode.Options{Role: swarmmode.RoleManager, NodeID: "node1"})
+
+	assert.NoError(t, manager.LeaveSwarmMode(nil))
+	assert.Equal(t, []string{"tcp://10.0.0.1:2376 leave --force"}, moder.Calls)
+}
+
+func TestSetSwarmModeRole(t *testing.T) {
+	moder := &mcndockerclient.FakeSwarmModer{}
+	defer withFakeSwarmModer(moder)()
+
+	manager := newSwarmModeHost("manager1", "10.0.0.1", state.Running, &swarmmode.Options{Role: swarmmode.RoleManager})
+	worker := newSwarmModeHost("worker1", "10.0.0.2", state.Running, &swarmmode.Options{Role: swarmmode.RoleWorker, Join: "manager1", NodeID: "node2"})
+
+	assert.NoError(t, worker.SetSwarmModeRole(manager, swarmmode.RoleManager))
+	assert.Equal(t, swarmmode.RoleManager, worker.SwarmModeRole())
+	assert.Equal(t, []string{"tcp://10.0.0.1:2376 manager node2"}, moder.Calls)
+
+	assert.Equal(t, errNotSwarmModeNode, newSwarmModeHost("other", "10.0.0.3", state.Running, nil).SetSwarmModeRole(manager, swarmmode.RoleWorker))
+}
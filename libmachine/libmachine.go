@@ -19,7 +19,6 @@ import (
 	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/persist"
-	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
@@ -162,14 +161,23 @@ func (api *Client) performCreate(h *host.Host) error {
 		return fmt.Errorf("Error waiting for machine to be running: %s", err)
 	}
 
+	if err := setLocalSwarmDiscovery(h); err != nil {
+		return fmt.Errorf("Error setting the Swarm discovery: %s", err)
+	}
+
 	log.Info("Detecting operating system of created instance...")
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := h.Provisioner()
 	if err != nil {
 		return fmt.Errorf("Error detecting OS: %s", err)
 	}
 
 	log.Infof("Provisioning with %s...", provisioner.String())
-	if err := provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
+	if err := h.ProvisionWith(provisioner, ""); err != nil {
+		// The completed steps are saved so that the provisioning can be
+		// resumed with `provision --resume`.
+		if saveErr := api.Save(h); saveErr != nil {
+			log.Debugf("Error saving the completed provisioning steps: %s", saveErr)
+		}
 		return fmt.Errorf("Error running provisioning: %s", err)
 	}
 
@@ -180,9 +188,50 @@ func (api *Client) performCreate(h *host.Host) error {
 	}
 
 	log.Info("Docker is up and running!")
+
+	if err := api.configureSwarmMode(h); err != nil {
+		return fmt.Errorf("Error configuring swarm mode: %s", err)
+	}
+
 	return nil
 }
 
+// setLocalSwarmDiscovery sets the discovery of a master which runs the
+// discovery service of its cluster, now that its IP is known.
+func setLocalSwarmDiscovery(h *host.Host) error {
+	swarmOptions := h.HostOptions.SwarmOptions
+	if swarmOptions == nil || !swarmOptions.LocalDiscovery || swarmOptions.Discovery != "" {
+		return nil
+	}
+
+	ip, err := h.Driver.GetIP()
+	if err != nil {
+		return err
+	}
+
+	swarmOptions.Discovery = swarm.LocalDiscoveryURL(ip)
+	return nil
+}
+
+// configureSwarmMode initializes the swarm mode cluster on the machine, or
+// joins it to the cluster of the machine it's set to join.
+func (api *Client) configureSwarmMode(h *host.Host) error {
+	if h.SwarmModeRole() == "" {
+		return nil
+	}
+
+	var manager *host.Host
+	if join := h.HostOptions.SwarmModeOptions.Join; join != "" {
+		var err error
+		if manager, err = api.Load(join); err != nil {
+			return err
+		}
+	}
+
+	return h.ConfigureSwarmMode(manager)
+}
+
 func (api *Client) Close() error {
+	ssh.CloseConnections()
 	return api.clientDriverFactory.Close()
 }
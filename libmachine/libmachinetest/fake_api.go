@@ -9,7 +9,8 @@ import (
 )
 
 type FakeAPI struct {
-	Hosts []*host.Host
+	Hosts       []*host.Host
+	MachinesDir string
 }
 
 func (api *FakeAPI) NewPluginDriver(string, []byte) (drivers.Driver, error) {
@@ -39,7 +40,12 @@ func (api *FakeAPI) Exists(name string) (bool, error) {
 }
 
 func (api *FakeAPI) List() ([]string, error) {
-	return []string{}, nil
+	names := []string{}
+	for _, host := range api.Hosts {
+		names = append(names, host.Name)
+	}
+
+	return names, nil
 }
 
 func (api *FakeAPI) Load(name string) (*host.Host, error) {
@@ -73,7 +79,7 @@ func (api *FakeAPI) Save(host *host.Host) error {
 }
 
 func (api FakeAPI) GetMachinesDir() string {
-	return ""
+	return api.MachinesDir
 }
 
 func State(api libmachine.API, name string) state.State {
@@ -45,3 +45,37 @@ func CreateContainer(dockerHost DockerHost, config *dockerclient.ContainerConfig
 
 	return nil
 }
+
+// RemoveContainer removes a docker container, running or not. Nothing is
+// done when there's no such container.
+func RemoveContainer(dockerHost DockerHost, name string) error {
+	docker, err := DockerClient(dockerHost)
+	if err != nil {
+		return err
+	}
+
+	if err := docker.RemoveContainer(name, true, false); err != nil && err != dockerclient.ErrNotFound {
+		return fmt.Errorf("Error while removing container: %s", err)
+	}
+
+	return nil
+}
+
+// InspectContainer returns the details of a docker container, nil when
+// there's no such container.
+func InspectContainer(dockerHost DockerHost, name string) (*dockerclient.ContainerInfo, error) {
+	docker, err := DockerClient(dockerHost)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := docker.InspectContainer(name)
+	if err == dockerclient.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error while inspecting container: %s", err)
+	}
+
+	return info, nil
+}
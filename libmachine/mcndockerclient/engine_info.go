@@ -0,0 +1,108 @@
+package mcndockerclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+)
+
+// defaultInsecureRegistry is the insecure registry every engine allows.
+const defaultInsecureRegistry = "127.0.0.0/8"
+
+var CurrentEngineInfoer EngineInfoer = &defaultEngineInfoer{}
+
+// EngineInfo is the configuration an engine runs with, as it reports it.
+type EngineInfo struct {
+	Labels             []string
+	StorageDriver      string
+	LoggingDriver      string
+	CgroupDriver       string
+	LiveRestore        bool
+	DataRoot           string
+	RegistryMirrors    []string
+	InsecureRegistries []string
+}
+
+type EngineInfoer interface {
+	EngineInfo(host DockerHost) (EngineInfo, error)
+}
+
+func GetEngineInfo(host DockerHost) (EngineInfo, error) {
+	return CurrentEngineInfoer.EngineInfo(host)
+}
+
+type defaultEngineInfoer struct{}
+
+func (ei *defaultEngineInfoer) EngineInfo(host DockerHost) (EngineInfo, error) {
+	client, err := DockerClient(host)
+	if err != nil {
+		return EngineInfo{}, fmt.Errorf("Unable to query the engine info: %s", err)
+	}
+
+	// Without a version in the path, the engine answers with its own API.
+	resp, err := client.HTTPClient.Get(client.URL.String() + "/info")
+	if err != nil {
+		return EngineInfo{}, fmt.Errorf("Unable to query the engine info: %s", err)
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return EngineInfo{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return EngineInfo{}, fmt.Errorf("Unable to query the engine info: %s %s", resp.Status, content)
+	}
+
+	return parseEngineInfo(content)
+}
+
+// parseEngineInfo reads the configuration of the engine in the response of
+// its /info endpoint.
+func parseEngineInfo(content []byte) (EngineInfo, error) {
+	info := struct {
+		Labels             []string
+		Driver             string
+		LoggingDriver      string
+		CgroupDriver       string
+		LiveRestoreEnabled bool
+		DockerRootDir      string
+		RegistryConfig     struct {
+			Mirrors               []string
+			InsecureRegistryCIDRs []string
+			IndexConfigs          map[string]struct {
+				Secure   bool
+				Official bool
+			}
+		}
+	}{}
+	if err := json.Unmarshal(content, &info); err != nil {
+		return EngineInfo{}, fmt.Errorf("Unable to read the engine info: %s", err)
+	}
+
+	insecureRegistries := []string{}
+	for _, cidr := range info.RegistryConfig.InsecureRegistryCIDRs {
+		if cidr != defaultInsecureRegistry {
+			insecureRegistries = append(insecureRegistries, cidr)
+		}
+	}
+	for name, index := range info.RegistryConfig.IndexConfigs {
+		if !index.Secure && !index.Official {
+			insecureRegistries = append(insecureRegistries, name)
+		}
+	}
+	sort.Strings(insecureRegistries)
+
+	return EngineInfo{
+		Labels:             info.Labels,
+		StorageDriver:      info.Driver,
+		LoggingDriver:      info.LoggingDriver,
+		CgroupDriver:       info.CgroupDriver,
+		LiveRestore:        info.LiveRestoreEnabled,
+		DataRoot:           info.DockerRootDir,
+		RegistryMirrors:    info.RegistryConfig.Mirrors,
+		InsecureRegistries: insecureRegistries,
+	}, nil
+}
@@ -0,0 +1,13 @@
+package mcndockerclient
+
+// FakeEngineInfoer returns the configuration of the engines whose URL are
+// given.
+type FakeEngineInfoer struct {
+	Infos map[string]EngineInfo
+	Err   error
+}
+
+func (ei *FakeEngineInfoer) EngineInfo(host DockerHost) (EngineInfo, error) {
+	url, _ := host.URL()
+	return ei.Infos[url], ei.Err
+}
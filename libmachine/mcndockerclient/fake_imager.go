@@ -0,0 +1,53 @@
+package mcndockerclient
+
+import (
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// FakeImager serves the image layers of the engines, and records the
+// tarballs loaded in them, by URL.
+type FakeImager struct {
+	Layers map[string][][]string
+	// Saved is written as the tarball of the saved images.
+	Saved  []byte
+	Loaded map[string][]byte
+	Err    error
+	lock   sync.Mutex
+}
+
+func (im *FakeImager) ImageLayers(host DockerHost) ([][]string, error) {
+	url, _ := host.URL()
+	return im.Layers[url], im.Err
+}
+
+func (im *FakeImager) SaveImages(host DockerHost, names []string, w io.Writer) error {
+	if im.Err != nil {
+		return im.Err
+	}
+
+	_, err := w.Write(im.Saved)
+	return err
+}
+
+func (im *FakeImager) LoadImages(host DockerHost, r io.Reader) error {
+	if im.Err != nil {
+		return im.Err
+	}
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	url, _ := host.URL()
+	im.lock.Lock()
+	defer im.lock.Unlock()
+	if im.Loaded == nil {
+		im.Loaded = map[string][]byte{}
+	}
+	im.Loaded[url] = content
+
+	return nil
+}
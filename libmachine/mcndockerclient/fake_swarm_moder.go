@@ -0,0 +1,53 @@
+package mcndockerclient
+
+// FakeSwarmModer records the swarm mode calls, made on the engines whose
+// URL are given.
+type FakeSwarmModer struct {
+	// Infos are the swarm mode states of the engines, by URL.
+	Infos      map[string]SwarmInfo
+	JoinTokens SwarmJoinTokens
+	Err        error
+	Calls      []string
+}
+
+func (sm *FakeSwarmModer) record(host DockerHost, call string) error {
+	url, _ := host.URL()
+	sm.Calls = append(sm.Calls, url+" "+call)
+	return sm.Err
+}
+
+func (sm *FakeSwarmModer) SwarmInfo(host DockerHost) (SwarmInfo, error) {
+	url, _ := host.URL()
+	return sm.Infos[url], sm.Err
+}
+
+func (sm *FakeSwarmModer) SwarmInit(host DockerHost, advertiseAddr string) error {
+	return sm.record(host, "init "+advertiseAddr)
+}
+
+func (sm *FakeSwarmModer) SwarmJoinTokens(host DockerHost) (SwarmJoinTokens, error) {
+	return sm.JoinTokens, sm.Err
+}
+
+func (sm *FakeSwarmModer) SwarmJoin(host DockerHost, advertiseAddr, remoteAddr, token string) error {
+	return sm.record(host, "join "+advertiseAddr+" "+remoteAddr+" "+token)
+}
+
+func (sm *FakeSwarmModer) SwarmLeave(host DockerHost, force bool) error {
+	if force {
+		return sm.record(host, "leave --force")
+	}
+	return sm.record(host, "leave")
+}
+
+func (sm *FakeSwarmModer) DrainNode(host DockerHost, nodeID string) error {
+	return sm.record(host, "drain "+nodeID)
+}
+
+func (sm *FakeSwarmModer) SetNodeRole(host DockerHost, nodeID, role string) error {
+	return sm.record(host, role+" "+nodeID)
+}
+
+func (sm *FakeSwarmModer) RemoveNode(host DockerHost, nodeID string) error {
+	return sm.record(host, "rm "+nodeID)
+}
@@ -0,0 +1,241 @@
+package mcndockerclient
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+const imageArchiveManifest = "manifest.json"
+
+// ImageArchive is a tarball of images saved from an engine.
+type ImageArchive struct {
+	Path string
+	// Size is the size of the tarball.
+	Size   int64
+	images []archivedImage
+	// layerSizes are the sizes of the layer tarballs, by path.
+	layerSizes map[string]int64
+	// layerLinks are the targets of the layer tarballs which are links to
+	// the tarball of an identical layer.
+	layerLinks map[string]string
+}
+
+type archivedImage struct {
+	// Layers are the paths of the layer tarballs, from the base layer up.
+	Layers []string
+	// DiffIDs are the diff IDs of the layers, in the same order.
+	DiffIDs []string
+}
+
+// ReadImageArchive reads the manifest and the image configs of the tarball,
+// as written by docker save since Docker 1.10.
+func ReadImageArchive(archivePath string) (*ImageArchive, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &ImageArchive{
+		Path:       archivePath,
+		Size:       info.Size(),
+		layerSizes: map[string]int64{},
+		layerLinks: map[string]string{},
+	}
+
+	manifest := []struct {
+		Config string
+		Layers []string
+	}{}
+	configs := map[string][]byte{}
+
+	reader := tar.NewReader(file)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the image archive: %s", err)
+		}
+
+		name := path.Clean(header.Name)
+		switch {
+		case path.Base(name) == "layer.tar":
+			switch header.Typeflag {
+			case tar.TypeReg, tar.TypeRegA:
+				archive.layerSizes[name] = header.Size
+			case tar.TypeSymlink:
+				archive.layerLinks[name] = path.Join(path.Dir(name), header.Linkname)
+			}
+		case name == imageArchiveManifest:
+			if err := json.NewDecoder(reader).Decode(&manifest); err != nil {
+				return nil, fmt.Errorf("Error reading the image archive manifest: %s", err)
+			}
+		case path.Dir(name) == "." && strings.HasSuffix(name, ".json"):
+			content, err := ioutil.ReadAll(reader)
+			if err != nil {
+				return nil, err
+			}
+			configs[name] = content
+		}
+	}
+
+	if len(manifest) == 0 {
+		return nil, fmt.Errorf("The image archive has no %s, the local engine is too old", imageArchiveManifest)
+	}
+
+	for _, entry := range manifest {
+		config := struct {
+			RootFS struct {
+				DiffIDs []string `json:"diff_ids"`
+			} `json:"rootfs"`
+		}{}
+		if err := json.Unmarshal(configs[path.Clean(entry.Config)], &config); err != nil {
+			return nil, fmt.Errorf("Error reading the image config %s: %s", entry.Config, err)
+		}
+		if len(config.RootFS.DiffIDs) != len(entry.Layers) {
+			return nil, fmt.Errorf("The image config %s doesn't match its layers", entry.Config)
+		}
+
+		image := archivedImage{DiffIDs: config.RootFS.DiffIDs}
+		for _, layer := range entry.Layers {
+			image.Layers = append(image.Layers, path.Clean(layer))
+		}
+		archive.images = append(archive.images, image)
+	}
+
+	return archive, nil
+}
+
+// layerChain identifies a layer along with the layers under it, as the
+// engines do.
+func layerChain(diffIDs []string) string {
+	return strings.Join(diffIDs, ",")
+}
+
+// SkippedLayers returns the paths of the layer tarballs an engine which has
+// images of the layers given doesn't need. An engine loading a tarball
+// doesn't open the tarball of a layer it already has.
+func (a *ImageArchive) SkippedLayers(engineLayers [][]string) map[string]bool {
+	chains := map[string]bool{}
+	for _, diffIDs := range engineLayers {
+		for i := range diffIDs {
+			chains[layerChain(diffIDs[:i+1])] = true
+		}
+	}
+
+	skipped := map[string]bool{}
+	needed := map[string]bool{}
+	for _, image := range a.images {
+		for i, layer := range image.Layers {
+			if chains[layerChain(image.DiffIDs[:i+1])] {
+				skipped[layer] = true
+			} else {
+				needed[layer] = true
+			}
+		}
+	}
+
+	for layer := range needed {
+		delete(skipped, layer)
+	}
+	// Only the regular files go, the links are kept along with their target.
+	for layer, target := range a.layerLinks {
+		delete(skipped, layer)
+		delete(skipped, target)
+	}
+
+	return skipped
+}
+
+// Push loads the images of the tarball in the engine of host, without the
+// layers it already has, and returns how many layers were left out.
+// progress is called with the bytes sent so far and the bytes to send.
+func (a *ImageArchive) Push(host DockerHost, progress func(sent, total int64)) (int, error) {
+	engineLayers, err := GetImageLayers(host)
+	if err != nil {
+		return 0, err
+	}
+
+	skipped := a.SkippedLayers(engineLayers)
+	total := a.Size
+	for layer := range skipped {
+		total -= a.layerSizes[layer]
+	}
+
+	file, err := os.Open(a.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		pipeWriter.CloseWithError(filterImageArchive(file, pipeWriter, skipped))
+	}()
+	defer pipeReader.Close()
+
+	return len(skipped), LoadImages(host, &progressReader{
+		reader:   pipeReader,
+		total:    total,
+		progress: progress,
+	})
+}
+
+// filterImageArchive copies the tarball from r to w, without the files
+// skipped.
+func filterImageArchive(r io.Reader, w io.Writer, skipped map[string]bool) error {
+	reader := tar.NewReader(r)
+	writer := tar.NewWriter(w)
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if skipped[path.Clean(header.Name)] {
+			continue
+		}
+
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(writer, reader); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+type progressReader struct {
+	reader   io.Reader
+	sent     int64
+	total    int64
+	progress func(sent, total int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.sent += int64(n)
+	if r.progress != nil && n > 0 {
+		r.progress(r.sent, r.total)
+	}
+	return n, err
+}
@@ -0,0 +1,197 @@
+package mcndockerclient
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/samalba/dockerclient"
+)
+
+const (
+	defaultLocalDockerHost = "unix:///var/run/docker.sock"
+	imageDialTimeout       = 30 * time.Second
+)
+
+var CurrentImager Imager = &defaultImager{}
+
+// Imager moves images in and out of engines.
+type Imager interface {
+	// ImageLayers returns the diff IDs of the layers of each image of the
+	// engine, from the base layer up.
+	ImageLayers(host DockerHost) ([][]string, error)
+	// SaveImages writes the tarball of the images to w, as docker save.
+	SaveImages(host DockerHost, names []string, w io.Writer) error
+	// LoadImages loads a tarball of images, as docker load.
+	LoadImages(host DockerHost, r io.Reader) error
+}
+
+func GetImageLayers(host DockerHost) ([][]string, error) {
+	return CurrentImager.ImageLayers(host)
+}
+
+func SaveImages(host DockerHost, names []string, w io.Writer) error {
+	return CurrentImager.SaveImages(host, names, w)
+}
+
+func LoadImages(host DockerHost, r io.Reader) error {
+	return CurrentImager.LoadImages(host, r)
+}
+
+// LocalDockerHost returns the engine the Docker client is configured to
+// use by DOCKER_HOST, DOCKER_TLS_VERIFY and DOCKER_CERT_PATH.
+func LocalDockerHost() DockerHost {
+	local := &RemoteDocker{
+		HostURL: os.Getenv("DOCKER_HOST"),
+	}
+	if local.HostURL == "" {
+		local.HostURL = defaultLocalDockerHost
+	}
+
+	if os.Getenv("DOCKER_TLS_VERIFY") != "" {
+		certPath := os.Getenv("DOCKER_CERT_PATH")
+		if certPath == "" {
+			certPath = filepath.Join(mcnutils.GetHomeDir(), ".docker")
+		}
+
+		local.AuthOption = &auth.Options{
+			CaCertPath:     filepath.Join(certPath, "ca.pem"),
+			ClientCertPath: filepath.Join(certPath, "cert.pem"),
+			ClientKeyPath:  filepath.Join(certPath, "key.pem"),
+		}
+	}
+
+	return local
+}
+
+type defaultImager struct{}
+
+func (im *defaultImager) ImageLayers(host DockerHost) ([][]string, error) {
+	images := []struct {
+		ID string `json:"Id"`
+	}{}
+	if err := imageRequestJSON(host, "/images/json", &images); err != nil {
+		return nil, fmt.Errorf("Unable to list the images: %s", err)
+	}
+
+	layers := [][]string{}
+	for _, image := range images {
+		info := struct {
+			RootFS struct {
+				Layers []string
+			}
+		}{}
+		if err := imageRequestJSON(host, fmt.Sprintf("/images/%s/json", image.ID), &info); err != nil {
+			return nil, fmt.Errorf("Unable to inspect the image %s: %s", image.ID, err)
+		}
+		layers = append(layers, info.RootFS.Layers)
+	}
+
+	return layers, nil
+}
+
+func (im *defaultImager) SaveImages(host DockerHost, names []string, w io.Writer) error {
+	query := url.Values{"names": names}
+	resp, err := imageRequest(host, "GET", "/images/get?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("Unable to save the images: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("Unable to save the images: %s", err)
+	}
+
+	return nil
+}
+
+func (im *defaultImager) LoadImages(host DockerHost, r io.Reader) error {
+	resp, err := imageRequest(host, "POST", "/images/load?quiet=1", r)
+	if err != nil {
+		return fmt.Errorf("Unable to load the images: %s", err)
+	}
+	defer resp.Body.Close()
+
+	// The errors happening once the load started are in the progress
+	// messages.
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		message := struct {
+			Error string `json:"error"`
+		}{}
+		err := decoder.Decode(&message)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to load the images: %s", err)
+		}
+		if message.Error != "" {
+			return fmt.Errorf("Unable to load the images: %s", message.Error)
+		}
+	}
+}
+
+func imageRequestJSON(host DockerHost, path string, response interface{}) error {
+	resp, err := imageRequest(host, "GET", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// imageRequest calls the API of the engine of host, over TLS when host has
+// auth options. There's no timeout as images take a while to move around.
+func imageRequest(host DockerHost, method, path string, body io.Reader) (*http.Response, error) {
+	hostURL, err := host.URL()
+	if err != nil {
+		return nil, err
+	}
+
+	var tlsConfig *tls.Config
+	if host.AuthOptions() != nil {
+		if tlsConfig, err = cert.ReadTLSConfig(hostURL, host.AuthOptions()); err != nil {
+			return nil, fmt.Errorf("Unable to read TLS config: %s", err)
+		}
+	}
+
+	// The client of dockerclient dials unix sockets as well as TCP.
+	docker, err := dockerclient.NewDockerClientTimeout(hostURL, tlsConfig, imageDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, docker.URL.String()+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-tar")
+	}
+
+	resp, err := docker.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		content, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(content)))
+	}
+
+	return resp, nil
+}
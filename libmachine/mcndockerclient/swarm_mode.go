@@ -0,0 +1,275 @@
+package mcndockerclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/swarmmode"
+)
+
+const (
+	// swarmModeAPIVersion is the first version of the API with swarm mode.
+	swarmModeAPIVersion = "v1.24"
+	swarmModeAPITimeout = 60 * time.Second
+)
+
+var (
+	CurrentSwarmModer SwarmModer = &defaultSwarmModer{}
+	listenAddr                   = fmt.Sprintf("0.0.0.0:%d", swarmmode.ListenPort)
+)
+
+// SwarmInfo is the swarm mode state of an engine.
+type SwarmInfo struct {
+	NodeID string
+	// LocalNodeState is active once the engine is part of a cluster.
+	LocalNodeState   string
+	ControlAvailable bool
+}
+
+// IsActive tells whether the engine is part of a cluster.
+func (info SwarmInfo) IsActive() bool {
+	return info.LocalNodeState == "active"
+}
+
+// SwarmJoinTokens are the tokens the nodes join a cluster with.
+type SwarmJoinTokens struct {
+	Worker  string
+	Manager string
+}
+
+// SwarmModer runs the swarm mode API of engines.
+type SwarmModer interface {
+	SwarmInfo(host DockerHost) (SwarmInfo, error)
+	SwarmInit(host DockerHost, advertiseAddr string) error
+	SwarmJoinTokens(host DockerHost) (SwarmJoinTokens, error)
+	SwarmJoin(host DockerHost, advertiseAddr, remoteAddr, token string) error
+	SwarmLeave(host DockerHost, force bool) error
+	// DrainNode drains the node through the manager host, and demotes it
+	// when it's a manager.
+	DrainNode(host DockerHost, nodeID string) error
+	// SetNodeRole promotes or demotes the node through the manager host.
+	SetNodeRole(host DockerHost, nodeID, role string) error
+	RemoveNode(host DockerHost, nodeID string) error
+}
+
+func GetSwarmInfo(host DockerHost) (SwarmInfo, error) {
+	return CurrentSwarmModer.SwarmInfo(host)
+}
+
+func SwarmInit(host DockerHost, advertiseAddr string) error {
+	return CurrentSwarmModer.SwarmInit(host, advertiseAddr)
+}
+
+func GetSwarmJoinTokens(host DockerHost) (SwarmJoinTokens, error) {
+	return CurrentSwarmModer.SwarmJoinTokens(host)
+}
+
+func SwarmJoin(host DockerHost, advertiseAddr, remoteAddr, token string) error {
+	return CurrentSwarmModer.SwarmJoin(host, advertiseAddr, remoteAddr, token)
+}
+
+func SwarmLeave(host DockerHost, force bool) error {
+	return CurrentSwarmModer.SwarmLeave(host, force)
+}
+
+func DrainNode(host DockerHost, nodeID string) error {
+	return CurrentSwarmModer.DrainNode(host, nodeID)
+}
+
+func SetNodeRole(host DockerHost, nodeID, role string) error {
+	return CurrentSwarmModer.SetNodeRole(host, nodeID, role)
+}
+
+func RemoveNode(host DockerHost, nodeID string) error {
+	return CurrentSwarmModer.RemoveNode(host, nodeID)
+}
+
+type defaultSwarmModer struct{}
+
+func (sm *defaultSwarmModer) SwarmInfo(host DockerHost) (SwarmInfo, error) {
+	info := struct {
+		Swarm SwarmInfo
+	}{}
+	if err := swarmModeRequest(host, "GET", "/info", nil, &info); err != nil {
+		return SwarmInfo{}, fmt.Errorf("Unable to query the swarm mode state: %s", err)
+	}
+
+	return info.Swarm, nil
+}
+
+func (sm *defaultSwarmModer) SwarmInit(host DockerHost, advertiseAddr string) error {
+	request := map[string]interface{}{
+		"ListenAddr":    listenAddr,
+		"AdvertiseAddr": advertiseAddr,
+	}
+	if err := swarmModeRequest(host, "POST", "/swarm/init", request, nil); err != nil {
+		return fmt.Errorf("Unable to initialize the swarm mode cluster: %s", err)
+	}
+
+	return nil
+}
+
+func (sm *defaultSwarmModer) SwarmJoinTokens(host DockerHost) (SwarmJoinTokens, error) {
+	swarm := struct {
+		JoinTokens SwarmJoinTokens
+	}{}
+	if err := swarmModeRequest(host, "GET", "/swarm", nil, &swarm); err != nil {
+		return SwarmJoinTokens{}, fmt.Errorf("Unable to get the join tokens of the swarm mode cluster: %s", err)
+	}
+
+	return swarm.JoinTokens, nil
+}
+
+func (sm *defaultSwarmModer) SwarmJoin(host DockerHost, advertiseAddr, remoteAddr, token string) error {
+	request := map[string]interface{}{
+		"ListenAddr":    listenAddr,
+		"AdvertiseAddr": advertiseAddr,
+		"RemoteAddrs":   []string{remoteAddr},
+		"JoinToken":     token,
+	}
+	if err := swarmModeRequest(host, "POST", "/swarm/join", request, nil); err != nil {
+		return fmt.Errorf("Unable to join the swarm mode cluster: %s", err)
+	}
+
+	return nil
+}
+
+func (sm *defaultSwarmModer) SwarmLeave(host DockerHost, force bool) error {
+	if err := swarmModeRequest(host, "POST", fmt.Sprintf("/swarm/leave?force=%t", force), nil, nil); err != nil {
+		return fmt.Errorf("Unable to leave the swarm mode cluster: %s", err)
+	}
+
+	return nil
+}
+
+func (sm *defaultSwarmModer) DrainNode(host DockerHost, nodeID string) error {
+	if err := updateNode(host, nodeID, map[string]interface{}{
+		"Availability": "drain",
+		"Role":         swarmmode.RoleWorker,
+	}); err != nil {
+		return fmt.Errorf("Unable to drain the node %s: %s", nodeID, err)
+	}
+
+	return nil
+}
+
+func (sm *defaultSwarmModer) SetNodeRole(host DockerHost, nodeID, role string) error {
+	if err := updateNode(host, nodeID, map[string]interface{}{
+		"Role": role,
+	}); err != nil {
+		return fmt.Errorf("Unable to make the node %s a %s: %s", nodeID, role, err)
+	}
+
+	return nil
+}
+
+// updateNode changes the fields of the spec of the node through the manager
+// host.
+func updateNode(host DockerHost, nodeID string, fields map[string]interface{}) error {
+	// The whole spec is sent back, it's kept as is but for the changed
+	// fields.
+	node := struct {
+		Version struct {
+			Index uint64
+		}
+		Spec map[string]interface{}
+	}{}
+	if err := swarmModeRequest(host, "GET", "/nodes/"+nodeID, nil, &node); err != nil {
+		return err
+	}
+
+	if node.Spec == nil {
+		node.Spec = map[string]interface{}{}
+	}
+	for field, value := range fields {
+		node.Spec[field] = value
+	}
+
+	return swarmModeRequest(host, "POST", fmt.Sprintf("/nodes/%s/update?version=%d", nodeID, node.Version.Index), node.Spec, nil)
+}
+
+func (sm *defaultSwarmModer) RemoveNode(host DockerHost, nodeID string) error {
+	if err := swarmModeRequest(host, "DELETE", fmt.Sprintf("/nodes/%s?force=true", nodeID), nil, nil); err != nil {
+		return fmt.Errorf("Unable to remove the node %s: %s", nodeID, err)
+	}
+
+	return nil
+}
+
+// swarmModeRequest calls the API of the engine of host over TLS, sending
+// request and decoding the response into response when they're not nil.
+func swarmModeRequest(host DockerHost, method, path string, request, response interface{}) error {
+	hostURL, err := host.URL()
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(hostURL)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := cert.ReadTLSConfig(hostURL, host.AuthOptions())
+	if err != nil {
+		return fmt.Errorf("Unable to read TLS config: %s", err)
+	}
+
+	var body io.Reader
+	if request != nil {
+		content, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("https://%s/%s%s", u.Host, swarmModeAPIVersion, path), body)
+	if err != nil {
+		return err
+	}
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{
+		Timeout: swarmModeAPITimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := struct {
+			Message string `json:"message"`
+		}{}
+		if json.Unmarshal(content, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("%s: %s", resp.Status, apiErr.Message)
+		}
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(content)))
+	}
+
+	if response != nil {
+		return json.Unmarshal(content, response)
+	}
+
+	return nil
+}
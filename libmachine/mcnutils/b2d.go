@@ -14,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/docker/machine/libmachine/log"
@@ -22,6 +23,7 @@ import (
 
 const (
 	defaultURL            = "https://api.github.com/repos/boot2docker/boot2docker/releases"
+	releaseDownloadURL    = "https://github.com/boot2docker/boot2docker/releases/download"
 	defaultISOFilename    = "boot2docker.iso"
 	defaultVolumeIDOffset = int64(0x8028)
 	versionPrefix         = "-v"
@@ -32,6 +34,12 @@ var (
 	GithubAPIToken string
 )
 
+// lastBoot2DockerRelease is the version of Docker shipped by the last release
+// of Boot2Docker, which was deprecated afterwards.
+const lastBoot2DockerRelease = "19.03.12"
+
+var dockerVersionRegex = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(-ce)?(.*)$`)
+
 var (
 	errGitHubAPIResponse = errors.New(`Error getting a version tag from the Github API response.
 You may be getting rate limited by Github.`)
@@ -462,6 +470,59 @@ func (b *B2dUtils) CopyIsoToMachineDir(isoURL, machineName string) error {
 	return b.DownloadISO(machineDir, b.filename(), downloadURL)
 }
 
+// CopyIsoVersionToMachineDir downloads the Boot2Docker release shipping the
+// given version of Docker to the machine's directory. Such ISOs are not
+// cached.
+func (b *B2dUtils) CopyIsoVersionToMachineDir(dockerVersion, machineName string) error {
+	machineDir := filepath.Join(b.storePath, "machines", machineName)
+
+	releaseURL, err := Boot2DockerReleaseURL(dockerVersion)
+	if err != nil {
+		return err
+	}
+
+	return b.DownloadISO(machineDir, b.filename(), releaseURL)
+}
+
+// Boot2DockerReleaseURL returns the URL of the Boot2Docker ISO shipping the
+// given version of Docker. Boot2Docker releases are tagged after the version
+// of Docker they ship, with the -ce suffix from 17.03 to 18.06 only, e.g.
+// v1.13.1, v17.03.1-ce or v19.03.12. The version is given with or without
+// the suffix.
+func Boot2DockerReleaseURL(dockerVersion string) (string, error) {
+	tag := "v" + strings.TrimPrefix(dockerVersion, "v")
+
+	if matches := dockerVersionRegex.FindStringSubmatch(dockerVersion); matches != nil {
+		release := fmt.Sprintf("%s.%s.%s", matches[1], matches[2], matches[3])
+		if compareVersions(release, lastBoot2DockerRelease) > 0 {
+			return "", fmt.Errorf("Boot2Docker has no release shipping Docker %s, the last one ships %s", dockerVersion, lastBoot2DockerRelease)
+		}
+
+		suffix := ""
+		if compareVersions(release, "17.03.0") >= 0 && compareVersions(release, "18.09.0") < 0 {
+			suffix = "-ce"
+		}
+		tag = "v" + release + suffix + matches[5]
+	}
+
+	return fmt.Sprintf("%s/%s/%s", releaseDownloadURL, tag, defaultISOFilename), nil
+}
+
+// compareVersions compares two major.minor.patch versions, it returns a
+// negative number, zero or a positive number whether a is older, the same
+// or newer than b.
+func compareVersions(a, b string) int {
+	partsA, partsB := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range partsA {
+		numberA, _ := strconv.Atoi(partsA[i])
+		numberB, _ := strconv.Atoi(partsB[i])
+		if numberA != numberB {
+			return numberA - numberB
+		}
+	}
+	return 0
+}
+
 // isLatest checks the latest release tag and
 // reports whether the local ISO cache is the latest version.
 //
@@ -96,6 +96,31 @@ func TestVersion(t *testing.T) {
 	}
 }
 
+func TestBoot2DockerReleaseURL(t *testing.T) {
+	for version, tag := range map[string]string{
+		"17.03.1-ce":     "v17.03.1-ce",
+		"v17.03.1-ce":    "v17.03.1-ce",
+		"17.03.1":        "v17.03.1-ce",
+		"17.06.0-ce-rc1": "v17.06.0-ce-rc1",
+		"1.13.1":         "v1.13.1",
+		"18.06.1":        "v18.06.1-ce",
+		"18.09.1":        "v18.09.1",
+		"19.03.5-ce":     "v19.03.5",
+		"19.03.12":       "v19.03.12",
+	} {
+		url, err := Boot2DockerReleaseURL(version)
+
+		assert.NoError(t, err, version)
+		assert.Equal(t, fmt.Sprintf("https://github.com/boot2docker/boot2docker/releases/download/%s/boot2docker.iso", tag), url, version)
+	}
+
+	for _, version := range []string{"19.03.13", "20.10.7"} {
+		_, err := Boot2DockerReleaseURL(version)
+
+		assert.EqualError(t, err, fmt.Sprintf("Boot2Docker has no release shipping Docker %s, the last one ships 19.03.12", version))
+	}
+}
+
 func TestDownloadISO(t *testing.T) {
 	testData := "test-download"
 	ts := newTestServer(testData)
@@ -102,6 +102,31 @@ func WaitFor(f func() bool) error {
 	return WaitForSpecific(f, 60, 3*time.Second)
 }
 
+// WaitForBackoff calls f until it succeeds or timeout elapses. The delay
+// between two calls starts at initialInterval and doubles up to maxInterval.
+// The last error returned by f is reported when it gives up.
+func WaitForBackoff(f func() error, initialInterval, maxInterval, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	interval := initialInterval
+
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil {
+			return nil
+		}
+
+		if !time.Now().Add(interval).Before(deadline) {
+			return fmt.Errorf("Giving up after %d attempts in %s: %s", attempt, timeout, err)
+		}
+
+		time.Sleep(interval)
+
+		if interval *= 2; interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
 // TruncateID returns a shorten id
 // Following two functions are from github.com/docker/docker/utils module. It
 // was way overkill to include the whole module, so we just have these bits
@@ -1,11 +1,15 @@
 package mcnutils
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCopyFile(t *testing.T) {
@@ -99,3 +103,36 @@ func TestShortenIdInvalid(t *testing.T) {
 		t.Fatalf("Id returned is incorrect: truncate on %s returned %s", id, truncID)
 	}
 }
+
+func TestWaitForBackoff(t *testing.T) {
+	attempts := 0
+	err := WaitForBackoff(func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}, time.Millisecond, 2*time.Millisecond, time.Second)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts; received %d", attempts)
+	}
+}
+
+func TestWaitForBackoffReportsLastError(t *testing.T) {
+	attempts := 0
+	err := WaitForBackoff(func() error {
+		attempts++
+		return fmt.Errorf("attempt %d failed", attempts)
+	}, time.Millisecond, 4*time.Millisecond, 20*time.Millisecond)
+
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if lastErr := fmt.Sprintf("attempt %d failed", attempts); !strings.HasSuffix(err.Error(), lastErr) {
+		t.Fatalf("expected the error to end with %q; received %q", lastErr, err)
+	}
+}
@@ -0,0 +1,27 @@
+package provision
+
+import (
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+func init() {
+	Register("AlmaLinux", &RegisteredProvisioner{
+		New: NewAlmaLinuxProvisioner,
+	})
+}
+
+func NewAlmaLinuxProvisioner(d drivers.Driver) Provisioner {
+	p := NewRedHatProvisioner("almalinux", d)
+	p.defaultStorageDriver = "overlay2"
+	p.engineInstallCommand = centosRepoInstallCommand
+
+	return &AlmaLinuxProvisioner{p}
+}
+
+type AlmaLinuxProvisioner struct {
+	*RedHatProvisioner
+}
+
+func (provisioner *AlmaLinuxProvisioner) String() string {
+	return "almalinux"
+}
@@ -0,0 +1,163 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+func init() {
+	Register("Alpine", &RegisteredProvisioner{
+		New: NewAlpineProvisioner,
+	})
+}
+
+func NewAlpineProvisioner(d drivers.Driver) Provisioner {
+	return &AlpineProvisioner{
+		NewOpenRCProvisioner("alpine", d),
+	}
+}
+
+type AlpineProvisioner struct {
+	OpenRCProvisioner
+}
+
+func (provisioner *AlpineProvisioner) String() string {
+	return "alpine"
+}
+
+func (provisioner *AlpineProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	if provisioner.EngineOptions.InstallBundle != "" {
+		return bundlePackage(provisioner, provisioner.EngineOptions.InstallBundle, name, action)
+	}
+
+	var command string
+
+	switch action {
+	case pkgaction.Install:
+		command = fmt.Sprintf("sudo apk add --no-cache %s", name)
+	case pkgaction.Remove:
+		command = fmt.Sprintf("sudo apk del %s", name)
+	case pkgaction.Upgrade:
+		command = fmt.Sprintf("sudo apk add --no-cache --upgrade %s", name)
+	}
+
+	log.Debugf("package: action=%s name=%s", action.String(), name)
+
+	if _, err := provisioner.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (provisioner *AlpineProvisioner) InstallEngineVersion(version string) error {
+	return apkInstallEngineVersion(provisioner, version)
+}
+
+func (provisioner *AlpineProvisioner) bundlePackageFormat() bundlePackageFormat {
+	return apkBundlePackages
+}
+
+func (provisioner *AlpineProvisioner) dockerDaemonResponding() bool {
+	log.Debug("checking docker daemon")
+
+	if out, err := provisioner.SSHCommand("sudo docker version"); err != nil {
+		log.Warnf("Error getting SSH command to check if the daemon is up: %s", err)
+		log.Debugf("'sudo docker version' output:\n%s", out)
+		return false
+	}
+
+	// The daemon is up if the command worked.  Carry on.
+	return true
+}
+
+// installDocker installs the engine from the Alpine repositories, which the
+// install script doesn't support, and starts it with OpenRC.
+func (provisioner *AlpineProvisioner) installDocker(engineOptions engine.Options) error {
+	switch {
+	case engineOptions.InstallBundle != "":
+		if err := InstallEngineBundle(provisioner, engineOptions.InstallBundle); err != nil {
+			return err
+		}
+	case engineOptions.Version != "":
+		if err := InstallEngineVersion(provisioner, engineOptions.Version); err != nil {
+			return err
+		}
+	default:
+		if err := provisioner.Package("docker", pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	if err := provisioner.Service("docker", serviceaction.Enable); err != nil {
+		return err
+	}
+
+	return provisioner.Service("docker", serviceaction.Restart)
+}
+
+// setOptions sets the options of the provisioning, and the storage driver
+// of the engine when none was given.
+func (provisioner *AlpineProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+
+	storageDriver, err := decideStorageDriver(provisioner, "overlay2", engineOptions.StorageDriver)
+	if err != nil {
+		return err
+	}
+	provisioner.EngineOptions.StorageDriver = storageDriver
+
+	return nil
+}
+
+func (provisioner *AlpineProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	steps, err := provisioner.Steps(swarmOptions, authOptions, engineOptions)
+	if err != nil {
+		return err
+	}
+
+	return ApplySteps(steps)
+}
+
+// Steps returns the steps of the provisioning.
+func (provisioner *AlpineProvisioner) Steps(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) ([]Step, error) {
+	if err := provisioner.setOptions(swarmOptions, authOptions, engineOptions); err != nil {
+		return nil, err
+	}
+	swarmOptions.Env = engineOptions.Env
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	return []Step{
+		{
+			// HACK: the minimal Alpine images don't come with sudo
+			Name: StepSudo,
+			Apply: func() error {
+				_, err := provisioner.SSHCommand("if ! type sudo; then apk add --no-cache sudo; fi")
+				return err
+			},
+		},
+		hostnameStep(provisioner),
+		packagesStep(provisioner, provisioner.Packages),
+		engineStep(provisioner, engineOptions, func() error {
+			log.Info("Installing Docker...")
+			if err := provisioner.installDocker(engineOptions); err != nil {
+				return err
+			}
+
+			log.Debug("waiting for docker daemon")
+			return mcnutils.WaitFor(provisioner.dockerDaemonResponding)
+		}),
+		authStep(provisioner),
+		swarmStep(provisioner, swarmOptions, provisioner.AuthOptions),
+	}, nil
+}
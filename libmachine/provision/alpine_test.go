@@ -0,0 +1,52 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlpineDefaultStorageDriver(t *testing.T) {
+	p := NewAlpineProvisioner(&fakedriver.Driver{}).(*AlpineProvisioner)
+	p.SSHCommander = provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{})
+	p.Provision(swarm.Options{}, auth.Options{}, engine.Options{})
+	if p.EngineOptions.StorageDriver != "overlay2" {
+		t.Fatal("Default storage driver should be overlay2")
+	}
+}
+
+func TestAlpineInstallDocker(t *testing.T) {
+	p := NewAlpineProvisioner(&fakedriver.Driver{}).(*AlpineProvisioner)
+	commander := &offlineSSHCommander{}
+	p.SSHCommander = commander
+
+	err := p.installDocker(engine.Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"sudo apk add --no-cache docker",
+		"sudo rc-update add docker default",
+		"sudo rc-service docker restart",
+	}, commander.Commands)
+}
+
+func TestOpenRCService(t *testing.T) {
+	p := NewAlpineProvisioner(&fakedriver.Driver{}).(*AlpineProvisioner)
+	commander := &offlineSSHCommander{}
+	p.SSHCommander = commander
+
+	assert.NoError(t, p.Service("docker", serviceaction.Stop))
+	assert.NoError(t, p.Service("docker", serviceaction.Disable))
+	assert.NoError(t, p.Service("docker", serviceaction.DaemonReload))
+
+	assert.Equal(t, []string{
+		"sudo rc-service docker stop",
+		"sudo rc-update del docker default",
+	}, commander.Commands)
+}
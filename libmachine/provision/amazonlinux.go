@@ -0,0 +1,29 @@
+package provision
+
+import (
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+func init() {
+	Register("AmazonLinux", &RegisteredProvisioner{
+		New: NewAmazonLinuxProvisioner,
+	})
+}
+
+func NewAmazonLinuxProvisioner(d drivers.Driver) Provisioner {
+	p := NewRedHatProvisioner("amzn", d)
+	p.defaultStorageDriver = "overlay2"
+	// The engine is packaged by Amazon, as docker.
+	p.engineInstallCommand = "sudo amazon-linux-extras install -y docker || sudo yum install -y docker"
+	p.enginePackages = "docker"
+
+	return &AmazonLinuxProvisioner{p}
+}
+
+type AmazonLinuxProvisioner struct {
+	*RedHatProvisioner
+}
+
+func (provisioner *AmazonLinuxProvisioner) String() string {
+	return "amzn"
+}
@@ -0,0 +1,58 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAmazonLinuxDefaultStorageDriver(t *testing.T) {
+	p := NewAmazonLinuxProvisioner(&fakedriver.Driver{}).(*AmazonLinuxProvisioner)
+	p.SSHCommander = provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{})
+	p.Provision(swarm.Options{}, auth.Options{}, engine.Options{})
+	if p.EngineOptions.StorageDriver != "overlay2" {
+		t.Fatal("Default storage driver should be overlay2")
+	}
+}
+
+func TestAmazonLinuxInstallDocker(t *testing.T) {
+	p := NewAmazonLinuxProvisioner(&fakedriver.Driver{}).(*AmazonLinuxProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"if ! type docker; then sudo amazon-linux-extras install -y docker || sudo yum install -y docker; fi":                                                              "",
+			"sudo $(command -v dnf || command -v yum) list -q --showduplicates docker":                                                                                         "docker.x86_64    20.10.7-3.amzn2    amzn2extra-docker\n",
+			"sudo -E $(command -v dnf || command -v yum) install -y docker-20.10.7-3.amzn2 || sudo -E $(command -v dnf || command -v yum) downgrade -y docker-20.10.7-3.amzn2": "",
+			"sudo systemctl daemon-reload":     "",
+			"sudo systemctl -f restart docker": "",
+			"sudo systemctl -f enable docker":  "",
+		},
+	}
+	p.EngineOptions = engine.Options{Version: "20.10.7"}
+
+	err := installDocker(p.RedHatProvisioner)
+
+	assert.NoError(t, err)
+}
+
+func TestAmazonLinuxGenerateDockerOptions(t *testing.T) {
+	p := NewAmazonLinuxProvisioner(&fakedriver.Driver{}).(*AmazonLinuxProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"docker --version": "Docker version 20.10.7, build f0df350\n",
+		},
+	}
+	p.EngineOptions = engine.Options{StorageDriver: "overlay2"}
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, dockerCfg.DaemonConfig)
+	assert.Equal(t, "overlay2", dockerCfg.DaemonConfig.StorageDriver)
+	assert.Contains(t, dockerCfg.EngineOptions, "ExecStart=/usr/bin/dockerd\n")
+	assert.NotContains(t, dockerCfg.EngineOptions, "docker daemon")
+}
@@ -38,6 +38,10 @@ func (provisioner *ArchProvisioner) CompatibleWithHost() bool {
 }
 
 func (provisioner *ArchProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	if provisioner.EngineOptions.InstallBundle != "" {
+		return bundlePackage(provisioner, provisioner.EngineOptions.InstallBundle, name, action)
+	}
+
 	var packageAction string
 
 	updateMetadata := true
@@ -75,6 +79,14 @@ func (provisioner *ArchProvisioner) Package(name string, action pkgaction.Packag
 	return nil
 }
 
+func (provisioner *ArchProvisioner) InstallEngineVersion(version string) error {
+	return pacmanInstallEngineVersion(provisioner, version)
+}
+
+func (provisioner *ArchProvisioner) bundlePackageFormat() bundlePackageFormat {
+	return pacmanBundlePackages
+}
+
 func (provisioner *ArchProvisioner) dockerDaemonResponding() bool {
 	log.Debug("checking docker daemon")
 
@@ -88,11 +100,12 @@ func (provisioner *ArchProvisioner) dockerDaemonResponding() bool {
 	return true
 }
 
-func (provisioner *ArchProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+// setOptions sets the options of the provisioning, and the storage driver
+// of the engine when none was given.
+func (provisioner *ArchProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
 
 	storageDriver, err := decideStorageDriver(provisioner, "overlay", engineOptions.StorageDriver)
 	if err != nil {
@@ -100,56 +113,53 @@ func (provisioner *ArchProvisioner) Provision(swarmOptions swarm.Options, authOp
 	}
 	provisioner.EngineOptions.StorageDriver = storageDriver
 
-	// HACK: since Arch does not come with sudo by default we install
-	log.Debug("Installing sudo")
-	if _, err := provisioner.SSHCommand("if ! type sudo; then pacman -Sy --noconfirm --noprogressbar sudo; fi"); err != nil {
-		return err
-	}
-
-	log.Debug("Setting hostname")
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
-		return err
-	}
-
-	log.Debug("Installing base packages")
-	for _, pkg := range provisioner.Packages {
-		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
-			return err
-		}
-	}
+	return nil
+}
 
-	log.Debug("Installing docker")
-	if err := provisioner.Package("docker", pkgaction.Install); err != nil {
+func (provisioner *ArchProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	steps, err := provisioner.Steps(swarmOptions, authOptions, engineOptions)
+	if err != nil {
 		return err
 	}
 
-	log.Debug("Starting systemd docker service")
-	if err := provisioner.Service("docker", serviceaction.Start); err != nil {
-		return err
-	}
+	return ApplySteps(steps)
+}
 
-	log.Debug("Waiting for docker daemon")
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
-		return err
+// Steps returns the steps of the provisioning.
+func (provisioner *ArchProvisioner) Steps(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) ([]Step, error) {
+	if err := provisioner.setOptions(swarmOptions, authOptions, engineOptions); err != nil {
+		return nil, err
 	}
-
+	swarmOptions.Env = engineOptions.Env
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	log.Debug("Configuring auth")
-	if err := ConfigureAuth(provisioner); err != nil {
-		return err
-	}
-
-	log.Debug("Configuring swarm")
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
-		return err
-	}
-
-	// enable in systemd
-	log.Debug("Enabling docker in systemd")
-	if err := provisioner.Service("docker", serviceaction.Enable); err != nil {
-		return err
-	}
-
-	return nil
+	return []Step{
+		{
+			// HACK: since Arch does not come with sudo by default we install
+			Name: StepSudo,
+			Apply: func() error {
+				_, err := provisioner.SSHCommand("if ! type sudo; then pacman -Sy --noconfirm --noprogressbar sudo; fi")
+				return err
+			},
+		},
+		hostnameStep(provisioner),
+		packagesStep(provisioner, provisioner.Packages),
+		engineStep(provisioner, engineOptions, func() error {
+			log.Debug("Installing docker")
+			if err := provisioner.Package("docker", pkgaction.Install); err != nil {
+				return err
+			}
+
+			log.Debug("Starting systemd docker service")
+			if err := provisioner.Service("docker", serviceaction.Start); err != nil {
+				return err
+			}
+
+			log.Debug("Waiting for docker daemon")
+			return mcnutils.WaitFor(provisioner.dockerDaemonResponding)
+		}),
+		authStep(provisioner),
+		swarmStep(provisioner, swarmOptions, provisioner.AuthOptions),
+		enableServiceStep(provisioner),
+	}, nil
 }
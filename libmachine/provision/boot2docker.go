@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"os"
 	"path"
 	"text/template"
 	"time"
@@ -50,7 +51,9 @@ func (provisioner *Boot2DockerProvisioner) Service(name string, action serviceac
 	return err
 }
 
-func (provisioner *Boot2DockerProvisioner) upgradeIso() error {
+// upgradeIso replaces the ISO of the machine by the one shipping the given
+// version of Docker, or by the latest one when dockerVersion is empty.
+func (provisioner *Boot2DockerProvisioner) upgradeIso(dockerVersion string) error {
 	// TODO: Ideally, we should not read from mcndirs directory at all.
 	// The driver should be able to communicate how and where to place the
 	// relevant files.
@@ -80,10 +83,16 @@ func (provisioner *Boot2DockerProvisioner) upgradeIso() error {
 
 	log.Infof("Upgrading machine %q...", machineName)
 
-	// Either download the latest version of the b2d url that was explicitly
-	// specified when creating the VM or copy the (updated) default ISO
-	if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, machineName); err != nil {
-		return err
+	if dockerVersion != "" {
+		if err := b2dutils.CopyIsoVersionToMachineDir(dockerVersion, machineName); err != nil {
+			return err
+		}
+	} else {
+		// Either download the latest version of the b2d url that was explicitly
+		// specified when creating the VM or copy the (updated) default ISO
+		if err := b2dutils.CopyIsoToMachineDir(d.Boot2DockerURL, machineName); err != nil {
+			return err
+		}
 	}
 
 	log.Infof("Starting machine back up...")
@@ -97,13 +106,17 @@ func (provisioner *Boot2DockerProvisioner) upgradeIso() error {
 
 func (provisioner *Boot2DockerProvisioner) Package(name string, action pkgaction.PackageAction) error {
 	if name == "docker" && action == pkgaction.Upgrade {
-		if err := provisioner.upgradeIso(); err != nil {
+		if err := provisioner.upgradeIso(""); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+func (provisioner *Boot2DockerProvisioner) InstallEngineVersion(version string) error {
+	return provisioner.upgradeIso(version)
+}
+
 func (provisioner *Boot2DockerProvisioner) Hostname() (string, error) {
 	return provisioner.SSHCommand("hostname")
 }
@@ -137,14 +150,14 @@ func (provisioner *Boot2DockerProvisioner) GenerateDockerOptions(dockerPort int)
 		engineCfg bytes.Buffer
 	)
 
-	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
-	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	provisioner.EngineOptions.Labels = withDriverLabel(provisioner.EngineOptions.Labels, provisioner.Driver.DriverName())
 
 	engineConfigTmpl := `
 EXTRA_ARGS='
 {{ range .EngineOptions.Labels }}--label {{.}}
 {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}}
 {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}}
+{{ end }}{{ range .TypedFlags }}--{{.}}
 {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}}
 {{ end }}
 '
@@ -155,7 +168,7 @@ DOCKER_TLS=auto
 SERVERKEY={{.AuthOptions.ServerKeyRemotePath}}
 SERVERCERT={{.AuthOptions.ServerCertRemotePath}}
 
-{{range .EngineOptions.Env}}export \"{{ printf "%q" . }}\"
+{{range .EngineOptions.Env}}export {{ printf "%q" . }}
 {{end}}
 `
 	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
@@ -163,10 +176,17 @@ SERVERCERT={{.AuthOptions.ServerCertRemotePath}}
 		return nil, err
 	}
 
+	version := installedEngineVersion(provisioner)
+	typedFlags, err := engineTypedFlags(version, provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
 	engineConfigContext := EngineConfigContext{
 		DockerPort:    dockerPort,
 		AuthOptions:   provisioner.AuthOptions,
 		EngineOptions: provisioner.EngineOptions,
+		TypedFlags:    typedFlags,
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -215,57 +235,71 @@ You also might want to clear any VirtualBox host only interfaces you are not usi
 	}
 }
 
-func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
-	var (
-		err error
-	)
-
-	defer func() {
-		if err == nil {
-			provisioner.AttemptIPContact(engine.DefaultPort)
-		}
-	}()
-
+// setOptions sets the options of the provisioning, and the storage driver
+// of the engine when none was given.
+func (provisioner *Boot2DockerProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
 
 	if provisioner.EngineOptions.StorageDriver == "" {
 		provisioner.EngineOptions.StorageDriver = "aufs"
 	}
 
-	if err = provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
-		return err
-	}
-
-	// b2d hosts need to wait for the daemon to be up
-	// before continuing with provisioning
-	if err = WaitForDocker(provisioner, engine.DefaultPort); err != nil {
-		return err
-	}
+	return nil
+}
 
-	if err = makeDockerOptionsDir(provisioner); err != nil {
+func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	steps, err := provisioner.Steps(swarmOptions, authOptions, engineOptions)
+	if err != nil {
 		return err
 	}
 
-	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
-
-	if err = ConfigureAuth(provisioner); err != nil {
-		return err
-	}
+	return ApplySteps(steps)
+}
 
-	if err = configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
-		return err
+// Steps returns the steps of the provisioning.
+func (provisioner *Boot2DockerProvisioner) Steps(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) ([]Step, error) {
+	if err := provisioner.setOptions(swarmOptions, authOptions, engineOptions); err != nil {
+		return nil, err
 	}
+	swarmOptions.Env = engineOptions.Env
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	return nil
+	return []Step{
+		hostnameStep(provisioner),
+		{
+			// b2d hosts need to wait for the daemon to be up
+			// before continuing with provisioning
+			Name: StepEngine,
+			Apply: func() error {
+				return WaitForDocker(provisioner, engine.DefaultPort)
+			},
+		},
+		authStep(provisioner),
+		swarmStep(provisioner, swarmOptions, provisioner.AuthOptions),
+		{
+			Name: "ip-contact",
+			Apply: func() error {
+				provisioner.AttemptIPContact(engine.DefaultPort)
+				return nil
+			},
+		},
+	}, nil
 }
 
 func (provisioner *Boot2DockerProvisioner) SSHCommand(args string) (string, error) {
 	return drivers.RunSSHCommandFromDriver(provisioner.Driver, args)
 }
 
+func (provisioner *Boot2DockerProvisioner) SSHWriteFile(path string, content []byte, mode os.FileMode) error {
+	return drivers.WriteSSHFileFromDriver(provisioner.Driver, path, content, mode)
+}
+
+func (provisioner *Boot2DockerProvisioner) SSHUploadFile(src, dst string) error {
+	return drivers.UploadSSHFileFromDriver(provisioner.Driver, src, dst)
+}
+
 func (provisioner *Boot2DockerProvisioner) GetDriver() drivers.Driver {
 	return provisioner.Driver
 }
@@ -0,0 +1,212 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+// bundleRemoteDir is where the install bundles are extracted on the
+// machines.
+const bundleRemoteDir = "/var/lib/docker-machine/bundle"
+
+// bundleUploadPath is where the bundles are uploaded, in the home directory
+// of the SSH user, before being extracted.
+const bundleUploadPath = ".docker-machine-bundle"
+
+// bundleSystemdUnit starts the engine installed from binaries until Machine
+// configures it.
+const bundleSystemdUnit = `[Unit]
+Description=Docker Application Container Engine
+After=network.target
+
+[Service]
+ExecStart=/usr/bin/dockerd
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// bundlePackageFormat tells how the packages of a distribution are found in
+// the packages directory of a bundle, and handled without network access.
+type bundlePackageFormat struct {
+	// pattern matches all the packages.
+	pattern string
+	// packagePattern matches the files of the package given as %s.
+	packagePattern string
+	install        string
+	remove         string
+	// query succeeds when the package given as argument is installed.
+	query string
+}
+
+var (
+	debBundlePackages = bundlePackageFormat{
+		pattern:        "*.deb",
+		packagePattern: "%s_*.deb",
+		install:        "DEBIAN_FRONTEND=noninteractive sudo -E dpkg -i",
+		remove:         "DEBIAN_FRONTEND=noninteractive sudo -E dpkg -r",
+		query:          "dpkg -s",
+	}
+	rpmBundlePackages = bundlePackageFormat{
+		pattern:        "*.rpm",
+		packagePattern: "%s-[0-9]*.rpm",
+		install:        "sudo rpm -Uvh --replacepkgs --oldpackage",
+		remove:         "sudo rpm -e",
+		query:          "rpm -q",
+	}
+	apkBundlePackages = bundlePackageFormat{
+		pattern:        "*.apk",
+		packagePattern: "%s-[0-9]*.apk",
+		install:        "sudo apk add --no-cache --allow-untrusted",
+		remove:         "sudo apk del",
+		query:          "apk info -e",
+	}
+	pacmanBundlePackages = bundlePackageFormat{
+		pattern:        "*.pkg.tar*",
+		packagePattern: "%s-[0-9]*.pkg.tar*",
+		install:        "sudo pacman -U --noconfirm --noprogressbar",
+		remove:         "sudo pacman -R --noconfirm",
+		query:          "pacman -Q",
+	}
+)
+
+// bundleInstaller is implemented by the provisioners able to install the
+// engine from a bundle, without network access.
+type bundleInstaller interface {
+	bundlePackageFormat() bundlePackageFormat
+}
+
+// IsRemoteBundle tells whether the bundle is served by an HTTP mirror the
+// machine downloads it from, rather than a local file uploaded over SSH.
+func IsRemoteBundle(bundle string) bool {
+	return strings.HasPrefix(bundle, "http://") || strings.HasPrefix(bundle, "https://")
+}
+
+// InstallEngineBundle installs the engine from the bundle, a tarball holding
+// either distribution packages in its packages directory or the static
+// binaries of the engine in its docker directory.
+func InstallEngineBundle(p Provisioner, bundle string) error {
+	installer, ok := p.(bundleInstaller)
+	if !ok {
+		return fmt.Errorf("The %s provisioner can't install the engine from a bundle", p.String())
+	}
+	format := installer.bundlePackageFormat()
+
+	if err := uploadBundle(p, bundle); err != nil {
+		return err
+	}
+
+	output, err := p.SSHCommand(fmt.Sprintf("ls %s/packages/%s %s/docker/dockerd 2>/dev/null || true", bundleRemoteDir, format.pattern, bundleRemoteDir))
+	if err != nil {
+		return err
+	}
+
+	hasPackages, hasBinaries := false, false
+	for _, file := range strings.Fields(output) {
+		if strings.HasPrefix(file, bundleRemoteDir+"/packages/") {
+			hasPackages = true
+		} else if file == bundleRemoteDir+"/docker/dockerd" {
+			hasBinaries = true
+		}
+	}
+
+	switch {
+	case hasPackages:
+		log.Info("Installing Docker from the packages of the bundle...")
+		if output, err := p.SSHCommand(fmt.Sprintf("%s %s/packages/%s", format.install, bundleRemoteDir, format.pattern)); err != nil {
+			return fmt.Errorf("Error installing the packages of the bundle: %s\n%s", err, output)
+		}
+		return nil
+	case hasBinaries:
+		log.Info("Installing Docker from the binaries of the bundle...")
+		return installBundleBinaries(p)
+	}
+
+	return fmt.Errorf("The bundle %s holds neither %s packages nor a docker/dockerd binary", bundle, format.pattern)
+}
+
+// installBundleBinaries copies the static binaries of the bundle to
+// /usr/bin and starts them with systemd.
+func installBundleBinaries(p SSHCommander) error {
+	if _, err := p.SSHCommand("command -v systemctl"); err != nil {
+		return fmt.Errorf("Installing Docker from binaries requires systemd: %s", err)
+	}
+
+	if output, err := p.SSHCommand(fmt.Sprintf("sudo install -m 0755 %s/docker/* /usr/bin/", bundleRemoteDir)); err != nil {
+		return fmt.Errorf("Error installing the binaries of the bundle: %s\n%s", err, output)
+	}
+
+	if err := writeRemoteFile(p, "/etc/systemd/system/docker.service", []byte(bundleSystemdUnit), 0644); err != nil {
+		return err
+	}
+
+	_, err := p.SSHCommand("sudo systemctl daemon-reload && sudo systemctl enable docker && sudo systemctl restart docker")
+	return err
+}
+
+// uploadBundle extracts the bundle to bundleRemoteDir, replacing a bundle
+// extracted before.
+func uploadBundle(p SSHCommander, bundle string) error {
+	if IsRemoteBundle(bundle) {
+		log.Infof("Downloading the bundle from %s...", bundle)
+		if output, err := p.SSHCommand(fmt.Sprintf("curl -fsSL -o %s %s", bundleUploadPath, bundle)); err != nil {
+			return fmt.Errorf("Error downloading the bundle from %s: %s\n%s", bundle, err, output)
+		}
+	} else {
+		log.Infof("Uploading the bundle %s...", bundle)
+		if err := p.SSHUploadFile(bundle, bundleUploadPath); err != nil {
+			return err
+		}
+	}
+
+	// tar detects the compression of the archive by itself.
+	command := fmt.Sprintf("sudo rm -rf %s && sudo mkdir -p %s && sudo tar -xf %s -C %s; status=$?; rm -f %s; exit $status",
+		bundleRemoteDir, bundleRemoteDir, bundleUploadPath, bundleRemoteDir, bundleUploadPath)
+	if output, err := p.SSHCommand(command); err != nil {
+		return fmt.Errorf("Error extracting the bundle: %s\n%s", err, output)
+	}
+
+	return nil
+}
+
+// bundlePackage is the Package implementation of the provisioners installing
+// the engine from a bundle. The other packages are installed from the bundle
+// only when they're missing.
+func bundlePackage(p Provisioner, bundle string, name string, action pkgaction.PackageAction) error {
+	installer, ok := p.(bundleInstaller)
+	if !ok {
+		return fmt.Errorf("The %s provisioner can't install packages from a bundle", p.String())
+	}
+	format := installer.bundlePackageFormat()
+
+	log.Debugf("package from bundle: action=%s name=%s", action.String(), name)
+
+	if action == pkgaction.Remove {
+		_, err := p.SSHCommand(fmt.Sprintf("%s %s", format.remove, name))
+		return err
+	}
+
+	if name == "docker" {
+		return InstallEngineBundle(p, bundle)
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("%s %s", format.query, name)); err == nil {
+		return nil
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("test -d %s", bundleRemoteDir)); err != nil {
+		if err := uploadBundle(p, bundle); err != nil {
+			return err
+		}
+	}
+
+	command := fmt.Sprintf("%s %s/packages/%s", format.install, bundleRemoteDir, fmt.Sprintf(format.packagePattern, name))
+	if output, err := p.SSHCommand(command); err != nil {
+		return fmt.Errorf("Package %s isn't installed and can't be installed from the bundle: %s\n%s", name, err, output)
+	}
+
+	return nil
+}
@@ -0,0 +1,249 @@
+package provision
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+// networkCommandRegex matches the commands which need network access.
+var networkCommandRegex = regexp.MustCompile(`\bcurl -|\bwget |https?://|apt-get |yum |dnf |zypper |pacman -S`)
+
+// offlineSSHCommander is a machine without network access: the commands
+// needing it fail, the others succeed with the registered output or none.
+type offlineSSHCommander struct {
+	provisiontest.FakeSSHCommander
+	Failures map[string]bool
+	Commands []string
+}
+
+func (sshCmder *offlineSSHCommander) SSHCommand(args string) (string, error) {
+	sshCmder.Commands = append(sshCmder.Commands, args)
+
+	if networkCommandRegex.MatchString(args) {
+		return "", errors.New("Network is unreachable")
+	}
+	if sshCmder.Failures[args] {
+		return "", errors.New("exit status 1")
+	}
+	return sshCmder.Responses[args], nil
+}
+
+func newTestBundle(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+
+	bundle := filepath.Join(dir, "bundle.tgz")
+	assert.NoError(t, ioutil.WriteFile(bundle, []byte("bundle"), 0600))
+
+	return bundle, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+// newTestAuthOptions generates a CA and a client cert in dir.
+func newTestAuthOptions(t *testing.T, dir string) auth.Options {
+	authOptions := auth.Options{
+		CertDir:              dir,
+		CaCertPath:           filepath.Join(dir, "ca.pem"),
+		CaPrivateKeyPath:     filepath.Join(dir, "ca-key.pem"),
+		ClientCertPath:       filepath.Join(dir, "cert.pem"),
+		ClientKeyPath:        filepath.Join(dir, "key.pem"),
+		ServerCertPath:       filepath.Join(dir, "server.pem"),
+		ServerKeyPath:        filepath.Join(dir, "server-key.pem"),
+		StorePath:            filepath.Join(dir, "machine"),
+		CaCertRemotePath:     "/etc/docker/ca.pem",
+		ServerCertRemotePath: "/etc/docker/server.pem",
+		ServerKeyRemotePath:  "/etc/docker/server-key.pem",
+	}
+	assert.NoError(t, os.Mkdir(authOptions.StorePath, 0700))
+	assert.NoError(t, cert.GenerateCACertificate(authOptions.CaCertPath, authOptions.CaPrivateKeyPath, "test", 1024))
+	assert.NoError(t, cert.GenerateCert(&cert.Options{
+		Hosts:     []string{""},
+		CertFile:  authOptions.ClientCertPath,
+		KeyFile:   authOptions.ClientKeyPath,
+		CAFile:    authOptions.CaCertPath,
+		CAKeyFile: authOptions.CaPrivateKeyPath,
+		Org:       "test",
+		Bits:      1024,
+	}))
+
+	return authOptions
+}
+
+func TestProvisionWithoutNetwork(t *testing.T) {
+	bundle, cleanup := newTestBundle(t)
+	defer cleanup()
+
+	authOptions := newTestAuthOptions(t, filepath.Dir(bundle))
+
+	for _, test := range []struct {
+		newProvisioner func(d drivers.Driver, commander SSHCommander) Provisioner
+		listPackages   string
+		packages       string
+		install        string
+		daemonJSON     bool
+	}{
+		{
+			newProvisioner: func(d drivers.Driver, commander SSHCommander) Provisioner {
+				p := NewUbuntuSystemdProvisioner(d).(*UbuntuSystemdProvisioner)
+				p.SSHCommander = commander
+				return p
+			},
+			listPackages: "ls /var/lib/docker-machine/bundle/packages/*.deb /var/lib/docker-machine/bundle/docker/dockerd 2>/dev/null || true",
+			packages:     "/var/lib/docker-machine/bundle/packages/docker-ce_17.03.1~ce-0~ubuntu-xenial_amd64.deb\n",
+			install:      "DEBIAN_FRONTEND=noninteractive sudo -E dpkg -i /var/lib/docker-machine/bundle/packages/*.deb",
+			daemonJSON:   true,
+		},
+		{
+			newProvisioner: func(d drivers.Driver, commander SSHCommander) Provisioner {
+				p := NewCentosProvisioner(d).(*CentosProvisioner)
+				p.SSHCommander = commander
+				return p
+			},
+			listPackages: "ls /var/lib/docker-machine/bundle/packages/*.rpm /var/lib/docker-machine/bundle/docker/dockerd 2>/dev/null || true",
+			packages:     "/var/lib/docker-machine/bundle/packages/docker-ce-17.03.1.ce-1.el7.centos.x86_64.rpm\n",
+			install:      "sudo rpm -Uvh --replacepkgs --oldpackage /var/lib/docker-machine/bundle/packages/*.rpm",
+		},
+		{
+			newProvisioner: func(d drivers.Driver, commander SSHCommander) Provisioner {
+				p := NewOpenSUSEProvisioner(d).(*SUSEProvisioner)
+				p.SSHCommander = commander
+				return p
+			},
+			listPackages: "ls /var/lib/docker-machine/bundle/packages/*.rpm /var/lib/docker-machine/bundle/docker/dockerd 2>/dev/null || true",
+			packages:     "/var/lib/docker-machine/bundle/packages/docker-17.03.1_ce-1.1.x86_64.rpm\n",
+			install:      "sudo rpm -Uvh --replacepkgs --oldpackage /var/lib/docker-machine/bundle/packages/*.rpm",
+		},
+	} {
+		commander := &offlineSSHCommander{
+			FakeSSHCommander: provisiontest.FakeSSHCommander{
+				Responses: map[string]string{
+					"stat -f -c %T /var/lib": "ext4\n",
+					test.listPackages:        test.packages,
+					"docker --version":       "Docker version 17.03.1-ce, build c6d412e\n",
+				},
+			},
+			Failures: map[string]bool{
+				"test -d /var/lib/docker-machine/bundle": true,
+			},
+		}
+		p := test.newProvisioner(&fakedriver.Driver{
+			MockName:  "offline",
+			MockIP:    "127.0.0.1",
+			MockState: state.Running,
+		}, commander)
+
+		err := p.Provision(swarm.Options{}, authOptions, engine.Options{
+			InstallURL:    "https://get.docker.com",
+			InstallBundle: bundle,
+		})
+
+		assert.NoError(t, err, p.String())
+		for _, command := range commander.Commands {
+			assert.False(t, networkCommandRegex.MatchString(command), "%s: %q needs the network", p.String(), command)
+		}
+		assert.Contains(t, commander.Commands, test.install, p.String())
+		assert.Equal(t, "bundle", commander.Files[bundleUploadPath], p.String())
+		if test.daemonJSON {
+			assert.Contains(t, commander.Files, ".docker-machine-daemon.json", p.String())
+		}
+	}
+}
+
+func TestInstallEngineBundleBinaries(t *testing.T) {
+	bundle, cleanup := newTestBundle(t)
+	defer cleanup()
+
+	p := NewDebianProvisioner(&fakedriver.Driver{}).(*DebianProvisioner)
+	commander := &offlineSSHCommander{
+		FakeSSHCommander: provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				"ls /var/lib/docker-machine/bundle/packages/*.deb /var/lib/docker-machine/bundle/docker/dockerd 2>/dev/null || true": "/var/lib/docker-machine/bundle/docker/dockerd\n",
+			},
+		},
+	}
+	p.SSHCommander = commander
+
+	err := InstallEngineBundle(p, bundle)
+
+	assert.NoError(t, err)
+	assert.Contains(t, commander.Commands, "sudo install -m 0755 /var/lib/docker-machine/bundle/docker/* /usr/bin/")
+	assert.Equal(t, bundleSystemdUnit, commander.Files[".docker-machine-docker.service"])
+}
+
+func TestInstallEngineBundleFromMirror(t *testing.T) {
+	p := NewDebianProvisioner(&fakedriver.Driver{}).(*DebianProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"curl -fsSL -o .docker-machine-bundle http://mirror.lab/docker.tgz": "",
+			"sudo rm -rf /var/lib/docker-machine/bundle && sudo mkdir -p /var/lib/docker-machine/bundle && sudo tar -xf .docker-machine-bundle -C /var/lib/docker-machine/bundle; status=$?; rm -f .docker-machine-bundle; exit $status": "",
+			"ls /var/lib/docker-machine/bundle/packages/*.deb /var/lib/docker-machine/bundle/docker/dockerd 2>/dev/null || true":                                                                                                         "/var/lib/docker-machine/bundle/packages/docker-engine_1.13.1-0~debian-jessie_amd64.deb\n",
+			"DEBIAN_FRONTEND=noninteractive sudo -E dpkg -i /var/lib/docker-machine/bundle/packages/*.deb":                                                                                                                               "",
+		},
+	}
+
+	err := InstallEngineBundle(p, "http://mirror.lab/docker.tgz")
+
+	assert.NoError(t, err)
+}
+
+func TestInstallEngineBundleEmpty(t *testing.T) {
+	bundle, cleanup := newTestBundle(t)
+	defer cleanup()
+
+	p := NewDebianProvisioner(&fakedriver.Driver{}).(*DebianProvisioner)
+	p.SSHCommander = &offlineSSHCommander{}
+
+	err := InstallEngineBundle(p, bundle)
+
+	assert.EqualError(t, err, "The bundle "+bundle+" holds neither *.deb packages nor a docker/dockerd binary")
+}
+
+func TestInstallEngineBundleUnsupported(t *testing.T) {
+	p := NewCoreOSProvisioner(&fakedriver.Driver{})
+
+	err := InstallEngineBundle(p, "bundle.tgz")
+
+	assert.EqualError(t, err, "The coreOS provisioner can't install the engine from a bundle")
+}
+
+func TestBundlePackageInstalled(t *testing.T) {
+	p := NewDebianProvisioner(&fakedriver.Driver{}).(*DebianProvisioner)
+	commander := &offlineSSHCommander{}
+	p.SSHCommander = commander
+
+	err := bundlePackage(p, "bundle.tgz", "curl", pkgaction.Install)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dpkg -s curl"}, commander.Commands)
+}
+
+func TestBundlePackageMissing(t *testing.T) {
+	p := NewDebianProvisioner(&fakedriver.Driver{}).(*DebianProvisioner)
+	commander := &offlineSSHCommander{
+		Failures: map[string]bool{
+			"dpkg -s aufs-tools": true,
+			"DEBIAN_FRONTEND=noninteractive sudo -E dpkg -i /var/lib/docker-machine/bundle/packages/aufs-tools_*.deb": true,
+		},
+	}
+	p.SSHCommander = commander
+
+	err := bundlePackage(p, "bundle.tgz", "aufs-tools", pkgaction.Install)
+
+	assert.EqualError(t, err, "Package aufs-tools isn't installed and can't be installed from the bundle: exit status 1\n")
+}
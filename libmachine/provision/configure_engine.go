@@ -0,0 +1,201 @@
+package provision
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+// daemonConfigReloadKeys are the daemon.json settings the engine applies
+// again when it's sent SIGHUP, since Docker 17.06.
+var daemonConfigReloadKeys = map[string]bool{
+	"debug":                    true,
+	"insecure-registries":      true,
+	"labels":                   true,
+	"live-restore":             true,
+	"max-concurrent-downloads": true,
+	"max-concurrent-uploads":   true,
+	"registry-mirrors":         true,
+	"shutdown-timeout":         true,
+}
+
+// EngineChange is what ReconfigureEngine did to the engine.
+type EngineChange struct {
+	// Files are the paths of the files which were written.
+	Files []string
+	// Reloaded is set when the engine applied the changes without
+	// restarting.
+	Reloaded bool
+}
+
+// Changed tells whether the configuration of the engine changed.
+func (c EngineChange) Changed() bool {
+	return len(c.Files) > 0
+}
+
+// ReconfigureEngine renders the options file of the engine, and its
+// daemon.json, for engineOptions and writes the ones which changed. The
+// engine is reloaded when it can apply the changes that way, and restarted
+// otherwise. Nothing else is provisioned again.
+func ReconfigureEngine(p Provisioner, authOptions auth.Options, engineOptions engine.Options) (EngineChange, error) {
+	change := EngineChange{}
+
+	authOptions = remoteAuthOptions(p.GetDockerOptionsDir(), authOptions)
+	if setter, ok := p.(optionsSetter); ok {
+		if err := setter.setOptions(swarm.Options{}, authOptions, engineOptions); err != nil {
+			return change, err
+		}
+		authOptions = p.GetAuthOptions()
+	}
+
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
+		return change, err
+	}
+
+	dkrcfg, err := p.GenerateDockerOptions(dockerPort)
+	if err != nil {
+		return change, err
+	}
+
+	currentOptions, err := readRemoteFile(p, dkrcfg.EngineOptionsPath)
+	if err != nil {
+		return change, err
+	}
+	options := shellDoubleQuoted(dkrcfg.EngineOptions)
+
+	reload := false
+	var daemonJSON []byte
+	if dkrcfg.DaemonConfig != nil {
+		currentJSON, content, err := renderDaemonJSON(p, dkrcfg.DaemonConfig)
+		if err != nil {
+			return change, err
+		}
+
+		changedKeys, err := daemonConfigChanges(currentJSON, content)
+		if err != nil {
+			return change, err
+		}
+		if len(changedKeys) > 0 {
+			daemonJSON = content
+			reload = engineReloadsDaemonJSON(p)
+			for _, key := range changedKeys {
+				reload = reload && daemonConfigReloadKeys[key]
+			}
+			log.Debugf("Changed daemon.json settings: %s", strings.Join(changedKeys, ", "))
+		}
+	}
+
+	if daemonJSON != nil {
+		if err := writeRemoteFile(p, daemonConfigPath(p), daemonJSON, 0644); err != nil {
+			return change, err
+		}
+		change.Files = append(change.Files, daemonConfigPath(p))
+	}
+
+	if options != currentOptions {
+		if err := writeRemoteFile(p, dkrcfg.EngineOptionsPath, []byte(options), 0644); err != nil {
+			return change, err
+		}
+		change.Files = append(change.Files, dkrcfg.EngineOptionsPath)
+		reload = false
+	}
+
+	if !change.Changed() {
+		return change, nil
+	}
+
+	if reload {
+		log.Info("Reloading docker...")
+		if _, err := p.SSHCommand(fmt.Sprintf("%skill -HUP $(pidof dockerd)", sudoPrefix(p))); err != nil {
+			return change, err
+		}
+		change.Reloaded = true
+	} else {
+		log.Info("Restarting docker...")
+		if err := p.Service("docker", serviceaction.Restart); err != nil {
+			return change, err
+		}
+	}
+
+	return change, WaitForDockerWithAuth(p, authOptions, dockerPort)
+}
+
+// daemonConfigChanges returns the settings which differ between two
+// daemon.json, sorted.
+func daemonConfigChanges(current string, content []byte) ([]string, error) {
+	before := map[string]interface{}{}
+	if strings.TrimSpace(current) != "" {
+		if err := json.Unmarshal([]byte(current), &before); err != nil {
+			return nil, fmt.Errorf("Error parsing the existing %s: %s", daemonConfigFile, err)
+		}
+	}
+
+	after := map[string]interface{}{}
+	if err := json.Unmarshal(content, &after); err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for key, value := range after {
+		if !reflect.DeepEqual(before[key], value) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// engineReloadsDaemonJSON tells whether the engine installed on the machine
+// applies the registry settings of daemon.json when it's reloaded. Rootless
+// engines are always restarted, their daemon runs in another namespace.
+func engineReloadsDaemonJSON(p Provisioner) bool {
+	if _, ok := p.(*RootlessProvisioner); ok {
+		return false
+	}
+
+	output, err := p.SSHCommand("docker --version")
+	if err != nil {
+		return false
+	}
+
+	major, minor, ok := parseEngineVersion(output)
+	return ok && (major > 17 || (major == 17 && minor >= 6))
+}
+
+// shellDoubleQuoted returns what the shell makes of content given in double
+// quotes. The templates of the options files of the engine are escaped for
+// it, this is the content of the file on the machine.
+func shellDoubleQuoted(content string) string {
+	buf := bytes.Buffer{}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\\' && i+1 < len(content) {
+			switch content[i+1] {
+			case '$', '`', '"', '\\':
+				buf.WriteByte(content[i+1])
+				i++
+				continue
+			case '\n':
+				i++
+				continue
+			}
+		}
+		buf.WriteByte(content[i])
+	}
+	return buf.String()
+}
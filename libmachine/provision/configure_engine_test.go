@@ -0,0 +1,127 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// newConfigureEngineTestProvisioner returns a provisioner whose engine runs
+// with the options of current.
+func newConfigureEngineTestProvisioner(t *testing.T, current engine.Options, responses map[string]string) (*UbuntuSystemdProvisioner, *provisiontest.FakeSSHCommander) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{MockState: state.Running}).(*UbuntuSystemdProvisioner)
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"docker --version": "Docker version 17.06.0-ce, build 02c1d87\n",
+			"sudo install -m 644 .docker-machine-daemon.json /etc/docker/daemon.json && rm -f .docker-machine-daemon.json":                  "",
+			"sudo install -m 644 .docker-machine-docker.service /etc/systemd/system/docker.service && rm -f .docker-machine-docker.service": "",
+			"sudo docker version": "",
+		},
+	}
+	p.SSHCommander = commander
+
+	// The engine was provisioned with current.
+	assert.NoError(t, p.setOptions(p.SwarmOptions, remoteAuthOptions(p.GetDockerOptionsDir(), auth.Options{}), current))
+	dkrcfg, err := p.GenerateDockerOptions(2376)
+	assert.NoError(t, err)
+	daemonJSON, err := dkrcfg.DaemonConfig.DaemonJSON(nil)
+	assert.NoError(t, err)
+
+	commander.Responses["sudo cat /etc/systemd/system/docker.service 2>/dev/null || true"] = shellDoubleQuoted(dkrcfg.EngineOptions)
+	commander.Responses["sudo cat /etc/docker/daemon.json 2>/dev/null || true"] = string(daemonJSON)
+	for command, response := range responses {
+		commander.Responses[command] = response
+	}
+
+	return p, commander
+}
+
+func TestReconfigureEngineReloads(t *testing.T) {
+	current := engine.Options{StorageDriver: "overlay2", Labels: []string{"env=dev"}}
+	p, commander := newConfigureEngineTestProvisioner(t, current, map[string]string{
+		"sudo kill -HUP $(pidof dockerd)": "",
+	})
+
+	current.Labels = []string{"env=prod"}
+	current.RegistryMirror = []string{"https://mirror.local"}
+	change, err := ReconfigureEngine(p, auth.Options{}, current)
+
+	assert.NoError(t, err)
+	assert.Equal(t, EngineChange{Files: []string{"/etc/docker/daemon.json"}, Reloaded: true}, change)
+	config := unmarshalDaemonJSON(t, []byte(commander.Files[".docker-machine-daemon.json"]))
+	assert.Equal(t, []interface{}{"env=prod", "provider=Driver"}, config["labels"])
+	assert.Equal(t, []interface{}{"https://mirror.local"}, config["registry-mirrors"])
+}
+
+func TestReconfigureEngineRestarts(t *testing.T) {
+	current := engine.Options{StorageDriver: "overlay2"}
+	p, _ := newConfigureEngineTestProvisioner(t, current, map[string]string{
+		"sudo systemctl daemon-reload":     "",
+		"sudo systemctl -f restart docker": "",
+	})
+
+	current.LogDriver = "journald"
+	current.Env = []string{"HTTP_PROXY=http://proxy.local:3128"}
+	change, err := ReconfigureEngine(p, auth.Options{}, current)
+
+	assert.NoError(t, err)
+	assert.Equal(t, EngineChange{Files: []string{"/etc/docker/daemon.json", "/etc/systemd/system/docker.service"}}, change)
+}
+
+func TestReconfigureEngineUnchanged(t *testing.T) {
+	current := engine.Options{StorageDriver: "overlay2", Labels: []string{"env=dev"}}
+	p, commander := newConfigureEngineTestProvisioner(t, current, nil)
+
+	change, err := ReconfigureEngine(p, auth.Options{}, current)
+
+	assert.NoError(t, err)
+	assert.False(t, change.Changed())
+	assert.Empty(t, commander.Files)
+}
+
+// The files are read in a tty on Red Hat, with CRLF line endings.
+func TestReconfigureEngineReloadsOnRedHat(t *testing.T) {
+	current := engine.Options{StorageDriver: "overlay2", Labels: []string{"env=dev"}}
+	p := NewCentosProvisioner(&fakedriver.Driver{MockState: state.Running}).(*CentosProvisioner)
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"docker --version": "Docker version 20.10.21, build baeda1f\n",
+			"sudo install -m 644 .docker-machine-daemon.json /etc/docker/daemon.json && rm -f .docker-machine-daemon.json": "",
+			"sudo kill -HUP $(pidof dockerd)": "",
+			"sudo docker version":             "",
+		},
+	}
+	p.SSHCommander = commander
+
+	assert.NoError(t, p.setOptions(p.SwarmOptions, remoteAuthOptions(p.GetDockerOptionsDir(), auth.Options{}), current))
+	dkrcfg, err := p.GenerateDockerOptions(2376)
+	assert.NoError(t, err)
+	daemonJSON, err := dkrcfg.DaemonConfig.DaemonJSON(nil)
+	assert.NoError(t, err)
+
+	commander.Responses["sudo cat "+dkrcfg.EngineOptionsPath+" 2>/dev/null || true"] = strings.Replace(shellDoubleQuoted(dkrcfg.EngineOptions), "\n", "\r\n", -1)
+	commander.Responses["sudo cat /etc/docker/daemon.json 2>/dev/null || true"] = strings.Replace(string(daemonJSON), "\n", "\r\n", -1)
+
+	current.Labels = []string{"env=prod"}
+	change, err := ReconfigureEngine(p, auth.Options{}, current)
+
+	assert.NoError(t, err)
+	assert.Equal(t, EngineChange{Files: []string{"/etc/docker/daemon.json"}, Reloaded: true}, change)
+}
+
+func TestDaemonConfigChanges(t *testing.T) {
+	keys, err := daemonConfigChanges(`{"debug": true, "labels": ["a=b"], "mtu": 1500}`, []byte(`{"labels": ["a=c"], "mtu": 1500, "registry-mirrors": []}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"debug", "labels", "registry-mirrors"}, keys)
+}
+
+func TestShellDoubleQuoted(t *testing.T) {
+	assert.Equal(t, `export "A=1" $DOCKER_OPTS \n`, shellDoubleQuoted(`export \"A=1\" \$DOCKER_OPTS \n`))
+}
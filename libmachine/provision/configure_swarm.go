@@ -2,6 +2,7 @@ package provision
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"strconv"
 	"strings"
@@ -14,6 +15,12 @@ import (
 	"github.com/samalba/dockerclient"
 )
 
+// swarmContainer is a container of the Swarm cluster run on the machine.
+type swarmContainer struct {
+	Name   string
+	Config *dockerclient.ContainerConfig
+}
+
 func configureSwarm(p Provisioner, swarmOptions swarm.Options, authOptions auth.Options) error {
 	if !swarmOptions.IsSwarm {
 		return nil
@@ -21,27 +28,140 @@ func configureSwarm(p Provisioner, swarmOptions swarm.Options, authOptions auth.
 
 	log.Info("Configuring swarm...")
 
-	ip, err := p.GetDriver().GetIP()
+	dockerHost, containers, err := swarmContainers(p, swarmOptions, authOptions)
 	if err != nil {
 		return err
 	}
 
+	for _, container := range containers {
+		if err := mcndockerclient.CreateContainer(dockerHost, container.Config, container.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReconfigureSwarm replaces the Swarm containers run on the machine for the
+// current options by the ones for swarmOptions, e.g. when the machine joins
+// or leaves a cluster or becomes its master.
+func ReconfigureSwarm(p Provisioner, current, swarmOptions swarm.Options, authOptions auth.Options) error {
+	if _, ok := p.(*RootlessProvisioner); ok && swarmOptions.IsSwarm {
+		return ErrRootlessSwarm
+	}
+
+	authOptions = remoteAuthOptions(p.GetDockerOptionsDir(), authOptions)
+
+	if current.IsSwarm {
+		dockerHost, containers, err := swarmContainers(p, current, authOptions)
+		if err != nil {
+			return err
+		}
+
+		for _, container := range containers {
+			if err := mcndockerclient.RemoveContainer(dockerHost, container.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return configureSwarm(p, swarmOptions, authOptions)
+}
+
+// SwarmRepair is a Swarm container recreated by RepairSwarm, and the
+// problem it had.
+type SwarmRepair struct {
+	Container string
+	Problem   string
+}
+
+// RepairSwarm inspects the Swarm containers of the machine through the API of
+// the engine and recreates the ones which are missing, stopped, or run with
+// other options than swarmOptions.
+func RepairSwarm(p Provisioner, swarmOptions swarm.Options, authOptions auth.Options) ([]SwarmRepair, error) {
+	repairs := []SwarmRepair{}
+	if !swarmOptions.IsSwarm {
+		return repairs, nil
+	}
+
+	authOptions = remoteAuthOptions(p.GetDockerOptionsDir(), authOptions)
+
+	dockerHost, containers, err := swarmContainers(p, swarmOptions, authOptions)
+	if err != nil {
+		return repairs, err
+	}
+
+	for _, container := range containers {
+		info, err := mcndockerclient.InspectContainer(dockerHost, container.Name)
+		if err != nil {
+			return repairs, err
+		}
+
+		problem := swarmContainerProblem(container, info)
+		if problem == "" {
+			continue
+		}
+
+		if err := mcndockerclient.RemoveContainer(dockerHost, container.Name); err != nil {
+			return repairs, err
+		}
+		if err := mcndockerclient.CreateContainer(dockerHost, container.Config, container.Name); err != nil {
+			return repairs, err
+		}
+
+		repairs = append(repairs, SwarmRepair{
+			Container: container.Name,
+			Problem:   problem,
+		})
+	}
+
+	return repairs, nil
+}
+
+// swarmContainerProblem tells what's wrong with the container whose details
+// are info, compared to the expected one. It returns an empty string when
+// the container is fine.
+func swarmContainerProblem(expected swarmContainer, info *dockerclient.ContainerInfo) string {
+	switch {
+	case info == nil || info.Config == nil:
+		return "missing"
+	case info.State == nil || !info.State.Running:
+		return "stopped"
+	case info.Config.Image != expected.Config.Image:
+		return fmt.Sprintf("running the %s image instead of %s", info.Config.Image, expected.Config.Image)
+	case strings.Join(info.Config.Cmd, " ") != strings.Join(expected.Config.Cmd, " "):
+		return "running with other arguments"
+	}
+
+	return ""
+}
+
+// swarmContainers returns the engine of the machine and the Swarm containers
+// to create there.
+func swarmContainers(p Provisioner, swarmOptions swarm.Options, authOptions auth.Options) (*mcndockerclient.RemoteDocker, []swarmContainer, error) {
+	containers := []swarmContainer{}
+
+	ip, err := p.GetDriver().GetIP()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	u, err := url.Parse(swarmOptions.Host)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	enginePort := engine.DefaultPort
 	engineURL, err := p.GetDriver().GetURL()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	parts := strings.Split(engineURL, ":")
 	if len(parts) == 3 {
 		dPort, err := strconv.Atoi(parts[2])
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 		enginePort = dPort
 	}
@@ -56,6 +176,40 @@ func configureSwarm(p Provisioner, swarmOptions swarm.Options, authOptions auth.
 	}
 	advertiseInfo := fmt.Sprintf("%s:%d", ip, enginePort)
 
+	// The discovery service comes first, the master and the agent register
+	// with it.
+	if swarmOptions.LocalDiscovery {
+		discoveryIP, err := discoveryHostIP(ip, swarmOptions.PublicDiscovery)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		discoveryPort := fmt.Sprintf("%d/tcp", swarm.DiscoveryPort)
+		discoveryConfig := &dockerclient.ContainerConfig{
+			Image: swarm.DiscoveryImage,
+			Cmd:   []string{"agent", "-dev", "-client", "0.0.0.0"},
+			ExposedPorts: map[string]struct{}{
+				discoveryPort: {},
+			},
+			HostConfig: dockerclient.HostConfig{
+				RestartPolicy: dockerclient.RestartPolicy{
+					Name:              "always",
+					MaximumRetryCount: 0,
+				},
+				PortBindings: map[string][]dockerclient.PortBinding{
+					discoveryPort: {
+						{
+							HostIp:   discoveryIP,
+							HostPort: strconv.Itoa(swarm.DiscoveryPort),
+						},
+					},
+				},
+			},
+		}
+
+		containers = append(containers, swarmContainer{swarm.DiscoveryContainer, discoveryConfig})
+	}
+
 	if swarmOptions.Master {
 		advertiseMasterInfo := fmt.Sprintf("%s:%s", ip, "3376")
 		cmd := fmt.Sprintf("manage --tlsverify --tlscacert=%s --tlscert=%s --tlskey=%s -H %s --strategy %s --advertise %s",
@@ -106,10 +260,7 @@ func configureSwarm(p Provisioner, swarmOptions swarm.Options, authOptions auth.
 			HostConfig: masterHostConfig,
 		}
 
-		err = mcndockerclient.CreateContainer(dockerHost, swarmMasterConfig, "swarm-agent-master")
-		if err != nil {
-			return err
-		}
+		containers = append(containers, swarmContainer{"swarm-agent-master", swarmMasterConfig})
 	}
 
 	if swarmOptions.Agent {
@@ -140,10 +291,32 @@ func configureSwarm(p Provisioner, swarmOptions swarm.Options, authOptions auth.
 			swarmWorkerConfig.Cmd = append([]string{"--experimental"}, swarmWorkerConfig.Cmd...)
 		}
 
-		err = mcndockerclient.CreateContainer(dockerHost, swarmWorkerConfig, "swarm-agent")
-		if err != nil {
-			return err
+		containers = append(containers, swarmContainer{"swarm-agent", swarmWorkerConfig})
+	}
+
+	return dockerHost, containers, nil
+}
+
+// privateNetworks are the networks whose addresses aren't reachable from the
+// internet.
+var privateNetworks = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}
+
+// discoveryHostIP returns the address the discovery service is published
+// on. It isn't authenticated, so it's only published on the private IP of
+// the machine unless it's asked to be public.
+func discoveryHostIP(ip string, public bool) (string, error) {
+	if public {
+		return "0.0.0.0", nil
+	}
+
+	if parsed := net.ParseIP(ip); parsed != nil {
+		for _, network := range privateNetworks {
+			_, ipNet, _ := net.ParseCIDR(network)
+			if ipNet.Contains(parsed) {
+				return ip, nil
+			}
 		}
 	}
-	return nil
+
+	return "", fmt.Errorf("The IP of the machine %s isn't private, the Swarm discovery service isn't authenticated: give --swarm-discovery-public to publish it on all the interfaces of the machine", ip)
 }
@@ -0,0 +1,75 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/samalba/dockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwarmContainerProblem(t *testing.T) {
+	expected := swarmContainer{
+		Name: "swarm-agent",
+		Config: &dockerclient.ContainerConfig{
+			Image: "swarm:latest",
+			Cmd:   []string{"join", "--advertise", "10.0.0.1:2376", "token://abc"},
+		},
+	}
+	running := func(image string, cmd ...string) *dockerclient.ContainerInfo {
+		return &dockerclient.ContainerInfo{
+			Config: &dockerclient.ContainerConfig{Image: image, Cmd: cmd},
+			State:  &dockerclient.State{Running: true},
+		}
+	}
+
+	stopped := running("swarm:latest", expected.Config.Cmd...)
+	stopped.State.Running = false
+
+	assert.Equal(t, "", swarmContainerProblem(expected, running("swarm:latest", expected.Config.Cmd...)))
+	assert.Equal(t, "missing", swarmContainerProblem(expected, nil))
+	assert.Equal(t, "stopped", swarmContainerProblem(expected, stopped))
+	assert.Equal(t, "running the swarm:1.2.0 image instead of swarm:latest", swarmContainerProblem(expected, running("swarm:1.2.0", expected.Config.Cmd...)))
+	assert.Equal(t, "running with other arguments", swarmContainerProblem(expected, running("swarm:latest", "join", "--advertise", "10.0.0.2:2376", "token://abc")))
+}
+
+func TestSwarmContainersLocalDiscovery(t *testing.T) {
+	p, _ := newPlanTestProvisioner()
+
+	_, containers, err := swarmContainers(p, swarm.Options{
+		IsSwarm:        true,
+		Master:         true,
+		Image:          "swarm:latest",
+		Host:           "tcp://0.0.0.0:3376",
+		Strategy:       "spread",
+		Discovery:      swarm.LocalDiscoveryURL("10.0.0.12"),
+		LocalDiscovery: true,
+	}, auth.Options{})
+
+	assert.NoError(t, err)
+	assert.Len(t, containers, 2)
+	assert.Equal(t, swarm.DiscoveryContainer, containers[0].Name)
+	assert.Equal(t, swarm.DiscoveryImage, containers[0].Config.Image)
+	assert.Equal(t, "10.0.0.12", containers[0].Config.HostConfig.PortBindings["8500/tcp"][0].HostIp)
+	assert.Equal(t, "8500", containers[0].Config.HostConfig.PortBindings["8500/tcp"][0].HostPort)
+	assert.Equal(t, "swarm-agent-master", containers[1].Name)
+	assert.Equal(t, "consul://10.0.0.12:8500/swarm", containers[1].Config.Cmd[len(containers[1].Config.Cmd)-1])
+}
+
+func TestDiscoveryHostIP(t *testing.T) {
+	ip, err := discoveryHostIP("192.168.99.100", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.99.100", ip)
+
+	ip, err = discoveryHostIP("172.31.4.20", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "172.31.4.20", ip)
+
+	_, err = discoveryHostIP("54.12.3.4", false)
+	assert.EqualError(t, err, "The IP of the machine 54.12.3.4 isn't private, the Swarm discovery service isn't authenticated: give --swarm-discovery-public to publish it on all the interfaces of the machine")
+
+	ip, err = discoveryHostIP("54.12.3.4", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.0.0.0", ip)
+}
@@ -42,9 +42,21 @@ func (provisioner *CoreOSProvisioner) String() string {
 	return "coreOS"
 }
 
+// CompatibleWithHost accepts Flatcar, which carries on Container Linux.
+func (provisioner *CoreOSProvisioner) CompatibleWithHost() bool {
+	id := provisioner.OsReleaseInfo.ID
+	return id == provisioner.OsReleaseID || id == "flatcar"
+}
+
 func (provisioner *CoreOSProvisioner) SetHostname(hostname string) error {
 	log.Debugf("SetHostname: %s", hostname)
 
+	// Flatcar is configured with Ignition rather than cloud-config.
+	if provisioner.isFlatcar() {
+		_, err := provisioner.SSHCommand(fmt.Sprintf("sudo hostnamectl set-hostname %s", hostname))
+		return err
+	}
+
 	if _, err := provisioner.SSHCommand(fmt.Sprintf(hostTmpl, hostname)); err != nil {
 		return err
 	}
@@ -56,13 +68,20 @@ func (provisioner *CoreOSProvisioner) SetHostname(hostname string) error {
 	return nil
 }
 
+func (provisioner *CoreOSProvisioner) isFlatcar() bool {
+	return provisioner.OsReleaseInfo != nil && provisioner.OsReleaseInfo.ID == "flatcar"
+}
+
 func (provisioner *CoreOSProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
 	var (
 		engineCfg bytes.Buffer
 	)
 
-	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
-	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	provisioner.EngineOptions.Labels = withDriverLabel(provisioner.EngineOptions.Labels, provisioner.Driver.DriverName())
+
+	if provisioner.isFlatcar() {
+		return provisioner.generateFlatcarDockerOptions(dockerPort)
+	}
 
 	engineConfigTmpl := `[Unit]
 Description=Docker Socket for the API
@@ -75,7 +94,7 @@ EnvironmentFile=-/run/flannel_docker_opts.env
 MountFlags=slave
 LimitNOFILE=1048576
 LimitNPROC=1048576
-ExecStart=/usr/lib/coreos/dockerd daemon --host=unix:///var/run/docker.sock --host=tcp://0.0.0.0:{{.DockerPort}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}}{{ range .EngineOptions.Labels }} --label {{.}}{{ end }}{{ range .EngineOptions.InsecureRegistry }} --insecure-registry {{.}}{{ end }}{{ range .EngineOptions.RegistryMirror }} --registry-mirror {{.}}{{ end }}{{ range .EngineOptions.ArbitraryFlags }} --{{.}}{{ end }} \$DOCKER_OPTS \$DOCKER_OPT_BIP \$DOCKER_OPT_MTU \$DOCKER_OPT_IPMASQ
+ExecStart=/usr/lib/coreos/dockerd daemon --host=unix:///var/run/docker.sock --host=tcp://0.0.0.0:{{.DockerPort}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}}{{ range .EngineOptions.Labels }} --label {{.}}{{ end }}{{ range .EngineOptions.InsecureRegistry }} --insecure-registry {{.}}{{ end }}{{ range .EngineOptions.RegistryMirror }} --registry-mirror {{.}}{{ end }}{{ range .TypedFlags }} --{{.}}{{ end }}{{ range .EngineOptions.ArbitraryFlags }} --{{.}}{{ end }} \$DOCKER_OPTS \$DOCKER_OPT_BIP \$DOCKER_OPT_MTU \$DOCKER_OPT_IPMASQ
 Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
 
 [Install]
@@ -87,10 +106,17 @@ WantedBy=multi-user.target
 		return nil, err
 	}
 
+	version := installedEngineVersion(provisioner)
+	typedFlags, err := engineTypedFlags(version, provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
 	engineConfigContext := EngineConfigContext{
 		DockerPort:    dockerPort,
 		AuthOptions:   provisioner.AuthOptions,
 		EngineOptions: provisioner.EngineOptions,
+		TypedFlags:    typedFlags,
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -101,35 +127,91 @@ WantedBy=multi-user.target
 	}, nil
 }
 
+// generateFlatcarDockerOptions starts the engine of Flatcar with dockerd, it
+// has neither early-docker.target nor /usr/lib/coreos/dockerd.
+func (provisioner *CoreOSProvisioner) generateFlatcarDockerOptions(dockerPort int) (*DockerOptions, error) {
+	var (
+		engineCfg bytes.Buffer
+	)
+
+	engineConfigTmpl := `[Unit]
+Description=Docker Application Container Engine
+After=containerd.service docker.socket network-online.target
+Requires=docker.socket
+
+[Service]
+Type=notify
+Environment=TMPDIR=/var/tmp
+MountFlags=slave
+LimitNOFILE=1048576
+LimitNPROC=1048576
+ExecStart=/usr/bin/dockerd{{ if .EngineConfig }}{{ range .EngineConfig.CommandLineFlags }} --{{.}}{{ end }}{{ else }} --host=unix:///var/run/docker.sock --host=tcp://0.0.0.0:{{.DockerPort}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}}{{ range .EngineOptions.Labels }} --label {{.}}{{ end }}{{ range .EngineOptions.InsecureRegistry }} --insecure-registry {{.}}{{ end }}{{ range .EngineOptions.RegistryMirror }} --registry-mirror {{.}}{{ end }}{{ range .TypedFlags }} --{{.}}{{ end }}{{ range .EngineOptions.ArbitraryFlags }} --{{.}}{{ end }}{{ end }}
+ExecReload=/bin/kill -s HUP $MAINPID
+Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
+
+[Install]
+WantedBy=multi-user.target
+`
+
+	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	version := installedEngineVersion(provisioner)
+	typedFlags, err := engineTypedFlags(version, provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	engineConfigContext := EngineConfigContext{
+		DockerPort:    dockerPort,
+		AuthOptions:   provisioner.AuthOptions,
+		EngineOptions: provisioner.EngineOptions,
+		TypedFlags:    typedFlags,
+	}
+	engineConfigContext.EngineConfig = newDaemonConfig(version, dockerPort, provisioner.AuthOptions, provisioner.EngineOptions)
+
+	t.Execute(&engineCfg, engineConfigContext)
+
+	return &DockerOptions{
+		EngineOptions:     engineCfg.String(),
+		EngineOptionsPath: provisioner.DaemonOptionsFile,
+		DaemonConfig:      engineConfigContext.EngineConfig,
+	}, nil
+}
+
 func (provisioner *CoreOSProvisioner) Package(name string, action pkgaction.PackageAction) error {
 	return nil
 }
 
-func (provisioner *CoreOSProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+// setOptions sets the options of the provisioning.
+func (provisioner *CoreOSProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
+	return nil
+}
 
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
-		return err
-	}
-
-	if err := makeDockerOptionsDir(provisioner); err != nil {
+func (provisioner *CoreOSProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	steps, err := provisioner.Steps(swarmOptions, authOptions, engineOptions)
+	if err != nil {
 		return err
 	}
 
-	log.Debugf("Preparing certificates")
-	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
-
-	log.Debugf("Setting up certificates")
-	if err := ConfigureAuth(provisioner); err != nil {
-		return err
-	}
+	return ApplySteps(steps)
+}
 
-	log.Debug("Configuring swarm")
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
-		return err
+// Steps returns the steps of the provisioning.
+func (provisioner *CoreOSProvisioner) Steps(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) ([]Step, error) {
+	if err := provisioner.setOptions(swarmOptions, authOptions, engineOptions); err != nil {
+		return nil, err
 	}
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	return nil
+	return []Step{
+		hostnameStep(provisioner),
+		authStep(provisioner),
+		swarmStep(provisioner, swarmOptions, provisioner.AuthOptions),
+	}, nil
 }
@@ -0,0 +1,68 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoreOSCompatibleWithFlatcar(t *testing.T) {
+	info, err := NewOsRelease(flatcarOsRelease)
+	assert.NoError(t, err)
+
+	p := NewCoreOSProvisioner(&fakedriver.Driver{})
+	p.SetOsReleaseInfo(info)
+
+	assert.True(t, p.CompatibleWithHost())
+}
+
+func TestCoreOSSetHostnameOnFlatcar(t *testing.T) {
+	p := NewCoreOSProvisioner(&fakedriver.Driver{}).(*CoreOSProvisioner)
+	p.SetOsReleaseInfo(&OsRelease{ID: "flatcar"})
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo hostnamectl set-hostname dev": "",
+		},
+	}
+
+	assert.NoError(t, p.SetHostname("dev"))
+}
+
+func TestCoreOSGenerateDockerOptionsOnFlatcar(t *testing.T) {
+	p := NewCoreOSProvisioner(&fakedriver.Driver{}).(*CoreOSProvisioner)
+	p.SetOsReleaseInfo(&OsRelease{ID: "flatcar"})
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"docker --version": "Docker version 20.10.24, build 297e128\n",
+		},
+	}
+	p.EngineOptions = engine.Options{
+		StorageDriver:  "overlay2",
+		ArbitraryFlags: []string{"api-cors-header=*"},
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, dockerCfg.DaemonConfig)
+	assert.Contains(t, dockerCfg.EngineOptions, "ExecStart=/usr/bin/dockerd --api-cors-header=*\n")
+	assert.NotContains(t, dockerCfg.EngineOptions, "/usr/lib/coreos/dockerd")
+	assert.NotContains(t, dockerCfg.EngineOptions, "early-docker.target")
+}
+
+func TestCoreOSGenerateDockerOptionsFlagsOnFlatcar(t *testing.T) {
+	p := NewCoreOSProvisioner(&fakedriver.Driver{}).(*CoreOSProvisioner)
+	p.SetOsReleaseInfo(&OsRelease{ID: "flatcar"})
+	p.SSHCommander = &provisiontest.FakeSSHCommander{}
+	p.EngineOptions = engine.Options{ArbitraryFlags: []string{"selinux-enabled"}}
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.Nil(t, dockerCfg.DaemonConfig)
+	assert.Contains(t, dockerCfg.EngineOptions, "ExecStart=/usr/bin/dockerd --host=unix:///var/run/docker.sock --host=tcp://0.0.0.0:2376 --tlsverify")
+	assert.Contains(t, dockerCfg.EngineOptions, " --selinux-enabled\n")
+}
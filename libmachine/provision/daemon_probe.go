@@ -0,0 +1,131 @@
+package provision
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+// The delays of WaitForDocker between the probes, and how long it waits
+// overall.
+var (
+	daemonProbeInitialInterval = 500 * time.Millisecond
+	daemonProbeMaxInterval     = 5 * time.Second
+	daemonProbeTimeout         = time.Minute
+)
+
+// daemonAPITimeout bounds each call to the API.
+var daemonAPITimeout = 5 * time.Second
+
+// DaemonProber is implemented by the provisioners which check that the
+// engine is ready in their own way.
+type DaemonProber interface {
+	// ProbeDaemon returns nil once the engine is ready to serve the API.
+	ProbeDaemon(authOptions auth.Options, dockerPort int) error
+}
+
+// WaitForDocker waits for the engine of the machine to serve the API, with
+// the client certs of the provisioner's auth options.
+func WaitForDocker(p Provisioner, dockerPort int) error {
+	return WaitForDockerWithAuth(p, p.GetAuthOptions(), dockerPort)
+}
+
+// WaitForDockerWithAuth waits for the engine of the machine to serve the
+// API, with the client certs of authOptions.
+func WaitForDockerWithAuth(p Provisioner, authOptions auth.Options, dockerPort int) error {
+	probe := func() error {
+		return ProbeDaemon(p, authOptions, dockerPort)
+	}
+
+	if err := mcnutils.WaitForBackoff(probe, daemonProbeInitialInterval, daemonProbeMaxInterval, daemonProbeTimeout); err != nil {
+		return NewErrDaemonAvailable(err)
+	}
+
+	return nil
+}
+
+// ProbeDaemon checks once that the engine is ready. The API is called from
+// here over TLS, and from the machine over SSH when that fails, for instance
+// because the port is firewalled.
+func ProbeDaemon(p Provisioner, authOptions auth.Options, dockerPort int) error {
+	if prober, ok := p.(DaemonProber); ok {
+		return prober.ProbeDaemon(authOptions, dockerPort)
+	}
+
+	apiErr := probeDaemonAPI(p, authOptions, dockerPort)
+	if apiErr == nil {
+		return nil
+	}
+	log.Debugf("Docker API not available from here, checking from the machine: %s", apiErr)
+
+	if sshErr := probeDaemonSSH(p); sshErr != nil {
+		return fmt.Errorf("API: %s, SSH: %s", apiErr, sshErr)
+	}
+
+	return nil
+}
+
+// probeDaemonAPI pings the engine through the API, on the TCP port.
+func probeDaemonAPI(p Provisioner, authOptions auth.Options, dockerPort int) error {
+	ip, err := p.GetDriver().GetIP()
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(ip, strconv.Itoa(dockerPort))
+	tlsConfig, err := cert.ReadTLSConfig(addr, &authOptions)
+	if err != nil {
+		return fmt.Errorf("Unable to read TLS config: %s", err)
+	}
+
+	client := &http.Client{
+		Timeout: daemonAPITimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}
+
+	resp, err := client.Get(fmt.Sprintf("https://%s/_ping", addr))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// probeDaemonSSH calls the API from the machine, through the unix socket.
+func probeDaemonSSH(p SSHCommander) error {
+	command := "sudo docker version"
+	if rootless, ok := p.(*RootlessProvisioner); ok {
+		if err := rootless.readUserDirs(); err != nil {
+			return err
+		}
+		command = rootless.dockerCommand("version")
+	}
+
+	out, err := p.SSHCommand(command)
+	if err != nil && strings.TrimSpace(out) != "" {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(out))
+	}
+
+	return err
+}
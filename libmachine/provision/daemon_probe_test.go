@@ -0,0 +1,171 @@
+package provision
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// newDaemonProbeTestServer starts a fake engine API over TLS, and returns
+// the auth options of a client allowed to call it.
+func newDaemonProbeTestServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, auth.Options, func()) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+
+	authOptions := auth.Options{
+		CaCertPath:       filepath.Join(dir, "ca.pem"),
+		CaPrivateKeyPath: filepath.Join(dir, "ca-key.pem"),
+		ClientCertPath:   filepath.Join(dir, "cert.pem"),
+		ClientKeyPath:    filepath.Join(dir, "key.pem"),
+	}
+	serverCertPath := filepath.Join(dir, "server.pem")
+	serverKeyPath := filepath.Join(dir, "server-key.pem")
+
+	assert.NoError(t, cert.GenerateCACertificate(authOptions.CaCertPath, authOptions.CaPrivateKeyPath, "test", 1024))
+	assert.NoError(t, cert.GenerateCert(&cert.Options{
+		Hosts:     []string{""},
+		CertFile:  authOptions.ClientCertPath,
+		KeyFile:   authOptions.ClientKeyPath,
+		CAFile:    authOptions.CaCertPath,
+		CAKeyFile: authOptions.CaPrivateKeyPath,
+		Org:       "test",
+		Bits:      1024,
+	}))
+	assert.NoError(t, cert.GenerateCert(&cert.Options{
+		Hosts:     []string{"127.0.0.1"},
+		CertFile:  serverCertPath,
+		KeyFile:   serverKeyPath,
+		CAFile:    authOptions.CaCertPath,
+		CAKeyFile: authOptions.CaPrivateKeyPath,
+		Org:       "test",
+		Bits:      1024,
+	}))
+
+	caCert, err := ioutil.ReadFile(authOptions.CaCertPath)
+	assert.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(caCert)
+
+	serverCert, err := tls.LoadX509KeyPair(serverCertPath, serverKeyPath)
+	assert.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+
+	return server, authOptions, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func newDaemonProbeTestProvisioner(t *testing.T, addr string, responses map[string]string) Provisioner {
+	host, _, err := net.SplitHostPort(addr)
+	assert.NoError(t, err)
+
+	p := NewDebianProvisioner(&fakedriver.Driver{
+		MockIP:    host,
+		MockState: state.Running,
+	}).(*DebianProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{Responses: responses}
+
+	return p
+}
+
+func serverPort(t *testing.T, server *httptest.Server) int {
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	assert.NoError(t, err)
+
+	dockerPort, err := strconv.Atoi(port)
+	assert.NoError(t, err)
+
+	return dockerPort
+}
+
+func TestProbeDaemonAPI(t *testing.T) {
+	server, authOptions, cleanup := newDaemonProbeTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/_ping", r.URL.Path)
+		w.Write([]byte("OK"))
+	})
+	defer cleanup()
+
+	// Nothing is registered over SSH: the API has to answer.
+	p := newDaemonProbeTestProvisioner(t, server.Listener.Addr().String(), map[string]string{})
+
+	assert.NoError(t, ProbeDaemon(p, authOptions, serverPort(t, server)))
+}
+
+func TestProbeDaemonFallsBackToSSH(t *testing.T) {
+	server, authOptions, cleanup := newDaemonProbeTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+	})
+	defer cleanup()
+
+	p := newDaemonProbeTestProvisioner(t, server.Listener.Addr().String(), map[string]string{
+		"sudo docker version": "Server:\n Version: 17.03.1-ce\n",
+	})
+
+	assert.NoError(t, ProbeDaemon(p, authOptions, serverPort(t, server)))
+}
+
+func TestProbeDaemonReportsErrors(t *testing.T) {
+	server, authOptions, cleanup := newDaemonProbeTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+	})
+	defer cleanup()
+
+	p := newDaemonProbeTestProvisioner(t, server.Listener.Addr().String(), map[string]string{})
+
+	err := ProbeDaemon(p, authOptions, serverPort(t, server))
+
+	assert.EqualError(t, err, "API: 503 Service Unavailable: starting, SSH: Command not registered in FakeSSHCommander")
+}
+
+type probingProvisioner struct {
+	FakeProvisioner
+	err error
+}
+
+func (p *probingProvisioner) ProbeDaemon(authOptions auth.Options, dockerPort int) error {
+	return p.err
+}
+
+func TestProbeDaemonOverride(t *testing.T) {
+	p := &probingProvisioner{err: errors.New("not ready")}
+
+	assert.EqualError(t, ProbeDaemon(p, auth.Options{}, 2376), "not ready")
+}
+
+func TestWaitForDockerGivesUp(t *testing.T) {
+	defer func(initialInterval, maxInterval, timeout time.Duration) {
+		daemonProbeInitialInterval, daemonProbeMaxInterval, daemonProbeTimeout = initialInterval, maxInterval, timeout
+	}(daemonProbeInitialInterval, daemonProbeMaxInterval, daemonProbeTimeout)
+	daemonProbeInitialInterval, daemonProbeMaxInterval, daemonProbeTimeout = time.Millisecond, time.Millisecond, 10*time.Millisecond
+
+	p := &probingProvisioner{err: errors.New("not ready")}
+
+	err := WaitForDocker(p, 2376)
+
+	assert.IsType(t, ErrDaemonAvailable{}, err)
+	assert.Contains(t, err.Error(), "not ready")
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/provision/pkgaction"
-	"github.com/docker/machine/libmachine/provision/serviceaction"
 	"github.com/docker/machine/libmachine/swarm"
 )
 
@@ -34,6 +33,10 @@ func (provisioner *DebianProvisioner) String() string {
 }
 
 func (provisioner *DebianProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	if provisioner.EngineOptions.InstallBundle != "" {
+		return bundlePackage(provisioner, provisioner.EngineOptions.InstallBundle, name, action)
+	}
+
 	var packageAction string
 
 	updateMetadata := true
@@ -68,6 +71,14 @@ func (provisioner *DebianProvisioner) Package(name string, action pkgaction.Pack
 	return nil
 }
 
+func (provisioner *DebianProvisioner) InstallEngineVersion(version string) error {
+	return aptInstallEngineVersion(provisioner, version)
+}
+
+func (provisioner *DebianProvisioner) bundlePackageFormat() bundlePackageFormat {
+	return debBundlePackages
+}
+
 func (provisioner *DebianProvisioner) dockerDaemonResponding() bool {
 	log.Debug("checking docker daemon")
 
@@ -81,11 +92,12 @@ func (provisioner *DebianProvisioner) dockerDaemonResponding() bool {
 	return true
 }
 
-func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+// setOptions sets the options of the provisioning, and the storage driver
+// of the engine when none was given.
+func (provisioner *DebianProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
 
 	storageDriver, err := decideStorageDriver(provisioner, "aufs", engineOptions.StorageDriver)
 	if err != nil {
@@ -93,51 +105,48 @@ func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.Options, auth
 	}
 	provisioner.EngineOptions.StorageDriver = storageDriver
 
-	// HACK: since debian does not come with sudo by default we install
-	log.Debug("installing sudo")
-	if _, err := provisioner.SSHCommand("if ! type sudo; then apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y sudo; fi"); err != nil {
-		return err
-	}
+	return nil
+}
 
-	log.Debug("setting hostname")
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	steps, err := provisioner.Steps(swarmOptions, authOptions, engineOptions)
+	if err != nil {
 		return err
 	}
 
-	log.Debug("installing base packages")
-	for _, pkg := range provisioner.Packages {
-		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
-			return err
-		}
-	}
-
-	log.Debug("installing docker")
-	if err := installDockerGeneric(provisioner, engineOptions.InstallURL); err != nil {
-		return err
-	}
+	return ApplySteps(steps)
+}
 
-	log.Debug("waiting for docker daemon")
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
-		return err
+// Steps returns the steps of the provisioning.
+func (provisioner *DebianProvisioner) Steps(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) ([]Step, error) {
+	if err := provisioner.setOptions(swarmOptions, authOptions, engineOptions); err != nil {
+		return nil, err
 	}
-
+	swarmOptions.Env = engineOptions.Env
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	log.Debug("configuring auth")
-	if err := ConfigureAuth(provisioner); err != nil {
-		return err
-	}
-
-	log.Debug("configuring swarm")
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
-		return err
-	}
-
-	// enable in systemd
-	log.Debug("enabling docker in systemd")
-	if err := provisioner.Service("docker", serviceaction.Enable); err != nil {
-		return err
-	}
-
-	return nil
+	return []Step{
+		{
+			// HACK: since debian does not come with sudo by default we install
+			Name: StepSudo,
+			Apply: func() error {
+				_, err := provisioner.SSHCommand("if ! type sudo; then apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y sudo; fi")
+				return err
+			},
+		},
+		hostnameStep(provisioner),
+		packagesStep(provisioner, provisioner.Packages),
+		engineStep(provisioner, engineOptions, func() error {
+			log.Debug("installing docker")
+			if err := installDockerGeneric(provisioner, engineOptions); err != nil {
+				return err
+			}
+
+			log.Debug("waiting for docker daemon")
+			return mcnutils.WaitFor(provisioner.dockerDaemonResponding)
+		}),
+		authStep(provisioner),
+		swarmStep(provisioner, swarmOptions, provisioner.AuthOptions),
+		enableServiceStep(provisioner),
+	}, nil
 }
@@ -0,0 +1,540 @@
+package provision
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// daemonConfigFile is where the engine reads its configuration from, since
+// Docker 1.12.
+const daemonConfigFile = "/etc/docker/daemon.json"
+
+var engineVersionRegex = regexp.MustCompile(`(\d+)\.(\d+)`)
+
+// daemonConfigListKeys maps the flags which can be repeated to their keys in
+// daemon.json, which hold arrays.
+var daemonConfigListKeys = map[string]string{
+	"authorization-plugin": "authorization-plugins",
+	"dns":                  "dns",
+	"dns-opt":              "dns-opts",
+	"dns-search":           "dns-search",
+	"exec-opt":             "exec-opts",
+	"host":                 "hosts",
+	"insecure-registry":    "insecure-registries",
+	"label":                "labels",
+	"registry-mirror":      "registry-mirrors",
+	"storage-opt":          "storage-opts",
+}
+
+// daemonConfigMapKeys maps the flags taking key=value options to their keys
+// in daemon.json, which hold objects.
+var daemonConfigMapKeys = map[string]string{
+	"cluster-store-opt": "cluster-store-opts",
+	"log-opt":           "log-opts",
+}
+
+// daemonConfigValueKeys are the flags taking a single value, or none, which
+// daemon.json holds under the same name.
+var daemonConfigValueKeys = map[string]bool{
+	"bip":                          true,
+	"bridge":                       true,
+	"cgroup-parent":                true,
+	"cluster-advertise":            true,
+	"cluster-store":                true,
+	"containerd":                   true,
+	"data-root":                    true,
+	"debug":                        true,
+	"default-gateway":              true,
+	"default-gateway-v6":           true,
+	"default-ipc-mode":             true,
+	"default-runtime":              true,
+	"default-shm-size":             true,
+	"exec-root":                    true,
+	"experimental":                 true,
+	"fixed-cidr":                   true,
+	"fixed-cidr-v6":                true,
+	"group":                        true,
+	"icc":                          true,
+	"init":                         true,
+	"init-path":                    true,
+	"ip":                           true,
+	"ip-forward":                   true,
+	"ip-masq":                      true,
+	"ip6tables":                    true,
+	"iptables":                     true,
+	"ipv6":                         true,
+	"live-restore":                 true,
+	"log-driver":                   true,
+	"log-level":                    true,
+	"max-concurrent-downloads":     true,
+	"max-concurrent-uploads":       true,
+	"max-download-attempts":        true,
+	"metrics-addr":                 true,
+	"mtu":                          true,
+	"no-new-privileges":            true,
+	"oom-score-adjust":             true,
+	"pidfile":                      true,
+	"raw-logs":                     true,
+	"seccomp-profile":              true,
+	"selinux-enabled":              true,
+	"shutdown-timeout":             true,
+	"storage-driver":               true,
+	"swarm-default-advertise-addr": true,
+	"tls":                          true,
+	"tlscacert":                    true,
+	"tlscert":                      true,
+	"tlskey":                       true,
+	"tlsverify":                    true,
+	"userland-proxy":               true,
+	"userland-proxy-path":          true,
+	"userns-remap":                 true,
+}
+
+// daemonConfigKeyVersions are the versions of the engine from which it reads
+// the keys below from daemon.json, the older engines refuse to start with
+// them. Their flags are left on the command line of those engines.
+var daemonConfigKeyVersions = map[string]engineVersion{
+	"default-shm-size": {17, 6},
+	"init":             {1, 13},
+	"init-path":        {1, 13},
+	"ip6tables":        {20, 10},
+}
+
+// daemonConfigFlagAliases maps the short and the deprecated flags of the
+// engine to the flags whose name daemon.json uses.
+var daemonConfigFlagAliases = map[string]string{
+	"D":     "debug",
+	"G":     "group",
+	"H":     "host",
+	"b":     "bridge",
+	"g":     "data-root",
+	"graph": "data-root",
+	"l":     "log-level",
+	"p":     "pidfile",
+}
+
+// EngineConfig is the configuration Machine gives to the engine, whether
+// it's passed as flags or written to daemon.json.
+type EngineConfig struct {
+	Hosts              []string
+	StorageDriver      string
+	TLSVerify          bool
+	TLSCACert          string
+	TLSCert            string
+	TLSKey             string
+	Labels             []string
+	InsecureRegistries []string
+	RegistryMirrors    []string
+	// Options are the typed options of the engine, which daemon.json holds
+	// in other shapes than their flags.
+	Options engine.Options
+	// Flags are the arbitrary flags given with --engine-opt, as flag=value
+	// or flag. The ones daemon.json has no key for are left on the command
+	// line of the engine.
+	Flags []string
+
+	// version is the version of the engine, it's zero when it isn't known
+	// and the keys of the latest engines are used then.
+	version engineVersion
+}
+
+// engineVersion is the major and minor version of an engine.
+type engineVersion struct {
+	major, minor int
+}
+
+// atLeast tells whether the version is major.minor or later. An unknown
+// version is taken as the latest.
+func (v engineVersion) atLeast(major, minor int) bool {
+	if v == (engineVersion{}) {
+		return true
+	}
+	return v.major > major || (v.major == major && v.minor >= minor)
+}
+
+// NewEngineConfig returns the configuration of an engine listening on
+// dockerPort with the TLS certs of authOptions.
+func NewEngineConfig(dockerPort int, authOptions auth.Options, engineOptions engine.Options) *EngineConfig {
+	return &EngineConfig{
+		Hosts: []string{
+			fmt.Sprintf("tcp://0.0.0.0:%d", dockerPort),
+			"unix:///var/run/docker.sock",
+		},
+		StorageDriver:      engineOptions.StorageDriver,
+		TLSVerify:          true,
+		TLSCACert:          authOptions.CaCertRemotePath,
+		TLSCert:            authOptions.ServerCertRemotePath,
+		TLSKey:             authOptions.ServerKeyRemotePath,
+		Labels:             engineOptions.Labels,
+		InsecureRegistries: engineOptions.InsecureRegistry,
+		RegistryMirrors:    engineOptions.RegistryMirror,
+		Options:            engineOptions,
+		Flags:              engineOptions.ArbitraryFlags,
+	}
+}
+
+// Settings returns the configuration as daemon.json settings.
+func (c *EngineConfig) Settings() (map[string]interface{}, error) {
+	settings := map[string]interface{}{
+		"hosts":     stringsToValues(c.Hosts),
+		"tlsverify": c.TLSVerify,
+	}
+
+	setString := func(key, value string) {
+		if value != "" {
+			settings[key] = value
+		}
+	}
+	setString("storage-driver", c.StorageDriver)
+	setString("tlscacert", c.TLSCACert)
+	setString("tlscert", c.TLSCert)
+	setString("tlskey", c.TLSKey)
+
+	appendList := func(key string, values ...string) {
+		list, _ := settings[key].([]interface{})
+		settings[key] = append(list, stringsToValues(values)...)
+	}
+	if len(c.Labels) > 0 {
+		appendList("labels", c.Labels...)
+	}
+	if len(c.InsecureRegistries) > 0 {
+		appendList("insecure-registries", c.InsecureRegistries...)
+	}
+	if len(c.RegistryMirrors) > 0 {
+		appendList("registry-mirrors", c.RegistryMirrors...)
+	}
+
+	if err := c.setOptions(settings); err != nil {
+		return nil, err
+	}
+
+	for _, flag := range c.Flags {
+		name, value, hasValue, err := parseEngineFlag(flag)
+		if err != nil {
+			return nil, err
+		}
+
+		if key, ok := daemonConfigListKeys[name]; ok {
+			if !hasValue {
+				return nil, fmt.Errorf("Invalid engine option %q: a value is expected", flag)
+			}
+			appendList(key, value)
+			continue
+		}
+
+		if key, ok := daemonConfigMapKeys[name]; ok {
+			option := strings.SplitN(value, "=", 2)
+			if !hasValue || len(option) != 2 {
+				return nil, fmt.Errorf("Invalid engine option %q: key=value is expected", flag)
+			}
+			options, _ := settings[key].(map[string]interface{})
+			if options == nil {
+				options = map[string]interface{}{}
+			}
+			options[option[0]] = option[1]
+			settings[key] = options
+			continue
+		}
+
+		key, ok := c.valueKey(name)
+		if !ok {
+			continue
+		}
+
+		if hasValue {
+			settings[key] = daemonConfigValue(value)
+		} else {
+			settings[key] = true
+		}
+	}
+
+	return settings, nil
+}
+
+// CommandLineFlags returns the flags given with --engine-opt which
+// daemon.json has no key for, or which the engine doesn't read from it yet,
+// they're passed to the engine as flags.
+func (c *EngineConfig) CommandLineFlags() []string {
+	flags := []string{}
+	for _, flag := range c.Flags {
+		name, _, _, err := parseEngineFlag(flag)
+		if err != nil {
+			continue
+		}
+
+		_, isList := daemonConfigListKeys[name]
+		_, isMap := daemonConfigMapKeys[name]
+		_, isValue := c.valueKey(name)
+		if !isList && !isMap && !isValue {
+			flags = append(flags, strings.TrimLeft(flag, "-"))
+		}
+	}
+	return flags
+}
+
+// valueKey returns the key of daemon.json holding the value of the flag name
+// for the engine, or false if the engine doesn't read it from daemon.json.
+func (c *EngineConfig) valueKey(name string) (string, bool) {
+	if !daemonConfigValueKeys[name] {
+		return "", false
+	}
+
+	// data-root was named graph before Docker 17.05.
+	if name == "data-root" && !c.version.atLeast(17, 5) {
+		return "graph", true
+	}
+
+	if version, ok := daemonConfigKeyVersions[name]; ok && !c.version.atLeast(version.major, version.minor) {
+		return "", false
+	}
+
+	return name, true
+}
+
+// parseEngineFlag splits a flag given with --engine-opt into its name, with
+// the aliases resolved, and its value.
+func parseEngineFlag(flag string) (string, string, bool, error) {
+	parts := strings.SplitN(strings.TrimLeft(flag, "-"), "=", 2)
+	name := parts[0]
+	if name == "" {
+		return "", "", false, fmt.Errorf("Invalid engine option %q", flag)
+	}
+
+	if alias, ok := daemonConfigFlagAliases[name]; ok {
+		name = alias
+	}
+
+	if len(parts) == 1 {
+		return name, "", false, nil
+	}
+	return name, parts[1], true, nil
+}
+
+// setOptions sets the typed options of the engine in the settings.
+func (c *EngineConfig) setOptions(settings map[string]interface{}) error {
+	options := c.Options
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	if options.LogDriver != "" {
+		settings["log-driver"] = options.LogDriver
+	}
+	if len(options.LogOpts) > 0 {
+		logOpts := map[string]interface{}{}
+		for _, option := range options.LogOpts {
+			parts := strings.SplitN(option, "=", 2)
+			logOpts[parts[0]] = parts[1]
+		}
+		settings["log-opts"] = logOpts
+	}
+	if options.CgroupDriver != "" {
+		settings["exec-opts"] = []interface{}{"native.cgroupdriver=" + options.CgroupDriver}
+	}
+	if options.LiveRestore {
+		settings["live-restore"] = true
+	}
+	if len(options.DefaultUlimits) > 0 {
+		ulimits := map[string]interface{}{}
+		for _, value := range options.DefaultUlimits {
+			ulimit, err := engine.ParseUlimit(value)
+			if err != nil {
+				return err
+			}
+			ulimits[ulimit.Name] = ulimit
+		}
+		settings["default-ulimits"] = ulimits
+	}
+	if err := checkDefaultAddressPools(c.version, options); err != nil {
+		return err
+	}
+
+	if len(options.DefaultAddressPools) > 0 {
+		pools := []interface{}{}
+		for _, value := range options.DefaultAddressPools {
+			pool, err := engine.ParseAddressPool(value)
+			if err != nil {
+				return err
+			}
+			pools = append(pools, pool)
+		}
+		settings["default-address-pools"] = pools
+	}
+	if options.Bip != "" {
+		settings["bip"] = options.Bip
+	}
+	if options.MTU != 0 {
+		settings["mtu"] = options.MTU
+	}
+	if options.DataRoot != "" {
+		key, _ := c.valueKey("data-root")
+		settings[key] = options.DataRoot
+	}
+
+	return nil
+}
+
+// DaemonJSON merges the configuration into the content of an existing
+// daemon.json. The settings set by Machine replace the ones of the file,
+// the others are kept.
+func (c *EngineConfig) DaemonJSON(existing []byte) ([]byte, error) {
+	config := map[string]interface{}{}
+	if len(strings.TrimSpace(string(existing))) > 0 {
+		if err := json.Unmarshal(existing, &config); err != nil {
+			return nil, fmt.Errorf("Error parsing the existing %s: %s", daemonConfigFile, err)
+		}
+	}
+
+	settings, err := c.Settings()
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range settings {
+		config[key] = value
+	}
+
+	content, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(content, '\n'), nil
+}
+
+// daemonConfigValue converts the value of a flag to the JSON type the engine
+// expects for it, as far as it can be guessed.
+func daemonConfigValue(value string) interface{} {
+	if value == "true" || value == "false" {
+		return value == "true"
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	return value
+}
+
+func stringsToValues(values []string) []interface{} {
+	result := []interface{}{}
+	for _, value := range values {
+		result = append(result, value)
+	}
+	return result
+}
+
+// installedEngineVersion returns the version of the engine installed on the
+// machine, or zero if it can't be known.
+func installedEngineVersion(p SSHCommander) engineVersion {
+	output, err := p.SSHCommand("docker --version")
+	if err != nil {
+		log.Debugf("Unable to get the version of the engine: %s", err)
+		return engineVersion{}
+	}
+
+	major, minor, ok := parseEngineVersion(output)
+	if !ok {
+		log.Debugf("Unable to parse the version of the engine %q", output)
+		return engineVersion{}
+	}
+
+	return engineVersion{major, minor}
+}
+
+// newDaemonConfig returns the configuration of an engine of the version, or
+// nil if it isn't known to read its configuration from daemon.json and is
+// configured with flags.
+func newDaemonConfig(version engineVersion, dockerPort int, authOptions auth.Options, engineOptions engine.Options) *EngineConfig {
+	if version == (engineVersion{}) || !version.atLeast(1, 12) {
+		log.Debug("Configuring the engine with flags")
+		return nil
+	}
+
+	config := NewEngineConfig(dockerPort, authOptions, engineOptions)
+	config.version = version
+	return config
+}
+
+// engineTypedFlags returns the typed options as flags of an engine of the
+// version. The data root is given as graph to the engines older than 17.05.
+func engineTypedFlags(version engineVersion, options engine.Options) ([]string, error) {
+	if err := checkDefaultAddressPools(version, options); err != nil {
+		return nil, err
+	}
+
+	flags := options.TypedFlags()
+	if !version.atLeast(17, 5) {
+		for i, flag := range flags {
+			if strings.HasPrefix(flag, "data-root=") {
+				flags[i] = "graph=" + strings.TrimPrefix(flag, "data-root=")
+			}
+		}
+	}
+	return flags, nil
+}
+
+// checkDefaultAddressPools refuses the default address pools for the engines
+// older than 18.06, which don't know them.
+func checkDefaultAddressPools(version engineVersion, options engine.Options) error {
+	if len(options.DefaultAddressPools) > 0 && !version.atLeast(18, 6) {
+		return fmt.Errorf("Default address pools need Docker 18.06 or later, the engine is %d.%02d", version.major, version.minor)
+	}
+	return nil
+}
+
+// parseEngineVersion returns the major and minor versions in the output of
+// "docker --version".
+func parseEngineVersion(output string) (int, int, bool) {
+	matches := engineVersionRegex.FindStringSubmatch(output)
+	if matches == nil {
+		return 0, 0, false
+	}
+
+	major, _ := strconv.Atoi(matches[1])
+	minor, _ := strconv.Atoi(matches[2])
+	return major, minor, true
+}
+
+// renderDaemonJSON returns the current daemon.json of the machine, and the
+// one with config merged into it.
+func renderDaemonJSON(p SSHCommander, config *EngineConfig) (string, []byte, error) {
+	existing, err := readRemoteFile(p, daemonConfigPath(p))
+	if err != nil {
+		return "", nil, err
+	}
+
+	content, err := config.DaemonJSON([]byte(existing))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return existing, content, nil
+}
+
+// writeDaemonJSON merges config into the daemon.json of the machine.
+func writeDaemonJSON(p SSHCommander, config *EngineConfig) error {
+	_, content, err := renderDaemonJSON(p, config)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("%s:\n%s", daemonConfigPath(p), content)
+
+	return writeRemoteFile(p, daemonConfigPath(p), content, 0644)
+}
+
+// daemonConfigPath returns the path of the daemon.json of the machine, which
+// is in the directory of the engine of the SSH user for rootless engines.
+func daemonConfigPath(p SSHCommander) string {
+	if rootless, ok := p.(*RootlessProvisioner); ok {
+		return path.Join(rootless.GetDockerOptionsDir(), "daemon.json")
+	}
+	return daemonConfigFile
+}
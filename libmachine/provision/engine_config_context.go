@@ -10,4 +10,10 @@ type EngineConfigContext struct {
 	AuthOptions      auth.Options
 	EngineOptions    engine.Options
 	DockerOptionsDir string
+	// EngineConfig is set when the engine is configured with daemon.json
+	// rather than with flags.
+	EngineConfig *EngineConfig
+	// TypedFlags are the typed options of EngineOptions as flags of the
+	// engine installed on the machine.
+	TypedFlags []string
 }
@@ -0,0 +1,363 @@
+package provision
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEngineConfig() *EngineConfig {
+	return NewEngineConfig(2376, auth.Options{
+		CaCertRemotePath:     "/etc/docker/ca.pem",
+		ServerCertRemotePath: "/etc/docker/server.pem",
+		ServerKeyRemotePath:  "/etc/docker/server-key.pem",
+	}, engine.Options{
+		StorageDriver:    "overlay2",
+		Labels:           []string{"provider=generic"},
+		InsecureRegistry: []string{"registry.local:5000"},
+		ArbitraryFlags:   []string{"log-opt=max-size=10m", "mtu=1500", "selinux-enabled", "dns=8.8.8.8", "label=env=test", "log-driver=json-file"},
+	})
+}
+
+func unmarshalDaemonJSON(t *testing.T, content []byte) map[string]interface{} {
+	config := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(content, &config))
+	return config
+}
+
+func TestEngineConfigDaemonJSON(t *testing.T) {
+	content, err := newTestEngineConfig().DaemonJSON(nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"hosts":               []interface{}{"tcp://0.0.0.0:2376", "unix:///var/run/docker.sock"},
+		"storage-driver":      "overlay2",
+		"tlsverify":           true,
+		"tlscacert":           "/etc/docker/ca.pem",
+		"tlscert":             "/etc/docker/server.pem",
+		"tlskey":              "/etc/docker/server-key.pem",
+		"labels":              []interface{}{"provider=generic", "env=test"},
+		"insecure-registries": []interface{}{"registry.local:5000"},
+		"log-opts":            map[string]interface{}{"max-size": "10m"},
+		"log-driver":          "json-file",
+		"mtu":                 float64(1500),
+		"selinux-enabled":     true,
+		"dns":                 []interface{}{"8.8.8.8"},
+	}, unmarshalDaemonJSON(t, content))
+}
+
+func TestEngineConfigDaemonJSONTypedOptions(t *testing.T) {
+	config := NewEngineConfig(2376, auth.Options{}, engine.Options{
+		LogDriver:           "json-file",
+		LogOpts:             []string{"max-size=10m", "max-file=3"},
+		CgroupDriver:        "systemd",
+		LiveRestore:         true,
+		DefaultUlimits:      []string{"nofile=1024:2048"},
+		DefaultAddressPools: []string{"base=172.80.0.0/16,size=24"},
+		Bip:                 "10.200.0.1/24",
+		MTU:                 1450,
+		DataRoot:            "/mnt/docker",
+		ArbitraryFlags:      []string{"exec-opt=native.umask=normal"},
+	})
+
+	content, err := config.DaemonJSON(nil)
+
+	assert.NoError(t, err)
+	settings := unmarshalDaemonJSON(t, content)
+	assert.Equal(t, "json-file", settings["log-driver"])
+	assert.Equal(t, map[string]interface{}{"max-size": "10m", "max-file": "3"}, settings["log-opts"])
+	assert.Equal(t, []interface{}{"native.cgroupdriver=systemd", "native.umask=normal"}, settings["exec-opts"])
+	assert.Equal(t, true, settings["live-restore"])
+	assert.Equal(t, map[string]interface{}{
+		"nofile": map[string]interface{}{"Name": "nofile", "Soft": float64(1024), "Hard": float64(2048)},
+	}, settings["default-ulimits"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"base": "172.80.0.0/16", "size": float64(24)},
+	}, settings["default-address-pools"])
+	assert.Equal(t, "10.200.0.1/24", settings["bip"])
+	assert.Equal(t, float64(1450), settings["mtu"])
+	assert.Equal(t, "/mnt/docker", settings["data-root"])
+}
+
+func TestEngineConfigDaemonJSONInvalidTypedOptions(t *testing.T) {
+	config := NewEngineConfig(2376, auth.Options{}, engine.Options{CgroupDriver: "upstart"})
+
+	_, err := config.DaemonJSON(nil)
+
+	assert.EqualError(t, err, `Invalid cgroup driver "upstart", expected one of cgroupfs, systemd`)
+}
+
+func TestEngineConfigDaemonJSONMergesExisting(t *testing.T) {
+	existing := `{"debug": true, "storage-driver": "devicemapper", "registry-mirrors": ["https://mirror.local"]}`
+
+	content, err := newTestEngineConfig().DaemonJSON([]byte(existing))
+
+	assert.NoError(t, err)
+	config := unmarshalDaemonJSON(t, content)
+	assert.Equal(t, true, config["debug"])
+	assert.Equal(t, "overlay2", config["storage-driver"])
+	assert.Equal(t, []interface{}{"https://mirror.local"}, config["registry-mirrors"])
+}
+
+func TestEngineConfigDaemonJSONInvalidExisting(t *testing.T) {
+	_, err := newTestEngineConfig().DaemonJSON([]byte("DOCKER_OPTS=''"))
+
+	assert.Contains(t, err.Error(), "Error parsing the existing /etc/docker/daemon.json")
+}
+
+func TestEngineConfigDaemonJSONInvalidFlags(t *testing.T) {
+	for _, flag := range []string{"dns", "log-opt=max-size", "="} {
+		config := &EngineConfig{Flags: []string{flag}}
+
+		_, err := config.DaemonJSON(nil)
+
+		assert.Error(t, err, flag)
+	}
+}
+
+func TestEngineConfigCommandLineFlags(t *testing.T) {
+	config := NewEngineConfig(2376, auth.Options{}, engine.Options{
+		ArbitraryFlags: []string{"H=tcp://0.0.0.0:2377", "g=/mnt/docker", "--api-cors-header=*", "add-runtime=crun=/usr/bin/crun", "selinux-enabled"},
+	})
+
+	content, err := config.DaemonJSON(nil)
+
+	assert.NoError(t, err)
+	settings := unmarshalDaemonJSON(t, content)
+	assert.Equal(t, []interface{}{"tcp://0.0.0.0:2376", "unix:///var/run/docker.sock", "tcp://0.0.0.0:2377"}, settings["hosts"])
+	assert.Equal(t, "/mnt/docker", settings["data-root"])
+	assert.Equal(t, true, settings["selinux-enabled"])
+	for _, key := range []string{"H", "g", "api-cors-header", "add-runtime"} {
+		assert.NotContains(t, settings, key)
+	}
+	assert.Equal(t, []string{"api-cors-header=*", "add-runtime=crun=/usr/bin/crun"}, config.CommandLineFlags())
+}
+
+func TestEngineConfigDaemonJSONOlderEngine(t *testing.T) {
+	config := NewEngineConfig(2376, auth.Options{}, engine.Options{
+		DataRoot:       "/mnt/docker",
+		ArbitraryFlags: []string{"init", "default-shm-size=128M", "mtu=1450"},
+	})
+	config.version = engineVersion{17, 3}
+
+	content, err := config.DaemonJSON(nil)
+
+	assert.NoError(t, err)
+	settings := unmarshalDaemonJSON(t, content)
+	assert.Equal(t, "/mnt/docker", settings["graph"])
+	assert.Equal(t, true, settings["init"])
+	assert.Equal(t, float64(1450), settings["mtu"])
+	for _, key := range []string{"data-root", "default-shm-size"} {
+		assert.NotContains(t, settings, key)
+	}
+	assert.Equal(t, []string{"default-shm-size=128M"}, config.CommandLineFlags())
+
+	config.Options.DataRoot = ""
+	config.Flags = []string{"g=/mnt/docker"}
+	content, err = config.DaemonJSON(nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/mnt/docker", unmarshalDaemonJSON(t, content)["graph"])
+}
+
+func TestEngineConfigDaemonJSONDefaultAddressPoolsOlderEngine(t *testing.T) {
+	config := NewEngineConfig(2376, auth.Options{}, engine.Options{
+		DefaultAddressPools: []string{"base=172.80.0.0/16,size=24"},
+	})
+	config.version = engineVersion{18, 3}
+
+	_, err := config.DaemonJSON(nil)
+
+	assert.EqualError(t, err, "Default address pools need Docker 18.06 or later, the engine is 18.03")
+}
+
+func TestParseEngineVersion(t *testing.T) {
+	major, minor, ok := parseEngineVersion("Docker version 1.11.2, build b9f10c9\n")
+	assert.True(t, ok)
+	assert.Equal(t, 1, major)
+	assert.Equal(t, 11, minor)
+
+	major, minor, ok = parseEngineVersion("Docker version 17.03.1-ce, build c6d412e\n")
+	assert.True(t, ok)
+	assert.Equal(t, 17, major)
+	assert.Equal(t, 3, minor)
+
+	_, _, ok = parseEngineVersion("docker: command not found")
+	assert.False(t, ok)
+}
+
+func newEngineConfigTestProvisioner(dockerVersion string) *UbuntuSystemdProvisioner {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"docker --version": dockerVersion,
+		},
+	}
+	p.AuthOptions = auth.Options{
+		CaCertRemotePath:     "/etc/docker/ca.pem",
+		ServerCertRemotePath: "/etc/docker/server.pem",
+		ServerKeyRemotePath:  "/etc/docker/server-key.pem",
+	}
+	p.EngineOptions = engine.Options{
+		StorageDriver: "aufs",
+		Env:           []string{"HTTP_PROXY=http://proxy.local:3128"},
+	}
+	return p
+}
+
+func TestSystemdGenerateDockerOptionsDaemonJSON(t *testing.T) {
+	p := newEngineConfigTestProvisioner("Docker version 17.03.1-ce, build c6d412e\n")
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, dockerCfg.DaemonConfig)
+	assert.Equal(t, "aufs", dockerCfg.DaemonConfig.StorageDriver)
+	assert.Contains(t, dockerCfg.EngineOptions, "ExecStart=/usr/bin/dockerd\n")
+	assert.Contains(t, dockerCfg.EngineOptions, `Environment="HTTP_PROXY=http://proxy.local:3128"`)
+	assert.False(t, strings.Contains(dockerCfg.EngineOptions, "--tlsverify"))
+}
+
+func TestSystemdGenerateDockerOptionsFlags(t *testing.T) {
+	p := newEngineConfigTestProvisioner("Docker version 1.11.2, build b9f10c9\n")
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.Nil(t, dockerCfg.DaemonConfig)
+	assert.Contains(t, dockerCfg.EngineOptions, "ExecStart=/usr/bin/docker daemon -H tcp://0.0.0.0:2376 -H unix:///var/run/docker.sock --storage-driver aufs --tlsverify")
+}
+
+func TestSystemdGenerateDockerOptionsTypedFlags(t *testing.T) {
+	p := newEngineConfigTestProvisioner("Docker version 1.11.2, build b9f10c9\n")
+	p.EngineOptions.LogDriver = "syslog"
+	p.EngineOptions.MTU = 1450
+	p.EngineOptions.ArbitraryFlags = []string{"selinux-enabled"}
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "--log-driver=syslog --mtu=1450 --selinux-enabled \n")
+}
+
+func TestSystemdGenerateDockerOptionsEngineVersion(t *testing.T) {
+	p := newEngineConfigTestProvisioner("Docker version 1.13.1, build 092cba3\n")
+	p.EngineOptions.ArbitraryFlags = []string{"graph=/mnt/docker", "ip6tables"}
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	content, err := dockerCfg.DaemonConfig.DaemonJSON(nil)
+	assert.NoError(t, err)
+	settings := unmarshalDaemonJSON(t, content)
+	assert.Equal(t, "/mnt/docker", settings["graph"])
+	assert.NotContains(t, settings, "data-root")
+	assert.NotContains(t, settings, "ip6tables")
+	assert.Contains(t, dockerCfg.EngineOptions, "ExecStart=/usr/bin/dockerd --ip6tables\n")
+}
+
+func TestSystemdGenerateDockerOptionsTypedFlagsOlderEngine(t *testing.T) {
+	p := newEngineConfigTestProvisioner("Docker version 1.11.2, build b9f10c9\n")
+	p.EngineOptions.DataRoot = "/mnt/docker"
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "--graph=/mnt/docker \n")
+	assert.NotContains(t, dockerCfg.EngineOptions, "--data-root")
+
+	p.EngineOptions.DefaultAddressPools = []string{"base=172.80.0.0/16,size=24"}
+
+	_, err = p.GenerateDockerOptions(2376)
+
+	assert.EqualError(t, err, "Default address pools need Docker 18.06 or later, the engine is 1.11")
+}
+
+func TestSystemdGenerateDockerOptionsCommandLineFlags(t *testing.T) {
+	p := newEngineConfigTestProvisioner("Docker version 17.03.1-ce, build c6d412e\n")
+	p.EngineOptions.ArbitraryFlags = []string{"api-cors-header=*", "mtu=1450"}
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "ExecStart=/usr/bin/dockerd --api-cors-header=*\n")
+}
+
+// The provisioners below configure the engine with flags only, whatever its
+// version, so all the flags given with --engine-opt are on its command line.
+func TestGenerateDockerOptionsFlagsOnly(t *testing.T) {
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"docker --version":              "Docker version 17.06.0-ce, build 02c1d87\n",
+			"sudo rm /etc/sysconfig/docker": "",
+		},
+	}
+	engineOptions := engine.Options{
+		StorageDriver:  "overlay2",
+		ArbitraryFlags: []string{"api-cors-header=*", "selinux-enabled"},
+	}
+
+	boot2docker := NewBoot2DockerProvisioner(&fakedriver.Driver{}).(*Boot2DockerProvisioner)
+	boot2docker.EngineOptions = engineOptions
+	coreos := NewCoreOSProvisioner(&fakedriver.Driver{}).(*CoreOSProvisioner)
+	coreos.SSHCommander = commander
+	coreos.EngineOptions = engineOptions
+	opensuse := NewOpenSUSEProvisioner(&fakedriver.Driver{}).(*SUSEProvisioner)
+	opensuse.SSHCommander = commander
+	opensuse.EngineOptions = engineOptions
+
+	for _, p := range []Provisioner{boot2docker, coreos, opensuse} {
+		dockerCfg, err := p.GenerateDockerOptions(2376)
+
+		if !assert.NoError(t, err, p.String()) {
+			continue
+		}
+		assert.Nil(t, dockerCfg.DaemonConfig, p.String())
+		assert.Contains(t, dockerCfg.EngineOptions, "--api-cors-header=*", p.String())
+		assert.Contains(t, dockerCfg.EngineOptions, "--selinux-enabled", p.String())
+	}
+}
+
+func TestGenericGenerateDockerOptionsDaemonJSON(t *testing.T) {
+	p := NewUbuntuProvisioner(&fakedriver.Driver{}).(*UbuntuProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"docker --version": "Docker version 1.12.6, build 78d1802\n",
+		},
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, dockerCfg.DaemonConfig)
+	assert.Contains(t, dockerCfg.EngineOptions, "DOCKER_OPTS='\n'")
+
+	p.EngineOptions.ArbitraryFlags = []string{"api-cors-header=*", "mtu=1450"}
+
+	dockerCfg, err = p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "DOCKER_OPTS='--api-cors-header=*\n\n'")
+}
+
+func TestWriteDaemonJSON(t *testing.T) {
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo cat /etc/docker/daemon.json 2>/dev/null || true":                                                         `{"debug": true}`,
+			"sudo install -m 644 .docker-machine-daemon.json /etc/docker/daemon.json && rm -f .docker-machine-daemon.json": "",
+		},
+	}
+
+	err := writeDaemonJSON(commander, newTestEngineConfig())
+
+	assert.NoError(t, err)
+	config := unmarshalDaemonJSON(t, []byte(commander.Files[".docker-machine-daemon.json"]))
+	assert.Equal(t, true, config["debug"])
+	assert.Equal(t, "/etc/docker/ca.pem", config["tlscacert"])
+}
@@ -0,0 +1,269 @@
+package provision
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+var dockerVersionRegex = regexp.MustCompile(`(?i)docker version ([^,\s]+)`)
+
+// EngineVersionInstaller is implemented by the provisioners which can
+// install a given version of the engine.
+type EngineVersionInstaller interface {
+	// InstallEngineVersion installs the given version of the engine, which
+	// may be older than the installed one.
+	InstallEngineVersion(version string) error
+}
+
+// EngineVersion returns the version of the engine installed on the machine,
+// e.g. 17.03.1-ce.
+func EngineVersion(p SSHCommander) (string, error) {
+	output, err := p.SSHCommand("docker --version")
+	if err != nil {
+		return "", fmt.Errorf("Error getting the version of the engine: %s", err)
+	}
+
+	matches := dockerVersionRegex.FindStringSubmatch(output)
+	if matches == nil {
+		return "", fmt.Errorf("Error parsing the version of the engine: %q", strings.TrimSpace(output))
+	}
+
+	return matches[1], nil
+}
+
+// InstallEngineVersion installs the given version of the engine, unless
+// it's the one already installed.
+func InstallEngineVersion(p Provisioner, version string) error {
+	installer, ok := p.(EngineVersionInstaller)
+	if !ok {
+		return fmt.Errorf("The %s provisioner can't install a given version of the engine", p.String())
+	}
+
+	if current, err := EngineVersion(p); err != nil {
+		log.Debugf("Installing Docker %s: %s", version, err)
+	} else if EngineVersionMatches(current, version) {
+		log.Debugf("Docker %s is already installed", current)
+		return nil
+	}
+
+	log.Infof("Installing Docker %s...", version)
+	return installer.InstallEngineVersion(version)
+}
+
+// EngineVersionMatches tells whether version, as reported by the engine or
+// the package managers, is the requested one. The edition suffix of the
+// requested version and the packaging suffixes of version are ignored, e.g.
+// 17.03.1~ce-0~ubuntu-xenial matches 17.03.1-ce.
+func EngineVersionMatches(version, requested string) bool {
+	requested = strings.TrimPrefix(requested, "v")
+	if i := strings.IndexAny(requested, "-~_"); i != -1 {
+		requested = requested[:i]
+	}
+	if requested == "" {
+		return false
+	}
+
+	// Drop the epoch of the package managers.
+	if i := strings.Index(version, ":"); i != -1 {
+		version = version[i+1:]
+	}
+	version = strings.TrimPrefix(version, "v")
+
+	if !strings.HasPrefix(version, requested) {
+		return false
+	}
+
+	rest := version[len(requested):]
+	return rest == "" || !strings.ContainsAny(rest[:1], "0123456789")
+}
+
+// packageVersion is a version of a package available from the repositories
+// of a machine.
+type packageVersion struct {
+	name    string
+	version string
+}
+
+// findPackageVersion returns the first of the available packages matching
+// the requested version of the engine.
+func findPackageVersion(available []packageVersion, requested string) (packageVersion, error) {
+	versions := []string{}
+	for _, pkg := range available {
+		if EngineVersionMatches(pkg.version, requested) {
+			return pkg, nil
+		}
+		versions = append(versions, pkg.version)
+	}
+
+	if len(versions) == 0 {
+		return packageVersion{}, fmt.Errorf("Docker %s isn't available: no Docker package was found in the repositories of the machine", requested)
+	}
+
+	return packageVersion{}, fmt.Errorf("Docker %s isn't available from the repositories of the machine, the available versions are: %s", requested, strings.Join(versions, ", "))
+}
+
+// aptInstallEngineVersion installs a version of the docker-ce or
+// docker-engine packages with apt.
+func aptInstallEngineVersion(p SSHCommander, version string) error {
+	if _, err := p.SSHCommand("sudo apt-get update"); err != nil {
+		return err
+	}
+
+	output, err := p.SSHCommand("apt-cache madison docker-ce docker-engine")
+	if err != nil {
+		return fmt.Errorf("Error listing the Docker packages: %s", err)
+	}
+
+	pkg, err := findPackageVersion(parseAptMadison(output), version)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.SSHCommand(fmt.Sprintf("DEBIAN_FRONTEND=noninteractive sudo -E apt-get install -y --force-yes %s=%s", pkg.name, pkg.version))
+	return err
+}
+
+// parseAptMadison parses the output of "apt-cache madison", which has one
+// "name | version | source" line per version, the most recent first.
+func parseAptMadison(output string) []packageVersion {
+	packages := []packageVersion{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 3 {
+			continue
+		}
+		packages = append(packages, packageVersion{strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])})
+	}
+	return packages
+}
+
+// yumInstallEngineVersion installs a version of one of the packages of the
+// engine, e.g. "docker-ce docker-engine", with dnf, or yum where there's no
+// dnf.
+func yumInstallEngineVersion(p SSHCommander, version string, packages string) error {
+	const yum = "$(command -v dnf || command -v yum)"
+
+	output, err := p.SSHCommand(fmt.Sprintf("sudo %s list -q --showduplicates %s", yum, packages))
+	if err != nil {
+		return fmt.Errorf("Error listing the Docker packages: %s", err)
+	}
+
+	pkg, err := findPackageVersion(parseYumList(output), version)
+	if err != nil {
+		return err
+	}
+
+	// install doesn't go back to an older version.
+	spec := pkg.name + "-" + pkg.version
+	_, err = p.SSHCommand(fmt.Sprintf("sudo -E %s install -y %s || sudo -E %s downgrade -y %s", yum, spec, yum, spec))
+	return err
+}
+
+// parseYumList parses the output of "yum list --showduplicates", which has
+// one "name.arch version repository" line per version, the oldest first.
+func parseYumList(output string) []packageVersion {
+	packages := []packageVersion{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || !strings.Contains(fields[0], ".") {
+			continue
+		}
+
+		name := fields[0][:strings.LastIndex(fields[0], ".")]
+		version := fields[1]
+		if i := strings.Index(version, ":"); i != -1 {
+			version = version[i+1:]
+		}
+
+		packages = append([]packageVersion{{name, version}}, packages...)
+	}
+	return packages
+}
+
+// apkInstallEngineVersion installs a version of the docker package with apk.
+func apkInstallEngineVersion(p SSHCommander, version string) error {
+	output, err := p.SSHCommand("sudo apk update >/dev/null && apk policy docker")
+	if err != nil {
+		return fmt.Errorf("Error listing the Docker packages: %s", err)
+	}
+
+	pkg, err := findPackageVersion(parseApkPolicy(output), version)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.SSHCommand(fmt.Sprintf("sudo apk add --no-cache %s=%s", pkg.name, pkg.version))
+	return err
+}
+
+// parseApkPolicy parses the output of "apk policy", which has a "  version:"
+// line per version, followed by the repositories it's in.
+func parseApkPolicy(output string) []packageVersion {
+	packages := []packageVersion{}
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "   ") || !strings.HasSuffix(line, ":") {
+			continue
+		}
+		packages = append(packages, packageVersion{"docker", strings.TrimSuffix(strings.TrimSpace(line), ":")})
+	}
+	return packages
+}
+
+// zypperInstallEngineVersion installs a version of the docker package with
+// zypper.
+func zypperInstallEngineVersion(p SSHCommander, version string) error {
+	output, err := p.SSHCommand("zypper -n search -s --match-exact docker")
+	if err != nil {
+		return fmt.Errorf("Error listing the Docker packages: %s", err)
+	}
+
+	pkg, err := findPackageVersion(parseZypperSearch(output), version)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.SSHCommand(fmt.Sprintf("sudo -E zypper -n install --oldpackage %s=%s", pkg.name, pkg.version))
+	return err
+}
+
+// parseZypperSearch parses the output of "zypper search -s", a table with
+// "status | name | type | version | arch | repository" rows.
+func parseZypperSearch(output string) []packageVersion {
+	packages := []packageVersion{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 6 || strings.TrimSpace(fields[2]) != "package" {
+			continue
+		}
+		packages = append(packages, packageVersion{strings.TrimSpace(fields[1]), strings.TrimSpace(fields[3])})
+	}
+	return packages
+}
+
+// pacmanInstallEngineVersion installs the docker package with pacman. The
+// Arch repositories only have the latest version, so it fails for the
+// others.
+func pacmanInstallEngineVersion(p SSHCommander, version string) error {
+	output, err := p.SSHCommand("sudo pacman -Sy >/dev/null && pacman -Si docker")
+	if err != nil {
+		return fmt.Errorf("Error getting the Docker package: %s", err)
+	}
+
+	available := []packageVersion{}
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == "Version" {
+			available = append(available, packageVersion{"docker", strings.TrimSpace(parts[1])})
+		}
+	}
+
+	if _, err := findPackageVersion(available, version); err != nil {
+		return err
+	}
+
+	_, err = p.SSHCommand("sudo -E pacman -S --noconfirm --noprogressbar docker")
+	return err
+}
@@ -0,0 +1,157 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineVersion(t *testing.T) {
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"docker --version": "Docker version 17.03.1-ce, build c6d412e\n",
+		},
+	}
+
+	version, err := EngineVersion(commander)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "17.03.1-ce", version)
+}
+
+func TestEngineVersionUnparsable(t *testing.T) {
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"docker --version": "docker: command not found\n",
+		},
+	}
+
+	_, err := EngineVersion(commander)
+
+	assert.EqualError(t, err, `Error parsing the version of the engine: "docker: command not found"`)
+}
+
+func TestEngineVersionMatches(t *testing.T) {
+	testCases := []struct {
+		version   string
+		requested string
+		expected  bool
+	}{
+		{"17.03.1-ce", "17.03.1-ce", true},
+		{"17.03.1-ce", "v17.03.1-ce", true},
+		{"17.03.1~ce-0~ubuntu-xenial", "17.03.1-ce", true},
+		{"17.03.1.ce-1.el7.centos", "17.03.1-ce", true},
+		{"1.13.1-0~ubuntu-xenial", "1.13.1", true},
+		{"1:1.13.1-0~ubuntu-xenial", "1.13.1", true},
+		{"1.13.1-0~ubuntu-xenial", "1.13", true},
+		{"1.13.10-0~ubuntu-xenial", "1.13.1", false},
+		{"17.06.0~ce-0~ubuntu", "17.03.1-ce", false},
+		{"17.03.1~ce-0~ubuntu", "", false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, EngineVersionMatches(tc.version, tc.requested), "%s matches %s", tc.version, tc.requested)
+	}
+}
+
+func TestInstallEngineVersionApt(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"docker --version":    "Docker version 17.06.0-ce, build 02c1d87\n",
+			"sudo apt-get update": "",
+			"apt-cache madison docker-ce docker-engine": ` docker-ce | 17.06.0~ce-0~ubuntu | https://download.docker.com/linux/ubuntu xenial/stable amd64 Packages
+ docker-ce | 17.03.1~ce-0~ubuntu-xenial | https://download.docker.com/linux/ubuntu xenial/stable amd64 Packages
+`,
+			"DEBIAN_FRONTEND=noninteractive sudo -E apt-get install -y --force-yes docker-ce=17.03.1~ce-0~ubuntu-xenial": "",
+		},
+	}
+
+	err := InstallEngineVersion(p, "17.03.1-ce")
+
+	assert.NoError(t, err)
+}
+
+func TestInstallEngineVersionAlreadyInstalled(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"docker --version": "Docker version 17.03.1-ce, build c6d412e\n",
+		},
+	}
+
+	err := InstallEngineVersion(p, "17.03.1-ce")
+
+	assert.NoError(t, err)
+}
+
+func TestInstallEngineVersionUnavailable(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"docker --version":    "Docker version 17.06.0-ce, build 02c1d87\n",
+			"sudo apt-get update": "",
+			"apt-cache madison docker-ce docker-engine": ` docker-ce | 17.06.0~ce-0~ubuntu | https://download.docker.com/linux/ubuntu xenial/stable amd64 Packages
+`,
+		},
+	}
+
+	err := InstallEngineVersion(p, "1.12.6")
+
+	assert.EqualError(t, err, "Docker 1.12.6 isn't available from the repositories of the machine, the available versions are: 17.06.0~ce-0~ubuntu")
+}
+
+func TestInstallEngineVersionUnsupported(t *testing.T) {
+	p := NewCoreOSProvisioner(&fakedriver.Driver{})
+
+	err := InstallEngineVersion(p, "17.03.1-ce")
+
+	assert.EqualError(t, err, "The coreOS provisioner can't install a given version of the engine")
+}
+
+func TestParseYumList(t *testing.T) {
+	output := `Available Packages
+docker-ce.x86_64            17.03.0.ce-1.el7.centos             docker-ce-stable
+docker-ce.x86_64            17.03.1.ce-1.el7.centos             docker-ce-stable
+docker-engine.x86_64        1.13.1-1.el7.centos                 docker-main
+`
+
+	assert.Equal(t, []packageVersion{
+		{"docker-engine", "1.13.1-1.el7.centos"},
+		{"docker-ce", "17.03.1.ce-1.el7.centos"},
+		{"docker-ce", "17.03.0.ce-1.el7.centos"},
+	}, parseYumList(output))
+}
+
+func TestParseApkPolicy(t *testing.T) {
+	output := `docker policy:
+  20.10.7-r2:
+    lib/apk/db/installed
+    http://dl-cdn.alpinelinux.org/alpine/v3.14/community
+  20.10.9-r0:
+    http://dl-cdn.alpinelinux.org/alpine/v3.14/community
+`
+
+	assert.Equal(t, []packageVersion{
+		{"docker", "20.10.7-r2"},
+		{"docker", "20.10.9-r0"},
+	}, parseApkPolicy(output))
+}
+
+func TestParseZypperSearch(t *testing.T) {
+	output := `Loading repository data...
+Reading installed packages...
+
+S | Name   | Type    | Version        | Arch   | Repository
+--+--------+---------+----------------+--------+-------------------
+i | docker | package | 17.04.0_ce-1.1 | x86_64 | Virtualization
+v | docker | package | 1.12.6-1.2     | x86_64 | Update
+`
+
+	assert.Equal(t, []packageVersion{
+		{"docker", "17.04.0_ce-1.1"},
+		{"docker", "1.12.6-1.2"},
+	}, parseZypperSearch(output))
+}
@@ -1,6 +1,8 @@
 package provision
 
 import (
+	"os"
+
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
@@ -11,12 +13,18 @@ import (
 
 type FakeDetector struct {
 	Provisioner
+	// Name is the name the detected provisioner is registered under.
+	Name string
 }
 
 func (fd *FakeDetector) DetectProvisioner(d drivers.Driver) (Provisioner, error) {
 	return fd.Provisioner, nil
 }
 
+func (fd *FakeDetector) DetectNamedProvisioner(d drivers.Driver) (string, Provisioner, error) {
+	return fd.Name, fd.Provisioner, nil
+}
+
 type FakeProvisioner struct{}
 
 func NewFakeProvisioner(d drivers.Driver) Provisioner {
@@ -27,6 +35,14 @@ func (fp *FakeProvisioner) SSHCommand(args string) (string, error) {
 	return "", nil
 }
 
+func (fp *FakeProvisioner) SSHWriteFile(path string, content []byte, mode os.FileMode) error {
+	return nil
+}
+
+func (fp *FakeProvisioner) SSHUploadFile(src, dst string) error {
+	return nil
+}
+
 func (fp *FakeProvisioner) String() string {
 	return "fakeprovisioner"
 }
@@ -81,30 +97,7 @@ func (fp *FakeProvisioner) GetOsReleaseInfo() (*OsRelease, error) {
 	return nil, nil
 }
 
-type NetstatProvisioner struct {
-	*FakeProvisioner
-}
-
-func (p *NetstatProvisioner) SSHCommand(args string) (string, error) {
-	return `Active Internet connections (servers and established)
-Proto Recv-Q Send-Q Local Address           Foreign Address         State
-tcp        0      0 0.0.0.0:ssh             0.0.0.0:*               LISTEN
-tcp        0     72 192.168.25.141:ssh      192.168.25.1:63235      ESTABLISHED
-tcp        0      0 :::2376                 :::*                    LISTEN
-tcp        0      0 :::ssh                  :::*                    LISTEN
-Active UNIX domain sockets (servers and established)
-Proto RefCnt Flags       Type       State         I-Node Path
-unix  2      [ ACC ]     STREAM     LISTENING      17990 /var/run/acpid.socket
-unix  2      [ ACC ]     SEQPACKET  LISTENING      14233 /run/udev/control
-unix  2      [ ACC ]     STREAM     LISTENING      19365 /var/run/docker.sock
-unix  3      [ ]         STREAM     CONNECTED      19774
-unix  3      [ ]         STREAM     CONNECTED      19775
-unix  3      [ ]         DGRAM                     14243
-unix  3      [ ]         DGRAM                     14242`, nil
-}
-
-func NewNetstatProvisioner() Provisioner {
-	return &NetstatProvisioner{
-		&FakeProvisioner{},
-	}
+// ProbeDaemon reports the engine as always ready.
+func (fp *FakeProvisioner) ProbeDaemon(authOptions auth.Options, dockerPort int) error {
+	return nil
 }
@@ -3,6 +3,7 @@ package provision
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"text/template"
 
 	"github.com/docker/machine/libmachine/auth"
@@ -32,6 +33,18 @@ func (sshCmder GenericSSHCommander) SSHCommand(args string) (string, error) {
 	return drivers.RunSSHCommandFromDriver(sshCmder.Driver, args)
 }
 
+func (sshCmder GenericSSHCommander) SSHWriteFile(path string, content []byte, mode os.FileMode) error {
+	return drivers.WriteSSHFileFromDriver(sshCmder.Driver, path, content, mode)
+}
+
+func (sshCmder GenericSSHCommander) SSHUploadFile(src, dst string) error {
+	return drivers.UploadSSHFileFromDriver(sshCmder.Driver, src, dst)
+}
+
+func (provisioner *GenericProvisioner) basePackages() []string {
+	return provisioner.Packages
+}
+
 func (provisioner *GenericProvisioner) Hostname() (string, error) {
 	return provisioner.SSHCommand("hostname")
 }
@@ -93,11 +106,11 @@ func (provisioner *GenericProvisioner) GenerateDockerOptions(dockerPort int) (*D
 		engineCfg bytes.Buffer
 	)
 
-	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
-	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	provisioner.EngineOptions.Labels = withDriverLabel(provisioner.EngineOptions.Labels, provisioner.Driver.DriverName())
 
+	// The engines reading daemon.json refuse the flags set there too.
 	engineConfigTmpl := `
-DOCKER_OPTS='
+DOCKER_OPTS='{{ if not .EngineConfig }}
 -H tcp://0.0.0.0:{{.DockerPort}}
 -H unix:///var/run/docker.sock
 --storage-driver {{.EngineOptions.StorageDriver}}
@@ -108,10 +121,12 @@ DOCKER_OPTS='
 {{ range .EngineOptions.Labels }}--label {{.}}
 {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}}
 {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}}
+{{ end }}{{ range .TypedFlags }}--{{.}}
 {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}}
-{{ end }}
+{{ end }}{{ else }}{{ range .EngineConfig.CommandLineFlags }}--{{.}}
+{{ end }}{{ end }}
 '
-{{range .EngineOptions.Env}}export \"{{ printf "%q" . }}\"
+{{range .EngineOptions.Env}}export {{ printf "%q" . }}
 {{end}}
 `
 	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
@@ -119,17 +134,26 @@ DOCKER_OPTS='
 		return nil, err
 	}
 
+	version := installedEngineVersion(provisioner)
+	typedFlags, err := engineTypedFlags(version, provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
 	engineConfigContext := EngineConfigContext{
 		DockerPort:    dockerPort,
 		AuthOptions:   provisioner.AuthOptions,
 		EngineOptions: provisioner.EngineOptions,
+		TypedFlags:    typedFlags,
 	}
+	engineConfigContext.EngineConfig = newDaemonConfig(version, dockerPort, provisioner.AuthOptions, provisioner.EngineOptions)
 
 	t.Execute(&engineCfg, engineConfigContext)
 
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: provisioner.DaemonOptionsFile,
+		DaemonConfig:      engineConfigContext.EngineConfig,
 	}, nil
 }
 
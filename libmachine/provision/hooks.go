@@ -0,0 +1,98 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// The phases of the provisioning at which the hooks run.
+const (
+	// HookPre runs once SSH is available, before anything is installed.
+	HookPre = "pre"
+	// HookPost runs once the engine and Swarm are configured.
+	HookPost = "post"
+)
+
+// HookPhases lists the phases of the provisioning in the order they run.
+var HookPhases = []string{HookPre, HookPost}
+
+// Hook is a script supplied by the user, run as root on the machine during
+// the provisioning.
+type Hook struct {
+	Phase string
+	// Name is the name of the file the script was read from.
+	Name   string
+	Script string
+}
+
+// ValidateHookPhase returns an error if phase isn't one of HookPhases.
+func ValidateHookPhase(phase string) error {
+	for _, p := range HookPhases {
+		if p == phase {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Unknown provisioning hook phase %q, expected one of: %s", phase, strings.Join(HookPhases, ", "))
+}
+
+// RunHooks runs the hooks of the given phase. The scripts are uploaded to
+// the home directory of the SSH user and removed once they're done. They
+// get the name, the IP and the engine port of the machine in the
+// MACHINE_NAME, MACHINE_IP and MACHINE_ENGINE_PORT environment variables.
+func RunHooks(p Provisioner, phase string, hooks []Hook) error {
+	var env string
+
+	for i, hook := range hooks {
+		if hook.Phase != phase {
+			continue
+		}
+
+		if env == "" {
+			var err error
+			if env, err = hookEnv(p); err != nil {
+				return fmt.Errorf("Error running the %s provisioning hooks: %s", phase, err)
+			}
+		}
+
+		log.Infof("Running the %s provisioning hook %s...", phase, hook.Name)
+
+		remotePath := fmt.Sprintf(".docker-machine-hook-%s-%d", phase, i)
+		if err := p.SSHWriteFile(remotePath, []byte(hook.Script), 0700); err != nil {
+			return fmt.Errorf("Error uploading the %s provisioning hook %s: %s", phase, hook.Name, err)
+		}
+
+		// Scripts without a shebang are run by the shell rather than relying
+		// on the way env deals with them.
+		interpreter := ""
+		if !strings.HasPrefix(hook.Script, "#!") {
+			interpreter = "sh "
+		}
+
+		output, err := p.SSHCommand(fmt.Sprintf("%senv %s %s./%s; status=$?; rm -f %s; exit $status", sudoPrefix(p), env, interpreter, remotePath, remotePath))
+		log.Debugf("%s provisioning hook %s output:\n%s", phase, hook.Name, output)
+		if err != nil {
+			return fmt.Errorf("Error running the %s provisioning hook %s: %s\n%s", phase, hook.Name, err, output)
+		}
+	}
+
+	return nil
+}
+
+func hookEnv(p Provisioner) (string, error) {
+	driver := p.GetDriver()
+
+	ip, err := driver.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	port, err := getDockerPort(driver)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("MACHINE_NAME=%s MACHINE_IP=%s MACHINE_ENGINE_PORT=%d", driver.GetMachineName(), ip, port), nil
+}
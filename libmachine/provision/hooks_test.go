@@ -0,0 +1,67 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func newHooksTestProvisioner(responses map[string]string) (Provisioner, *provisiontest.FakeSSHCommander) {
+	commander := &provisiontest.FakeSSHCommander{Responses: responses}
+
+	p := NewDebianProvisioner(&fakedriver.Driver{
+		MockName:  "foo",
+		MockIP:    "10.0.0.2",
+		MockState: state.Running,
+	}).(*DebianProvisioner)
+	p.SSHCommander = commander
+
+	return p, commander
+}
+
+func TestRunHooks(t *testing.T) {
+	p, commander := newHooksTestProvisioner(map[string]string{
+		"sudo env MACHINE_NAME=foo MACHINE_IP=10.0.0.2 MACHINE_ENGINE_PORT=2376 sh ./.docker-machine-hook-pre-0; status=$?; rm -f .docker-machine-hook-pre-0; exit $status": "",
+		"sudo env MACHINE_NAME=foo MACHINE_IP=10.0.0.2 MACHINE_ENGINE_PORT=2376 ./.docker-machine-hook-post-1; status=$?; rm -f .docker-machine-hook-post-1; exit $status":  "",
+	})
+	hooks := []Hook{
+		{Phase: HookPre, Name: "proxy.sh", Script: "echo pre"},
+		{Phase: HookPost, Name: "users.sh", Script: "#!/bin/bash\necho post"},
+	}
+
+	assert.NoError(t, RunHooks(p, HookPre, hooks))
+	assert.Equal(t, map[string]string{".docker-machine-hook-pre-0": "echo pre"}, commander.Files)
+
+	assert.NoError(t, RunHooks(p, HookPost, hooks))
+	assert.Equal(t, "#!/bin/bash\necho post", commander.Files[".docker-machine-hook-post-1"])
+}
+
+func TestRunHooksFailure(t *testing.T) {
+	p, _ := newHooksTestProvisioner(map[string]string{})
+	hooks := []Hook{
+		{Phase: HookPost, Name: "disks.sh", Script: "exit 1"},
+	}
+
+	err := RunHooks(p, HookPost, hooks)
+
+	assert.Contains(t, err.Error(), "Error running the post provisioning hook disks.sh")
+}
+
+func TestRunHooksNoHookForPhase(t *testing.T) {
+	p, commander := newHooksTestProvisioner(map[string]string{})
+	hooks := []Hook{
+		{Phase: HookPost, Name: "disks.sh", Script: "exit 1"},
+	}
+
+	assert.NoError(t, RunHooks(p, HookPre, hooks))
+	assert.Empty(t, commander.Files)
+}
+
+func TestValidateHookPhase(t *testing.T) {
+	assert.NoError(t, ValidateHookPhase("pre"))
+	assert.NoError(t, ValidateHookPhase("post"))
+	assert.EqualError(t, ValidateHookPhase("during"), `Unknown provisioning hook phase "during", expected one of: pre, post`)
+}
@@ -0,0 +1,51 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+)
+
+// OpenRCProvisioner is the base of the provisioners of the distributions
+// whose services are managed by OpenRC.
+type OpenRCProvisioner struct {
+	GenericProvisioner
+}
+
+func NewOpenRCProvisioner(osReleaseID string, d drivers.Driver) OpenRCProvisioner {
+	return OpenRCProvisioner{
+		GenericProvisioner{
+			SSHCommander:      GenericSSHCommander{Driver: d},
+			DockerOptionsDir:  "/etc/docker",
+			DaemonOptionsFile: "/etc/conf.d/docker",
+			OsReleaseID:       osReleaseID,
+			Packages: []string{
+				"curl",
+			},
+			Driver: d,
+		},
+	}
+}
+
+func (p *OpenRCProvisioner) Service(name string, action serviceaction.ServiceAction) error {
+	var command string
+
+	switch action {
+	case serviceaction.Enable:
+		command = fmt.Sprintf("sudo rc-update add %s default", name)
+	case serviceaction.Disable:
+		command = fmt.Sprintf("sudo rc-update del %s default", name)
+	case serviceaction.DaemonReload:
+		// OpenRC reads the configuration of the services when they start.
+		return nil
+	default:
+		command = fmt.Sprintf("sudo rc-service %s %s", name, action.String())
+	}
+
+	if _, err := p.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -170,6 +170,86 @@ BUG_REPORT_URL="https://bugzilla.redhat.com/"
 	}
 }
 
+// The os-release files of the minimal distributions Machine provisions.
+var (
+	alpineOsRelease = []byte(`NAME="Alpine Linux"
+ID=alpine
+VERSION_ID=3.14.2
+PRETTY_NAME="Alpine Linux v3.14"
+HOME_URL="https://alpinelinux.org/"
+BUG_REPORT_URL="https://bugs.alpinelinux.org/"
+`)
+	amazonLinux2OsRelease = []byte(`NAME="Amazon Linux"
+VERSION="2"
+ID="amzn"
+ID_LIKE="centos rhel fedora"
+VERSION_ID="2"
+PRETTY_NAME="Amazon Linux 2"
+ANSI_COLOR="0;33"
+CPE_NAME="cpe:2.3:o:amazon:amazon_linux:2"
+HOME_URL="https://amazonlinux.com/"
+`)
+	rockyOsRelease = []byte(`NAME="Rocky Linux"
+VERSION="8.5 (Green Obsidian)"
+ID="rocky"
+ID_LIKE="rhel centos fedora"
+VERSION_ID="8.5"
+PLATFORM_ID="platform:el8"
+PRETTY_NAME="Rocky Linux 8.5 (Green Obsidian)"
+ANSI_COLOR="0;32"
+CPE_NAME="cpe:/o:rocky:rocky:8.5:GA"
+HOME_URL="https://rockylinux.org/"
+BUG_REPORT_URL="https://bugs.rockylinux.org/"
+`)
+	almaLinuxOsRelease = []byte(`NAME="AlmaLinux"
+VERSION="8.5 (Arctic Sphynx)"
+ID="almalinux"
+ID_LIKE="rhel centos fedora"
+VERSION_ID="8.5"
+PLATFORM_ID="platform:el8"
+PRETTY_NAME="AlmaLinux 8.5 (Arctic Sphynx)"
+ANSI_COLOR="0;34"
+CPE_NAME="cpe:/o:almalinux:almalinux:8::baseos"
+HOME_URL="https://almalinux.org/"
+BUG_REPORT_URL="https://bugs.almalinux.org/"
+`)
+	flatcarOsRelease = []byte(`NAME="Flatcar Container Linux by Kinvolk"
+ID=flatcar
+ID_LIKE=coreos
+VERSION=2905.2.5
+VERSION_ID=2905.2.5
+BUILD_ID=2021-09-30-1634
+PRETTY_NAME="Flatcar Container Linux by Kinvolk 2905.2.5 (Oklo)"
+ANSI_COLOR="38;5;75"
+HOME_URL="https://flatcar-linux.org/"
+BUG_REPORT_URL="https://issues.flatcar-linux.org"
+`)
+)
+
+func TestParseMinimalOsReleases(t *testing.T) {
+	testCases := []struct {
+		content  []byte
+		expected OsRelease
+	}{
+		{alpineOsRelease, OsRelease{Name: "Alpine Linux", ID: "alpine", VersionID: "3.14.2", PrettyName: "Alpine Linux v3.14", HomeURL: "https://alpinelinux.org/", BugReportURL: "https://bugs.alpinelinux.org/"}},
+		{amazonLinux2OsRelease, OsRelease{Name: "Amazon Linux", Version: "2", ID: "amzn", IDLike: "centos rhel fedora", VersionID: "2", PrettyName: "Amazon Linux 2", AnsiColor: "0;33", HomeURL: "https://amazonlinux.com/"}},
+		{rockyOsRelease, OsRelease{Name: "Rocky Linux", Version: "8.5 (Green Obsidian)", ID: "rocky", IDLike: "rhel centos fedora", VersionID: "8.5", PrettyName: "Rocky Linux 8.5 (Green Obsidian)", AnsiColor: "0;32", HomeURL: "https://rockylinux.org/", BugReportURL: "https://bugs.rockylinux.org/"}},
+		{almaLinuxOsRelease, OsRelease{Name: "AlmaLinux", Version: "8.5 (Arctic Sphynx)", ID: "almalinux", IDLike: "rhel centos fedora", VersionID: "8.5", PrettyName: "AlmaLinux 8.5 (Arctic Sphynx)", AnsiColor: "0;34", HomeURL: "https://almalinux.org/", BugReportURL: "https://bugs.almalinux.org/"}},
+		{flatcarOsRelease, OsRelease{Name: "Flatcar Container Linux by Kinvolk", ID: "flatcar", IDLike: "coreos", Version: "2905.2.5", VersionID: "2905.2.5", PrettyName: "Flatcar Container Linux by Kinvolk 2905.2.5 (Oklo)", AnsiColor: "38;5;75", HomeURL: "https://flatcar-linux.org/", BugReportURL: "https://issues.flatcar-linux.org"}},
+	}
+
+	for _, tc := range testCases {
+		osr, err := NewOsRelease(tc.content)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing os release: %s", err)
+		}
+
+		if !reflect.DeepEqual(*osr, tc.expected) {
+			t.Fatalf("Error with %s osr parsing: structs do not match: %+v", tc.expected.ID, *osr)
+		}
+	}
+}
+
 func TestParseLine(t *testing.T) {
 	var (
 		withQuotes    = "ID=\"ubuntu\""
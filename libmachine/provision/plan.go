@@ -0,0 +1,252 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Plan is what provisioning a machine would do, rendered without changing
+// anything on the machine.
+type Plan struct {
+	Driver      string
+	Provisioner string
+	// Packages are the base packages which would be installed.
+	Packages []string
+	// Engine tells how the engine would be installed.
+	Engine     string
+	Hooks      []Hook
+	ServerCert PlannedCert
+	// Files are the configuration files of the engine which would be
+	// written.
+	Files           []PlannedFile
+	SwarmContainers []PlannedContainer
+}
+
+// PlannedCert is a cert which would be issued for the machine.
+type PlannedCert struct {
+	Path       string
+	RemotePath string
+	SANs       []string
+}
+
+// PlannedFile is a file which would be written on the machine.
+type PlannedFile struct {
+	Path    string
+	Current string
+	Content string
+}
+
+// Changed tells whether writing the file would change it.
+func (f PlannedFile) Changed() bool {
+	return f.Current != f.Content
+}
+
+// Diff returns the unified diff from the current content of the file to
+// the planned one.
+func (f PlannedFile) Diff() (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(f.Current),
+		B:        difflib.SplitLines(f.Content),
+		FromFile: f.Path + " (current)",
+		ToFile:   f.Path + " (planned)",
+		Context:  3,
+	})
+}
+
+// PlannedContainer is a Swarm container which would be created on the
+// machine.
+type PlannedContainer struct {
+	Name  string
+	Image string
+	Cmd   []string
+}
+
+// optionsSetter is implemented by the provisioners which set the options
+// they are given before provisioning.
+type optionsSetter interface {
+	setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error
+}
+
+// basePackageLister is implemented by the provisioners which install base
+// packages before the engine.
+type basePackageLister interface {
+	basePackages() []string
+}
+
+// PlanProvisioning renders what provisioning the machine with the given
+// options would do. It only runs read-only commands on the machine.
+func PlanProvisioning(p Provisioner, hooks []Hook, swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) (*Plan, error) {
+	plan := &Plan{
+		Driver:      p.GetDriver().DriverName(),
+		Provisioner: p.String(),
+		Packages:    []string{},
+		Hooks:       hooks,
+	}
+
+	// The certs are configured with their paths on the machine.
+	authOptions = remoteAuthOptions(p.GetDockerOptionsDir(), authOptions)
+
+	if setter, ok := p.(optionsSetter); ok {
+		if err := setter.setOptions(swarmOptions, authOptions, engineOptions); err != nil {
+			return nil, err
+		}
+		authOptions = p.GetAuthOptions()
+	}
+	swarmOptions.Env = engineOptions.Env
+
+	installer, installsPackages := p.(bundleInstaller)
+	if lister, ok := p.(basePackageLister); ok && installsPackages {
+		format := installer.bundlePackageFormat()
+		for _, pkg := range lister.basePackages() {
+			if _, err := p.SSHCommand(fmt.Sprintf("%s %s", format.query, pkg)); err != nil {
+				plan.Packages = append(plan.Packages, pkg)
+			}
+		}
+	}
+
+	if rootless, ok := p.(*RootlessProvisioner); ok {
+		plan.Engine = fmt.Sprintf("rootless, installed with %s", rootlessInstallURL(engineOptions.InstallURL))
+		if rootless.engineInstalled() {
+			plan.Engine = "rootless, already installed"
+		}
+	} else if installsPackages {
+		plan.Engine = planEngineInstall(p, engineOptions)
+	} else {
+		plan.Engine = "shipped with the OS"
+	}
+
+	driver := p.GetDriver()
+	ip, err := driver.GetIP()
+	if err != nil {
+		return nil, err
+	}
+
+	plan.ServerCert = PlannedCert{
+		Path:       authOptions.ServerCertPath,
+		RemotePath: authOptions.ServerCertRemotePath,
+		SANs:       serverCertHosts(authOptions, ip),
+	}
+
+	dockerPort, err := getDockerPort(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	dkrcfg, err := p.GenerateDockerOptions(dockerPort)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := readRemoteFile(p, dkrcfg.EngineOptionsPath)
+	if err != nil {
+		return nil, err
+	}
+	// The options file is written through the shell, the machine has it
+	// expanded.
+	plan.Files = append(plan.Files, PlannedFile{
+		Path:    dkrcfg.EngineOptionsPath,
+		Current: current,
+		Content: shellDoubleQuoted(dkrcfg.EngineOptions),
+	})
+
+	if dkrcfg.DaemonConfig != nil {
+		current, content, err := renderDaemonJSON(p, dkrcfg.DaemonConfig)
+		if err != nil {
+			return nil, err
+		}
+		plan.Files = append(plan.Files, PlannedFile{
+			Path:    daemonConfigPath(p),
+			Current: current,
+			Content: string(content),
+		})
+	}
+
+	if swarmOptions.IsSwarm {
+		_, containers, err := swarmContainers(p, swarmOptions, authOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, container := range containers {
+			plan.SwarmContainers = append(plan.SwarmContainers, PlannedContainer{
+				Name:  container.Name,
+				Image: container.Config.Image,
+				Cmd:   container.Config.Cmd,
+			})
+		}
+	}
+
+	return plan, nil
+}
+
+// PlanCreation renders what creating a machine with the given options would
+// do. Nothing is known about the OS of the machine yet, so the packages and
+// the configuration files aren't planned.
+func PlanCreation(driverName, provisionerName string, hooks []Hook, swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) *Plan {
+	plan := &Plan{
+		Driver:      driverName,
+		Provisioner: provisionerName,
+		Hooks:       hooks,
+		ServerCert: PlannedCert{
+			Path: authOptions.ServerCertPath,
+			SANs: serverCertHosts(authOptions, "<machine IP>"),
+		},
+	}
+	if plan.Provisioner == "" {
+		plan.Provisioner = "detected once the machine is created"
+	}
+
+	switch {
+	case engineOptions.Rootless:
+		plan.Engine = fmt.Sprintf("rootless, installed with %s unless it's installed already", rootlessInstallURL(engineOptions.InstallURL))
+	case engineOptions.InstallBundle != "":
+		plan.Engine = fmt.Sprintf("installed from the bundle %s", engineOptions.InstallBundle)
+	case engineOptions.Version != "":
+		plan.Engine = fmt.Sprintf("%s, installed with %s unless the OS ships the engine", engineOptions.Version, engineOptions.InstallURL)
+	default:
+		plan.Engine = fmt.Sprintf("latest version, installed with %s unless the OS ships the engine", engineOptions.InstallURL)
+	}
+
+	if swarmOptions.IsSwarm {
+		if swarmOptions.LocalDiscovery {
+			plan.SwarmContainers = append(plan.SwarmContainers, PlannedContainer{Name: swarm.DiscoveryContainer, Image: swarm.DiscoveryImage})
+		}
+		if swarmOptions.Master {
+			plan.SwarmContainers = append(plan.SwarmContainers, PlannedContainer{Name: "swarm-agent-master", Image: swarmOptions.Image})
+		}
+		if swarmOptions.Agent {
+			plan.SwarmContainers = append(plan.SwarmContainers, PlannedContainer{Name: "swarm-agent", Image: swarmOptions.Image})
+		}
+	}
+
+	return plan
+}
+
+// planEngineInstall tells how the engine would be installed on a machine
+// provisioned with a package manager.
+func planEngineInstall(p SSHCommander, engineOptions engine.Options) string {
+	if engineOptions.InstallBundle != "" {
+		return fmt.Sprintf("installed from the bundle %s", engineOptions.InstallBundle)
+	}
+
+	current, err := EngineVersion(p)
+	if engineOptions.Version == "" {
+		if err != nil {
+			return fmt.Sprintf("latest version, installed with %s", engineOptions.InstallURL)
+		}
+		return fmt.Sprintf("%s, already installed", current)
+	}
+
+	switch {
+	case err != nil:
+		return fmt.Sprintf("%s, installed with %s", engineOptions.Version, engineOptions.InstallURL)
+	case EngineVersionMatches(current, engineOptions.Version):
+		return fmt.Sprintf("%s, already installed", current)
+	}
+
+	return fmt.Sprintf("%s, replacing %s", engineOptions.Version, current)
+}
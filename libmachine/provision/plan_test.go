@@ -0,0 +1,150 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPlanTestProvisioner() (*UbuntuSystemdProvisioner, *provisiontest.FakeSSHCommander) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{
+		MockName:  "dev",
+		MockIP:    "10.0.0.12",
+		MockState: state.Running,
+	}).(*UbuntuSystemdProvisioner)
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"stat -f -c %T /var/lib": "ext4\n",
+			"docker --version":       "Docker version 17.03.1-ce, build c6d412e\n",
+			"sudo cat /etc/systemd/system/docker.service 2>/dev/null || true": "[Service]\nExecStart=/usr/bin/dockerd\nMountFlags=slave\n",
+			"sudo cat /etc/docker/daemon.json 2>/dev/null || true":            `{"debug": true}`,
+		},
+	}
+	p.SSHCommander = commander
+
+	return p, commander
+}
+
+func TestPlanProvisioning(t *testing.T) {
+	p, commander := newPlanTestProvisioner()
+	hooks := []Hook{{Phase: HookPost, Name: "users.sh", Script: "true"}}
+
+	plan, err := PlanProvisioning(p, hooks, swarm.Options{
+		IsSwarm:   true,
+		Master:    true,
+		Agent:     true,
+		Image:     "swarm:latest",
+		Host:      "tcp://0.0.0.0:3376",
+		Strategy:  "spread",
+		Discovery: "token://abc",
+	}, auth.Options{
+		ServerCertPath: "/machines/dev/server.pem",
+		ServerCertSANs: []string{"dev.example.com"},
+	}, engine.Options{
+		InstallURL: "https://get.docker.com",
+		Labels:     []string{"env=test"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ubuntu(systemd)", plan.Provisioner)
+	assert.Equal(t, []string{"curl"}, plan.Packages)
+	assert.Equal(t, "17.03.1-ce, already installed", plan.Engine)
+	assert.Equal(t, hooks, plan.Hooks)
+	assert.Equal(t, PlannedCert{
+		Path:       "/machines/dev/server.pem",
+		RemotePath: "/etc/docker/server.pem",
+		SANs:       []string{"dev.example.com", "10.0.0.12", "localhost"},
+	}, plan.ServerCert)
+
+	assert.Len(t, plan.Files, 2)
+	assert.Equal(t, "/etc/systemd/system/docker.service", plan.Files[0].Path)
+	assert.True(t, plan.Files[0].Changed())
+	diff, err := plan.Files[0].Diff()
+	assert.NoError(t, err)
+	assert.Contains(t, diff, "--- /etc/systemd/system/docker.service (current)")
+	assert.Contains(t, diff, "+LimitNOFILE=1048576")
+	assert.NotContains(t, diff, "-ExecStart=/usr/bin/dockerd")
+
+	assert.Equal(t, "/etc/docker/daemon.json", plan.Files[1].Path)
+	assert.Contains(t, plan.Files[1].Content, `"debug": true`)
+	assert.Contains(t, plan.Files[1].Content, `"tlscert": "/etc/docker/server.pem"`)
+	assert.Contains(t, plan.Files[1].Content, `"env=test"`)
+
+	assert.Len(t, plan.SwarmContainers, 2)
+	assert.Equal(t, "swarm-agent-master", plan.SwarmContainers[0].Name)
+	assert.Equal(t, "swarm:latest", plan.SwarmContainers[0].Image)
+	assert.Equal(t, "token://abc", plan.SwarmContainers[0].Cmd[len(plan.SwarmContainers[0].Cmd)-1])
+	assert.Equal(t, "swarm-agent", plan.SwarmContainers[1].Name)
+	assert.Equal(t, []string{"join", "--advertise", "10.0.0.12:2376", "token://abc"}, plan.SwarmContainers[1].Cmd)
+
+	assert.Empty(t, commander.Files)
+}
+
+func TestPlanProvisioningWithoutSwarm(t *testing.T) {
+	p, _ := newPlanTestProvisioner()
+
+	plan, err := PlanProvisioning(p, nil, swarm.Options{}, auth.Options{}, engine.Options{
+		InstallURL: "https://get.docker.com",
+		Version:    "17.06.0-ce",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "17.06.0-ce, replacing 17.03.1-ce", plan.Engine)
+	assert.Empty(t, plan.SwarmContainers)
+}
+
+// The options file of CoreOS is escaped for the shell, the machine has it
+// unescaped.
+func TestPlanProvisioningUnchangedOptionsFile(t *testing.T) {
+	p := NewCoreOSProvisioner(&fakedriver.Driver{
+		MockName:  "dev",
+		MockIP:    "10.0.0.12",
+		MockState: state.Running,
+	}).(*CoreOSProvisioner)
+	commander := &offlineSSHCommander{
+		FakeSSHCommander: provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				"docker --version": "Docker version 17.03.1-ce, build c6d412e\n",
+			},
+		},
+	}
+	p.SSHCommander = commander
+	engineOptions := engine.Options{Env: []string{"HTTP_PROXY=http://proxy.local:3128"}}
+
+	assert.NoError(t, p.setOptions(swarm.Options{}, remoteAuthOptions(p.GetDockerOptionsDir(), auth.Options{}), engineOptions))
+	dkrcfg, err := p.GenerateDockerOptions(2376)
+	assert.NoError(t, err)
+	commander.Responses["sudo cat "+dkrcfg.EngineOptionsPath+" 2>/dev/null || true"] = shellDoubleQuoted(dkrcfg.EngineOptions)
+
+	plan, err := PlanProvisioning(p, nil, swarm.Options{}, auth.Options{}, engineOptions)
+
+	assert.NoError(t, err)
+	assert.Equal(t, dkrcfg.EngineOptionsPath, plan.Files[0].Path)
+	assert.Contains(t, plan.Files[0].Content, ` $DOCKER_OPTS `)
+	assert.False(t, plan.Files[0].Changed())
+}
+
+func TestPlannedFileUnchanged(t *testing.T) {
+	file := PlannedFile{Path: "/etc/default/docker", Current: "DOCKER_OPTS=''\n", Content: "DOCKER_OPTS=''\n"}
+
+	diff, err := file.Diff()
+
+	assert.NoError(t, err)
+	assert.False(t, file.Changed())
+	assert.Equal(t, "", strings.TrimSpace(diff))
+}
+
+func TestPlanEngineInstall(t *testing.T) {
+	noDocker := &provisiontest.FakeSSHCommander{}
+
+	assert.Equal(t, "latest version, installed with https://get.docker.com", planEngineInstall(noDocker, engine.Options{InstallURL: "https://get.docker.com"}))
+	assert.Equal(t, "17.03.1-ce, installed with https://get.docker.com", planEngineInstall(noDocker, engine.Options{InstallURL: "https://get.docker.com", Version: "17.03.1-ce"}))
+	assert.Equal(t, "installed from the bundle /tmp/docker.tgz", planEngineInstall(noDocker, engine.Options{InstallBundle: "/tmp/docker.tgz"}))
+}
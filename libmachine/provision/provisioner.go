@@ -2,6 +2,9 @@ package provision
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
@@ -20,12 +23,25 @@ var (
 type SSHCommander interface {
 	// Short-hand for accessing an SSH command from the driver.
 	SSHCommand(args string) (string, error)
+
+	// Short-hand for writing a file on the machine, as the SSH user.
+	SSHWriteFile(path string, content []byte, mode os.FileMode) error
+
+	// Short-hand for copying a local file to the machine, as the SSH user,
+	// without reading it in memory.
+	SSHUploadFile(src, dst string) error
 }
 
 type Detector interface {
 	DetectProvisioner(d drivers.Driver) (Provisioner, error)
 }
 
+// NamedDetector is implemented by the detectors which can tell the name
+// under which the detected provisioner is registered.
+type NamedDetector interface {
+	DetectNamedProvisioner(d drivers.Driver) (string, Provisioner, error)
+}
+
 type StandardDetector struct{}
 
 func SetDetector(newDetector Detector) {
@@ -86,24 +102,93 @@ type Provisioner interface {
 // RegisteredProvisioner creates a new provisioner
 type RegisteredProvisioner struct {
 	New func(d drivers.Driver) Provisioner
+
+	// Priority decides which provisioner is used when several are
+	// compatible with a host: the highest wins, then the first name in
+	// alphabetical order.
+	Priority int
 }
 
 func Register(name string, p *RegisteredProvisioner) {
 	provisioners[name] = p
 }
 
+// ProvisionerNames returns the names of the registered provisioners, in the
+// order they are tried by the detection.
+func ProvisionerNames() []string {
+	names := []string{}
+	for name := range provisioners {
+		names = append(names, name)
+	}
+
+	sort.Sort(byPriority(names))
+	return names
+}
+
+type byPriority []string
+
+func (names byPriority) Len() int      { return len(names) }
+func (names byPriority) Swap(i, j int) { names[i], names[j] = names[j], names[i] }
+func (names byPriority) Less(i, j int) bool {
+	pi, pj := provisioners[names[i]].Priority, provisioners[names[j]].Priority
+	if pi != pj {
+		return pi > pj
+	}
+	return names[i] < names[j]
+}
+
+// ValidateProvisionerName returns an error if no provisioner is registered
+// under name.
+func ValidateProvisionerName(name string) error {
+	if _, ok := provisioners[name]; !ok {
+		return fmt.Errorf("Unknown provisioner %q, expected one of: %s", name, strings.Join(ProvisionerNames(), ", "))
+	}
+	return nil
+}
+
 func DetectProvisioner(d drivers.Driver) (Provisioner, error) {
 	return detector.DetectProvisioner(d)
 }
 
-func (detector StandardDetector) DetectProvisioner(d drivers.Driver) (Provisioner, error) {
+// DetectNamedProvisioner detects the provisioner to use for d, along with
+// the name it's registered under. The name is empty if the detector can't
+// tell it.
+func DetectNamedProvisioner(d drivers.Driver) (string, Provisioner, error) {
+	if namedDetector, ok := detector.(NamedDetector); ok {
+		return namedDetector.DetectNamedProvisioner(d)
+	}
+
+	provisioner, err := detector.DetectProvisioner(d)
+	return "", provisioner, err
+}
+
+// NewProvisioner returns the provisioner registered under name for d,
+// without checking that it's compatible with the host.
+func NewProvisioner(name string, d drivers.Driver) (Provisioner, error) {
+	if err := ValidateProvisionerName(name); err != nil {
+		return nil, err
+	}
+
+	osReleaseInfo, err := getOsReleaseInfo(d)
+	if err != nil {
+		return nil, err
+	}
+
+	provisioner := provisioners[name].New(d)
+	if !compatibleOsRelease(provisioner, osReleaseInfo) {
+		log.Warnf("The %s provisioner doesn't look compatible with %s, using it anyway", name, osReleaseInfo.PrettyName)
+	}
+	provisioner.SetOsReleaseInfo(osReleaseInfo)
+
+	return provisioner, nil
+}
+
+func getOsReleaseInfo(d drivers.Driver) (*OsRelease, error) {
 	log.Info("Waiting for SSH to be available...")
 	if err := drivers.WaitForSSH(d); err != nil {
 		return nil, err
 	}
 
-	log.Info("Detecting the provisioner...")
-
 	osReleaseOut, err := drivers.RunSSHCommandFromDriver(d, "cat /etc/os-release")
 	if err != nil {
 		return nil, fmt.Errorf("Error getting SSH command: %s", err)
@@ -114,15 +199,77 @@ func (detector StandardDetector) DetectProvisioner(d drivers.Driver) (Provisione
 		return nil, fmt.Errorf("Error parsing /etc/os-release file: %s", err)
 	}
 
-	for _, p := range provisioners {
-		provisioner := p.New(d)
-		provisioner.SetOsReleaseInfo(osReleaseInfo)
+	return osReleaseInfo, nil
+}
 
+// osReleaseCandidates returns info followed by copies of info for each of
+// the OSes listed in its ID_LIKE, closest first.
+func osReleaseCandidates(info *OsRelease) []*OsRelease {
+	candidates := []*OsRelease{info}
+	for _, id := range strings.Fields(info.IDLike) {
+		like := *info
+		like.ID = id
+		candidates = append(candidates, &like)
+	}
+	return candidates
+}
+
+// compatibleOsRelease tells whether provisioner is compatible with the OS
+// described by info or with one of the OSes it's like.
+func compatibleOsRelease(provisioner Provisioner, info *OsRelease) bool {
+	for _, candidate := range osReleaseCandidates(info) {
+		provisioner.SetOsReleaseInfo(candidate)
 		if provisioner.CompatibleWithHost() {
-			log.Debugf("found compatible host: %s", osReleaseInfo.ID)
-			return provisioner, nil
+			return true
+		}
+	}
+
+	return false
+}
+
+func (detector StandardDetector) DetectProvisioner(d drivers.Driver) (Provisioner, error) {
+	_, provisioner, err := detector.DetectNamedProvisioner(d)
+	return provisioner, err
+}
+
+// DetectNamedProvisioner tries the provisioners by order of priority, first
+// for the ID of the OS and then for each of the IDs in its ID_LIKE.
+func (detector StandardDetector) DetectNamedProvisioner(d drivers.Driver) (string, Provisioner, error) {
+	osReleaseInfo, err := getOsReleaseInfo(d)
+	if err != nil {
+		return "", nil, err
+	}
+
+	log.Info("Detecting the provisioner...")
+
+	name, provisioner := selectProvisioner(d, osReleaseInfo)
+	if provisioner == nil {
+		return "", nil, ErrDetectionFailed
+	}
+
+	return name, provisioner, nil
+}
+
+func selectProvisioner(d drivers.Driver, osReleaseInfo *OsRelease) (string, Provisioner) {
+	for _, candidate := range osReleaseCandidates(osReleaseInfo) {
+		for _, name := range ProvisionerNames() {
+			provisioner := provisioners[name].New(d)
+			provisioner.SetOsReleaseInfo(candidate)
+
+			if !provisioner.CompatibleWithHost() {
+				continue
+			}
+
+			if candidate == osReleaseInfo {
+				log.Debugf("found compatible host: %s", osReleaseInfo.ID)
+			} else {
+				log.Infof("No provisioner for %s, using the %s provisioner since it's like %s", osReleaseInfo.ID, name, candidate.ID)
+			}
+
+			provisioner.SetOsReleaseInfo(osReleaseInfo)
+			return name, provisioner
 		}
 	}
 
-	return nil, ErrDetectionFailed
+	return "", nil
 }
@@ -0,0 +1,74 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvisionerNamesOrder(t *testing.T) {
+	names := ProvisionerNames()
+
+	assert.Equal(t, "Ubuntu-SystemD", names[0])
+	for i := 2; i < len(names); i++ {
+		assert.True(t, names[i-1] < names[i], "%s should be before %s", names[i-1], names[i])
+	}
+}
+
+func TestValidateProvisionerName(t *testing.T) {
+	assert.NoError(t, ValidateProvisionerName("Debian"))
+
+	err := ValidateProvisionerName("debian")
+	assert.EqualError(t, err, `Unknown provisioner "debian", expected one of: `+strings.Join(ProvisionerNames(), ", "))
+}
+
+func TestSelectProvisionerForMinimalOsReleases(t *testing.T) {
+	testCases := map[string][]byte{
+		"Alpine":      alpineOsRelease,
+		"AmazonLinux": amazonLinux2OsRelease,
+		"Rocky":       rockyOsRelease,
+		"AlmaLinux":   almaLinuxOsRelease,
+		"CoreOS":      flatcarOsRelease,
+	}
+
+	for expectedName, content := range testCases {
+		info, err := NewOsRelease(content)
+		assert.NoError(t, err)
+
+		name, provisioner := selectProvisioner(nil, info)
+
+		assert.Equal(t, expectedName, name, "ID=%s", info.ID)
+		assert.NotNil(t, provisioner)
+	}
+}
+
+func TestSelectProvisioner(t *testing.T) {
+	testCases := []struct {
+		info         *OsRelease
+		expectedName string
+	}{
+		{&OsRelease{ID: "ubuntu", VersionID: "16.04"}, "Ubuntu-SystemD"},
+		{&OsRelease{ID: "ubuntu", VersionID: "14.04"}, "Ubuntu-UpStart"},
+		{&OsRelease{ID: "debian", VersionID: "9"}, "Debian"},
+		{&OsRelease{ID: "raspbian", IDLike: "debian", VersionID: "9"}, "Debian"},
+		{&OsRelease{ID: "linuxmint", IDLike: "ubuntu debian", VersionID: "18.3"}, "Ubuntu-SystemD"},
+		{&OsRelease{ID: "ol", IDLike: "fedora", VersionID: "7.5"}, "OracleLinux"},
+		{&OsRelease{ID: "unknown", IDLike: "unknown-too"}, ""},
+	}
+
+	for _, tc := range testCases {
+		name, provisioner := selectProvisioner(nil, tc.info)
+
+		assert.Equal(t, tc.expectedName, name, "ID=%s ID_LIKE=%s", tc.info.ID, tc.info.IDLike)
+		if tc.expectedName == "" {
+			assert.Nil(t, provisioner)
+			continue
+		}
+
+		// The provisioner keeps the real OS, not the one it's like.
+		info, err := provisioner.GetOsReleaseInfo()
+		assert.NoError(t, err)
+		assert.Equal(t, tc.info, info)
+	}
+}
@@ -1,7 +1,11 @@
 //Package provisiontest provides utilities for testing provisioners
 package provisiontest
 
-import "errors"
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+)
 
 //FakeSSHCommanderOptions is intended to create a FakeSSHCommander without actually knowing the underlying sshcommands by passing it to NewSSHCommander
 type FakeSSHCommanderOptions struct {
@@ -13,6 +17,8 @@ type FakeSSHCommanderOptions struct {
 //Extend it when needed
 type FakeSSHCommander struct {
 	Responses map[string]string
+	//Files holds the content of the files written through SSHWriteFile
+	Files map[string]string
 }
 
 //NewFakeSSHCommander creates a FakeSSHCommander without actually knowing the underlying sshcommands
@@ -37,3 +43,21 @@ func (sshCmder *FakeSSHCommander) SSHCommand(args string) (string, error) {
 	}
 	return response, nil
 }
+
+//SSHWriteFile is an implementation of provision.SSHCommander.SSHWriteFile which records the written files
+func (sshCmder *FakeSSHCommander) SSHWriteFile(path string, content []byte, mode os.FileMode) error {
+	if sshCmder.Files == nil {
+		sshCmder.Files = map[string]string{}
+	}
+	sshCmder.Files[path] = string(content)
+	return nil
+}
+
+//SSHUploadFile is an implementation of provision.SSHCommander.SSHUploadFile which records the uploaded files along with the written ones
+func (sshCmder *FakeSSHCommander) SSHUploadFile(src, dst string) error {
+	content, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return sshCmder.SSHWriteFile(dst, content, 0600)
+}
@@ -92,11 +92,12 @@ func (provisioner *RancherProvisioner) Package(name string, action pkgaction.Pac
 	return nil
 }
 
-func (provisioner *RancherProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+// setOptions sets the options of the provisioning, and the storage driver
+// of the engine when none was given.
+func (provisioner *RancherProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
 
 	if provisioner.EngineOptions.StorageDriver == "" {
 		provisioner.EngineOptions.StorageDriver = "overlay"
@@ -104,32 +105,32 @@ func (provisioner *RancherProvisioner) Provision(swarmOptions swarm.Options, aut
 		return fmt.Errorf("Unsupported storage driver: %s", provisioner.EngineOptions.StorageDriver)
 	}
 
-	log.Debugf("Setting hostname %s", provisioner.Driver.GetMachineName())
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
-		return err
-	}
-
-	for _, pkg := range provisioner.Packages {
-		log.Debugf("Installing package %s", pkg)
-		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
-			return err
-		}
-	}
-
-	log.Debugf("Preparing certificates")
-	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+	return nil
+}
 
-	log.Debugf("Setting up certificates")
-	if err := ConfigureAuth(provisioner); err != nil {
+func (provisioner *RancherProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	steps, err := provisioner.Steps(swarmOptions, authOptions, engineOptions)
+	if err != nil {
 		return err
 	}
 
-	log.Debugf("Configuring swarm")
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
-		return err
+	return ApplySteps(steps)
+}
+
+// Steps returns the steps of the provisioning.
+func (provisioner *RancherProvisioner) Steps(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) ([]Step, error) {
+	if err := provisioner.setOptions(swarmOptions, authOptions, engineOptions); err != nil {
+		return nil, err
 	}
+	swarmOptions.Env = engineOptions.Env
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	return nil
+	return []Step{
+		hostnameStep(provisioner),
+		packagesStep(provisioner, provisioner.Packages),
+		authStep(provisioner),
+		swarmStep(provisioner, swarmOptions, provisioner.AuthOptions),
+	}, nil
 }
 
 func (provisioner *RancherProvisioner) SetHostname(hostname string) error {
@@ -33,7 +33,7 @@ After=network.target
 
 [Service]
 Type=notify
-ExecStart=/usr/bin/docker daemon -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --storage-driver {{.EngineOptions.StorageDriver}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}
+ExecStart={{ if .EngineConfig }}/usr/bin/dockerd{{ range .EngineConfig.CommandLineFlags }} --{{.}}{{ end }}{{ else }}/usr/bin/docker daemon -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --storage-driver {{.EngineOptions.StorageDriver}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .TypedFlags }}--{{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}{{ end }}
 ExecReload=/bin/kill -s HUP $MAINPID
 MountFlags=slave
 LimitNOFILE=infinity
@@ -68,12 +68,22 @@ func NewRedHatProvisioner(osReleaseID string, d drivers.Driver) *RedHatProvision
 	systemdProvisioner := NewSystemdProvisioner(osReleaseID, d)
 	systemdProvisioner.SSHCommander = RedHatSSHCommander{Driver: d}
 	return &RedHatProvisioner{
-		systemdProvisioner,
+		SystemdProvisioner:   systemdProvisioner,
+		defaultStorageDriver: "devicemapper",
+		enginePackages:       "docker-ce docker-engine",
 	}
 }
 
 type RedHatProvisioner struct {
 	SystemdProvisioner
+	// defaultStorageDriver is used when no storage driver is given.
+	defaultStorageDriver string
+	// engineInstallCommand installs the engine instead of the install
+	// script, for the distributions the script doesn't support.
+	engineInstallCommand string
+	// enginePackages are the names of the packages of the engine, which a
+	// version of the engine is looked up in.
+	enginePackages string
 }
 
 func (provisioner *RedHatProvisioner) String() string {
@@ -104,6 +114,10 @@ func (provisioner *RedHatProvisioner) SetHostname(hostname string) error {
 }
 
 func (provisioner *RedHatProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	if provisioner.EngineOptions.InstallBundle != "" {
+		return bundlePackage(provisioner, provisioner.EngineOptions.InstallBundle, name, action)
+	}
+
 	var packageAction string
 
 	switch action {
@@ -124,9 +138,31 @@ func (provisioner *RedHatProvisioner) Package(name string, action pkgaction.Pack
 	return nil
 }
 
+func (provisioner *RedHatProvisioner) InstallEngineVersion(version string) error {
+	return yumInstallEngineVersion(provisioner, version, provisioner.enginePackages)
+}
+
+func (provisioner *RedHatProvisioner) bundlePackageFormat() bundlePackageFormat {
+	return rpmBundlePackages
+}
+
 func installDocker(provisioner *RedHatProvisioner) error {
-	if err := installDockerGeneric(provisioner, provisioner.EngineOptions.InstallURL); err != nil {
-		return err
+	engineOptions := provisioner.EngineOptions
+
+	if engineOptions.InstallBundle != "" || provisioner.engineInstallCommand == "" {
+		if err := installDockerGeneric(provisioner, engineOptions); err != nil {
+			return err
+		}
+	} else {
+		if output, err := provisioner.SSHCommand(fmt.Sprintf("if ! type docker; then %s; fi", provisioner.engineInstallCommand)); err != nil {
+			return fmt.Errorf("error installing docker: %s\n", output)
+		}
+
+		if engineOptions.Version != "" {
+			if err := InstallEngineVersion(provisioner, engineOptions.Version); err != nil {
+				return err
+			}
+		}
 	}
 
 	if err := provisioner.Service("docker", serviceaction.Restart); err != nil {
@@ -153,59 +189,61 @@ func (provisioner *RedHatProvisioner) dockerDaemonResponding() bool {
 	return true
 }
 
-func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+// setOptions sets the options of the provisioning, and the storage driver
+// of the engine when none was given.
+func (provisioner *RedHatProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
 
 	// set default storage driver for redhat
-	storageDriver, err := decideStorageDriver(provisioner, "devicemapper", engineOptions.StorageDriver)
+	storageDriver, err := decideStorageDriver(provisioner, provisioner.defaultStorageDriver, engineOptions.StorageDriver)
 	if err != nil {
 		return err
 	}
 	provisioner.EngineOptions.StorageDriver = storageDriver
 
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
-		return err
-	}
-
-	for _, pkg := range provisioner.Packages {
-		log.Debugf("installing base package: name=%s", pkg)
-		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
-			return err
-		}
-	}
-
-	// update OS -- this is needed for libdevicemapper and the docker install
-	if _, err := provisioner.SSHCommand("sudo -E yum -y update"); err != nil {
-		return err
-	}
+	return nil
+}
 
-	// install docker
-	if err := installDocker(provisioner); err != nil {
+func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	steps, err := provisioner.Steps(swarmOptions, authOptions, engineOptions)
+	if err != nil {
 		return err
 	}
 
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
-		return err
-	}
+	return ApplySteps(steps)
+}
 
-	if err := makeDockerOptionsDir(provisioner); err != nil {
-		return err
+// Steps returns the steps of the provisioning.
+func (provisioner *RedHatProvisioner) Steps(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) ([]Step, error) {
+	if err := provisioner.setOptions(swarmOptions, authOptions, engineOptions); err != nil {
+		return nil, err
 	}
-
+	swarmOptions.Env = engineOptions.Env
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	if err := ConfigureAuth(provisioner); err != nil {
-		return err
-	}
-
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
-		return err
-	}
-
-	return nil
+	return []Step{
+		hostnameStep(provisioner),
+		packagesStep(provisioner, provisioner.Packages),
+		engineStep(provisioner, engineOptions, func() error {
+			// update OS -- this is needed for libdevicemapper and the docker
+			// install, but not with a bundle which holds what's needed
+			if engineOptions.InstallBundle == "" {
+				if _, err := provisioner.SSHCommand("sudo -E yum -y update"); err != nil {
+					return err
+				}
+			}
+
+			if err := installDocker(provisioner); err != nil {
+				return err
+			}
+
+			return mcnutils.WaitFor(provisioner.dockerDaemonResponding)
+		}),
+		authStep(provisioner),
+		swarmStep(provisioner, swarmOptions, provisioner.AuthOptions),
+	}, nil
 }
 
 func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
@@ -214,8 +252,7 @@ func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*Do
 		configPath = provisioner.DaemonOptionsFile
 	)
 
-	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
-	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	provisioner.EngineOptions.Labels = withDriverLabel(provisioner.EngineOptions.Labels, provisioner.Driver.DriverName())
 
 	// systemd / redhat will not load options if they are on newlines
 	// instead, it just continues with a different set of options; yeah...
@@ -224,12 +261,22 @@ func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*Do
 		return nil, err
 	}
 
+	version := installedEngineVersion(provisioner)
+	typedFlags, err := engineTypedFlags(version, provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
 	engineConfigContext := EngineConfigContext{
 		DockerPort:       dockerPort,
 		AuthOptions:      provisioner.AuthOptions,
 		EngineOptions:    provisioner.EngineOptions,
 		DockerOptionsDir: provisioner.DockerOptionsDir,
+		TypedFlags:       typedFlags,
 	}
+	// The engines reading daemon.json are started with dockerd, they no
+	// longer have the docker daemon command.
+	engineConfigContext.EngineConfig = newDaemonConfig(version, dockerPort, provisioner.AuthOptions, provisioner.EngineOptions)
 
 	t.Execute(&engineCfg, engineConfigContext)
 
@@ -237,5 +284,6 @@ func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*Do
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: daemonOptsDir,
+		DaemonConfig:      engineConfigContext.EngineConfig,
 	}, nil
 }
@@ -2,7 +2,9 @@ package provision
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/docker/machine/libmachine/audit"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/ssh"
@@ -24,6 +26,8 @@ func (sshCmder RedHatSSHCommander) SSHCommand(args string) (string, error) {
 	// external client and add as needed.
 	// Note: CentOS 7.0 needs multiple "-tt" to force tty allocation when ssh has
 	// no local tty.
+	done := audit.Start(sshCmder.Driver.GetMachineName(), audit.TypeCommand, args)
+
 	var output string
 	switch c := client.(type) {
 	case *ssh.ExternalClient:
@@ -32,6 +36,7 @@ func (sshCmder RedHatSSHCommander) SSHCommand(args string) (string, error) {
 	case *ssh.NativeClient:
 		output, err = c.OutputWithPty(args)
 	}
+	done(output, err)
 
 	log.Debugf("SSH cmd err, output: %v: %s", err, output)
 	if err != nil {
@@ -44,3 +49,11 @@ output  : %s
 
 	return output, nil
 }
+
+func (sshCmder RedHatSSHCommander) SSHWriteFile(path string, content []byte, mode os.FileMode) error {
+	return drivers.WriteSSHFileFromDriver(sshCmder.Driver, path, content, mode)
+}
+
+func (sshCmder RedHatSSHCommander) SSHUploadFile(src, dst string) error {
+	return drivers.UploadSSHFileFromDriver(sshCmder.Driver, src, dst)
+}
@@ -0,0 +1,150 @@
+package provision
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/registry"
+)
+
+const (
+	registryCertsDir   = "/etc/docker/certs.d"
+	registryAuthConfig = "/root/.docker/config.json"
+)
+
+// registryPaths returns the directory the engine reads the CA certs of the
+// registries from, and the client config of the user the engine runs as.
+func registryPaths(p Provisioner) (string, string, error) {
+	rootless, ok := p.(*RootlessProvisioner)
+	if !ok {
+		return registryCertsDir, registryAuthConfig, nil
+	}
+
+	if err := rootless.readUserDirs(); err != nil {
+		return "", "", err
+	}
+
+	return path.Join(rootless.Home, rootlessDockerDir, "certs.d"), path.Join(rootless.Home, ".docker", "config.json"), nil
+}
+
+// ConfigureRegistries installs the CA certs of the registries in the certs
+// directory of the engine, and logs the user the engine runs as in to the
+// registries, reading the credentials from their sources.
+func ConfigureRegistries(p Provisioner, engineOptions engine.Options) error {
+	if len(engineOptions.RegistryCA) == 0 && len(engineOptions.RegistryAuth) == 0 {
+		return nil
+	}
+
+	certsDir, configPath, err := registryPaths(p)
+	if err != nil {
+		return err
+	}
+
+	for _, option := range engineOptions.RegistryCA {
+		name, pemPath, err := registry.ParseOption(option)
+		if err != nil {
+			return err
+		}
+
+		caCert, err := ioutil.ReadFile(pemPath)
+		if err != nil {
+			return fmt.Errorf("Unable to read the CA cert of %s: %s", name, err)
+		}
+
+		log.Infof("Installing the CA cert of the %s registry...", name)
+
+		dir := path.Join(certsDir, name)
+		if _, err := p.SSHCommand(fmt.Sprintf("%smkdir -p %s", sudoPrefix(p), dir)); err != nil {
+			return err
+		}
+		if err := writeRemoteFile(p, path.Join(dir, "ca.crt"), caCert, 0644); err != nil {
+			return err
+		}
+	}
+
+	if len(engineOptions.RegistryAuth) == 0 {
+		return nil
+	}
+
+	auths := map[string]registry.Credentials{}
+	for _, option := range engineOptions.RegistryAuth {
+		name, source, err := registry.ParseOption(option)
+		if err != nil {
+			return err
+		}
+
+		if auths[name], err = registry.ReadCredentials(name, source); err != nil {
+			return err
+		}
+	}
+
+	current, err := readRemoteFile(p, configPath)
+	if err != nil {
+		return err
+	}
+
+	content, err := mergeRegistryAuths(current, auths)
+	if err != nil {
+		return fmt.Errorf("Unable to update %s: %s", configPath, err)
+	}
+
+	log.Info("Logging in to the registries...")
+
+	if _, err := p.SSHCommand(fmt.Sprintf("%smkdir -p %s", sudoPrefix(p), path.Dir(configPath))); err != nil {
+		return err
+	}
+
+	return writeRemoteFile(p, configPath, content, 0600)
+}
+
+// mergeRegistryAuths sets the credentials of the registries in the client
+// config, keeping the rest of it as is. The CLI ignores auths for the
+// registries it has a credential helper for, with credsStore or credHelpers,
+// so an empty helper is set for the registries to read their auths instead.
+func mergeRegistryAuths(current string, auths map[string]registry.Credentials) ([]byte, error) {
+	config := map[string]interface{}{}
+	if strings.TrimSpace(current) != "" {
+		if err := json.Unmarshal([]byte(current), &config); err != nil {
+			return nil, err
+		}
+	}
+
+	configAuths, _ := config["auths"].(map[string]interface{})
+	if configAuths == nil {
+		configAuths = map[string]interface{}{}
+	}
+
+	credsStore, _ := config["credsStore"].(string)
+	credHelpers, _ := config["credHelpers"].(map[string]interface{})
+
+	for name, credentials := range auths {
+		configAuths[name] = map[string]string{
+			"auth": base64.StdEncoding.EncodeToString([]byte(credentials.Username + ":" + credentials.Secret)),
+		}
+
+		helper, found := credHelpers[name].(string)
+		if !found {
+			helper = credsStore
+		}
+		if helper != "" {
+			log.Warnf("The client config of the machine uses the %s credential helper for the %s registry, it's set to use the credentials given with --registry-auth instead", helper, name)
+
+			if credHelpers == nil {
+				credHelpers = map[string]interface{}{}
+			}
+			credHelpers[name] = ""
+		}
+	}
+	config["auths"] = configAuths
+	if credHelpers != nil {
+		config["credHelpers"] = credHelpers
+	}
+
+	return json.MarshalIndent(config, "", "\t")
+}
@@ -0,0 +1,99 @@
+package provision
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigureRegistries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	caPath := filepath.Join(dir, "corp-ca.pem")
+	assert.NoError(t, ioutil.WriteFile(caPath, []byte("CA"), 0644))
+
+	os.Setenv("MACHINE_TEST_REGISTRY_AUTH", "deploy:s3cret")
+	defer os.Unsetenv("MACHINE_TEST_REGISTRY_AUTH")
+
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo mkdir -p /etc/docker/certs.d/registry.corp:5000":                                                                     "",
+			"sudo install -m 644 .docker-machine-ca.crt /etc/docker/certs.d/registry.corp:5000/ca.crt && rm -f .docker-machine-ca.crt": "",
+			"sudo cat /root/.docker/config.json 2>/dev/null || true":                                                                   `{"auths":{"other.io":{"auth":"b3RoZXI="}},"detachKeys":"ctrl-x"}`,
+			"sudo mkdir -p /root/.docker":                                                                                              "",
+			"sudo install -m 600 .docker-machine-config.json /root/.docker/config.json && rm -f .docker-machine-config.json":           "",
+		},
+	}
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+
+	err = ConfigureRegistries(p, engine.Options{
+		RegistryCA:   []string{"registry.corp:5000=" + caPath},
+		RegistryAuth: []string{"registry.corp:5000=env:MACHINE_TEST_REGISTRY_AUTH"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "CA", commander.Files[".docker-machine-ca.crt"])
+	assert.JSONEq(t, `{
+		"auths": {
+			"other.io": {"auth": "b3RoZXI="},
+			"registry.corp:5000": {"auth": "ZGVwbG95OnMzY3JldA=="}
+		},
+		"detachKeys": "ctrl-x"
+	}`, commander.Files[".docker-machine-config.json"])
+}
+
+func TestMergeRegistryAuthsWithCredentialHelpers(t *testing.T) {
+	auths := map[string]registry.Credentials{
+		"registry.corp:5000": {Username: "deploy", Secret: "s3cret"},
+		"ghcr.io":            {Username: "bot", Secret: "token"},
+	}
+
+	content, err := mergeRegistryAuths(`{"credsStore":"secretservice","credHelpers":{"ghcr.io":"pass","gcr.io":"gcloud"}}`, auths)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"auths": {
+			"ghcr.io": {"auth": "Ym90OnRva2Vu"},
+			"registry.corp:5000": {"auth": "ZGVwbG95OnMzY3JldA=="}
+		},
+		"credsStore": "secretservice",
+		"credHelpers": {"ghcr.io": "", "gcr.io": "gcloud", "registry.corp:5000": ""}
+	}`, string(content))
+
+	content, err = mergeRegistryAuths(`{}`, auths)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "credHelpers")
+}
+
+func TestConfigureRegistriesRootless(t *testing.T) {
+	os.Setenv("MACHINE_TEST_REGISTRY_AUTH", "deploy:s3cret")
+	defer os.Unsetenv("MACHINE_TEST_REGISTRY_AUTH")
+
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			rootlessDirsCommand: "/home/dev\n/run/user/1000\n",
+			"cat /home/dev/.docker/config.json 2>/dev/null || true": "",
+			"mkdir -p /home/dev/.docker":                            "",
+			"install -m 600 .docker-machine-config.json /home/dev/.docker/config.json && rm -f .docker-machine-config.json": "",
+		},
+	}
+	p := newTestRootlessProvisioner(commander)
+
+	err := ConfigureRegistries(p, engine.Options{
+		RegistryAuth: []string{"registry.corp=env:MACHINE_TEST_REGISTRY_AUTH"},
+	})
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"auths": {"registry.corp": {"auth": "ZGVwbG95OnMzY3JldA=="}}}`, commander.Files[".docker-machine-config.json"])
+}
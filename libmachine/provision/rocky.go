@@ -0,0 +1,32 @@
+package provision
+
+import (
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// centosRepoInstallCommand installs the engine from the CentOS repository of
+// Docker, for the distributions rebuilt from RHEL which the install script
+// doesn't support.
+const centosRepoInstallCommand = "sudo dnf config-manager --add-repo https://download.docker.com/linux/centos/docker-ce.repo && sudo dnf install -y docker-ce docker-ce-cli containerd.io"
+
+func init() {
+	Register("Rocky", &RegisteredProvisioner{
+		New: NewRockyProvisioner,
+	})
+}
+
+func NewRockyProvisioner(d drivers.Driver) Provisioner {
+	p := NewRedHatProvisioner("rocky", d)
+	p.defaultStorageDriver = "overlay2"
+	p.engineInstallCommand = centosRepoInstallCommand
+
+	return &RockyProvisioner{p}
+}
+
+type RockyProvisioner struct {
+	*RedHatProvisioner
+}
+
+func (provisioner *RockyProvisioner) String() string {
+	return "rocky"
+}
@@ -0,0 +1,63 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRockyDefaultStorageDriver(t *testing.T) {
+	p := NewRockyProvisioner(&fakedriver.Driver{}).(*RockyProvisioner)
+	p.SSHCommander = provisiontest.NewFakeSSHCommander(provisiontest.FakeSSHCommanderOptions{})
+	p.Provision(swarm.Options{}, auth.Options{}, engine.Options{})
+	if p.EngineOptions.StorageDriver != "overlay2" {
+		t.Fatal("Default storage driver should be overlay2")
+	}
+}
+
+func TestRockyAndAlmaLinuxInstallDockerFromCentOSRepository(t *testing.T) {
+	for _, p := range []*RedHatProvisioner{
+		NewRockyProvisioner(&fakedriver.Driver{}).(*RockyProvisioner).RedHatProvisioner,
+		NewAlmaLinuxProvisioner(&fakedriver.Driver{}).(*AlmaLinuxProvisioner).RedHatProvisioner,
+	} {
+		p.SSHCommander = &provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				"if ! type docker; then " + centosRepoInstallCommand + "; fi": "",
+				"sudo systemctl daemon-reload":                                "",
+				"sudo systemctl -f restart docker":                            "",
+				"sudo systemctl -f enable docker":                             "",
+			},
+		}
+
+		assert.NoError(t, installDocker(p), p.OsReleaseID)
+	}
+}
+
+func TestRockyAndAlmaLinuxGenerateDockerOptions(t *testing.T) {
+	for _, p := range []*RedHatProvisioner{
+		NewRockyProvisioner(&fakedriver.Driver{}).(*RockyProvisioner).RedHatProvisioner,
+		NewAlmaLinuxProvisioner(&fakedriver.Driver{}).(*AlmaLinuxProvisioner).RedHatProvisioner,
+	} {
+		p.SSHCommander = &provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				"docker --version": "Docker version 20.10.21, build baeda1f\n",
+			},
+		}
+		p.EngineOptions = engine.Options{
+			StorageDriver:  "overlay2",
+			ArbitraryFlags: []string{"api-cors-header=*"},
+		}
+
+		dockerCfg, err := p.GenerateDockerOptions(2376)
+
+		assert.NoError(t, err, p.OsReleaseID)
+		assert.NotNil(t, dockerCfg.DaemonConfig, p.OsReleaseID)
+		assert.Contains(t, dockerCfg.EngineOptions, "ExecStart=/usr/bin/dockerd --api-cors-header=*\n", p.OsReleaseID)
+		assert.NotContains(t, dockerCfg.EngineOptions, "docker daemon", p.OsReleaseID)
+	}
+}
@@ -0,0 +1,342 @@
+package provision
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+const (
+	// rootlessDockerDir holds the config and the certs of a rootless engine,
+	// in the home directory of the SSH user.
+	rootlessDockerDir = ".config/docker"
+	rootlessUnitFile  = ".config/systemd/user/docker.service"
+	rootlessBinDir    = "bin"
+)
+
+var (
+	ErrRootlessSwarm   = errors.New("Swarm can't be configured on a rootless engine")
+	ErrRootlessVersion = errors.New("A rootless engine can't be pinned to a version or installed from a bundle")
+)
+
+// RootlessProvisioner provisions an engine run by the SSH user with
+// rootless Docker, on machines where the user can't use sudo. It wraps the
+// provisioner of the OS of the machine and runs nothing as root: the engine
+// is installed in ~/bin, configured in ~/.config/docker and run by a systemd
+// user unit. rootlesskit publishes the engine on the port of the URL of the
+// driver, so the machine is reached the same way as a rootful one.
+type RootlessProvisioner struct {
+	Provisioner
+	SwarmOptions  swarm.Options
+	AuthOptions   auth.Options
+	EngineOptions engine.Options
+	// Home, RuntimeDir and BinDir are read from the machine: the home and
+	// the XDG runtime directory of the SSH user, and the directory of
+	// dockerd-rootless.sh.
+	Home       string
+	RuntimeDir string
+	BinDir     string
+}
+
+// NewRootlessProvisioner returns the rootless provisioner of a machine
+// whose OS is provisioned by p. The install URL of engineOptions is used to
+// upgrade the engine.
+func NewRootlessProvisioner(p Provisioner, engineOptions engine.Options) *RootlessProvisioner {
+	return &RootlessProvisioner{
+		Provisioner:   p,
+		EngineOptions: engineOptions,
+	}
+}
+
+func (p *RootlessProvisioner) String() string {
+	return p.Provisioner.String() + ", rootless"
+}
+
+func (p *RootlessProvisioner) GetDockerOptionsDir() string {
+	return path.Join(p.Home, rootlessDockerDir)
+}
+
+func (p *RootlessProvisioner) GetAuthOptions() auth.Options {
+	return p.AuthOptions
+}
+
+func (p *RootlessProvisioner) GetSwarmOptions() swarm.Options {
+	return p.SwarmOptions
+}
+
+// readUserDirs reads the directories of the SSH user from the machine, once.
+func (p *RootlessProvisioner) readUserDirs() error {
+	if p.Home != "" {
+		return nil
+	}
+
+	output, err := p.SSHCommand(`printf '%s\n%s\n' "$HOME" "${XDG_RUNTIME_DIR:-/run/user/$(id -u)}"; PATH="$HOME/bin:$PATH" command -v dockerd-rootless.sh || true`)
+	if err != nil {
+		return fmt.Errorf("Error reading the directories of the SSH user: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 || lines[0] == "" {
+		return fmt.Errorf("Error reading the directories of the SSH user: %q", output)
+	}
+
+	p.Home = strings.TrimSpace(lines[0])
+	p.RuntimeDir = strings.TrimSpace(lines[1])
+	p.BinDir = path.Join(p.Home, rootlessBinDir)
+	if len(lines) > 2 && strings.TrimSpace(lines[2]) != "" {
+		p.BinDir = path.Dir(strings.TrimSpace(lines[2]))
+	}
+
+	return nil
+}
+
+// dockerCommand returns the command running the docker client against the
+// rootless engine.
+func (p *RootlessProvisioner) dockerCommand(args string) string {
+	return fmt.Sprintf("DOCKER_HOST=unix://%s/docker.sock PATH=%s:$PATH docker %s", p.RuntimeDir, p.BinDir, args)
+}
+
+// rootlessInstallURL returns the URL of the rootless install script served
+// next to the install script at installURL.
+func rootlessInstallURL(installURL string) string {
+	return strings.TrimSuffix(installURL, "/") + "/rootless"
+}
+
+func (p *RootlessProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	var (
+		engineCfg bytes.Buffer
+	)
+
+	if err := p.readUserDirs(); err != nil {
+		return nil, err
+	}
+
+	p.EngineOptions.Labels = withDriverLabel(p.EngineOptions.Labels, p.GetDriver().DriverName())
+
+	// The API is published by rootlesskit, the engine only listens in its
+	// network namespace.
+	engineConfigTmpl := `[Unit]
+Description=Docker Application Container Engine (Rootless)
+
+[Service]
+Environment="PATH={{.BinDir}}:/sbin:/usr/sbin:/usr/local/bin:/usr/bin:/bin"
+Environment="DOCKERD_ROOTLESS_ROOTLESSKIT_FLAGS=-p 0.0.0.0:{{.DockerPort}}:{{.DockerPort}}/tcp"
+Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
+ExecStart={{.BinDir}}/dockerd-rootless.sh{{ range .Flags }} --{{.}}{{ end }}
+ExecReload=/bin/kill -s HUP $MAINPID
+TimeoutSec=0
+RestartSec=2
+Restart=always
+StartLimitBurst=3
+StartLimitInterval=60s
+LimitNOFILE=infinity
+LimitNPROC=infinity
+LimitCORE=infinity
+TasksMax=infinity
+Delegate=yes
+Type=simple
+KillMode=mixed
+
+[Install]
+WantedBy=default.target
+`
+	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	engineConfig := NewEngineConfig(dockerPort, p.AuthOptions, p.EngineOptions)
+	engineConfig.Hosts = []string{
+		fmt.Sprintf("tcp://0.0.0.0:%d", dockerPort),
+		fmt.Sprintf("unix://%s/docker.sock", p.RuntimeDir),
+	}
+
+	t.Execute(&engineCfg, struct {
+		DockerPort    int
+		BinDir        string
+		EngineOptions engine.Options
+		Flags         []string
+	}{
+		DockerPort:    dockerPort,
+		BinDir:        p.BinDir,
+		EngineOptions: p.EngineOptions,
+		Flags:         engineConfig.CommandLineFlags(),
+	})
+
+	return &DockerOptions{
+		EngineOptions:     engineCfg.String(),
+		EngineOptionsPath: path.Join(p.Home, rootlessUnitFile),
+		DaemonConfig:      engineConfig,
+	}, nil
+}
+
+// Package installs or upgrades the rootless engine with the rootless install
+// script. The other packages need root, they can't be installed.
+func (p *RootlessProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	if name != "docker" || action == pkgaction.Remove {
+		return fmt.Errorf("Unable to %s the %s package without root", action.String(), name)
+	}
+
+	// The script refuses to overwrite an engine, or to install next to the
+	// engine of root, unless it's forced.
+	command := fmt.Sprintf("curl -fsSL %s | FORCE_ROOTLESS_INSTALL=1 sh", rootlessInstallURL(p.EngineOptions.InstallURL))
+	if output, err := p.SSHCommand(command); err != nil {
+		return fmt.Errorf("error installing rootless docker: %s\n", output)
+	}
+
+	return nil
+}
+
+// Service runs the action on a systemd user unit of the SSH user.
+func (p *RootlessProvisioner) Service(name string, action serviceaction.ServiceAction) error {
+	switch action {
+	case serviceaction.Start, serviceaction.Restart:
+		if _, err := p.SSHCommand("systemctl --user daemon-reload"); err != nil {
+			return err
+		}
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("systemctl --user %s %s", action.String(), name)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// setOptions sets the options of the provisioning, and reads where the
+// engine goes from the machine.
+func (p *RootlessProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	if swarmOptions.IsSwarm {
+		return ErrRootlessSwarm
+	}
+	if engineOptions.Version != "" || engineOptions.InstallBundle != "" {
+		return ErrRootlessVersion
+	}
+
+	if err := p.readUserDirs(); err != nil {
+		return err
+	}
+
+	p.SwarmOptions = swarmOptions
+	p.EngineOptions = engineOptions
+	p.AuthOptions = remoteAuthOptions(p.GetDockerOptionsDir(), authOptions)
+
+	return nil
+}
+
+func (p *RootlessProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	steps, err := p.Steps(swarmOptions, authOptions, engineOptions)
+	if err != nil {
+		return err
+	}
+
+	return ApplySteps(steps)
+}
+
+// Steps returns the steps of the provisioning. The hostname is left alone,
+// setting it needs root.
+func (p *RootlessProvisioner) Steps(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) ([]Step, error) {
+	if err := p.setOptions(swarmOptions, authOptions, engineOptions); err != nil {
+		return nil, err
+	}
+
+	return []Step{
+		{
+			Name: StepEngine,
+			Check: func() (bool, error) {
+				return p.engineInstalled(), nil
+			},
+			Apply: func() error {
+				log.Info("Installing rootless Docker...")
+				return p.Package("docker", pkgaction.Install)
+			},
+		},
+		{
+			Name: StepAuth,
+			Check: func() (bool, error) {
+				return authConfigured(p)
+			},
+			Apply: p.configureAuth,
+		},
+		{
+			Name: StepEnableService,
+			Check: func() (bool, error) {
+				_, err := p.SSHCommand("systemctl --user -q is-enabled docker && test -e /var/lib/systemd/linger/$(id -un)")
+				return err == nil, nil
+			},
+			Apply: p.enableService,
+		},
+	}, nil
+}
+
+func (p *RootlessProvisioner) engineInstalled() bool {
+	_, err := p.SSHCommand(fmt.Sprintf("test -x %s/dockerd-rootless.sh", p.BinDir))
+	return err == nil
+}
+
+// configureAuth is ConfigureAuth for the rootless engine: the certs and the
+// configuration are written in the home of the SSH user and the user unit
+// is restarted.
+func (p *RootlessProvisioner) configureAuth() error {
+	if err := generateServerCert(p); err != nil {
+		return err
+	}
+
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
+		return err
+	}
+
+	dkrcfg, err := p.GenerateDockerOptions(dockerPort)
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("mkdir -p %s %s", p.GetDockerOptionsDir(), path.Dir(dkrcfg.EngineOptionsPath))); err != nil {
+		return err
+	}
+
+	if err := copyCertsToMachine(p); err != nil {
+		return err
+	}
+
+	log.Info("Setting Docker configuration on the remote daemon...")
+
+	if err := writeDaemonJSON(p, dkrcfg.DaemonConfig); err != nil {
+		return err
+	}
+
+	if err := writeRemoteFile(p, dkrcfg.EngineOptionsPath, []byte(dkrcfg.EngineOptions), 0644); err != nil {
+		return err
+	}
+
+	if err := p.Service("docker", serviceaction.Restart); err != nil {
+		return err
+	}
+
+	return WaitForDocker(p, dockerPort)
+}
+
+// enableService starts the engine with the user manager, and keeps the user
+// manager running when the user isn't logged in.
+func (p *RootlessProvisioner) enableService() error {
+	if err := p.Service("docker", serviceaction.Enable); err != nil {
+		return err
+	}
+
+	if output, err := p.SSHCommand("loginctl enable-linger $(id -un)"); err != nil {
+		log.Warnf("Unable to enable lingering for the SSH user, the engine will stop once the user logs out unless root runs `loginctl enable-linger <user>`: %s", strings.TrimSpace(output))
+	}
+
+	return nil
+}
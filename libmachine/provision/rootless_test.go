@@ -0,0 +1,143 @@
+package provision
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+const rootlessDirsCommand = `printf '%s\n%s\n' "$HOME" "${XDG_RUNTIME_DIR:-/run/user/$(id -u)}"; PATH="$HOME/bin:$PATH" command -v dockerd-rootless.sh || true`
+
+func newTestRootlessProvisioner(commander SSHCommander) *RootlessProvisioner {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{
+		MockName:  "rootless",
+		MockIP:    "127.0.0.1",
+		MockState: state.Running,
+	}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = commander
+
+	return NewRootlessProvisioner(p, engine.Options{InstallURL: "https://get.docker.com"})
+}
+
+func TestRootlessReadUserDirs(t *testing.T) {
+	p := newTestRootlessProvisioner(&provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			rootlessDirsCommand: "/home/dev\n/run/user/1000\n",
+		},
+	})
+
+	assert.NoError(t, p.readUserDirs())
+	assert.Equal(t, "/home/dev", p.Home)
+	assert.Equal(t, "/run/user/1000", p.RuntimeDir)
+	assert.Equal(t, "/home/dev/bin", p.BinDir)
+	assert.Equal(t, "/home/dev/.config/docker", p.GetDockerOptionsDir())
+
+	// The rootless extras of the packaged engine are used when they're
+	// installed.
+	p = newTestRootlessProvisioner(&provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			rootlessDirsCommand: "/home/dev\n/run/user/1000\n/usr/bin/dockerd-rootless.sh\n",
+		},
+	})
+
+	assert.NoError(t, p.readUserDirs())
+	assert.Equal(t, "/usr/bin", p.BinDir)
+}
+
+func TestRootlessGenerateDockerOptions(t *testing.T) {
+	p := newTestRootlessProvisioner(&provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			rootlessDirsCommand: "/home/dev\n/run/user/1000\n",
+		},
+	})
+	assert.NoError(t, p.setOptions(swarm.Options{}, newTestRemoteAuthOptions(), engine.Options{Env: []string{"HTTP_PROXY=http://proxy:3128"}}))
+
+	dkrcfg, err := p.GenerateDockerOptions(2376)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/home/dev/.config/systemd/user/docker.service", dkrcfg.EngineOptionsPath)
+	assert.Contains(t, dkrcfg.EngineOptions, "Environment=\"DOCKERD_ROOTLESS_ROOTLESSKIT_FLAGS=-p 0.0.0.0:2376:2376/tcp\"\n")
+	assert.Contains(t, dkrcfg.EngineOptions, "Environment=\"HTTP_PROXY=http://proxy:3128\" \n")
+	assert.Contains(t, dkrcfg.EngineOptions, "ExecStart=/home/dev/bin/dockerd-rootless.sh\n")
+	assert.Equal(t, []string{"tcp://0.0.0.0:2376", "unix:///run/user/1000/docker.sock"}, dkrcfg.DaemonConfig.Hosts)
+	assert.Equal(t, "/home/dev/.config/docker/ca.pem", dkrcfg.DaemonConfig.TLSCACert)
+	assert.Equal(t, "/home/dev/.config/docker/server-key.pem", dkrcfg.DaemonConfig.TLSKey)
+	assert.Equal(t, []string{"provider=Driver"}, dkrcfg.DaemonConfig.Labels)
+}
+
+func TestRootlessRefusedOptions(t *testing.T) {
+	p := newTestRootlessProvisioner(&provisiontest.FakeSSHCommander{})
+
+	_, err := p.Steps(swarm.Options{IsSwarm: true}, newTestRemoteAuthOptions(), engine.Options{})
+	assert.Equal(t, ErrRootlessSwarm, err)
+
+	_, err = p.Steps(swarm.Options{}, newTestRemoteAuthOptions(), engine.Options{Version: "17.03.1-ce"})
+	assert.Equal(t, ErrRootlessVersion, err)
+}
+
+func TestRootlessPackage(t *testing.T) {
+	commander := &offlineSSHCommander{}
+	p := newTestRootlessProvisioner(commander)
+
+	err := p.Package("docker", pkgaction.Upgrade)
+
+	assert.Error(t, err)
+	assert.Equal(t, []string{"curl -fsSL https://get.docker.com/rootless | FORCE_ROOTLESS_INSTALL=1 sh"}, commander.Commands)
+
+	assert.EqualError(t, p.Package("curl", pkgaction.Install), "Unable to install the curl package without root")
+}
+
+// TestRootlessProvision checks that a rootless engine is provisioned without
+// sudo.
+func TestRootlessProvision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	commander := &offlineSSHCommander{
+		FakeSSHCommander: provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				rootlessDirsCommand: "/home/dev\n/run/user/1000\n",
+			},
+		},
+		Failures: map[string]bool{
+			"systemctl --user -q is-enabled docker && test -e /var/lib/systemd/linger/$(id -un)": true,
+		},
+	}
+	p := newTestRootlessProvisioner(commander)
+
+	steps, err := p.Steps(swarm.Options{}, newTestAuthOptions(t, dir), engine.Options{InstallURL: "https://get.docker.com"})
+	assert.NoError(t, err)
+
+	err = RunSteps(steps, "", nil)
+
+	assert.NoError(t, err)
+	for _, command := range commander.Commands {
+		assert.False(t, strings.Contains(command, "sudo"), "%q uses sudo", command)
+	}
+	assert.NotContains(t, commander.Commands, "curl -fsSL https://get.docker.com/rootless | FORCE_ROOTLESS_INSTALL=1 sh")
+	assert.Contains(t, commander.Commands, "install -m 600 .docker-machine-server-key.pem /home/dev/.config/docker/server-key.pem && rm -f .docker-machine-server-key.pem")
+	assert.Contains(t, commander.Commands, "install -m 644 .docker-machine-docker.service /home/dev/.config/systemd/user/docker.service && rm -f .docker-machine-docker.service")
+	assert.Contains(t, commander.Commands, "systemctl --user restart docker")
+	assert.Contains(t, commander.Commands, "systemctl --user enable docker")
+	assert.Contains(t, commander.Commands, "loginctl enable-linger $(id -un)")
+	assert.Contains(t, commander.Files[".docker-machine-daemon.json"], `"unix:///run/user/1000/docker.sock"`)
+}
+
+func newTestRemoteAuthOptions() auth.Options {
+	return auth.Options{
+		CaCertPath:     "/machines/ca.pem",
+		ServerCertPath: "/machines/rootless/server.pem",
+		ServerKeyPath:  "/machines/rootless/server-key.pem",
+	}
+}
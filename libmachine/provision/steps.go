@@ -0,0 +1,383 @@
+package provision
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+// The names of the provisioning steps shared by the provisioners.
+const (
+	StepPreHooks      = "pre-hooks"
+	StepSudo          = "sudo"
+	StepHostname      = "hostname"
+	StepPackages      = "packages"
+	StepEngine        = "engine"
+	StepAuth          = "auth"
+	StepSwarm         = "swarm"
+	StepEnableService = "enable-service"
+	StepRegistry      = "registry"
+	StepPostHooks     = "post-hooks"
+	// StepProvision is the only step of the provisioners which aren't split
+	// into steps.
+	StepProvision = "provision"
+)
+
+// Step is a named part of the provisioning.
+type Step struct {
+	Name string
+	// Check tells whether the step is already satisfied on the machine, in
+	// which case Apply is skipped. Steps without a check are always applied.
+	Check func() (bool, error)
+	Apply func() error
+}
+
+// Stepper is implemented by the provisioners whose provisioning is split
+// into steps.
+type Stepper interface {
+	Steps(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) ([]Step, error)
+}
+
+// ErrUnknownStep is returned when resuming the provisioning after a step
+// which isn't one of the steps of the provisioner.
+var ErrUnknownStep = errors.New("unknown provisioning step")
+
+// ProvisionSteps provisions the machine step by step, running the hooks of
+// each phase. The steps already satisfied on the machine are skipped, as
+// well as the steps up to and including resumeAfter when it's set. done is
+// called with the name of each step once it's completed.
+func ProvisionSteps(p Provisioner, hooks []Hook, swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options, resumeAfter string, done func(step string)) error {
+	steps := []Step{{
+		Name:  StepPreHooks,
+		Apply: func() error { return RunHooks(p, HookPre, hooks) },
+	}}
+
+	if stepper, ok := p.(Stepper); ok {
+		provisionerSteps, err := stepper.Steps(swarmOptions, authOptions, engineOptions)
+		if err != nil {
+			return err
+		}
+		steps = append(steps, provisionerSteps...)
+	} else {
+		steps = append(steps, Step{
+			Name:  StepProvision,
+			Apply: func() error { return p.Provision(swarmOptions, authOptions, engineOptions) },
+		})
+	}
+
+	steps = append(steps, Step{
+		Name:  StepRegistry,
+		Apply: func() error { return ConfigureRegistries(p, engineOptions) },
+	}, Step{
+		Name:  StepPostHooks,
+		Apply: func() error { return RunHooks(p, HookPost, hooks) },
+	})
+
+	return RunSteps(steps, resumeAfter, done)
+}
+
+// RunSteps runs the steps which aren't already satisfied, after resumeAfter
+// when it's set.
+func RunSteps(steps []Step, resumeAfter string, done func(step string)) error {
+	if resumeAfter != "" {
+		resumed := false
+		for i, step := range steps {
+			if step.Name == resumeAfter {
+				log.Infof("Resuming the provisioning after the %s step...", resumeAfter)
+				steps = steps[i+1:]
+				resumed = true
+				break
+			}
+		}
+		if !resumed {
+			return fmt.Errorf("%s: %s", ErrUnknownStep, resumeAfter)
+		}
+	}
+
+	for _, step := range steps {
+		satisfied := false
+		if step.Check != nil {
+			var err error
+			if satisfied, err = step.Check(); err != nil {
+				return fmt.Errorf("Error checking the %s provisioning step: %s", step.Name, err)
+			}
+		}
+
+		if satisfied {
+			log.Infof("Skipping the %s provisioning step, already satisfied", step.Name)
+		} else {
+			log.Debugf("running the %s provisioning step", step.Name)
+			if err := step.Apply(); err != nil {
+				return err
+			}
+		}
+
+		if done != nil {
+			done(step.Name)
+		}
+	}
+
+	return nil
+}
+
+// ApplySteps applies all the steps without checking them.
+func ApplySteps(steps []Step) error {
+	for _, step := range steps {
+		log.Debugf("running the %s provisioning step", step.Name)
+		if err := step.Apply(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hostnameStep(p Provisioner) Step {
+	hostname := p.GetDriver().GetMachineName()
+
+	return Step{
+		Name: StepHostname,
+		Check: func() (bool, error) {
+			current, err := p.SSHCommand("hostname")
+			if err != nil {
+				return false, nil
+			}
+			return strings.TrimSpace(current) == hostname, nil
+		},
+		Apply: func() error {
+			return p.SetHostname(hostname)
+		},
+	}
+}
+
+func packagesStep(p Provisioner, packages []string) Step {
+	step := Step{
+		Name: StepPackages,
+		Apply: func() error {
+			for _, pkg := range packages {
+				log.Debugf("installing base package: name=%s", pkg)
+				if err := p.Package(pkg, pkgaction.Install); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	// Only the package managers can tell whether the packages are there.
+	if installer, ok := p.(bundleInstaller); ok {
+		format := installer.bundlePackageFormat()
+		step.Check = func() (bool, error) {
+			for _, pkg := range packages {
+				if _, err := p.SSHCommand(fmt.Sprintf("%s %s", format.query, pkg)); err != nil {
+					return false, nil
+				}
+			}
+			return true, nil
+		}
+	}
+
+	return step
+}
+
+// engineStep installs the engine with install, unless the requested version
+// of the engine is already installed.
+func engineStep(p Provisioner, engineOptions engine.Options, install func() error) Step {
+	return Step{
+		Name: StepEngine,
+		Check: func() (bool, error) {
+			current, err := EngineVersion(p)
+			if err != nil {
+				return false, nil
+			}
+			return engineOptions.Version == "" || EngineVersionMatches(current, engineOptions.Version), nil
+		},
+		Apply: install,
+	}
+}
+
+// authStep configures the engine with TLS, unless the certs and the
+// configuration of the engine on the machine are up to date. Configuring it
+// restarts the engine.
+func authStep(p Provisioner) Step {
+	return Step{
+		Name: StepAuth,
+		Check: func() (bool, error) {
+			return authConfigured(p)
+		},
+		Apply: func() error {
+			if err := makeDockerOptionsDir(p); err != nil {
+				return err
+			}
+			return ConfigureAuth(p)
+		},
+	}
+}
+
+func swarmStep(p Provisioner, swarmOptions swarm.Options, authOptions auth.Options) Step {
+	return Step{
+		Name: StepSwarm,
+		Check: func() (bool, error) {
+			return swarmRunning(p, swarmOptions, authOptions)
+		},
+		Apply: func() error {
+			return configureSwarm(p, swarmOptions, authOptions)
+		},
+	}
+}
+
+// enableServiceStep enables the engine in systemd.
+func enableServiceStep(p Provisioner) Step {
+	return Step{
+		Name: StepEnableService,
+		Check: func() (bool, error) {
+			_, err := p.SSHCommand("sudo systemctl -q is-enabled docker")
+			return err == nil, nil
+		},
+		Apply: func() error {
+			return p.Service("docker", serviceaction.Enable)
+		},
+	}
+}
+
+// authConfigured tells whether the server cert of the machine is valid for
+// its SANs and whether the certs and the configuration files on the machine
+// match what ConfigureAuth would write.
+func authConfigured(p Provisioner) (bool, error) {
+	authOptions := p.GetAuthOptions()
+	driver := p.GetDriver()
+
+	ip, err := driver.GetIP()
+	if err != nil {
+		return false, err
+	}
+
+	serverCert, err := ioutil.ReadFile(authOptions.ServerCertPath)
+	if err != nil {
+		return false, nil
+	}
+	if !certValidFor(serverCert, serverCertHosts(authOptions, ip)) {
+		return false, nil
+	}
+
+	certs := []struct{ local, remote string }{
+		{authOptions.CaCertPath, authOptions.CaCertRemotePath},
+		{authOptions.ServerCertPath, authOptions.ServerCertRemotePath},
+		{authOptions.ServerKeyPath, authOptions.ServerKeyRemotePath},
+	}
+	for _, c := range certs {
+		local, err := ioutil.ReadFile(c.local)
+		if err != nil {
+			return false, nil
+		}
+		remote, err := readRemoteFile(p, c.remote)
+		if err != nil {
+			return false, err
+		}
+		if remote != string(local) {
+			return false, nil
+		}
+	}
+
+	dockerPort, err := getDockerPort(driver)
+	if err != nil {
+		return false, err
+	}
+
+	dkrcfg, err := p.GenerateDockerOptions(dockerPort)
+	if err != nil {
+		return false, err
+	}
+
+	current, err := readRemoteFile(p, dkrcfg.EngineOptionsPath)
+	if err != nil {
+		return false, err
+	}
+	if current != shellDoubleQuoted(dkrcfg.EngineOptions) {
+		return false, nil
+	}
+
+	if dkrcfg.DaemonConfig != nil {
+		current, content, err := renderDaemonJSON(p, dkrcfg.DaemonConfig)
+		if err != nil {
+			return false, err
+		}
+		if current != string(content) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// certValidFor tells whether the PEM encoded cert is valid for all the
+// hosts.
+func certValidFor(certPEM []byte, hosts []string) bool {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return false
+	}
+
+	c, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			found := false
+			for _, certIP := range c.IPAddresses {
+				found = found || certIP.Equal(ip)
+			}
+			if !found {
+				return false
+			}
+		} else if err := c.VerifyHostname(host); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// swarmRunning tells whether the Swarm containers of the machine are
+// running with the image and the command they would be created with.
+func swarmRunning(p Provisioner, swarmOptions swarm.Options, authOptions auth.Options) (bool, error) {
+	if !swarmOptions.IsSwarm {
+		return true, nil
+	}
+
+	_, containers, err := swarmContainers(p, swarmOptions, authOptions)
+	if err != nil {
+		return false, err
+	}
+
+	for _, container := range containers {
+		cmd, err := json.Marshal(container.Config.Cmd)
+		if err != nil {
+			return false, err
+		}
+
+		current, err := p.SSHCommand(fmt.Sprintf("sudo docker inspect --format '{{.State.Running}} {{.Config.Image}} {{json .Config.Cmd}}' %s", container.Name))
+		if err != nil {
+			return false, nil
+		}
+		if strings.TrimSpace(current) != fmt.Sprintf("true %s %s", container.Config.Image, cmd) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
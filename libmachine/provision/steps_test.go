@@ -0,0 +1,214 @@
+package provision
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSteps(applied *[]string, satisfied map[string]bool) []Step {
+	steps := []Step{}
+	for _, name := range []string{StepHostname, StepEngine, StepAuth, StepSwarm} {
+		name := name
+		steps = append(steps, Step{
+			Name: name,
+			Check: func() (bool, error) {
+				return satisfied[name], nil
+			},
+			Apply: func() error {
+				*applied = append(*applied, name)
+				if name == StepAuth && satisfied["auth fails"] {
+					return errors.New("auth failed")
+				}
+				return nil
+			},
+		})
+	}
+
+	return steps
+}
+
+func TestRunStepsSkipsSatisfiedSteps(t *testing.T) {
+	applied := []string{}
+	done := []string{}
+
+	err := RunSteps(newTestSteps(&applied, map[string]bool{StepHostname: true, StepAuth: true}), "", func(step string) {
+		done = append(done, step)
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{StepEngine, StepSwarm}, applied)
+	assert.Equal(t, []string{StepHostname, StepEngine, StepAuth, StepSwarm}, done)
+}
+
+func TestRunStepsStopsAtFailedStep(t *testing.T) {
+	applied := []string{}
+	lastDone := ""
+
+	err := RunSteps(newTestSteps(&applied, map[string]bool{"auth fails": true}), "", func(step string) {
+		lastDone = step
+	})
+
+	assert.EqualError(t, err, "auth failed")
+	assert.Equal(t, []string{StepHostname, StepEngine, StepAuth}, applied)
+	assert.Equal(t, StepEngine, lastDone)
+}
+
+func TestRunStepsResumesAfterStep(t *testing.T) {
+	applied := []string{}
+
+	err := RunSteps(newTestSteps(&applied, map[string]bool{}), StepEngine, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{StepAuth, StepSwarm}, applied)
+}
+
+func TestRunStepsResumesAfterUnknownStep(t *testing.T) {
+	applied := []string{}
+
+	err := RunSteps(newTestSteps(&applied, map[string]bool{}), "reboot", nil)
+
+	assert.EqualError(t, err, "unknown provisioning step: reboot")
+	assert.Empty(t, applied)
+}
+
+func TestEngineStepCheck(t *testing.T) {
+	p := NewDebianProvisioner(&fakedriver.Driver{}).(*DebianProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"docker --version": "Docker version 17.03.1-ce, build c6d412e\n",
+		},
+	}
+
+	for version, expected := range map[string]bool{"": true, "17.03.1-ce": true, "17.06.0-ce": false} {
+		satisfied, err := engineStep(p, engine.Options{Version: version}, nil).Check()
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, satisfied, "version %q", version)
+	}
+
+	p.SSHCommander = &provisiontest.FakeSSHCommander{}
+	satisfied, err := engineStep(p, engine.Options{}, nil).Check()
+
+	assert.NoError(t, err)
+	assert.False(t, satisfied)
+}
+
+func TestCertValidFor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	authOptions := newTestAuthOptions(t, dir)
+	assert.NoError(t, cert.GenerateCert(&cert.Options{
+		Hosts:     []string{"10.0.0.12", "localhost"},
+		CertFile:  authOptions.ServerCertPath,
+		KeyFile:   authOptions.ServerKeyPath,
+		CAFile:    authOptions.CaCertPath,
+		CAKeyFile: authOptions.CaPrivateKeyPath,
+		Org:       "test",
+		Bits:      1024,
+	}))
+	serverCert, err := ioutil.ReadFile(authOptions.ServerCertPath)
+	assert.NoError(t, err)
+
+	assert.True(t, certValidFor(serverCert, []string{"10.0.0.12", "localhost"}))
+	assert.False(t, certValidFor(serverCert, []string{"10.0.0.13", "localhost"}))
+	assert.False(t, certValidFor(serverCert, []string{"dev.example.com", "10.0.0.12"}))
+	assert.False(t, certValidFor([]byte("not a cert"), nil))
+}
+
+// TestProvisionHealthyMachine checks that provisioning a machine which is
+// already provisioned doesn't restart its engine.
+func TestProvisionHealthyMachine(t *testing.T) {
+	driver := &fakedriver.Driver{
+		MockName:  "healthy",
+		MockIP:    "127.0.0.1",
+		MockState: state.Running,
+	}
+
+	systemd := NewUbuntuSystemdProvisioner(driver).(*UbuntuSystemdProvisioner)
+	systemd.SSHCommander = newHealthyMachineCommander()
+	testProvisionHealthyMachine(t, systemd, systemd.SSHCommander.(*offlineSSHCommander))
+
+	// The options file of CoreOS is escaped for the shell.
+	coreos := NewCoreOSProvisioner(driver).(*CoreOSProvisioner)
+	coreos.SSHCommander = newHealthyMachineCommander()
+	testProvisionHealthyMachine(t, coreos, coreos.SSHCommander.(*offlineSSHCommander))
+}
+
+func newHealthyMachineCommander() *offlineSSHCommander {
+	return &offlineSSHCommander{
+		FakeSSHCommander: provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				"stat -f -c %T /var/lib": "ext4\n",
+				"hostname":               "healthy\n",
+				"docker --version":       "Docker version 17.03.1-ce, build c6d412e\n",
+			},
+		},
+	}
+}
+
+func testProvisionHealthyMachine(t *testing.T, p interface {
+	Provisioner
+	Stepper
+}, commander *offlineSSHCommander) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	authOptions := newTestAuthOptions(t, dir)
+	assert.NoError(t, cert.GenerateCert(&cert.Options{
+		Hosts:     []string{"127.0.0.1", "localhost"},
+		CertFile:  authOptions.ServerCertPath,
+		KeyFile:   authOptions.ServerKeyPath,
+		CAFile:    authOptions.CaCertPath,
+		CAKeyFile: authOptions.CaPrivateKeyPath,
+		Org:       "test",
+		Bits:      1024,
+	}))
+
+	// The environment is quoted in the options file.
+	steps, err := p.Steps(swarm.Options{}, authOptions, engine.Options{
+		Env: []string{"HTTP_PROXY=http://proxy.local:3128"},
+	})
+	assert.NoError(t, err, p.String())
+
+	// The machine has the certs and the configuration the provisioning
+	// writes.
+	for local, remote := range map[string]string{
+		authOptions.CaCertPath:     "/etc/docker/ca.pem",
+		authOptions.ServerCertPath: "/etc/docker/server.pem",
+		authOptions.ServerKeyPath:  "/etc/docker/server-key.pem",
+	} {
+		content, err := ioutil.ReadFile(local)
+		assert.NoError(t, err)
+		commander.Responses["sudo cat "+remote+" 2>/dev/null || true"] = string(content)
+	}
+	dkrcfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+	assert.NoError(t, err)
+	commander.Responses["sudo cat "+dkrcfg.EngineOptionsPath+" 2>/dev/null || true"] = shellDoubleQuoted(dkrcfg.EngineOptions)
+	if dkrcfg.DaemonConfig != nil {
+		_, daemonJSON, err := renderDaemonJSON(p, dkrcfg.DaemonConfig)
+		assert.NoError(t, err)
+		commander.Responses["sudo cat /etc/docker/daemon.json 2>/dev/null || true"] = string(daemonJSON)
+	}
+
+	err = RunSteps(steps, "", nil)
+
+	assert.NoError(t, err, p.String())
+	for _, command := range commander.Commands {
+		assert.False(t, strings.Contains(command, "stop") || strings.Contains(command, "restart"), "%s: %q restarts the engine", p.String(), command)
+	}
+	assert.Empty(t, commander.Files, p.String())
+}
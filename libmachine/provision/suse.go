@@ -106,6 +106,10 @@ func (provisioner *SUSEProvisioner) Service(name string, action serviceaction.Se
 }
 
 func (provisioner *SUSEProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	if provisioner.EngineOptions.InstallBundle != "" {
+		return bundlePackage(provisioner, provisioner.EngineOptions.InstallBundle, name, action)
+	}
+
 	var packageAction string
 
 	switch action {
@@ -126,6 +130,14 @@ func (provisioner *SUSEProvisioner) Package(name string, action pkgaction.Packag
 	return nil
 }
 
+func (provisioner *SUSEProvisioner) InstallEngineVersion(version string) error {
+	return zypperInstallEngineVersion(provisioner, version)
+}
+
+func (provisioner *SUSEProvisioner) bundlePackageFormat() bundlePackageFormat {
+	return rpmBundlePackages
+}
+
 func (provisioner *SUSEProvisioner) dockerDaemonResponding() bool {
 	log.Debug("checking docker daemon")
 
@@ -139,66 +151,69 @@ func (provisioner *SUSEProvisioner) dockerDaemonResponding() bool {
 	return true
 }
 
-func (provisioner *SUSEProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+// setOptions sets the options of the provisioning.
+func (provisioner *SUSEProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
-
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
-		return err
-	}
-
-	for _, pkg := range provisioner.Packages {
-		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
-			return err
-		}
-	}
-
-	// update OS -- this is needed for libdevicemapper and the docker install
-	if _, err := provisioner.SSHCommand("sudo zypper ref"); err != nil {
-		return err
-	}
-	if _, err := provisioner.SSHCommand("sudo zypper -n update"); err != nil {
-		return err
-	}
-
-	if err := installDockerGeneric(provisioner, engineOptions.InstallURL); err != nil {
-		return err
-	}
-
-	if _, err := provisioner.SSHCommand("sudo systemctl start docker"); err != nil {
-		return err
-	}
-
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
-		return err
-	}
+	return nil
+}
 
-	if _, err := provisioner.SSHCommand("sudo systemctl stop docker"); err != nil {
+func (provisioner *SUSEProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	steps, err := provisioner.Steps(swarmOptions, authOptions, engineOptions)
+	if err != nil {
 		return err
 	}
 
-	// open firewall port required by docker
-	if _, err := provisioner.SSHCommand("sudo /sbin/yast2 firewall services add ipprotocol=tcp tcpport=2376 zone=EXT"); err != nil {
-		return err
-	}
+	return ApplySteps(steps)
+}
 
-	if err := makeDockerOptionsDir(provisioner); err != nil {
-		return err
+// Steps returns the steps of the provisioning.
+func (provisioner *SUSEProvisioner) Steps(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) ([]Step, error) {
+	if err := provisioner.setOptions(swarmOptions, authOptions, engineOptions); err != nil {
+		return nil, err
 	}
-
+	swarmOptions.Env = engineOptions.Env
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	if err := ConfigureAuth(provisioner); err != nil {
-		return err
-	}
-
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
-		return err
-	}
-
-	return nil
+	return []Step{
+		hostnameStep(provisioner),
+		packagesStep(provisioner, provisioner.Packages),
+		engineStep(provisioner, engineOptions, func() error {
+			// update OS -- this is needed for libdevicemapper and the docker
+			// install, but not with a bundle which holds what's needed
+			if engineOptions.InstallBundle == "" {
+				if _, err := provisioner.SSHCommand("sudo zypper ref"); err != nil {
+					return err
+				}
+				if _, err := provisioner.SSHCommand("sudo zypper -n update"); err != nil {
+					return err
+				}
+			}
+
+			if err := installDockerGeneric(provisioner, engineOptions); err != nil {
+				return err
+			}
+
+			if _, err := provisioner.SSHCommand("sudo systemctl start docker"); err != nil {
+				return err
+			}
+
+			if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+				return err
+			}
+
+			if _, err := provisioner.SSHCommand("sudo systemctl stop docker"); err != nil {
+				return err
+			}
+
+			// open firewall port required by docker
+			_, err := provisioner.SSHCommand("sudo /sbin/yast2 firewall services add ipprotocol=tcp tcpport=2376 zone=EXT")
+			return err
+		}),
+		authStep(provisioner),
+		swarmStep(provisioner, swarmOptions, provisioner.AuthOptions),
+	}, nil
 }
 
 func (provisioner *SUSEProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
@@ -212,22 +227,28 @@ func (provisioner *SUSEProvisioner) GenerateDockerOptions(dockerPort int) (*Dock
 		return nil, err
 	}
 
-	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
-	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	provisioner.EngineOptions.Labels = withDriverLabel(provisioner.EngineOptions.Labels, provisioner.Driver.DriverName())
 
 	engineConfigTmpl := `# File automatically generated by docker-machine
-DOCKER_OPTS=' -H tcp://0.0.0.0:{{.DockerPort}} {{ if .EngineOptions.StorageDriver }} --storage-driver {{.EngineOptions.StorageDriver}} {{ end }} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}'
+DOCKER_OPTS=' -H tcp://0.0.0.0:{{.DockerPort}} {{ if .EngineOptions.StorageDriver }} --storage-driver {{.EngineOptions.StorageDriver}} {{ end }} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .TypedFlags }}--{{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}'
 `
 	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
 	if err != nil {
 		return nil, err
 	}
 
+	version := installedEngineVersion(provisioner)
+	typedFlags, err := engineTypedFlags(version, provisioner.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
 	engineConfigContext := EngineConfigContext{
 		DockerPort:       dockerPort,
 		AuthOptions:      provisioner.AuthOptions,
 		EngineOptions:    provisioner.EngineOptions,
 		DockerOptionsDir: provisioner.DockerOptionsDir,
+		TypedFlags:       typedFlags,
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -37,11 +37,11 @@ func (p *SystemdProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptio
 		engineCfg bytes.Buffer
 	)
 
-	driverNameLabel := fmt.Sprintf("provider=%s", p.Driver.DriverName())
-	p.EngineOptions.Labels = append(p.EngineOptions.Labels, driverNameLabel)
+	p.EngineOptions.Labels = withDriverLabel(p.EngineOptions.Labels, p.Driver.DriverName())
 
+	// The engines reading daemon.json refuse the flags set there too.
 	engineConfigTmpl := `[Service]
-ExecStart=/usr/bin/docker daemon -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --storage-driver {{.EngineOptions.StorageDriver}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}
+ExecStart={{ if .EngineConfig }}/usr/bin/dockerd{{ range .EngineConfig.CommandLineFlags }} --{{.}}{{ end }}{{ else }}/usr/bin/docker daemon -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --storage-driver {{.EngineOptions.StorageDriver}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .TypedFlags }}--{{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}{{ end }}
 MountFlags=slave
 LimitNOFILE=1048576
 LimitNPROC=1048576
@@ -56,17 +56,26 @@ WantedBy=multi-user.target
 		return nil, err
 	}
 
+	version := installedEngineVersion(p)
+	typedFlags, err := engineTypedFlags(version, p.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
 	engineConfigContext := EngineConfigContext{
 		DockerPort:    dockerPort,
 		AuthOptions:   p.AuthOptions,
 		EngineOptions: p.EngineOptions,
+		TypedFlags:    typedFlags,
 	}
+	engineConfigContext.EngineConfig = newDaemonConfig(version, dockerPort, p.AuthOptions, p.EngineOptions)
 
 	t.Execute(&engineCfg, engineConfigContext)
 
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
 		EngineOptionsPath: p.DaemonOptionsFile,
+		DaemonConfig:      engineConfigContext.EngineConfig,
 	}, nil
 }
 
@@ -10,13 +10,14 @@ import (
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/provision/pkgaction"
-	"github.com/docker/machine/libmachine/provision/serviceaction"
 	"github.com/docker/machine/libmachine/swarm"
 )
 
 func init() {
 	Register("Ubuntu-SystemD", &RegisteredProvisioner{
 		New: NewUbuntuSystemdProvisioner,
+		// Preferred over Ubuntu-UpStart by the releases which have both.
+		Priority: 10,
 	})
 }
 
@@ -50,6 +51,10 @@ func (provisioner *UbuntuSystemdProvisioner) CompatibleWithHost() bool {
 }
 
 func (provisioner *UbuntuSystemdProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	if provisioner.EngineOptions.InstallBundle != "" {
+		return bundlePackage(provisioner, provisioner.EngineOptions.InstallBundle, name, action)
+	}
+
 	var packageAction string
 
 	updateMetadata := true
@@ -84,6 +89,14 @@ func (provisioner *UbuntuSystemdProvisioner) Package(name string, action pkgacti
 	return nil
 }
 
+func (provisioner *UbuntuSystemdProvisioner) InstallEngineVersion(version string) error {
+	return aptInstallEngineVersion(provisioner, version)
+}
+
+func (provisioner *UbuntuSystemdProvisioner) bundlePackageFormat() bundlePackageFormat {
+	return debBundlePackages
+}
+
 func (provisioner *UbuntuSystemdProvisioner) dockerDaemonResponding() bool {
 	log.Debug("checking docker daemon")
 
@@ -97,11 +110,12 @@ func (provisioner *UbuntuSystemdProvisioner) dockerDaemonResponding() bool {
 	return true
 }
 
-func (provisioner *UbuntuSystemdProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+// setOptions sets the options of the provisioning, and the storage driver
+// of the engine when none was given.
+func (provisioner *UbuntuSystemdProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
 
 	storageDriver, err := decideStorageDriver(provisioner, "aufs", engineOptions.StorageDriver)
 	if err != nil {
@@ -109,45 +123,40 @@ func (provisioner *UbuntuSystemdProvisioner) Provision(swarmOptions swarm.Option
 	}
 	provisioner.EngineOptions.StorageDriver = storageDriver
 
-	log.Debug("setting hostname")
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
-		return err
-	}
-
-	log.Debug("installing base packages")
-	for _, pkg := range provisioner.Packages {
-		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
-			return err
-		}
-	}
-
-	log.Info("Installing Docker...")
-	if err := installDockerGeneric(provisioner, engineOptions.InstallURL); err != nil {
-		return err
-	}
-
-	log.Debug("waiting for docker daemon")
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
-		return err
-	}
-
-	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+	return nil
+}
 
-	log.Debug("configuring auth")
-	if err := ConfigureAuth(provisioner); err != nil {
+func (provisioner *UbuntuSystemdProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	steps, err := provisioner.Steps(swarmOptions, authOptions, engineOptions)
+	if err != nil {
 		return err
 	}
 
-	log.Debug("configuring swarm")
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
-		return err
-	}
+	return ApplySteps(steps)
+}
 
-	// enable in systemd
-	log.Debug("enabling docker in systemd")
-	if err := provisioner.Service("docker", serviceaction.Enable); err != nil {
-		return err
+// Steps returns the steps of the provisioning.
+func (provisioner *UbuntuSystemdProvisioner) Steps(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) ([]Step, error) {
+	if err := provisioner.setOptions(swarmOptions, authOptions, engineOptions); err != nil {
+		return nil, err
 	}
+	swarmOptions.Env = engineOptions.Env
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	return nil
+	return []Step{
+		hostnameStep(provisioner),
+		packagesStep(provisioner, provisioner.Packages),
+		engineStep(provisioner, engineOptions, func() error {
+			log.Info("Installing Docker...")
+			if err := installDockerGeneric(provisioner, engineOptions); err != nil {
+				return err
+			}
+
+			log.Debug("waiting for docker daemon")
+			return mcnutils.WaitFor(provisioner.dockerDaemonResponding)
+		}),
+		authStep(provisioner),
+		swarmStep(provisioner, swarmOptions, provisioner.AuthOptions),
+		enableServiceStep(provisioner),
+	}, nil
 }
@@ -69,6 +69,10 @@ func (provisioner *UbuntuProvisioner) Service(name string, action serviceaction.
 }
 
 func (provisioner *UbuntuProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	if provisioner.EngineOptions.InstallBundle != "" {
+		return bundlePackage(provisioner, provisioner.EngineOptions.InstallBundle, name, action)
+	}
+
 	var packageAction string
 
 	updateMetadata := true
@@ -103,6 +107,14 @@ func (provisioner *UbuntuProvisioner) Package(name string, action pkgaction.Pack
 	return nil
 }
 
+func (provisioner *UbuntuProvisioner) InstallEngineVersion(version string) error {
+	return aptInstallEngineVersion(provisioner, version)
+}
+
+func (provisioner *UbuntuProvisioner) bundlePackageFormat() bundlePackageFormat {
+	return debBundlePackages
+}
+
 func (provisioner *UbuntuProvisioner) dockerDaemonResponding() bool {
 	log.Debug("checking docker daemon")
 
@@ -116,11 +128,12 @@ func (provisioner *UbuntuProvisioner) dockerDaemonResponding() bool {
 	return true
 }
 
-func (provisioner *UbuntuProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+// setOptions sets the options of the provisioning, and the storage driver
+// of the engine when none was given.
+func (provisioner *UbuntuProvisioner) setOptions(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
-	swarmOptions.Env = engineOptions.Env
 
 	storageDriver, err := decideStorageDriver(provisioner, "aufs", engineOptions.StorageDriver)
 	if err != nil {
@@ -128,38 +141,38 @@ func (provisioner *UbuntuProvisioner) Provision(swarmOptions swarm.Options, auth
 	}
 	provisioner.EngineOptions.StorageDriver = storageDriver
 
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
-		return err
-	}
-
-	for _, pkg := range provisioner.Packages {
-		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
-			return err
-		}
-	}
+	return nil
+}
 
-	log.Info("Installing Docker...")
-	if err := installDockerGeneric(provisioner, engineOptions.InstallURL); err != nil {
+func (provisioner *UbuntuProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	steps, err := provisioner.Steps(swarmOptions, authOptions, engineOptions)
+	if err != nil {
 		return err
 	}
 
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
-		return err
-	}
+	return ApplySteps(steps)
+}
 
-	if err := makeDockerOptionsDir(provisioner); err != nil {
-		return err
+// Steps returns the steps of the provisioning.
+func (provisioner *UbuntuProvisioner) Steps(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) ([]Step, error) {
+	if err := provisioner.setOptions(swarmOptions, authOptions, engineOptions); err != nil {
+		return nil, err
 	}
-
+	swarmOptions.Env = engineOptions.Env
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	if err := ConfigureAuth(provisioner); err != nil {
-		return err
-	}
-
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
-		return err
-	}
-
-	return nil
+	return []Step{
+		hostnameStep(provisioner),
+		packagesStep(provisioner, provisioner.Packages),
+		engineStep(provisioner, engineOptions, func() error {
+			log.Info("Installing Docker...")
+			if err := installDockerGeneric(provisioner, engineOptions); err != nil {
+				return err
+			}
+
+			return mcnutils.WaitFor(provisioner.dockerDaemonResponding)
+		}),
+		authStep(provisioner),
+		swarmStep(provisioner, swarmOptions, provisioner.AuthOptions),
+	}, nil
 }
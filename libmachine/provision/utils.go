@@ -4,15 +4,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/url"
+	"os"
 	"path"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
@@ -22,18 +22,79 @@ import (
 type DockerOptions struct {
 	EngineOptions     string
 	EngineOptionsPath string
+	// DaemonConfig is merged into daemon.json when set. EngineOptions then
+	// only holds what daemon.json can't express, like the environment.
+	DaemonConfig *EngineConfig
 }
 
-func installDockerGeneric(p Provisioner, baseURL string) error {
+// withDriverLabel adds the label of the driver of the machine to the labels
+// of the engine, once.
+func withDriverLabel(labels []string, driverName string) []string {
+	driverNameLabel := fmt.Sprintf("provider=%s", driverName)
+	for _, label := range labels {
+		if label == driverNameLabel {
+			return labels
+		}
+	}
+
+	return append(labels, driverNameLabel)
+}
+
+func installDockerGeneric(p Provisioner, engineOptions engine.Options) error {
+	if engineOptions.InstallBundle != "" {
+		return InstallEngineBundle(p, engineOptions.InstallBundle)
+	}
+
 	// install docker - until cloudinit we use ubuntu everywhere so we
 	// just install it using the docker repos
-	if output, err := p.SSHCommand(fmt.Sprintf("if ! type docker; then curl -sSL %s | sh -; fi", baseURL)); err != nil {
+	if output, err := p.SSHCommand(fmt.Sprintf("if ! type docker; then curl -sSL %s | sh -; fi", engineOptions.InstallURL)); err != nil {
 		return fmt.Errorf("error installing docker: %s\n", output)
 	}
 
+	// The install script sets up the repositories and installs the latest
+	// version, the pinned one is installed from there.
+	if engineOptions.Version != "" {
+		return InstallEngineVersion(p, engineOptions.Version)
+	}
+
+	return nil
+}
+
+// readRemoteFile returns the content of the file at remotePath, which is
+// usually owned by root, or nothing when it doesn't exist. The line endings
+// are CRLF when the command runs in a tty, as on Red Hat, they're turned back
+// into LF so that the content compares with the rendered one.
+func readRemoteFile(p SSHCommander, remotePath string) (string, error) {
+	content, err := p.SSHCommand(fmt.Sprintf("%scat %s 2>/dev/null || true", sudoPrefix(p), remotePath))
+	return strings.Replace(content, "\r\n", "\n", -1), err
+}
+
+// writeRemoteFile writes content to the file at remotePath, which is usually
+// owned by root. The content is uploaded to a file in the home directory of
+// the SSH user first and then moved in place with sudo.
+func writeRemoteFile(p SSHCommander, remotePath string, content []byte, mode os.FileMode) error {
+	tmpPath := ".docker-machine-" + path.Base(remotePath)
+
+	if err := p.SSHWriteFile(tmpPath, content, 0600); err != nil {
+		return err
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("%sinstall -m %o %s %s && rm -f %s", sudoPrefix(p), mode, tmpPath, remotePath, tmpPath)); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// sudoPrefix returns what prefixes the commands writing the files of the
+// engine: sudo, unless the engine is run by the SSH user.
+func sudoPrefix(p SSHCommander) string {
+	if _, ok := p.(*RootlessProvisioner); ok {
+		return ""
+	}
+	return "sudo "
+}
+
 func makeDockerOptionsDir(p Provisioner) error {
 	dockerDir := p.GetDockerOptionsDir()
 	if _, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s", dockerDir)); err != nil {
@@ -44,9 +105,11 @@ func makeDockerOptionsDir(p Provisioner) error {
 }
 
 func setRemoteAuthOptions(p Provisioner) auth.Options {
-	dockerDir := p.GetDockerOptionsDir()
-	authOptions := p.GetAuthOptions()
+	return remoteAuthOptions(p.GetDockerOptionsDir(), p.GetAuthOptions())
+}
 
+// remoteAuthOptions sets the paths of the certs on the machine, in dockerDir.
+func remoteAuthOptions(dockerDir string, authOptions auth.Options) auth.Options {
 	// due to windows clients, we cannot use filepath.Join as the paths
 	// will be mucked on the linux hosts
 	authOptions.CaCertRemotePath = path.Join(dockerDir, "ca.pem")
@@ -56,11 +119,62 @@ func setRemoteAuthOptions(p Provisioner) auth.Options {
 	return authOptions
 }
 
+// serverCertHosts returns the SANs of the server cert of a machine.
+func serverCertHosts(authOptions auth.Options, ip string) []string {
+	// The Host IP is always added to the certificate's SANs list
+	hosts := append([]string{}, authOptions.ServerCertSANs...)
+	return append(hosts, ip, "localhost")
+}
+
 func ConfigureAuth(p Provisioner) error {
-	var (
-		err error
-	)
+	if err := generateServerCert(p); err != nil {
+		return err
+	}
+
+	if err := p.Service("docker", serviceaction.Stop); err != nil {
+		return err
+	}
+
+	if _, err := p.SSHCommand(`if [ ! -z "$(ip link show docker0)" ]; then sudo ip link delete docker0; fi`); err != nil {
+		return err
+	}
+
+	if err := copyCertsToMachine(p); err != nil {
+		return err
+	}
+
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
+		return err
+	}
+
+	dkrcfg, err := p.GenerateDockerOptions(dockerPort)
+	if err != nil {
+		return err
+	}
 
+	log.Info("Setting Docker configuration on the remote daemon...")
+
+	if dkrcfg.DaemonConfig != nil {
+		if err := writeDaemonJSON(p, dkrcfg.DaemonConfig); err != nil {
+			return err
+		}
+	}
+
+	if err := writeRemoteFile(p, dkrcfg.EngineOptionsPath, []byte(shellDoubleQuoted(dkrcfg.EngineOptions)), 0644); err != nil {
+		return err
+	}
+
+	if err := p.Service("docker", serviceaction.Start); err != nil {
+		return err
+	}
+
+	return WaitForDocker(p, dockerPort)
+}
+
+// generateServerCert copies the client certs to the directory of the machine
+// and issues the server cert of the machine.
+func generateServerCert(p Provisioner) error {
 	driver := p.GetDriver()
 	machineName := driver.GetMachineName()
 	authOptions := p.GetAuthOptions()
@@ -87,8 +201,7 @@ func ConfigureAuth(p Provisioner) error {
 		return fmt.Errorf("Copying key.pem to machine dir failed: %s", err)
 	}
 
-	// The Host IP is always added to the certificate's SANs list
-	hosts := append(authOptions.ServerCertSANs, ip, "localhost")
+	hosts := serverCertHosts(authOptions, ip)
 	log.Debugf("generating server cert: %s ca-key=%s private-key=%s org=%s san=%s",
 		authOptions.ServerCertPath,
 		authOptions.CaCertPath,
@@ -114,15 +227,14 @@ func ConfigureAuth(p Provisioner) error {
 		return fmt.Errorf("error generating server cert: %s", err)
 	}
 
-	if err := p.Service("docker", serviceaction.Stop); err != nil {
-		return err
-	}
+	return nil
+}
 
-	if _, err := p.SSHCommand(`if [ ! -z "$(ip link show docker0)" ]; then sudo ip link delete docker0; fi`); err != nil {
-		return err
-	}
+// copyCertsToMachine uploads the CA cert and the server cert and key to the
+// machine.
+func copyCertsToMachine(p Provisioner) error {
+	authOptions := p.GetAuthOptions()
 
-	// upload certs and configure TLS auth
 	caCert, err := ioutil.ReadFile(authOptions.CaCertPath)
 	if err != nil {
 		return err
@@ -139,76 +251,38 @@ func ConfigureAuth(p Provisioner) error {
 
 	log.Info("Copying certs to the remote machine...")
 
-	// printf will choke if we don't pass a format string because of the
-	// dashes, so that's the reason for the '%%s'
-	certTransferCmdFmt := "printf '%%s' '%s' | sudo tee %s"
-
-	// These ones are for Jessie and Mike <3 <3 <3
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(caCert), authOptions.CaCertRemotePath)); err != nil {
+	if err := writeRemoteFile(p, authOptions.CaCertRemotePath, caCert, 0644); err != nil {
 		return err
 	}
 
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(serverCert), authOptions.ServerCertRemotePath)); err != nil {
+	if err := writeRemoteFile(p, authOptions.ServerCertRemotePath, serverCert, 0644); err != nil {
 		return err
 	}
 
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(serverKey), authOptions.ServerKeyRemotePath)); err != nil {
-		return err
-	}
+	return writeRemoteFile(p, authOptions.ServerKeyRemotePath, serverKey, 0600)
+}
 
-	dockerURL, err := driver.GetURL()
+// getDockerPort returns the port the engine of the machine listens on.
+func getDockerPort(d drivers.Driver) (int, error) {
+	dockerURL, err := d.GetURL()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	u, err := url.Parse(dockerURL)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	dockerPort := engine.DefaultPort
 	parts := strings.Split(u.Host, ":")
 	if len(parts) == 2 {
 		dPort, err := strconv.Atoi(parts[1])
 		if err != nil {
-			return err
+			return 0, err
 		}
 		dockerPort = dPort
 	}
 
-	dkrcfg, err := p.GenerateDockerOptions(dockerPort)
-	if err != nil {
-		return err
-	}
-
-	log.Info("Setting Docker configuration on the remote daemon...")
-
-	if _, err = p.SSHCommand(fmt.Sprintf("printf %%s \"%s\" | sudo tee %s", dkrcfg.EngineOptions, dkrcfg.EngineOptionsPath)); err != nil {
-		return err
-	}
-
-	if err := p.Service("docker", serviceaction.Start); err != nil {
-		return err
-	}
-
-	return WaitForDocker(p, dockerPort)
-}
-
-func matchNetstatOut(reDaemonListening, netstatOut string) bool {
-	// TODO: I would really prefer this be a Scanner directly on
-	// the STDOUT of the executed command than to do all the string
-	// manipulation hokey-pokey.
-	//
-	// TODO: Unit test this matching.
-	for _, line := range strings.Split(netstatOut, "\n") {
-		match, err := regexp.MatchString(reDaemonListening, line)
-		if err != nil {
-			log.Warnf("Regex warning: %s", err)
-		}
-		if match && line != "" {
-			return true
-		}
-	}
-
-	return false
+	return dockerPort, nil
 }
 
 func decideStorageDriver(p Provisioner, defaultDriver, suppliedDriver string) (string, error) {
@@ -250,25 +324,3 @@ func getFilesystemType(p Provisioner, directory string) (string, error) {
 	fstype := strings.TrimSpace(statCommandOutput)
 	return fstype, nil
 }
-
-func checkDaemonUp(p Provisioner, dockerPort int) func() bool {
-	reDaemonListening := fmt.Sprintf(":%d\\s+.*:.*", dockerPort)
-	return func() bool {
-		// HACK: Check netstat's output to see if anyone's listening on the Docker API port.
-		netstatOut, err := p.SSHCommand("netstat -tln")
-		if err != nil {
-			log.Warnf("Error running SSH command: %s", err)
-			return false
-		}
-
-		return matchNetstatOut(reDaemonListening, netstatOut)
-	}
-}
-
-func WaitForDocker(p Provisioner, dockerPort int) error {
-	if err := mcnutils.WaitForSpecific(checkDaemonUp(p, dockerPort), 10, 3*time.Second); err != nil {
-		return NewErrDaemonAvailable(err)
-	}
-
-	return nil
-}
@@ -16,33 +16,6 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-var (
-	reDaemonListening = ":2376\\s+.*:.*"
-)
-
-func TestMatchNetstatOutMissing(t *testing.T) {
-	nsOut := `Active Internet connections (servers and established)
-Proto Recv-Q Send-Q Local Address           Foreign Address         State
-tcp        0      0 0.0.0.0:22              0.0.0.0:*               LISTEN
-tcp        0      0 0.0.0.0:237             0.0.0.0:*               LISTEN
-tcp6       0      0 :::22                   :::*                    LISTEN
-tcp6       0      0 :::23760                :::*                    LISTEN`
-	if matchNetstatOut(reDaemonListening, nsOut) {
-		t.Fatal("Expected not to match the netstat output as showing the daemon listening but got a match")
-	}
-}
-
-func TestMatchNetstatOutPresent(t *testing.T) {
-	nsOut := `Active Internet connections (servers and established)
-Proto Recv-Q Send-Q Local Address           Foreign Address         State
-tcp        0      0 0.0.0.0:22              0.0.0.0:*               LISTEN
-tcp6       0      0 :::2376                 :::*                    LISTEN
-tcp6       0      0 :::22                   :::*                    LISTEN`
-	if !matchNetstatOut(reDaemonListening, nsOut) {
-		t.Fatal("Expected to match the netstat output as showing the daemon listening but didn't")
-	}
-}
-
 func TestGenerateDockerOptionsBoot2Docker(t *testing.T) {
 	p := &Boot2DockerProvisioner{
 		Driver: &fakedriver.Driver{},
@@ -208,3 +181,18 @@ func TestGetFilesystemType(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "btrfs", fsType)
 }
+
+func TestWriteRemoteFile(t *testing.T) {
+	commander := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo install -m 644 .docker-machine-ca.pem /etc/docker/ca.pem && rm -f .docker-machine-ca.pem": "",
+		},
+	}
+
+	err := writeRemoteFile(commander, "/etc/docker/ca.pem", []byte("CERTIFICATE"), 0644)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{".docker-machine-ca.pem": "CERTIFICATE"}, commander.Files)
+
+	err = writeRemoteFile(commander, "/etc/docker/server-key.pem", []byte("KEY"), 0600)
+	assert.Error(t, err)
+}
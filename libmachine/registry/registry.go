@@ -0,0 +1,130 @@
+// Package registry reads the credentials and the CA certs of the registries
+// the engines of the machines pull from.
+//
+// The credentials are given as sources which are read when provisioning, so
+// that no secret is stored in the config of the machines:
+//
+//	env:NAME     the variable NAME holds user:password
+//	file:PATH    the file at PATH holds user:password
+//	helper:NAME  the docker-credential-NAME credential helper gets them
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	SourceEnv    = "env"
+	SourceFile   = "file"
+	SourceHelper = "helper"
+)
+
+var errNoSeparator = errors.New("expected registry=value")
+
+// Credentials are the credentials of a registry.
+type Credentials struct {
+	Username string
+	Secret   string
+}
+
+// ParseOption splits an option given as registry=value.
+func ParseOption(option string) (string, string, error) {
+	parts := strings.SplitN(option, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("Invalid registry option %q: %s", option, errNoSeparator)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// ValidateSource checks that source is a source of credentials, without
+// reading them.
+func ValidateSource(source string) error {
+	kind, name, err := splitSource(source)
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		return fmt.Errorf("Invalid credential source %q: the %s source needs a name", source, kind)
+	}
+
+	return nil
+}
+
+func splitSource(source string) (string, string, error) {
+	parts := strings.SplitN(source, ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("Invalid credential source %q, expected env:NAME, file:PATH or helper:NAME", source)
+	}
+
+	switch parts[0] {
+	case SourceEnv, SourceFile, SourceHelper:
+		return parts[0], parts[1], nil
+	}
+
+	return "", "", fmt.Errorf("Invalid credential source %q, expected env:NAME, file:PATH or helper:NAME", source)
+}
+
+// ReadCredentials reads the credentials of the registry from source.
+func ReadCredentials(registry, source string) (Credentials, error) {
+	kind, name, err := splitSource(source)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	switch kind {
+	case SourceEnv:
+		value := os.Getenv(name)
+		if value == "" {
+			return Credentials{}, fmt.Errorf("The credentials of %s aren't set, %s is empty", registry, name)
+		}
+		return parseUserPassword(registry, value)
+	case SourceFile:
+		content, err := ioutil.ReadFile(name)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("Unable to read the credentials of %s: %s", registry, err)
+		}
+		return parseUserPassword(registry, strings.TrimSpace(string(content)))
+	default:
+		return readHelperCredentials(registry, name)
+	}
+}
+
+func parseUserPassword(registry, value string) (Credentials, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return Credentials{}, fmt.Errorf("The credentials of %s must be given as user:password", registry)
+	}
+
+	return Credentials{Username: parts[0], Secret: parts[1]}, nil
+}
+
+// readHelperCredentials gets the credentials with a credential helper of
+// the Docker CLI.
+func readHelperCredentials(registry, helper string) (Credentials, error) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(registry)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return Credentials{}, fmt.Errorf("The docker-credential-%s helper can't get the credentials of %s: %s %s", helper, registry, err, strings.TrimSpace(stdout.String()+stderr.String()))
+	}
+
+	credentials := Credentials{}
+	if err := json.Unmarshal(stdout.Bytes(), &credentials); err != nil {
+		return Credentials{}, fmt.Errorf("Unable to read the output of docker-credential-%s: %s", helper, err)
+	}
+
+	return credentials, nil
+}
@@ -0,0 +1,50 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOption(t *testing.T) {
+	name, value, err := ParseOption("registry.corp:5000=env:TOKEN")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "registry.corp:5000", name)
+	assert.Equal(t, "env:TOKEN", value)
+
+	_, _, err = ParseOption("registry.corp")
+	assert.EqualError(t, err, `Invalid registry option "registry.corp": expected registry=value`)
+}
+
+func TestValidateSource(t *testing.T) {
+	assert.NoError(t, ValidateSource("env:TOKEN"))
+	assert.NoError(t, ValidateSource("file:/etc/token"))
+	assert.NoError(t, ValidateSource("helper:pass"))
+	assert.EqualError(t, ValidateSource("env:"), `Invalid credential source "env:": the env source needs a name`)
+	assert.EqualError(t, ValidateSource("user:password"), `Invalid credential source "user:password", expected env:NAME, file:PATH or helper:NAME`)
+}
+
+func TestReadCredentials(t *testing.T) {
+	os.Setenv("MACHINE_TEST_REGISTRY_AUTH", "deploy:s3:cret")
+	defer os.Unsetenv("MACHINE_TEST_REGISTRY_AUTH")
+
+	credentials, err := ReadCredentials("registry.corp", "env:MACHINE_TEST_REGISTRY_AUTH")
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{Username: "deploy", Secret: "s3:cret"}, credentials)
+
+	file, err := ioutil.TempFile("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString("deploy:s3cret\n")
+	file.Close()
+
+	credentials, err = ReadCredentials("registry.corp", "file:"+file.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{Username: "deploy", Secret: "s3cret"}, credentials)
+
+	_, err = ReadCredentials("registry.corp", "env:MACHINE_TEST_REGISTRY_UNSET")
+	assert.EqualError(t, err, "The credentials of registry.corp aren't set, MACHINE_TEST_REGISTRY_UNSET is empty")
+}
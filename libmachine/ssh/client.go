@@ -1,12 +1,15 @@
 package ssh
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -14,7 +17,9 @@ import (
 	"github.com/docker/docker/pkg/term"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
@@ -33,18 +38,56 @@ type Client interface {
 	// Wait waits for the command started by the Start function to exit. The
 	// returned error follows the same logic as in the exec.Cmd.Wait function.
 	Wait() error
+
+	// Upload copies the local file or directory src to dst on the remote
+	// host, preserving the file modes. If dst is an existing directory, src
+	// is copied inside it.
+	Upload(src, dst string, opts TransferOptions) error
+
+	// Download copies the file or directory src from the remote host to
+	// the local dst, preserving the file modes.
+	Download(src, dst string, opts TransferOptions) error
+
+	// WriteFile writes content to the file at path on the remote host,
+	// creating it with the given mode if needed.
+	WriteFile(path string, content []byte, mode os.FileMode) error
+}
+
+// AgentForwarder is implemented by the clients able to forward the local
+// ssh-agent to the remote host during interactive sessions.
+type AgentForwarder interface {
+	SetForwardAgent(forward bool)
+}
+
+// Transcriber is implemented by the clients able to copy the output of the
+// interactive sessions to a writer, in addition to the terminal.
+type Transcriber interface {
+	SetTranscript(w io.Writer)
 }
 
 type ExternalClient struct {
-	BaseArgs   []string
-	BinaryPath string
-	cmd        *exec.Cmd
+	BaseArgs     []string
+	BinaryPath   string
+	ForwardAgent bool
+	Transcript   io.Writer
+	cmd          *exec.Cmd
+	// scpArgs and destination are the options and the user@host given
+	// to scp for the file transfers.
+	scpArgs     []string
+	destination string
 }
 
 type NativeClient struct {
-	Config      ssh.ClientConfig
-	Hostname    string
-	Port        int
+	Config       ssh.ClientConfig
+	Hostname     string
+	Port         int
+	ForwardAgent bool
+	Transcript   io.Writer
+	// DriverName is the name of the driver of the machine. Two machines
+	// reached at the same address through different drivers don't share
+	// their connection.
+	DriverName  string
+	identity    string
 	openSession *ssh.Session
 }
 
@@ -73,10 +116,16 @@ var (
 		"-o", "LogLevel=quiet", // suppress "Warning: Permanently added '[localhost]:2022' (ECDSA) to the list of known hosts."
 		"-o", "ConnectionAttempts=3", // retry 3 times if SSH connection fails
 		"-o", "ConnectTimeout=10", // timeout after 10 seconds
+	}
+	noMultiplexingSSHArgs = []string{
 		"-o", "ControlMaster=no", // disable ssh multiplexing
 		"-o", "ControlPath=none",
 	}
 	defaultClientType = External
+
+	// controlPathDir holds the ssh multiplexing sockets of the external
+	// client. Multiplexing is disabled when empty.
+	controlPathDir string
 )
 
 func SetDefaultClient(clientType ClientType) {
@@ -91,6 +140,29 @@ func SetDefaultClient(clientType ClientType) {
 	}
 }
 
+// SetControlPathDir enables connection multiplexing (ControlMaster) for the
+// external client, keeping the control sockets in dir. An empty dir
+// disables multiplexing.
+func SetControlPathDir(dir string) {
+	controlPathDir = dir
+}
+
+func multiplexingSSHArgs() ([]string, error) {
+	if controlPathDir == "" {
+		return noMultiplexingSSHArgs, nil
+	}
+
+	if err := os.MkdirAll(controlPathDir, 0700); err != nil {
+		return nil, fmt.Errorf("Error creating the SSH control path directory: %s", err)
+	}
+
+	return []string{
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPath=" + filepath.Join(controlPathDir, "%r@%h:%p"),
+		"-o", "ControlPersist=60s", // keep the master connection 60 seconds after the last session
+	}, nil
+}
+
 func NewClient(user string, host string, port int, auth *Auth) (Client, error) {
 	sshBinaryPath, err := exec.LookPath("ssh")
 	if err != nil {
@@ -100,6 +172,14 @@ func NewClient(user string, host string, port int, auth *Auth) (Client, error) {
 		return client, err
 	}
 
+	if len(auth.Passwords) > 0 {
+		// The ssh binary can't be given a password non-interactively.
+		log.Debug("Password authentication requested, using native Go implementation")
+		client, err := NewNativeClient(user, host, port, auth)
+		log.Debug(client)
+		return client, err
+	}
+
 	if defaultClientType == Native {
 		log.Debug("Using SSH client type: native")
 		client, err := NewNativeClient(user, host, port, auth)
@@ -123,63 +203,266 @@ func NewNativeClient(user, host string, port int, auth *Auth) (Client, error) {
 		Config:   config,
 		Hostname: host,
 		Port:     port,
+		identity: authIdentity(auth),
 	}, nil
 }
 
+// authIdentity identifies the credentials of auth in the key of the pooled
+// connections, so that a connection is only shared by the clients logged in
+// the same way. The passwords are hashed, the key is written to the logs.
+func authIdentity(auth *Auth) string {
+	identity := strings.Join(auth.Keys, ",")
+	if len(auth.Passwords) > 0 {
+		sum := sha256.Sum256([]byte(strings.Join(auth.Passwords, "\x00")))
+		identity += fmt.Sprintf(";password=%x", sum[:8])
+	}
+	return identity
+}
+
 func NewNativeConfig(user string, auth *Auth) (ssh.ClientConfig, error) {
 	var (
 		authMethods []ssh.AuthMethod
+		signers     []ssh.Signer
+		keyErr      error
 	)
 
 	for _, k := range auth.Keys {
-		key, err := ioutil.ReadFile(k)
+		signer, err := loadPrivateKey(k)
 		if err != nil {
-			return ssh.ClientConfig{}, err
+			log.Debugf("Unable to use SSH private key %s: %s", k, err)
+			keyErr = err
+			continue
 		}
 
-		privateKey, err := ssh.ParsePrivateKey(key)
+		signers = append(signers, signer)
+	}
+
+	// Fall back to the keys held by ssh-agent if no key was given or if
+	// one of the given keys could not be used.
+	if len(auth.Keys) == 0 || keyErr != nil {
+		agentSigners, err := getAgentSigners()
 		if err != nil {
-			return ssh.ClientConfig{}, err
+			log.Debugf("Unable to use ssh-agent: %s", err)
 		}
+		signers = append(signers, agentSigners...)
+	}
 
-		authMethods = append(authMethods, ssh.PublicKeys(privateKey))
+	// The passwords may still log in when none of the keys can be used.
+	if keyErr != nil && len(signers) == 0 && len(auth.Passwords) == 0 {
+		return ssh.ClientConfig{}, keyErr
+	}
+
+	// The Go SSH client only tries each authentication method once, so
+	// all the signers have to be offered through a single method.
+	if len(signers) > 0 {
+		authMethods = append(authMethods, ssh.PublicKeys(signers...))
 	}
 
 	for _, p := range auth.Passwords {
 		authMethods = append(authMethods, ssh.Password(p))
 	}
 
+	if len(auth.Passwords) > 0 {
+		authMethods = append(authMethods, ssh.KeyboardInteractive(keyboardInteractive(auth.Passwords)))
+	}
+
 	return ssh.ClientConfig{
-		User: user,
-		Auth: authMethods,
+		User:            user,
+		Auth:            authMethods,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 	}, nil
 }
 
-func (client *NativeClient) dialSuccess() bool {
-	if _, err := ssh.Dial("tcp", net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port)), &client.Config); err != nil {
-		log.Debugf("Error dialing TCP: %s", err)
-		return false
+func loadPrivateKey(path string) (ssh.Signer, error) {
+	key, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.ParsePrivateKey(key)
+}
+
+// getAgentSigners returns the keys held by the ssh-agent listening on
+// SSH_AUTH_SOCK. The signatures are computed by the agent itself, each
+// through its own connection which is closed right after, so that no
+// connection to the agent outlives the authentication.
+func getAgentSigners() ([]ssh.Signer, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("SSH_AUTH_SOCK is not set")
 	}
-	return true
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	keys, err := agent.NewClient(conn).List()
+	if err != nil {
+		return nil, err
+	}
+
+	signers := []ssh.Signer{}
+	for _, key := range keys {
+		signers = append(signers, &agentSigner{socket: socket, key: key})
+	}
+
+	return signers, nil
+}
+
+// agentSigner signs with a key held by the ssh-agent listening on socket.
+type agentSigner struct {
+	socket string
+	key    ssh.PublicKey
+}
+
+func (s *agentSigner) PublicKey() ssh.PublicKey {
+	return s.key
+}
+
+func (s *agentSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	conn, err := net.Dial("unix", s.socket)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return agent.NewClient(conn).Sign(s.key, data)
+}
+
+// keyboardInteractive answers every question asked by the server with the
+// first password. Questions that echo the answer are not secrets and are
+// left blank.
+func keyboardInteractive(passwords []string) ssh.KeyboardInteractiveChallenge {
+	return func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i := range questions {
+			if !echos[i] {
+				answers[i] = passwords[0]
+			}
+		}
+		return answers, nil
+	}
+}
+
+func (client *NativeClient) address() string {
+	return net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port))
+}
+
+func (client *NativeClient) poolKey() string {
+	return fmt.Sprintf("%s:%s@%s[%s]", client.DriverName, client.Config.User, client.address(), client.identity)
+}
+
+// dial opens a new connection, retrying while the machine is not reachable.
+func (client *NativeClient) dial() (*ssh.Client, error) {
+	var (
+		conn    *ssh.Client
+		lastErr error
+	)
+
+	if err := mcnutils.WaitFor(func() bool {
+		conn, lastErr = ssh.Dial("tcp", client.address(), &client.Config)
+		if lastErr != nil {
+			log.Debugf("Error dialing TCP: %s", lastErr)
+			return false
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("Error attempting SSH client dial: %s: %s", err, lastErr)
+	}
+
+	return conn, nil
+}
+
+// withConn runs open on the connection shared with the other clients of
+// the same machine. If it fails because the shared connection is broken,
+// the connection is replaced by a new one and open is retried.
+func (client *NativeClient) withConn(open func(conn *ssh.Client) error) error {
+	key := client.poolKey()
+
+	conn, err := defaultPool.get(key, client.dial)
+	if err != nil {
+		return err
+	}
+
+	err = open(conn)
+	if err == nil {
+		return nil
+	}
+
+	log.Debugf("Error using shared SSH connection, reconnecting: %s", err)
+	defaultPool.remove(key, conn)
+
+	conn, err = defaultPool.get(key, client.dial)
+	if err != nil {
+		return err
+	}
+
+	return open(conn)
 }
 
 func (client *NativeClient) session(command string) (*ssh.Session, error) {
-	if err := mcnutils.WaitFor(client.dialSuccess); err != nil {
-		return nil, fmt.Errorf("Error attempting SSH client dial: %s", err)
+	var session *ssh.Session
+
+	err := client.withConn(func(conn *ssh.Client) error {
+		var err error
+		session, err = conn.NewSession()
+		return err
+	})
+
+	return session, err
+}
+
+func (client *NativeClient) openSFTP() (*sftp.Client, error) {
+	var sftpClient *sftp.Client
+
+	err := client.withConn(func(conn *ssh.Client) error {
+		var err error
+		sftpClient, err = sftp.NewClient(conn)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error starting SFTP session: %s", err)
+	}
+
+	return sftpClient, nil
+}
+
+func (client *NativeClient) Upload(src, dst string, opts TransferOptions) error {
+	sftpClient, err := client.openSFTP()
+	if err != nil {
+		return err
 	}
+	defer sftpClient.Close()
 
-	conn, err := ssh.Dial("tcp", net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port)), &client.Config)
+	return sftpUpload(sftpClient, src, dst, opts)
+}
+
+func (client *NativeClient) Download(src, dst string, opts TransferOptions) error {
+	sftpClient, err := client.openSFTP()
 	if err != nil {
-		return nil, fmt.Errorf("Mysterious error dialing TCP for SSH (we already succeeded at least once) : %s", err)
+		return err
 	}
+	defer sftpClient.Close()
 
-	return conn.NewSession()
+	return sftpDownload(sftpClient, src, dst, opts)
+}
+
+func (client *NativeClient) WriteFile(path string, content []byte, mode os.FileMode) error {
+	sftpClient, err := client.openSFTP()
+	if err != nil {
+		return err
+	}
+	defer sftpClient.Close()
+
+	return sftpWriteFile(sftpClient, path, content, mode)
 }
 
 func (client *NativeClient) Output(command string) (string, error) {
 	session, err := client.session(command)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	output, err := session.CombinedOutput(command)
@@ -191,7 +474,7 @@ func (client *NativeClient) Output(command string) (string, error) {
 func (client *NativeClient) OutputWithPty(command string) (string, error) {
 	session, err := client.session(command)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	fd := int(os.Stdin.Fd())
@@ -252,10 +535,19 @@ func (client *NativeClient) Shell(args ...string) error {
 	var (
 		termWidth, termHeight int
 	)
-	conn, err := ssh.Dial("tcp", net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port)), &client.Config)
+	// Interactive sessions get their own connection since agent forwarding
+	// is set up on the whole connection.
+	conn, err := ssh.Dial("tcp", client.address(), &client.Config)
 	if err != nil {
 		return err
 	}
+	defer conn.Close()
+
+	if client.ForwardAgent {
+		if err := forwardAgent(conn); err != nil {
+			return err
+		}
+	}
 
 	session, err := conn.NewSession()
 	if err != nil {
@@ -264,8 +556,14 @@ func (client *NativeClient) Shell(args ...string) error {
 
 	defer session.Close()
 
-	session.Stdout = os.Stdout
-	session.Stderr = os.Stderr
+	if client.ForwardAgent {
+		if err := agent.RequestAgentForwarding(session); err != nil {
+			return fmt.Errorf("Error requesting agent forwarding: %s", err)
+		}
+	}
+
+	session.Stdout = withTranscript(os.Stdout, client.Transcript)
+	session.Stderr = withTranscript(os.Stderr, client.Transcript)
 	session.Stdin = os.Stdin
 
 	modes := ssh.TerminalModes{
@@ -308,17 +606,43 @@ func (client *NativeClient) Shell(args ...string) error {
 	return nil
 }
 
+func (client *NativeClient) SetTranscript(w io.Writer) {
+	client.Transcript = w
+}
+
+func (client *NativeClient) SetForwardAgent(forward bool) {
+	client.ForwardAgent = forward
+}
+
+func forwardAgent(conn *ssh.Client) error {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return errors.New("Cannot forward ssh-agent: SSH_AUTH_SOCK is not set")
+	}
+
+	return agent.ForwardToRemote(conn, socket)
+}
+
 func NewExternalClient(sshBinaryPath, user, host string, port int, auth *Auth) (*ExternalClient, error) {
 	client := &ExternalClient{
 		BinaryPath: sshBinaryPath,
 	}
 
-	args := append(baseSSHArgs, fmt.Sprintf("%s@%s", user, host))
+	multiplexingArgs, err := multiplexingSSHArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string{}, baseSSHArgs...)
+	args = append(args, multiplexingArgs...)
+	scpArgs := append([]string{}, args...)
+	args = append(args, fmt.Sprintf("%s@%s", user, host))
 
 	// If no identities are explicitly provided, also look at the identities
 	// offered by ssh-agent
 	if len(auth.Keys) > 0 {
 		args = append(args, "-o", "IdentitiesOnly=yes")
+		scpArgs = append(scpArgs, "-o", "IdentitiesOnly=yes")
 	}
 
 	// Specify which private keys to use to authorize the SSH request.
@@ -343,13 +667,17 @@ func NewExternalClient(sshBinaryPath, user, host string, port int, auth *Auth) (
 				}
 			}
 			args = append(args, "-i", privateKeyPath)
+			scpArgs = append(scpArgs, "-i", privateKeyPath)
 		}
 	}
 
 	// Set which port to use for SSH.
 	args = append(args, "-p", fmt.Sprintf("%d", port))
+	scpArgs = append(scpArgs, "-P", fmt.Sprintf("%d", port))
 
 	client.BaseArgs = args
+	client.scpArgs = scpArgs
+	client.destination = fmt.Sprintf("%s@%s", user, host)
 
 	return client, nil
 }
@@ -365,19 +693,41 @@ func (client *ExternalClient) Output(command string) (string, error) {
 	return string(output), err
 }
 
+func (client *ExternalClient) SetForwardAgent(forward bool) {
+	client.ForwardAgent = forward
+}
+
+func (client *ExternalClient) shellArgs(args ...string) []string {
+	baseArgs := client.BaseArgs
+	if client.ForwardAgent {
+		baseArgs = append([]string{"-A"}, baseArgs...)
+	}
+	return append(baseArgs, args...)
+}
+
 func (client *ExternalClient) Shell(args ...string) error {
-	args = append(client.BaseArgs, args...)
-	cmd := getSSHCmd(client.BinaryPath, args...)
+	cmd := getSSHCmd(client.BinaryPath, client.shellArgs(args...)...)
 
 	log.Debug(cmd)
 
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = withTranscript(os.Stdout, client.Transcript)
+	cmd.Stderr = withTranscript(os.Stderr, client.Transcript)
 
 	return cmd.Run()
 }
 
+func (client *ExternalClient) SetTranscript(w io.Writer) {
+	client.Transcript = w
+}
+
+func withTranscript(w io.Writer, transcript io.Writer) io.Writer {
+	if transcript == nil {
+		return w
+	}
+	return io.MultiWriter(w, transcript)
+}
+
 func (client *ExternalClient) Start(command string) (io.ReadCloser, io.ReadCloser, error) {
 	args := append(client.BaseArgs, command)
 	cmd := getSSHCmd(client.BinaryPath, args...)
@@ -414,3 +764,116 @@ func (client *ExternalClient) Wait() error {
 	client.cmd = nil
 	return err
 }
+
+// scp runs the scp binary with the options needed to reach the remote host,
+// showing its progress meter. The remote paths in args must be prefixed
+// with ':'.
+func (client *ExternalClient) scp(args ...string) error {
+	scpPath, err := exec.LookPath("scp")
+	if err != nil {
+		return errors.New("Error: You must have a copy of the scp binary locally to show the progress of the copies with the external SSH client. Use --native-ssh otherwise.")
+	}
+
+	scpArgs := append([]string{}, client.scpArgs...)
+	for _, arg := range args {
+		if strings.HasPrefix(arg, ":") {
+			arg = client.destination + arg
+		}
+		scpArgs = append(scpArgs, arg)
+	}
+
+	cmd := exec.Command(scpPath, scpArgs...)
+	log.Debug(cmd)
+
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// openSFTP starts an SFTP session through the ssh binary, over the master
+// connection when multiplexing is enabled.
+func (client *ExternalClient) openSFTP() (*sftp.Client, func() error, error) {
+	cmd := getSSHCmd(client.BinaryPath, append(client.BaseArgs, "-s", "sftp")...)
+	log.Debug(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	sftpClient, err := sftp.NewClientPipe(stdout, stdin)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, nil, fmt.Errorf("Error starting SFTP session: %s", err)
+	}
+
+	closer := func() error {
+		sftpClient.Close()
+		return cmd.Wait()
+	}
+
+	return sftpClient, closer, nil
+}
+
+// Upload goes through SFTP, unless a progress function is given: scp is used
+// then, which displays its own progress meter. The delta and filtered
+// transfers always go through SFTP.
+func (client *ExternalClient) Upload(src, dst string, opts TransferOptions) error {
+	if opts.needsSFTP() || opts.Progress == nil {
+		sftpClient, closer, err := client.openSFTP()
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		return sftpUpload(sftpClient, src, dst, opts)
+	}
+
+	args := []string{"-p"}
+	if opts.Recursive {
+		args = append(args, "-r")
+	}
+
+	return client.scp(append(args, src, ":"+dst)...)
+}
+
+// Download goes through SFTP, unless a progress function is given: scp is
+// used then, which displays its own progress meter. The delta and filtered
+// transfers always go through SFTP.
+func (client *ExternalClient) Download(src, dst string, opts TransferOptions) error {
+	if opts.needsSFTP() || opts.Progress == nil {
+		sftpClient, closer, err := client.openSFTP()
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		return sftpDownload(sftpClient, src, dst, opts)
+	}
+
+	args := []string{"-p"}
+	if opts.Recursive {
+		args = append(args, "-r")
+	}
+
+	return client.scp(append(args, ":"+src, dst)...)
+}
+
+// WriteFile goes through SFTP, so that no scp binary is needed locally.
+func (client *ExternalClient) WriteFile(path string, content []byte, mode os.FileMode) error {
+	sftpClient, closer, err := client.openSFTP()
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	return sftpWriteFile(sftpClient, path, content, mode)
+}
@@ -1,13 +1,21 @@
 package ssh
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
 	"runtime"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 func TestGetSSHCmdArgs(t *testing.T) {
@@ -104,3 +112,216 @@ func TestNewExternalClient(t *testing.T) {
 		}
 	}
 }
+
+func publicKeyServerConfig(t *testing.T, authorized ssh.PublicKey) *ssh.ServerConfig {
+	return &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized key")
+		},
+	}
+}
+
+func TestNativeClientUsesAgentWithoutKeys(t *testing.T) {
+	key := newTestKey(t)
+	server := newTestServer(t, publicKeyServerConfig(t, newTestSigner(t, key).PublicKey()))
+	defer server.Close()
+
+	defer startTestAgent(t, key)()
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{})
+	assert.NoError(t, err)
+
+	output, err := client.Output("echo hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "echo hello", output)
+}
+
+func TestNativeClientFallsBackToAgentWhenKeyFails(t *testing.T) {
+	key := newTestKey(t)
+	server := newTestServer(t, publicKeyServerConfig(t, newTestSigner(t, key).PublicKey()))
+	defer server.Close()
+
+	defer startTestAgent(t, key)()
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{Keys: []string{"/tmp/private-key-not-exist"}})
+	assert.NoError(t, err)
+
+	output, err := client.Output("echo hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "echo hello", output)
+}
+
+func TestNativeClientClosesAgentConnections(t *testing.T) {
+	key := newTestKey(t)
+	server := newTestServer(t, publicKeyServerConfig(t, newTestSigner(t, key).PublicKey()))
+	defer server.Close()
+
+	keyring := agent.NewKeyring()
+	assert.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: key}))
+
+	tmpDir, err := ioutil.TempDir("", "machine-test-agent-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	listener, err := net.Listen("unix", filepath.Join(tmpDir, "agent.sock"))
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	served := sync.WaitGroup{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			served.Add(1)
+			go func() {
+				defer served.Done()
+				agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	oldSocket := os.Getenv("SSH_AUTH_SOCK")
+	defer os.Setenv("SSH_AUTH_SOCK", oldSocket)
+	os.Setenv("SSH_AUTH_SOCK", listener.Addr().String())
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{})
+	assert.NoError(t, err)
+
+	_, err = client.Output("echo hello")
+	assert.NoError(t, err)
+
+	// The agent only stops serving a connection once the client closed it.
+	done := make(chan struct{})
+	go func() {
+		served.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The connections to ssh-agent were left open")
+	}
+}
+
+func TestNewNativeConfigKeyErrorWithoutAgent(t *testing.T) {
+	oldSocket := os.Getenv("SSH_AUTH_SOCK")
+	defer os.Setenv("SSH_AUTH_SOCK", oldSocket)
+	os.Unsetenv("SSH_AUTH_SOCK")
+
+	_, err := NewNativeConfig("docker", &Auth{Keys: []string{"/tmp/private-key-not-exist"}})
+	assert.EqualError(t, err, "open /tmp/private-key-not-exist: no such file or directory")
+}
+
+func TestNewNativeConfigKeyErrorWithPassword(t *testing.T) {
+	oldSocket := os.Getenv("SSH_AUTH_SOCK")
+	defer os.Setenv("SSH_AUTH_SOCK", oldSocket)
+	os.Unsetenv("SSH_AUTH_SOCK")
+
+	config, err := NewNativeConfig("docker", &Auth{Keys: []string{"/tmp/private-key-not-exist"}, Passwords: []string{"s3cr3t"}})
+	assert.NoError(t, err)
+	assert.Len(t, config.Auth, 2)
+}
+
+func TestNativeClientPasswordAuth(t *testing.T) {
+	server := newTestServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) == "s3cr3t" {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+	})
+	defer server.Close()
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{Passwords: []string{"s3cr3t"}})
+	assert.NoError(t, err)
+
+	output, err := client.Output("uptime")
+	assert.NoError(t, err)
+	assert.Equal(t, "uptime", output)
+}
+
+func TestNativeClientKeyboardInteractiveAuth(t *testing.T) {
+	server := newTestServer(t, &ssh.ServerConfig{
+		KeyboardInteractiveCallback: func(conn ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := challenge("docker", "", []string{"Password: "}, []bool{false})
+			if err != nil {
+				return nil, err
+			}
+			if len(answers) == 1 && answers[0] == "s3cr3t" {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+	})
+	defer server.Close()
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{Passwords: []string{"s3cr3t"}})
+	assert.NoError(t, err)
+
+	output, err := client.Output("uptime")
+	assert.NoError(t, err)
+	assert.Equal(t, "uptime", output)
+}
+
+func TestNativeClientForwardAgent(t *testing.T) {
+	key := newTestKey(t)
+	publicKey := newTestSigner(t, key).PublicKey()
+	server := newTestServer(t, publicKeyServerConfig(t, publicKey))
+	defer server.Close()
+
+	defer startTestAgent(t, key)()
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{})
+	assert.NoError(t, err)
+
+	client.(AgentForwarder).SetForwardAgent(true)
+
+	err = client.Shell("true")
+	assert.NoError(t, err)
+
+	forwardedKeys := server.ForwardedKeys()
+	if assert.Len(t, forwardedKeys, 1) {
+		assert.Equal(t, publicKey.Marshal(), forwardedKeys[0].Marshal())
+	}
+}
+
+func TestExternalClientShellArgs(t *testing.T) {
+	client := &ExternalClient{
+		BaseArgs: []string{"docker@localhost", "-p", "22"},
+	}
+
+	assert.Equal(t, []string{"docker@localhost", "-p", "22", "df", "-h"}, client.shellArgs("df", "-h"))
+
+	client.SetForwardAgent(true)
+
+	assert.Equal(t, []string{"-A", "docker@localhost", "-p", "22", "df", "-h"}, client.shellArgs("df", "-h"))
+}
+
+func TestNewExternalClientMultiplexing(t *testing.T) {
+	defer SetControlPathDir("")
+
+	client, err := NewExternalClient("/usr/bin/ssh", "docker", "localhost", 22, &Auth{})
+	assert.NoError(t, err)
+	assert.Contains(t, client.BaseArgs, "ControlMaster=no")
+	assert.Contains(t, client.BaseArgs, "ControlPath=none")
+
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	SetControlPathDir(filepath.Join(tmpDir, "ssh"))
+
+	client, err = NewExternalClient("/usr/bin/ssh", "docker", "localhost", 22, &Auth{})
+	assert.NoError(t, err)
+	assert.Contains(t, client.BaseArgs, "ControlMaster=auto")
+	assert.Contains(t, client.BaseArgs, "ControlPath="+filepath.Join(tmpDir, "ssh", "%r@%h:%p"))
+
+	_, err = os.Stat(filepath.Join(tmpDir, "ssh"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,118 @@
+package ssh
+
+import (
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// keepAliveInterval is the delay between two keepalive requests sent
+	// on the connections held by the pool.
+	keepAliveInterval = 30 * time.Second
+
+	defaultPool = newConnPool()
+)
+
+// connPool holds the connections opened by the native client so that
+// consecutive commands sent to the same machine share a single SSH
+// handshake. Connections are keyed by driver, user, address and
+// credentials.
+type connPool struct {
+	sync.Mutex
+	conns map[string]*ssh.Client
+}
+
+func newConnPool() *connPool {
+	return &connPool{
+		conns: map[string]*ssh.Client{},
+	}
+}
+
+// get returns the pooled connection for key, dialing a new one if there is
+// none yet. The lock isn't held while dialing so that a slow machine
+// doesn't hold up the others.
+func (p *connPool) get(key string, dial func() (*ssh.Client, error)) (*ssh.Client, error) {
+	p.Lock()
+	conn, ok := p.conns[key]
+	p.Unlock()
+
+	if ok {
+		return conn, nil
+	}
+
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if existing, ok := p.conns[key]; ok {
+		conn.Close()
+		return existing, nil
+	}
+
+	p.conns[key] = conn
+	go p.keepAlive(key, conn)
+
+	return conn, nil
+}
+
+// remove closes conn and drops it from the pool, unless it was already
+// replaced by another connection.
+func (p *connPool) remove(key string, conn *ssh.Client) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.conns[key] == conn {
+		delete(p.conns, key)
+	}
+	conn.Close()
+}
+
+func (p *connPool) closeAll() {
+	p.Lock()
+	defer p.Unlock()
+
+	for key, conn := range p.conns {
+		conn.Close()
+		delete(p.conns, key)
+	}
+}
+
+// keepAlive periodically checks that conn is still usable and removes it
+// from the pool as soon as it isn't.
+func (p *connPool) keepAlive(key string, conn *ssh.Client) {
+	done := make(chan struct{})
+	go func() {
+		conn.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			p.remove(key, conn)
+			return
+		case <-ticker.C:
+			if _, _, err := conn.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				log.Debugf("SSH keepalive to %s failed: %s", key, err)
+				p.remove(key, conn)
+				return
+			}
+		}
+	}
+}
+
+// CloseConnections closes all the connections kept open by the native
+// client.
+func CloseConnections() {
+	defaultPool.closeAll()
+}
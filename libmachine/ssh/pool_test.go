@@ -0,0 +1,123 @@
+package ssh
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func newPasswordTestServer(t *testing.T) *testServer {
+	return newTestServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) == "s3cr3t" {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+	})
+}
+
+func TestNativeClientReusesConnection(t *testing.T) {
+	server := newPasswordTestServer(t)
+	defer server.Close()
+	defer CloseConnections()
+
+	for _, command := range []string{"hostname", "cat /etc/os-release", "uptime"} {
+		client, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{Passwords: []string{"s3cr3t"}})
+		assert.NoError(t, err)
+
+		output, err := client.Output(command)
+		assert.NoError(t, err)
+		assert.Equal(t, command, output)
+	}
+
+	assert.Len(t, server.Conns(), 1)
+}
+
+func TestNativeClientSharesConnectionOnlyWithSameCredentials(t *testing.T) {
+	server := newPasswordTestServer(t)
+	defer server.Close()
+	defer CloseConnections()
+
+	first, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{Passwords: []string{"s3cr3t"}})
+	assert.NoError(t, err)
+	_, err = first.Output("hostname")
+	assert.NoError(t, err)
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{Passwords: []string{"s3cr3t"}})
+	assert.NoError(t, err)
+	client.(*NativeClient).DriverName = "generic"
+	_, err = client.Output("hostname")
+	assert.NoError(t, err)
+
+	assert.Len(t, server.Conns(), 2)
+
+	// A client with other credentials doesn't get the connection opened
+	// with the right ones.
+	wrong, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{Passwords: []string{"wrong"}})
+	assert.NoError(t, err)
+	key, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{Keys: []string{"/tmp/private-key-not-exist"}, Passwords: []string{"s3cr3t"}})
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.(*NativeClient).poolKey(), wrong.(*NativeClient).poolKey())
+	assert.NotEqual(t, first.(*NativeClient).poolKey(), key.(*NativeClient).poolKey())
+	assert.NotContains(t, wrong.(*NativeClient).poolKey(), "wrong")
+}
+
+func TestNativeClientReconnects(t *testing.T) {
+	server := newPasswordTestServer(t)
+	defer server.Close()
+	defer CloseConnections()
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{Passwords: []string{"s3cr3t"}})
+	assert.NoError(t, err)
+
+	_, err = client.Output("hostname")
+	assert.NoError(t, err)
+
+	// Simulate a connection dropped by the network.
+	server.Conns()[0].Close()
+
+	output, err := client.Output("uptime")
+	assert.NoError(t, err)
+	assert.Equal(t, "uptime", output)
+
+	assert.Len(t, server.Conns(), 2)
+}
+
+func TestConnPoolKeepAliveDropsDeadConnections(t *testing.T) {
+	defer func(interval time.Duration) { keepAliveInterval = interval }(keepAliveInterval)
+	keepAliveInterval = 10 * time.Millisecond
+
+	server := newPasswordTestServer(t)
+	defer server.Close()
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{Passwords: []string{"s3cr3t"}})
+	assert.NoError(t, err)
+
+	pool := newConnPool()
+	nativeClient := client.(*NativeClient)
+	conn, err := pool.get(nativeClient.poolKey(), nativeClient.dial)
+	assert.NoError(t, err)
+
+	server.Conns()[0].Close()
+
+	for i := 0; i < 100; i++ {
+		pool.Lock()
+		_, ok := pool.conns[nativeClient.poolKey()]
+		pool.Unlock()
+		if !ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	pool.Lock()
+	assert.Empty(t, pool.conns)
+	pool.Unlock()
+
+	_, _, err = conn.SendRequest("keepalive@openssh.com", true, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,216 @@
+package ssh
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// testServer is an in-process SSH server which answers every command by
+// echoing it back. It also serves the sftp subsystem on the local
+// filesystem.
+type testServer struct {
+	listener net.Listener
+	port     int
+
+	mu sync.Mutex
+	// forwardedKeys lists the keys found in the agent forwarded by the
+	// client, if any.
+	forwardedKeys []*agent.Key
+	// conns holds the connections established so far.
+	conns []*ssh.ServerConn
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func newTestSigner(t *testing.T, key *ecdsa.PrivateKey) ssh.Signer {
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer
+}
+
+func newTestServer(t *testing.T, config *ssh.ServerConfig) *testServer {
+	config.AddHostKey(newTestSigner(t, newTestKey(t)))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &testServer{
+		listener: listener,
+		port:     listener.Addr().(*net.TCPAddr).Port,
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.handle(conn, config)
+		}
+	}()
+
+	return server
+}
+
+func (s *testServer) Close() {
+	s.listener.Close()
+}
+
+func (s *testServer) ForwardedKeys() []*agent.Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.forwardedKeys
+}
+
+func (s *testServer) Conns() []*ssh.ServerConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+func (s *testServer) handle(netConn net.Conn, config *ssh.ServerConfig) {
+	conn, chans, reqs, err := ssh.NewServerConn(netConn, config)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	s.mu.Lock()
+	s.conns = append(s.conns, conn)
+	s.mu.Unlock()
+
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handleSession(conn, channel, requests)
+	}
+}
+
+func (s *testServer) handleSession(conn *ssh.ServerConn, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		switch req.Type {
+		case "auth-agent-req@openssh.com":
+			req.Reply(true, nil)
+			s.listForwardedKeys(conn)
+		case "exec":
+			req.Reply(true, nil)
+
+			// The payload is the command, prefixed by its length.
+			channel.Write(req.Payload[4:])
+
+			status := make([]byte, 4)
+			binary.BigEndian.PutUint32(status, 0)
+			channel.SendRequest("exit-status", false, status)
+			return
+		case "subsystem":
+			if string(req.Payload[4:]) != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			server.Serve()
+			return
+		default:
+			req.Reply(true, nil)
+		}
+	}
+}
+
+func (s *testServer) listForwardedKeys(conn *ssh.ServerConn) {
+	channel, reqs, err := conn.OpenChannel("auth-agent@openssh.com", nil)
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	go ssh.DiscardRequests(reqs)
+
+	keys, err := agent.NewClient(channel).List()
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.forwardedKeys = keys
+	s.mu.Unlock()
+}
+
+// startTestAgent serves a keyring holding the given key on a unix socket
+// and points SSH_AUTH_SOCK at it. The returned function restores the
+// environment.
+func startTestAgent(t *testing.T, key *ecdsa.PrivateKey) func() {
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "machine-test-agent-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("unix", filepath.Join(tmpDir, "agent.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	oldSocket := os.Getenv("SSH_AUTH_SOCK")
+	os.Setenv("SSH_AUTH_SOCK", listener.Addr().String())
+
+	return func() {
+		os.Setenv("SSH_AUTH_SOCK", oldSocket)
+		listener.Close()
+		os.RemoveAll(tmpDir)
+	}
+}
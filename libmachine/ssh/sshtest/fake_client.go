@@ -1,15 +1,32 @@
 package sshtest
 
-import "io"
+import (
+	"io"
+	"os"
+
+	"github.com/docker/machine/libmachine/ssh"
+)
 
 type CmdResult struct {
 	Out string
 	Err error
 }
 
+// Transfer records a call to Upload or Download.
+type Transfer struct {
+	Src, Dst string
+	Opts     ssh.TransferOptions
+}
+
 type FakeClient struct {
 	ActivatedShell []string
 	Outputs        map[string]CmdResult
+	ForwardAgent   bool
+	Transcript     io.Writer
+	Uploads        []Transfer
+	Downloads      []Transfer
+	Files          map[string][]byte
+	TransferErr    error
 }
 
 func (fsc *FakeClient) Output(command string) (string, error) {
@@ -22,6 +39,14 @@ func (fsc *FakeClient) Shell(args ...string) error {
 	return nil
 }
 
+func (fsc *FakeClient) SetForwardAgent(forward bool) {
+	fsc.ForwardAgent = forward
+}
+
+func (fsc *FakeClient) SetTranscript(w io.Writer) {
+	fsc.Transcript = w
+}
+
 func (fsc *FakeClient) Start(command string) (io.ReadCloser, io.ReadCloser, error) {
 	return nil, nil, nil
 }
@@ -29,3 +54,21 @@ func (fsc *FakeClient) Start(command string) (io.ReadCloser, io.ReadCloser, erro
 func (fsc *FakeClient) Wait() error {
 	return nil
 }
+
+func (fsc *FakeClient) Upload(src, dst string, opts ssh.TransferOptions) error {
+	fsc.Uploads = append(fsc.Uploads, Transfer{src, dst, opts})
+	return fsc.TransferErr
+}
+
+func (fsc *FakeClient) Download(src, dst string, opts ssh.TransferOptions) error {
+	fsc.Downloads = append(fsc.Downloads, Transfer{src, dst, opts})
+	return fsc.TransferErr
+}
+
+func (fsc *FakeClient) WriteFile(path string, content []byte, mode os.FileMode) error {
+	if fsc.Files == nil {
+		fsc.Files = map[string][]byte{}
+	}
+	fsc.Files[path] = content
+	return fsc.TransferErr
+}
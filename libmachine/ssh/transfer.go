@@ -0,0 +1,294 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/pkg/sftp"
+)
+
+// TransferOptions tune the copies made with Client.Upload and
+// Client.Download.
+type TransferOptions struct {
+	// Recursive is required to copy directories.
+	Recursive bool
+
+	// Delta only transfers the files whose size or modification time
+	// differ from the ones at the destination.
+	Delta bool
+
+	// Progress, if set, is called as the files are copied.
+	Progress ProgressFunc
+
+	// Exclude, if set, is called with the slash separated path of each
+	// file relative to the copied directory. The files for which it
+	// returns true are skipped, as well as the content of the directories.
+	Exclude func(rel string, info os.FileInfo) bool
+}
+
+// needsSFTP tells whether the options can't be honoured by scp.
+func (opts TransferOptions) needsSFTP() bool {
+	return opts.Delta || opts.Exclude != nil
+}
+
+func (opts TransferOptions) excluded(rel string, info os.FileInfo) bool {
+	return opts.Exclude != nil && rel != "." && opts.Exclude(rel, info)
+}
+
+// ProgressFunc reports that copied bytes out of total have been transferred
+// for the file at path.
+type ProgressFunc func(path string, copied, total int64)
+
+type progressReader struct {
+	io.Reader
+	path     string
+	copied   int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.copied += int64(n)
+		r.progress(r.path, r.copied, r.total)
+	}
+	return n, err
+}
+
+func withProgress(reader io.Reader, path string, total int64, progress ProgressFunc) io.Reader {
+	if progress == nil {
+		return reader
+	}
+
+	return &progressReader{
+		Reader:   reader,
+		path:     path,
+		total:    total,
+		progress: progress,
+	}
+}
+
+// unchanged tells whether the delta mode can skip a file. The modification
+// times are compared to the second since that's all SFTP keeps.
+func unchanged(src, dst os.FileInfo) bool {
+	return src.Size() == dst.Size() && src.ModTime().Unix() == dst.ModTime().Unix()
+}
+
+// sftpUpload copies the local file or directory src to dst, following the
+// scp semantics: if dst is an existing directory, src is copied inside it.
+func sftpUpload(client *sftp.Client, src, dst string, opts TransferOptions) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if remoteInfo, err := client.Stat(dst); err == nil && remoteInfo.IsDir() {
+		dst = path.Join(dst, filepath.Base(src))
+	}
+
+	if !info.IsDir() {
+		return sftpUploadFile(client, src, dst, info, opts)
+	}
+
+	if !opts.Recursive {
+		return fmt.Errorf("%s is a directory, a recursive copy is required", src)
+	}
+
+	return filepath.Walk(src, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, localPath)
+		if err != nil {
+			return err
+		}
+
+		if opts.excluded(filepath.ToSlash(rel), info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// due to windows clients, we cannot use filepath.Join on the
+		// remote paths
+		remotePath := path.Join(dst, filepath.ToSlash(rel))
+
+		switch {
+		case info.IsDir():
+			if err := client.MkdirAll(remotePath); err != nil {
+				return fmt.Errorf("Error creating remote directory %s: %s", remotePath, err)
+			}
+			return client.Chmod(remotePath, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			return sftpUploadFile(client, localPath, remotePath, info, opts)
+		default:
+			log.Debugf("Skipping %s: not a regular file", localPath)
+			return nil
+		}
+	})
+}
+
+func sftpUploadFile(client *sftp.Client, src, dst string, info os.FileInfo, opts TransferOptions) error {
+	if opts.Delta {
+		if remoteInfo, err := client.Stat(dst); err == nil && unchanged(info, remoteInfo) {
+			log.Debugf("Skipping unchanged file %s", dst)
+			return nil
+		}
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := sftpCreate(client, dst, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, withProgress(in, dst, info.Size(), opts.Progress)); err != nil {
+		out.Close()
+		return fmt.Errorf("Error copying %s to %s: %s", src, dst, err)
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return client.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// sftpDownload copies the remote file or directory src to the local dst,
+// following the scp semantics.
+func sftpDownload(client *sftp.Client, src, dst string, opts TransferOptions) error {
+	info, err := client.Stat(src)
+	if err != nil {
+		return fmt.Errorf("Error reading remote file %s: %s", src, err)
+	}
+
+	if localInfo, err := os.Stat(dst); err == nil && localInfo.IsDir() {
+		dst = filepath.Join(dst, path.Base(src))
+	}
+
+	if !info.IsDir() {
+		return sftpDownloadFile(client, src, dst, info, opts)
+	}
+
+	if !opts.Recursive {
+		return fmt.Errorf("%s is a directory, a recursive copy is required", src)
+	}
+
+	walker := client.Walk(src)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+
+		remotePath := walker.Path()
+		rel, err := filepath.Rel(filepath.FromSlash(src), filepath.FromSlash(remotePath))
+		if err != nil {
+			return err
+		}
+		localPath := filepath.Join(dst, rel)
+
+		info := walker.Stat()
+		if opts.excluded(filepath.ToSlash(rel), info) {
+			if info.IsDir() {
+				walker.SkipDir()
+			}
+			continue
+		}
+
+		switch {
+		case info.IsDir():
+			if err := os.MkdirAll(localPath, info.Mode().Perm()); err != nil {
+				return err
+			}
+			if err := os.Chmod(localPath, info.Mode().Perm()); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if err := sftpDownloadFile(client, remotePath, localPath, info, opts); err != nil {
+				return err
+			}
+		default:
+			log.Debugf("Skipping %s: not a regular file", remotePath)
+		}
+	}
+
+	return nil
+}
+
+func sftpDownloadFile(client *sftp.Client, src, dst string, info os.FileInfo, opts TransferOptions) error {
+	if opts.Delta {
+		if localInfo, err := os.Stat(dst); err == nil && unchanged(info, localInfo) {
+			log.Debugf("Skipping unchanged file %s", dst)
+			return nil
+		}
+	}
+
+	in, err := client.Open(src)
+	if err != nil {
+		return fmt.Errorf("Error opening remote file %s: %s", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, withProgress(in, dst, info.Size(), opts.Progress)); err != nil {
+		out.Close()
+		return fmt.Errorf("Error copying %s to %s: %s", src, dst, err)
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+func sftpWriteFile(client *sftp.Client, dst string, content []byte, mode os.FileMode) error {
+	out, err := sftpCreate(client, dst, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := out.Write(content); err != nil {
+		out.Close()
+		return fmt.Errorf("Error writing remote file %s: %s", dst, err)
+	}
+
+	return out.Close()
+}
+
+// sftpCreate creates or truncates the remote file dst and sets its mode
+// before any content is written to it, so that it's never readable with a
+// wider mode.
+func sftpCreate(client *sftp.Client, dst string, mode os.FileMode) (*sftp.File, error) {
+	out, err := client.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating remote file %s: %s", dst, err)
+	}
+
+	if err := out.Chmod(mode); err != nil {
+		out.Close()
+		return nil, fmt.Errorf("Error setting the mode of remote file %s: %s", dst, err)
+	}
+
+	return out, nil
+}
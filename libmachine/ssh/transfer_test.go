@@ -0,0 +1,281 @@
+package ssh
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTransferTestClient(t *testing.T) (Client, func()) {
+	server := newPasswordTestServer(t)
+
+	client, err := NewNativeClient("docker", "127.0.0.1", server.port, &Auth{Passwords: []string{"s3cr3t"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, func() {
+		CloseConnections()
+		server.Close()
+	}
+}
+
+func newTransferTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "machine-test-transfer-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func writeTestFile(t *testing.T, path, content string, mode os.FileMode) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertFile(t *testing.T, path, content string, mode os.FileMode) {
+	data, err := ioutil.ReadFile(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, content, string(data))
+	}
+
+	info, err := os.Stat(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, mode, info.Mode().Perm())
+	}
+}
+
+func TestNativeClientUploadRecursive(t *testing.T) {
+	client, cleanup := newTransferTestClient(t)
+	defer cleanup()
+
+	src, dst := newTransferTestDir(t), newTransferTestDir(t)
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	writeTestFile(t, filepath.Join(src, "app", "run.sh"), "#!/bin/sh", 0755)
+	writeTestFile(t, filepath.Join(src, "app", "conf", "app.yml"), "debug: true", 0600)
+
+	err := client.Upload(filepath.Join(src, "app"), dst, TransferOptions{})
+	assert.Error(t, err)
+
+	err = client.Upload(filepath.Join(src, "app"), dst, TransferOptions{Recursive: true})
+	assert.NoError(t, err)
+
+	assertFile(t, filepath.Join(dst, "app", "run.sh"), "#!/bin/sh", 0755)
+	assertFile(t, filepath.Join(dst, "app", "conf", "app.yml"), "debug: true", 0600)
+}
+
+func TestNativeClientDownload(t *testing.T) {
+	client, cleanup := newTransferTestClient(t)
+	defer cleanup()
+
+	src, dst := newTransferTestDir(t), newTransferTestDir(t)
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	writeTestFile(t, filepath.Join(src, "logs", "docker.log"), "started", 0640)
+
+	err := client.Download(filepath.Join(src, "logs"), dst, TransferOptions{Recursive: true})
+	assert.NoError(t, err)
+	assertFile(t, filepath.Join(dst, "logs", "docker.log"), "started", 0640)
+
+	err = client.Download(filepath.Join(src, "logs", "docker.log"), filepath.Join(dst, "copy.log"), TransferOptions{})
+	assert.NoError(t, err)
+	assertFile(t, filepath.Join(dst, "copy.log"), "started", 0640)
+}
+
+func TestNativeClientUploadDelta(t *testing.T) {
+	client, cleanup := newTransferTestClient(t)
+	defer cleanup()
+
+	src, dst := newTransferTestDir(t), newTransferTestDir(t)
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	writeTestFile(t, filepath.Join(src, "unchanged"), "same", 0644)
+	writeTestFile(t, filepath.Join(src, "changed"), "new", 0644)
+
+	opts := TransferOptions{Recursive: true, Delta: true}
+	assert.NoError(t, client.Upload(src, dst, opts))
+
+	// Touch the remote copies: delta must skip the file whose size and
+	// modification time still match, and copy the other one.
+	copied := filepath.Join(dst, filepath.Base(src))
+	writeTestFile(t, filepath.Join(copied, "changed"), "old content", 0644)
+
+	var transferred []string
+	opts.Progress = func(path string, copied, total int64) {
+		if copied == total {
+			transferred = append(transferred, filepath.Base(path))
+		}
+	}
+	assert.NoError(t, client.Upload(src, dst, opts))
+
+	assert.Equal(t, []string{"changed"}, transferred)
+	assertFile(t, filepath.Join(copied, "changed"), "new", 0644)
+}
+
+func TestNativeClientProgress(t *testing.T) {
+	client, cleanup := newTransferTestClient(t)
+	defer cleanup()
+
+	src, dst := newTransferTestDir(t), newTransferTestDir(t)
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	writeTestFile(t, filepath.Join(src, "big"), string(make([]byte, 100000)), 0644)
+
+	var last, total int64
+	err := client.Upload(filepath.Join(src, "big"), dst, TransferOptions{
+		Progress: func(path string, c, t int64) {
+			last, total = c, t
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100000), last)
+	assert.Equal(t, int64(100000), total)
+}
+
+func TestNativeClientWriteFile(t *testing.T) {
+	client, cleanup := newTransferTestClient(t)
+	defer cleanup()
+
+	dst := newTransferTestDir(t)
+	defer os.RemoveAll(dst)
+
+	path := filepath.Join(dst, "server-key.pem")
+	assert.NoError(t, client.WriteFile(path, []byte("secret"), 0600))
+	assertFile(t, path, "secret", 0600)
+
+	// An existing file is truncated, and loses its wider mode.
+	writeTestFile(t, path, "a longer public content", 0644)
+	assert.NoError(t, client.WriteFile(path, []byte("secret"), 0600))
+	assertFile(t, path, "secret", 0600)
+}
+
+func newExternalTransferTestClient(t *testing.T, dir string) (Client, func()) {
+	sshBinaryPath, err := exec.LookPath("ssh")
+	if err != nil {
+		t.Skip("The ssh binary is needed to test the external client")
+	}
+
+	key := newTestKey(t)
+	server := newTestServer(t, publicKeyServerConfig(t, newTestSigner(t, key).PublicKey()))
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "id_ecdsa")
+	writeTestFile(t, keyPath, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), 0600)
+
+	client, err := NewExternalClient(sshBinaryPath, "docker", "127.0.0.1", server.port, &Auth{Keys: []string{keyPath}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without scp in the PATH, the files can only go through SFTP.
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", "")
+
+	return client, func() {
+		os.Setenv("PATH", path)
+		server.Close()
+	}
+}
+
+func TestExternalClientWriteFileWithoutScp(t *testing.T) {
+	dir := newTransferTestDir(t)
+	defer os.RemoveAll(dir)
+
+	client, cleanup := newExternalTransferTestClient(t, dir)
+	defer cleanup()
+
+	path := filepath.Join(dir, "server-key.pem")
+	assert.NoError(t, client.WriteFile(path, []byte("secret"), 0600))
+	assertFile(t, path, "secret", 0600)
+
+	// An existing file is truncated, and loses its wider mode.
+	writeTestFile(t, path, "a longer public content", 0644)
+	assert.NoError(t, client.WriteFile(path, []byte("secret"), 0600))
+	assertFile(t, path, "secret", 0600)
+}
+
+func TestExternalClientUploadWithoutScp(t *testing.T) {
+	dir := newTransferTestDir(t)
+	defer os.RemoveAll(dir)
+
+	client, cleanup := newExternalTransferTestClient(t, dir)
+	defer cleanup()
+
+	src := filepath.Join(dir, "bundle.tgz")
+	writeTestFile(t, src, "bundle", 0644)
+
+	dst := filepath.Join(dir, "uploaded.tgz")
+	assert.NoError(t, client.Upload(src, dst, TransferOptions{}))
+	assertFile(t, dst, "bundle", 0644)
+}
+
+func TestUnchanged(t *testing.T) {
+	dir := newTransferTestDir(t)
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeTestFile(t, a, "foo", 0644)
+	writeTestFile(t, b, "bar", 0644)
+
+	now := time.Now()
+	os.Chtimes(a, now, now)
+	os.Chtimes(b, now, now)
+
+	infoA, _ := os.Stat(a)
+	infoB, _ := os.Stat(b)
+	assert.True(t, unchanged(infoA, infoB))
+
+	os.Chtimes(b, now, now.Add(-time.Hour))
+	infoB, _ = os.Stat(b)
+	assert.False(t, unchanged(infoA, infoB))
+}
+
+func TestNativeClientUploadExclude(t *testing.T) {
+	client, cleanup := newTransferTestClient(t)
+	defer cleanup()
+
+	src, dst := newTransferTestDir(t), newTransferTestDir(t)
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	writeTestFile(t, filepath.Join(src, "main.go"), "package main", 0644)
+	writeTestFile(t, filepath.Join(src, "debug.log"), "excluded", 0644)
+	writeTestFile(t, filepath.Join(src, "node_modules", "pkg", "index.js"), "excluded", 0644)
+
+	err := client.Upload(src+string(filepath.Separator)+".", dst, TransferOptions{
+		Recursive: true,
+		Exclude: func(rel string, info os.FileInfo) bool {
+			return rel == "node_modules" || filepath.Ext(rel) == ".log"
+		},
+	})
+	assert.NoError(t, err)
+
+	assertFile(t, filepath.Join(dst, "main.go"), "package main", 0644)
+	_, err = os.Stat(filepath.Join(dst, "debug.log"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dst, "node_modules"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -1,7 +1,20 @@
 package swarm
 
+import "fmt"
+
 const (
+	// DiscoveryServiceEndpoint is the hosted token discovery service, which
+	// was shut down. Use DiscoveryAuto or a discovery service of your own.
 	DiscoveryServiceEndpoint = "https://discovery-stage.hub.docker.com/v1"
+
+	// DiscoveryAuto is given as the discovery to run the discovery service of
+	// a cluster on its master, and to use it on the agents.
+	DiscoveryAuto = "auto"
+	// DiscoveryContainer is the container of the discovery service run on
+	// the master, a Consul key/value store.
+	DiscoveryContainer = "swarm-discovery"
+	DiscoveryImage     = "hashicorp/consul:1.15.4"
+	DiscoveryPort      = 8500
 )
 
 type Options struct {
@@ -19,4 +32,16 @@ type Options struct {
 	ArbitraryJoinFlags []string
 	Env                []string
 	IsExperimental     bool
+	// LocalDiscovery is set on the machine which runs the discovery service
+	// of its cluster.
+	LocalDiscovery bool
+	// PublicDiscovery publishes the discovery service on all the interfaces
+	// of the machine rather than on its IP only, which must be private.
+	PublicDiscovery bool
+}
+
+// LocalDiscoveryURL returns the discovery of a cluster whose discovery
+// service runs on the machine with the given IP.
+func LocalDiscoveryURL(ip string) string {
+	return fmt.Sprintf("consul://%s:%d/swarm", ip, DiscoveryPort)
 }
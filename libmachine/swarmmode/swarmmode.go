@@ -0,0 +1,26 @@
+package swarmmode
+
+const (
+	RoleManager = "manager"
+	RoleWorker  = "worker"
+	// ListenPort is the port of the cluster management traffic, which the
+	// nodes listen on and advertise.
+	ListenPort = 2377
+)
+
+type Options struct {
+	// Role is the role of the machine in the swarm mode cluster, manager or
+	// worker. The machine isn't part of a cluster when it's empty.
+	Role string
+	// Join is the name of the manager machine whose cluster the machine
+	// joins. The cluster is initialized on the machine when it's empty.
+	Join string
+	// NodeID is the ID of the node of the machine in the cluster, once it's
+	// part of it.
+	NodeID string
+}
+
+// IsValidRole tells whether role is a role of the swarm mode nodes.
+func IsValidRole(role string) bool {
+	return role == RoleManager || role == RoleWorker
+}
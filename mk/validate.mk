@@ -16,5 +16,5 @@ vet:
 
 lint:
 	$(if $(GOLINT), , \
-		$(error Please install golint: go get -u github.com/golang/lint/golint))
+		$(error Please install golint: go get -u golang.org/x/lint/golint))
 	@test -z "$$($(GOLINT) ./... 2>&1 | grep -v vendor/ | grep -v "cli/" | grep -v "amazonec2/" |grep -v "openstack/" |grep -v "softlayer/" | grep -v "should have comment" | tee /dev/stderr)"